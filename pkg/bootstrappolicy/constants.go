@@ -66,6 +66,7 @@ const (
 	AggregatedBasicUserRoleName     = "system:openshift:aggregate-to-basic-user"
 	AggregatedStorageAdminRoleName  = "system:openshift:aggregate-to-storage-admin"
 	SelfProvisionerRoleName         = "self-provisioner"
+	ProjectRequestApproverRoleName  = "project-request-approver"
 	BasicUserRoleName               = "basic-user"
 	StatusCheckerRoleName           = "cluster-status"
 	SelfAccessReviewerRoleName      = "self-access-reviewer"
@@ -18,6 +18,72 @@ const (
 	// if the buildconfig does not specify a value.  This only applies to buildconfigs created
 	// via the new group api resource, not the legacy resource.
 	DefaultFailedBuildsHistoryLimit = int32(5)
+
+	// BuildConfigCoalesceWindowAnnotation is set on a BuildConfig to a duration, e.g. "30s", to
+	// coalesce the instantiate requests for the revision of a New or Pending build created within
+	// the duration into that build, instead of creating another build. Only the requests with the
+	// same environment, strategy options, labels, annotations and from image are coalesced.
+	BuildConfigCoalesceWindowAnnotation = "build.openshift.io/coalesce-window"
+
+	// BuildConfigWebHookIncludePathsAnnotation is set on a BuildConfig to a comma separated list of
+	// globs, relative to the context directory of its source. Its git webhook triggers only start a
+	// build for a push changing a file under the context directory matched by one of the globs.
+	// A glob matches a file if it matches its path or the path of one of its parent directories,
+	// a "**" segment matches any number of directories.
+	BuildConfigWebHookIncludePathsAnnotation = "build.openshift.io/webhook-include-paths"
+	// BuildConfigWebHookExcludePathsAnnotation is set on a BuildConfig to a comma separated list of
+	// globs, relative to the context directory of its source, of the files whose changes do not
+	// start a build from its git webhook triggers.
+	BuildConfigWebHookExcludePathsAnnotation = "build.openshift.io/webhook-exclude-paths"
+	// BuildConfigWebHookRequireSignatureAnnotation is set on a BuildConfig to a comma separated
+	// list of the names of the secrets referenced by its GitHub and GitLab webhook triggers which
+	// only accept signed payloads. The signature is computed with the WebHookSigningKey of the
+	// referenced secret, never with the secret of the trigger which is part of the webhook URL.
+	BuildConfigWebHookRequireSignatureAnnotation = "build.openshift.io/webhook-require-signature"
+
+	// BuildConfigWebHookPullRequestBranchesAnnotation is set on a BuildConfig to a comma separated
+	// list of globs matching target branches, e.g. "main,release-*". Its GitHub, GitLab and
+	// Bitbucket webhook triggers start a build for the pull requests, or merge requests, into the
+	// matched branches. Pull requests do not trigger builds without it.
+	BuildConfigWebHookPullRequestBranchesAnnotation = "build.openshift.io/webhook-pull-request-branches"
+	// BuildConfigWebHookPullRequestRefAnnotation is set on a BuildConfig to "head" to build the
+	// head commit of its pull requests, the default, or to "merge" to build the result of their
+	// merge into the target branch.
+	BuildConfigWebHookPullRequestRefAnnotation = "build.openshift.io/webhook-pull-request-ref"
+	// BuildConfigWebHookPullRequestCancelAnnotation is set on a BuildConfig to "true" to cancel
+	// the New and Pending builds of its pull requests when they are closed or merged.
+	BuildConfigWebHookPullRequestCancelAnnotation = "build.openshift.io/webhook-pull-request-cancel-on-close"
+	// BuildConfigWebHookPullRequestOutputAnnotation is set on a BuildConfig to the ImageStreamTag,
+	// "<name>:<tag>" in the namespace of the BuildConfig, the builds of its pull requests push to.
+	// Pull requests may come from forks, so their builds never push to the output of the
+	// BuildConfig nor with its push secret, and do not push at all without the annotation. The
+	// ImageStreamTag must differ from the output of the BuildConfig and from the images triggering
+	// it.
+	BuildConfigWebHookPullRequestOutputAnnotation = "build.openshift.io/webhook-pull-request-output"
+
+	// BuildSourceRefAnnotation is set on a BuildRequest to the git reference of a pull request the
+	// build checks out instead of the ref of the BuildConfig source. The build pushes to the output
+	// set by BuildConfigWebHookPullRequestOutputAnnotation instead of the output of the
+	// BuildConfig.
+	BuildSourceRefAnnotation = "build.openshift.io/source-ref"
+	// BuildPullRequestLabel is set on the builds of a pull request to its number.
+	BuildPullRequestLabel = "build.openshift.io/pull-request"
+	// BuildPullRequestSourceBranchAnnotation is set on the builds of a pull request to the branch
+	// it merges.
+	BuildPullRequestSourceBranchAnnotation = "build.openshift.io/pull-request-source-branch"
+	// BuildPullRequestTargetBranchAnnotation is set on the builds of a pull request to the branch
+	// it merges into.
+	BuildPullRequestTargetBranchAnnotation = "build.openshift.io/pull-request-target-branch"
+	// BuildCancelReasonAnnotation is set on a build cancelled through the cancel subresource of
+	// builds or BuildConfigs to the reason given for the cancellation.
+	BuildCancelReasonAnnotation = "build.openshift.io/cancel-reason"
+	// BuildCancelledByAnnotation is set on a build cancelled through the cancel subresource of
+	// builds or BuildConfigs to the name of the user who cancelled it.
+	BuildCancelledByAnnotation = "build.openshift.io/cancelled-by"
+	// BuildRequestParametersAnnotation is set by the server on the builds of a BuildConfig with a
+	// coalesce window to a hash of the parameters of the BuildRequest which created them. Only the
+	// requests with the same parameters are coalesced into a build.
+	BuildRequestParametersAnnotation = "build.openshift.io/request-parameters"
 )
 
 var (
@@ -6,6 +6,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -49,6 +50,13 @@ func ValidateBuildUpdate(build *buildapi.Build, older *buildapi.Build) field.Err
 	// lie about the old build's pushsecret value so we can allow it to be updated.
 	olderCopy := older.DeepCopy()
 	olderCopy.Spec.Output.PushSecret = build.Spec.Output.PushSecret
+	// trigger causes may be added to builds that did not start yet, instantiate requests are
+	// coalesced into them.
+	if (older.Status.Phase == buildapi.BuildPhaseNew || older.Status.Phase == buildapi.BuildPhasePending) &&
+		len(build.Spec.TriggeredBy) >= len(older.Spec.TriggeredBy) &&
+		kapihelper.Semantic.DeepEqual(build.Spec.TriggeredBy[:len(older.Spec.TriggeredBy)], older.Spec.TriggeredBy) {
+		olderCopy.Spec.TriggeredBy = build.Spec.TriggeredBy
+	}
 
 	if !kapihelper.Semantic.DeepEqual(build.Spec, olderCopy.Spec) {
 		diff, err := diffBuildSpec(build.Spec, olderCopy.Spec)
@@ -118,11 +126,78 @@ func ValidateBuildConfig(config *buildapi.BuildConfig) field.ErrorList {
 		allErrs = append(allErrs, validation.ValidateNonnegativeField(int64(*config.Spec.FailedBuildsHistoryLimit), specPath.Child("failedBuildsHistoryLimit"))...)
 	}
 
+	if window, ok := config.Annotations[buildapi.BuildConfigCoalesceWindowAnnotation]; ok {
+		if d, err := time.ParseDuration(window); err != nil || d < 0 {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "annotations").Key(buildapi.BuildConfigCoalesceWindowAnnotation), window, "must be a non-negative duration, e.g. 30s"))
+		}
+	}
+	for _, annotation := range []string{buildapi.BuildConfigWebHookIncludePathsAnnotation, buildapi.BuildConfigWebHookExcludePathsAnnotation} {
+		if globs, ok := config.Annotations[annotation]; ok {
+			allErrs = append(allErrs, validatePathGlobs(globs, field.NewPath("metadata", "annotations").Key(annotation))...)
+		}
+	}
+	if secrets, ok := config.Annotations[buildapi.BuildConfigWebHookRequireSignatureAnnotation]; ok {
+		allErrs = append(allErrs, validateSignedWebHookSecrets(secrets, config.Spec.Triggers, field.NewPath("metadata", "annotations").Key(buildapi.BuildConfigWebHookRequireSignatureAnnotation))...)
+	}
+	if branches, ok := config.Annotations[buildapi.BuildConfigWebHookPullRequestBranchesAnnotation]; ok {
+		for _, branch := range strings.Split(branches, ",") {
+			if _, err := path.Match(strings.TrimSpace(branch), ""); err != nil || len(strings.TrimSpace(branch)) == 0 {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "annotations").Key(buildapi.BuildConfigWebHookPullRequestBranchesAnnotation), branches, "must be a comma separated list of branch globs"))
+				break
+			}
+		}
+	}
+	if ref, ok := config.Annotations[buildapi.BuildConfigWebHookPullRequestRefAnnotation]; ok && ref != "head" && ref != "merge" {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("metadata", "annotations").Key(buildapi.BuildConfigWebHookPullRequestRefAnnotation), ref, []string{"head", "merge"}))
+	}
+	if cancel, ok := config.Annotations[buildapi.BuildConfigWebHookPullRequestCancelAnnotation]; ok && cancel != "true" && cancel != "false" {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("metadata", "annotations").Key(buildapi.BuildConfigWebHookPullRequestCancelAnnotation), cancel, []string{"true", "false"}))
+	}
+	if output, ok := config.Annotations[buildapi.BuildConfigWebHookPullRequestOutputAnnotation]; ok {
+		allErrs = append(allErrs, validatePullRequestOutput(output, config, fromRefs, field.NewPath("metadata", "annotations").Key(buildapi.BuildConfigWebHookPullRequestOutputAnnotation))...)
+	}
+
 	allErrs = append(allErrs, validateCommonSpec(&config.Spec.CommonSpec, specPath)...)
 
 	return allErrs
 }
 
+// validatePullRequestOutput checks that the output of the builds of pull requests is an
+// ImageStreamTag which is neither the output of config nor one of the images triggering it, given
+// by the keys of fromRefs.
+func validatePullRequestOutput(output string, config *buildapi.BuildConfig, fromRefs map[string]struct{}, fldPath *field.Path) field.ErrorList {
+	if name, _, ok := imageutil.SplitImageStreamTag(output); !ok || len(imageapivalidation.ValidateImageStreamName(name, false)) != 0 {
+		return field.ErrorList{field.Invalid(fldPath, output, "must be an ImageStreamTag in the form <name>:<tag>")}
+	}
+	key := refKey(config.Namespace, &kapi.ObjectReference{Kind: "ImageStreamTag", Name: output})
+	if key == refKey(config.Namespace, config.Spec.Output.To) {
+		return field.ErrorList{field.Invalid(fldPath, output, "must differ from the output of the BuildConfig")}
+	}
+	if _, ok := fromRefs[key]; ok {
+		return field.ErrorList{field.Invalid(fldPath, output, "must differ from the images triggering the BuildConfig")}
+	}
+	return nil
+}
+
+// validateSignedWebHookSecrets checks that the secrets of the triggers requiring signed payloads
+// are referenced by GitHub or GitLab webhook triggers, the signing key being read from them.
+func validateSignedWebHookSecrets(secrets string, triggers []buildapi.BuildTriggerPolicy, fldPath *field.Path) field.ErrorList {
+	referenced := sets.New[string]()
+	for _, trigger := range triggers {
+		for _, webHook := range []*buildapi.WebHookTrigger{trigger.GitHubWebHook, trigger.GitLabWebHook} {
+			if webHook != nil && webHook.SecretReference != nil {
+				referenced.Insert(webHook.SecretReference.Name)
+			}
+		}
+	}
+	for _, name := range strings.Split(secrets, ",") {
+		if !referenced.Has(strings.TrimSpace(name)) {
+			return field.ErrorList{field.Invalid(fldPath, secrets, "must be a comma separated list of the secrets referenced by the GitHub or GitLab webhook triggers")}
+		}
+	}
+	return nil
+}
+
 func ValidateBuildConfigUpdate(config *buildapi.BuildConfig, older *buildapi.BuildConfig) field.ErrorList {
 	allErrs := field.ErrorList{}
 	allErrs = append(allErrs, validation.ValidateObjectMetaUpdate(&config.ObjectMeta, &older.ObjectMeta, field.NewPath("metadata"))...)
@@ -135,6 +210,26 @@ func ValidateBuildRequest(request *buildapi.BuildRequest) field.ErrorList {
 	return validation.ValidateObjectMeta(&request.ObjectMeta, true, kpath.ValidatePathSegmentName, field.NewPath("metadata"))
 }
 
+// validatePathGlobs validates a comma separated list of globs relative to the context directory
+// of a build source.
+func validatePathGlobs(globs string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, glob := range strings.Split(globs, ",") {
+		glob = strings.TrimSpace(glob)
+		switch {
+		case len(glob) == 0:
+			allErrs = append(allErrs, field.Invalid(fldPath, globs, "must not contain empty globs"))
+		case path.IsAbs(glob) || glob == ".." || strings.HasPrefix(glob, "../"):
+			allErrs = append(allErrs, field.Invalid(fldPath, glob, "must be relative to the context directory"))
+		default:
+			if _, err := path.Match(glob, ""); err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath, glob, err.Error()))
+			}
+		}
+	}
+	return allErrs
+}
+
 func validateCommonSpec(spec *buildapi.CommonSpec, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 	s := spec.Strategy
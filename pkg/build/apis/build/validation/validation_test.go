@@ -549,6 +549,147 @@ func TestBuildConfigDockerStrategyImageChangeTrigger(t *testing.T) {
 	}
 }
 
+func TestBuildConfigValidationCoalesceWindow(t *testing.T) {
+	for window, valid := range map[string]bool{"30s": true, "0": true, "1m30s": true, "30": false, "-5s": false} {
+		buildConfig := &buildapi.BuildConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "config-id", Namespace: "namespace", Annotations: map[string]string{buildapi.BuildConfigCoalesceWindowAnnotation: window}},
+			Spec: buildapi.BuildConfigSpec{
+				RunPolicy: buildapi.BuildRunPolicySerial,
+				CommonSpec: buildapi.CommonSpec{
+					Source: buildapi.BuildSource{
+						Git: &buildapi.GitBuildSource{
+							URI: "http://github.com/my/repository",
+						},
+					},
+					Strategy: buildapi.BuildStrategy{
+						DockerStrategy: &buildapi.DockerBuildStrategy{},
+					},
+				},
+			},
+		}
+		if errors := ValidateBuildConfig(buildConfig); (len(errors) == 0) != valid {
+			t.Errorf("%s: expected valid to be %t, got %v", window, valid, errors)
+		}
+	}
+}
+
+func TestBuildConfigValidationWebHookPaths(t *testing.T) {
+	for globs, valid := range map[string]bool{
+		"src":                 true,
+		"src/**/*.go, go.mod": true,
+		"docs/[a-z]*":         true,
+		"src,":                false,
+		"/src":                false,
+		"../shared":           false,
+		"src/[":               false,
+	} {
+		for _, annotation := range []string{buildapi.BuildConfigWebHookIncludePathsAnnotation, buildapi.BuildConfigWebHookExcludePathsAnnotation} {
+			buildConfig := &buildapi.BuildConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "config-id", Namespace: "namespace", Annotations: map[string]string{annotation: globs}},
+				Spec: buildapi.BuildConfigSpec{
+					RunPolicy: buildapi.BuildRunPolicySerial,
+					CommonSpec: buildapi.CommonSpec{
+						Source: buildapi.BuildSource{
+							Git: &buildapi.GitBuildSource{
+								URI: "http://github.com/my/repository",
+							},
+						},
+						Strategy: buildapi.BuildStrategy{
+							DockerStrategy: &buildapi.DockerBuildStrategy{},
+						},
+					},
+				},
+			}
+			if errors := ValidateBuildConfig(buildConfig); (len(errors) == 0) != valid {
+				t.Errorf("%s=%q: expected valid to be %t, got %v", annotation, globs, valid, errors)
+			}
+		}
+	}
+}
+
+func TestBuildConfigValidationWebHookPullRequests(t *testing.T) {
+	for _, tc := range []struct {
+		annotations map[string]string
+		valid       bool
+	}{
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "main, release-*"}, valid: true},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "main,"}},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "release-["}},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestRefAnnotation: "merge"}, valid: true},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestRefAnnotation: "base"}},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestCancelAnnotation: "true"}, valid: true},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestCancelAnnotation: "yes"}},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestOutputAnnotation: "app:pr"}, valid: true},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestOutputAnnotation: "app"}},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestOutputAnnotation: "app:latest"}},
+		{annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestOutputAnnotation: "base:latest"}},
+	} {
+		buildConfig := &buildapi.BuildConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "config-id", Namespace: "namespace", Annotations: tc.annotations},
+			Spec: buildapi.BuildConfigSpec{
+				RunPolicy: buildapi.BuildRunPolicySerial,
+				Triggers: []buildapi.BuildTriggerPolicy{
+					{Type: buildapi.ImageChangeBuildTriggerType, ImageChange: &buildapi.ImageChangeTrigger{From: &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "base:latest"}}},
+				},
+				CommonSpec: buildapi.CommonSpec{
+					Source: buildapi.BuildSource{
+						Git: &buildapi.GitBuildSource{
+							URI: "http://github.com/my/repository",
+						},
+					},
+					Strategy: buildapi.BuildStrategy{
+						DockerStrategy: &buildapi.DockerBuildStrategy{},
+					},
+					Output: buildapi.BuildOutput{
+						To: &kapi.ObjectReference{Kind: "ImageStreamTag", Name: "app:latest"},
+					},
+				},
+			},
+		}
+		if errors := ValidateBuildConfig(buildConfig); (len(errors) == 0) != tc.valid {
+			t.Errorf("%v: expected valid to be %t, got %v", tc.annotations, tc.valid, errors)
+		}
+	}
+}
+
+func TestBuildConfigValidationWebHookRequireSignature(t *testing.T) {
+	for secrets, valid := range map[string]bool{
+		"github":         true,
+		"gitlab, github": true,
+		"generic":        false,
+		"inline":         false,
+		"github,":        false,
+		"other":          false,
+	} {
+		buildConfig := &buildapi.BuildConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "config-id", Namespace: "namespace", Annotations: map[string]string{buildapi.BuildConfigWebHookRequireSignatureAnnotation: secrets}},
+			Spec: buildapi.BuildConfigSpec{
+				RunPolicy: buildapi.BuildRunPolicySerial,
+				Triggers: []buildapi.BuildTriggerPolicy{
+					{Type: buildapi.GitHubWebHookBuildTriggerType, GitHubWebHook: &buildapi.WebHookTrigger{SecretReference: &buildapi.SecretLocalReference{Name: "github"}}},
+					{Type: buildapi.GitHubWebHookBuildTriggerType, GitHubWebHook: &buildapi.WebHookTrigger{Secret: "inline"}},
+					{Type: buildapi.GitLabWebHookBuildTriggerType, GitLabWebHook: &buildapi.WebHookTrigger{SecretReference: &buildapi.SecretLocalReference{Name: "gitlab"}}},
+					{Type: buildapi.GenericWebHookBuildTriggerType, GenericWebHook: &buildapi.WebHookTrigger{SecretReference: &buildapi.SecretLocalReference{Name: "generic"}}},
+				},
+				CommonSpec: buildapi.CommonSpec{
+					Source: buildapi.BuildSource{
+						Git: &buildapi.GitBuildSource{
+							URI: "http://github.com/my/repository",
+						},
+					},
+					Strategy: buildapi.BuildStrategy{
+						DockerStrategy: &buildapi.DockerBuildStrategy{},
+					},
+				},
+			},
+		}
+		errors := ValidateBuildConfig(buildConfig)
+		if (len(errors) == 0) != valid {
+			t.Errorf("%q: expected valid to be %t, got %v", secrets, valid, errors)
+		}
+	}
+}
+
 func TestBuildConfigValidationFailureRequiredName(t *testing.T) {
 	buildConfig := &buildapi.BuildConfig{
 		ObjectMeta: metav1.ObjectMeta{Name: "", Namespace: "foo"},
@@ -3343,6 +3484,35 @@ func TestValidateBuildImageRefs(t *testing.T) {
 	}
 }
 
+func TestValidateBuildUpdateTriggerCauses(t *testing.T) {
+	manual := buildapi.BuildTriggerCause{Message: buildapi.BuildTriggerCauseManualMsg}
+	webhook := buildapi.BuildTriggerCause{Message: buildapi.BuildTriggerCauseGithubMsg}
+	for name, tc := range map[string]struct {
+		phase  buildapi.BuildPhase
+		old    []buildapi.BuildTriggerCause
+		update []buildapi.BuildTriggerCause
+		valid  bool
+	}{
+		"added to a new build":     {phase: buildapi.BuildPhaseNew, old: []buildapi.BuildTriggerCause{manual}, update: []buildapi.BuildTriggerCause{manual, webhook}, valid: true},
+		"added to a pending build": {phase: buildapi.BuildPhasePending, update: []buildapi.BuildTriggerCause{webhook}, valid: true},
+		"added to a running build": {phase: buildapi.BuildPhaseRunning, old: []buildapi.BuildTriggerCause{manual}, update: []buildapi.BuildTriggerCause{manual, webhook}},
+		"removed from a new build": {phase: buildapi.BuildPhaseNew, old: []buildapi.BuildTriggerCause{manual, webhook}, update: []buildapi.BuildTriggerCause{manual}},
+		"replaced in a new build":  {phase: buildapi.BuildPhaseNew, old: []buildapi.BuildTriggerCause{manual}, update: []buildapi.BuildTriggerCause{webhook, manual}},
+	} {
+		old := &buildapi.Build{
+			ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceDefault, Name: "my-build", ResourceVersion: "1"},
+			Spec:       newDefaultParameters(),
+			Status:     buildapi.BuildStatus{Phase: tc.phase},
+		}
+		old.Spec.TriggeredBy = tc.old
+		update := old.DeepCopy()
+		update.Spec.TriggeredBy = tc.update
+		if errs := ValidateBuildUpdate(update, old); (len(errs) == 0) != tc.valid {
+			t.Errorf("%s: expected valid to be %t, got %v", name, tc.valid, errs)
+		}
+	}
+}
+
 func TestValidateBuildUpdateImageReferences(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -17,6 +17,7 @@ import (
 
 	"k8s.io/apiserver/pkg/admission"
 	"k8s.io/apiserver/pkg/admission/initializer"
+	"k8s.io/apiserver/pkg/authentication/user"
 
 	"github.com/openshift/api/build"
 	buildclient "github.com/openshift/client-go/build/clientset/versioned"
@@ -138,38 +139,29 @@ func resourceName(objectMeta metav1.ObjectMeta) string {
 
 func (a *buildByStrategy) checkBuildAuthorization(ctx context.Context, build *buildapi.Build, attr admission.Attributes) error {
 	strategy := build.Spec.Strategy
-	resource, err := resourceForStrategyType(strategy)
+	sar, err := strategyAccessReview(attr.GetUserInfo(), attr.GetNamespace(), resourceName(build.ObjectMeta), strategy)
 	if err != nil {
 		return admission.NewForbidden(attr, err)
 	}
-	subresource := ""
-	tokens := strings.SplitN(resource.Resource, "/", 2)
-	resourceType := tokens[0]
-	if len(tokens) == 2 {
-		subresource = tokens[1]
-	}
-
-	sar := authorizationutil.AddUserToSAR(attr.GetUserInfo(), &authorizationv1.SubjectAccessReview{
-		Spec: authorizationv1.SubjectAccessReviewSpec{
-			ResourceAttributes: &authorizationv1.ResourceAttributes{
-				Namespace:   attr.GetNamespace(),
-				Verb:        "create",
-				Group:       resource.Group,
-				Resource:    resourceType,
-				Subresource: subresource,
-				Name:        resourceName(build.ObjectMeta),
-			},
-		},
-	})
 	return a.checkAccess(ctx, strategy, sar, attr)
 }
 
 func (a *buildByStrategy) checkBuildConfigAuthorization(ctx context.Context, buildConfig *buildapi.BuildConfig, attr admission.Attributes) error {
 	strategy := buildConfig.Spec.Strategy
-	resource, err := resourceForStrategyType(strategy)
+	sar, err := strategyAccessReview(attr.GetUserInfo(), attr.GetNamespace(), resourceName(buildConfig.ObjectMeta), strategy)
 	if err != nil {
 		return admission.NewForbidden(attr, err)
 	}
+	return a.checkAccess(ctx, strategy, sar, attr)
+}
+
+// strategyAccessReview returns the review of the access of a user to the builds of a strategy,
+// for the build or BuildConfig named name.
+func strategyAccessReview(userInfo user.Info, namespace, name string, strategy buildapi.BuildStrategy) (*authorizationv1.SubjectAccessReview, error) {
+	resource, err := resourceForStrategyType(strategy)
+	if err != nil {
+		return nil, err
+	}
 	subresource := ""
 	tokens := strings.SplitN(resource.Resource, "/", 2)
 	resourceType := tokens[0]
@@ -177,19 +169,37 @@ func (a *buildByStrategy) checkBuildConfigAuthorization(ctx context.Context, bui
 		subresource = tokens[1]
 	}
 
-	sar := authorizationutil.AddUserToSAR(attr.GetUserInfo(), &authorizationv1.SubjectAccessReview{
+	return authorizationutil.AddUserToSAR(userInfo, &authorizationv1.SubjectAccessReview{
 		Spec: authorizationv1.SubjectAccessReviewSpec{
 			ResourceAttributes: &authorizationv1.ResourceAttributes{
-				Namespace:   attr.GetNamespace(),
+				Namespace:   namespace,
 				Verb:        "create",
 				Group:       resource.Group,
 				Resource:    resourceType,
 				Subresource: subresource,
-				Name:        resourceName(buildConfig.ObjectMeta),
+				Name:        name,
 			},
 		},
-	})
-	return a.checkAccess(ctx, strategy, sar, attr)
+	}), nil
+}
+
+// CheckBuildConfigStrategy returns an error unless the user may build the BuildConfig with its
+// strategy. It runs the review of the admission plugin for the requests instantiating BuildConfigs
+// which are not admitted, like the BuildConfigs of a batch.
+func CheckBuildConfigStrategy(ctx context.Context, sarClient authorizationclient.SubjectAccessReviewInterface, userInfo user.Info, buildConfig *buildapi.BuildConfig) error {
+	strategy := buildConfig.Spec.Strategy
+	sar, err := strategyAccessReview(userInfo, buildConfig.Namespace, resourceName(buildConfig.ObjectMeta), strategy)
+	if err != nil {
+		return err
+	}
+	resp, err := sarClient.Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	if !resp.Status.Allowed {
+		return notAllowedError(strategy)
+	}
+	return nil
 }
 
 func (a *buildByStrategy) checkBuildRequestAuthorization(ctx context.Context, req *buildapi.BuildRequest, attr admission.Attributes) error {
@@ -235,7 +245,11 @@ func (a *buildByStrategy) checkAccess(ctx context.Context, strategy buildapi.Bui
 }
 
 func notAllowed(strategy buildapi.BuildStrategy, attr admission.Attributes) error {
-	return admission.NewForbidden(attr, fmt.Errorf("build strategy %s is not allowed", strategyTypeString(strategy)))
+	return admission.NewForbidden(attr, notAllowedError(strategy))
+}
+
+func notAllowedError(strategy buildapi.BuildStrategy) error {
+	return fmt.Errorf("build strategy %s is not allowed", strategyTypeString(strategy))
 }
 
 func strategyTypeString(strategy buildapi.BuildStrategy) string {
@@ -1,21 +1,28 @@
 package apiserver
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
 
 	buildv1 "github.com/openshift/api/build/v1"
 	buildv1client "github.com/openshift/client-go/build/clientset/versioned"
 	imagev1client "github.com/openshift/client-go/image/clientset/versioned"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildgenerator"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildlogarchive"
 	buildetcd "github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/build/etcd"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/buildcancel"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/buildclone"
 	buildconfigregistry "github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/buildconfig"
 	buildconfigetcd "github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/buildconfig/etcd"
@@ -24,12 +31,36 @@ import (
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook/bitbucket"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook/generic"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook/gitea"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook/github"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook/gitlab"
 )
 
+const (
+	// logArchivePruneInterval is how often the build logs archived for longer than their retention
+	// are deleted.
+	logArchivePruneInterval = time.Hour
+	// webHookDeliveriesDeleteTimeout bounds the time spent deleting the webhook deliveries of a
+	// deleted BuildConfig.
+	webHookDeliveriesDeleteTimeout = time.Minute
+)
+
 type ExtraConfig struct {
 	KubeAPIServerClientConfig *restclient.Config
+	// LogSplitStreams requests the stdout and stderr of build containers
+	// separately for structured build logs.
+	LogSplitStreams bool
+	// LogArchive keeps the logs of completed builds once their pods are
+	// gone. Optional.
+	LogArchive buildlogarchive.Archive
+	// LogArchiveRetention is how long the archived build logs are kept, 0
+	// keeps them until their build is deleted.
+	LogArchiveRetention time.Duration
+	// BinaryUploadLimits limits the resumable uploads of binary builds.
+	BinaryUploadLimits buildconfiginstantiate.UploadLimits
+	// WebHookDeliveryNamespace is the namespace of the config maps recording the webhook
+	// deliveries of BuildConfigs. Deliveries are not recorded if it is empty.
+	WebHookDeliveryNamespace string
 
 	// TODO these should all become local eventually
 	Scheme *runtime.Scheme
@@ -38,6 +69,7 @@ type ExtraConfig struct {
 	makeV1Storage sync.Once
 	v1Storage     map[string]rest.Storage
 	v1StorageErr  error
+	startFns      []func(<-chan struct{})
 }
 
 type BuildServerConfig struct {
@@ -93,6 +125,17 @@ func (c completedConfig) New(delegationTarget genericapiserver.DelegationTarget)
 		return nil, err
 	}
 
+	if len(c.ExtraConfig.startFns) > 0 {
+		if err := s.GenericAPIServer.AddPostStartHook("build.openshift.io-buildlogarchive", func(context genericapiserver.PostStartHookContext) error {
+			for _, fn := range c.ExtraConfig.startFns {
+				go fn(context.Done())
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
 	return s, nil
 }
 
@@ -117,11 +160,37 @@ func (c *completedConfig) newV1RESTStorage() (map[string]rest.Storage, error) {
 	if err != nil {
 		return nil, err
 	}
-	buildStorage, buildDetailsStorage, err := buildetcd.NewREST(c.GenericConfig.RESTOptionsGetter)
+	buildLogStorage := buildlogregistry.NewREST(
+		buildClient.BuildV1(),
+		kubeClient.CoreV1(),
+		c.GenericConfig.SharedInformerFactory.Core().V1().Pods().Lister(),
+	)
+	buildLogStorage.SplitStreams = c.ExtraConfig.LogSplitStreams
+	buildLogStorage.Archive = c.ExtraConfig.LogArchive
+	var buildCompleted, buildDeleted func(build *buildapi.Build)
+	if c.ExtraConfig.LogArchive != nil {
+		buildCompleted = buildLogStorage.ArchiveBuild
+		buildDeleted = buildLogStorage.DeleteArchivedLog
+		c.ExtraConfig.startFns = append(c.ExtraConfig.startFns, buildLogStorage.RunArchiver)
+		if retention := c.ExtraConfig.LogArchiveRetention; retention > 0 {
+			c.ExtraConfig.startFns = append(c.ExtraConfig.startFns, func(stopCh <-chan struct{}) {
+				wait.JitterUntilWithContext(wait.ContextForChannel(stopCh), func(ctx context.Context) {
+					buildLogStorage.PruneArchive(ctx, retention)
+				}, logArchivePruneInterval, 0.1, true)
+			})
+		}
+	}
+	buildStorage, buildDetailsStorage, err := buildetcd.NewREST(c.GenericConfig.RESTOptionsGetter, buildCompleted, buildDeleted)
 	if err != nil {
 		return nil, fmt.Errorf("error building REST storage: %v", err)
 	}
-	buildConfigStorage, err := buildconfigetcd.NewREST(c.GenericConfig.RESTOptionsGetter)
+	var webHookDeliveries *webhook.DeliveryLog
+	var buildConfigDeleted func(buildConfig *buildapi.BuildConfig)
+	if len(c.ExtraConfig.WebHookDeliveryNamespace) > 0 {
+		webHookDeliveries = webhook.NewDeliveryLog(kubeClient.CoreV1(), c.ExtraConfig.WebHookDeliveryNamespace)
+		buildConfigDeleted = deleteWebHookDeliveries(webHookDeliveries)
+	}
+	buildConfigStorage, err := buildconfigetcd.NewREST(c.GenericConfig.RESTOptionsGetter, buildConfigDeleted)
 	if err != nil {
 		return nil, fmt.Errorf("error building REST storage: %v", err)
 	}
@@ -140,31 +209,49 @@ func (c *completedConfig) newV1RESTStorage() (map[string]rest.Storage, error) {
 	buildConfigWebHooks := buildconfigregistry.NewWebHookREST(
 		buildClient.BuildV1(),
 		kubeClient.CoreV1(),
+		kubeClient.AuthorizationV1().SubjectAccessReviews(),
+		webHookDeliveries,
 		// We use the buildv1 schemegroup to encode the Build that gets
 		// returned. As such, we need to make sure that the GroupVersion we use
 		// is the same API version that the storage is going to be used for.
 		buildv1.GroupVersion,
 		map[string]webhook.Plugin{
 			"generic":   generic.New(),
-			"github":    github.New(),
-			"gitlab":    gitlab.New(),
+			"github":    github.New(kubeClient.CoreV1()),
+			"gitlab":    gitlab.New(kubeClient.CoreV1()),
 			"bitbucket": bitbucket.New(),
+			"gitea":     gitea.New(kubeClient.CoreV1()),
 		},
 	)
 
 	v1Storage := map[string]rest.Storage{}
 	v1Storage["builds"] = buildStorage
 	v1Storage["builds/clone"] = buildclone.NewStorage(buildGenerator)
-	v1Storage["builds/log"] = buildlogregistry.NewREST(
-		buildClient.BuildV1(),
-		kubeClient.CoreV1(),
-		c.GenericConfig.SharedInformerFactory.Core().V1().Pods().Lister(),
-	)
+	v1Storage["builds/log"] = buildLogStorage
 	v1Storage["builds/details"] = buildDetailsStorage
+	v1Storage["builds/cancel"] = buildcancel.NewBuildStorage(buildStorage)
 
 	v1Storage["buildconfigs"] = buildConfigStorage
 	v1Storage["buildconfigs/webhooks"] = buildConfigWebHooks
+	v1Storage["buildconfigs/webhookdeliveries"] = buildconfigregistry.NewDeliveriesREST(buildClient.BuildV1(), webHookDeliveries)
 	v1Storage["buildconfigs/instantiate"] = buildconfiginstantiate.NewStorage(buildGenerator)
-	v1Storage["buildconfigs/instantiatebinary"] = buildconfiginstantiate.NewBinaryStorage(buildGenerator, buildClient.BuildV1(), c.ExtraConfig.KubeAPIServerClientConfig)
+	v1Storage["buildconfigs/instantiatebatch"] = buildconfiginstantiate.NewBatchStorage(buildGenerator, buildClient.BuildV1(), kubeClient.AuthorizationV1().SubjectAccessReviews())
+	v1Storage["buildconfigs/instantiatebinary"] = buildconfiginstantiate.NewBinaryStorage(buildGenerator, buildClient.BuildV1(), c.ExtraConfig.KubeAPIServerClientConfig, c.ExtraConfig.BinaryUploadLimits)
+	v1Storage["buildconfigs/cancel"] = buildcancel.NewBuildConfigStorage(buildStorage)
 	return v1Storage, nil
 }
+
+// deleteWebHookDeliveries returns a function deleting in the background the webhook deliveries
+// recorded for a deleted BuildConfig.
+func deleteWebHookDeliveries(deliveries *webhook.DeliveryLog) func(buildConfig *buildapi.BuildConfig) {
+	return func(buildConfig *buildapi.BuildConfig) {
+		namespace, name, uid := buildConfig.Namespace, buildConfig.Name, buildConfig.UID
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), webHookDeliveriesDeleteTimeout)
+			defer cancel()
+			if err := deliveries.Delete(ctx, uid); err != nil {
+				klog.V(2).Infof("unable to delete the webhook deliveries of BuildConfig %s/%s: %v", namespace, name, err)
+			}
+		}()
+	}
+}
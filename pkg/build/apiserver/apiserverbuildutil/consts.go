@@ -5,6 +5,8 @@ const (
 	BuildTriggerCauseGenericMsg   = "Generic WebHook"
 	BuildTriggerCauseGitLabMsg    = "GitLab WebHook"
 	BuildTriggerCauseBitbucketMsg = "Bitbucket WebHook"
+	BuildTriggerCauseGiteaMsg     = "Gitea WebHook"
+	BuildTriggerCauseReplayMsg    = "WebHook delivery replayed"
 )
 
 const (
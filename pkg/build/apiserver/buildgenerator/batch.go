@@ -0,0 +1,138 @@
+package buildgenerator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	internal "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	conversions "github.com/openshift/openshift-apiserver/pkg/build/apis/build/v1"
+)
+
+// BatchError reports the BuildRequests of a batch which could not be instantiated, by the name of
+// their BuildConfig. None of the builds of a batch are run when it is returned.
+type BatchError struct {
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	names := e.Names()
+	messages := make([]string, 0, len(names))
+	for _, name := range names {
+		messages = append(messages, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+	return fmt.Sprintf("unable to instantiate the batch of builds: %s", strings.Join(messages, "; "))
+}
+
+// Names returns the sorted names of the BuildConfigs which could not be instantiated.
+func (e *BatchError) Names() []string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InstantiateBatchInternal is InstantiateBatch for internal BuildRequests.
+// DEPRECATED: Use only by apiserver
+func (g *BuildGenerator) InstantiateBatchInternal(ctx context.Context, requests []*internal.BuildRequest, opts metav1.CreateOptions) ([]*internal.Build, error) {
+	versionedRequests := make([]*buildv1.BuildRequest, 0, len(requests))
+	for _, request := range requests {
+		versionedRequest := &buildv1.BuildRequest{}
+		if err := conversions.Convert_build_BuildRequest_To_v1_BuildRequest(request, versionedRequest, nil); err != nil {
+			return nil, fmt.Errorf("failed to convert internal BuildRequest to external: %v", err)
+		}
+		versionedRequests = append(versionedRequests, versionedRequest)
+	}
+	builds, err := g.InstantiateBatch(ctx, versionedRequests, opts)
+	if err != nil {
+		return nil, err
+	}
+	internalBuilds := make([]*internal.Build, 0, len(builds))
+	for _, build := range builds {
+		internalBuild := &internal.Build{}
+		if err := conversions.Convert_v1_Build_To_build_Build(build, internalBuild, nil); err != nil {
+			return nil, fmt.Errorf("failed to convert external Build to internal: %v", err)
+		}
+		internalBuilds = append(internalBuilds, internalBuild)
+	}
+	return internalBuilds, nil
+}
+
+// InstantiateBatch returns the new Builds of a batch of BuildRequests for distinct BuildConfigs.
+// The Builds of all the requests are generated before any of them is created, so a batch with an
+// invalid request does not run any build. If a BuildConfig changes while the batch is created, the
+// builds created so far are cancelled and a BatchError is returned.
+func (g *BuildGenerator) InstantiateBatch(ctx context.Context, requests []*buildv1.BuildRequest, opts metav1.CreateOptions) ([]*buildv1.Build, error) {
+	type preparedBuild struct {
+		bc    *buildv1.BuildConfig
+		build *buildv1.Build
+	}
+	prepared := make([]preparedBuild, 0, len(requests))
+	batchErr := &BatchError{Errors: map[string]error{}}
+	requested := map[string]bool{}
+	for _, request := range requests {
+		if requested[request.Name] {
+			batchErr.Errors[request.Name] = errors.NewBadRequest("the BuildConfig is requested more than once")
+			continue
+		}
+		requested[request.Name] = true
+		bc, err := g.Client.GetBuildConfig(ctx, request.Name, metav1.GetOptions{})
+		if err != nil {
+			batchErr.Errors[request.Name] = err
+			continue
+		}
+		bc, build, err := g.prepareInstantiate(ctx, bc, request)
+		if err != nil {
+			batchErr.Errors[request.Name] = err
+			continue
+		}
+		prepared = append(prepared, preparedBuild{bc: bc, build: build})
+	}
+	if len(batchErr.Errors) > 0 {
+		return nil, batchErr
+	}
+
+	builds := make([]*buildv1.Build, 0, len(prepared))
+	for _, p := range prepared {
+		build, err := g.commitInstantiate(ctx, p.bc, p.build, opts)
+		if err != nil {
+			klog.V(2).Infof("Cancelling the %d builds of the batch created before BuildConfig %s/%s failed: %v", len(builds), p.bc.Namespace, p.bc.Name, err)
+			for _, created := range builds {
+				g.cancelBuild(ctx, created)
+			}
+			batchErr.Errors[p.bc.Name] = err
+			return nil, batchErr
+		}
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
+
+// cancelBuild requests the cancellation of a build.
+func (g *BuildGenerator) cancelBuild(ctx context.Context, build *buildv1.Build) {
+	for i := 0; i < conflictRetries; i++ {
+		build = build.DeepCopy()
+		build.Status.Cancelled = true
+		err := g.Client.UpdateBuild(ctx, build, metav1.UpdateOptions{})
+		if !errors.IsConflict(err) {
+			if err != nil {
+				klog.Warningf("Unable to cancel build %s/%s: %v", build.Namespace, build.Name, err)
+			}
+			return
+		}
+		latest, err := g.Client.GetBuild(ctx, build.Name, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("Unable to cancel build %s/%s: %v", build.Namespace, build.Name, err)
+			return
+		}
+		build = latest
+	}
+}
@@ -0,0 +1,93 @@
+package buildgenerator
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	buildv1 "github.com/openshift/api/build/v1"
+)
+
+func TestInstantiateBatch(t *testing.T) {
+	lastVersion := int64(0)
+	staleVersion := int64(1)
+	for name, tc := range map[string]struct {
+		requests       []*buildv1.BuildRequest
+		conflictOn     string
+		expectedErrors []string
+		expectedBuilds int
+		cancelled      int
+	}{
+		"all valid": {
+			requests:       []*buildv1.BuildRequest{{ObjectMeta: metav1.ObjectMeta{Name: "a"}}, {ObjectMeta: metav1.ObjectMeta{Name: "b"}, LastVersion: &lastVersion}},
+			expectedBuilds: 2,
+		},
+		"one invalid": {
+			requests:       []*buildv1.BuildRequest{{ObjectMeta: metav1.ObjectMeta{Name: "a"}}, {ObjectMeta: metav1.ObjectMeta{Name: "b"}, LastVersion: &staleVersion}, {ObjectMeta: metav1.ObjectMeta{Name: "missing"}}},
+			expectedErrors: []string{"b", "missing"},
+		},
+		"duplicate": {
+			requests:       []*buildv1.BuildRequest{{ObjectMeta: metav1.ObjectMeta{Name: "a"}}, {ObjectMeta: metav1.ObjectMeta{Name: "a"}}},
+			expectedErrors: []string{"a"},
+		},
+		"conflict while creating": {
+			requests:       []*buildv1.BuildRequest{{ObjectMeta: metav1.ObjectMeta{Name: "a"}}, {ObjectMeta: metav1.ObjectMeta{Name: "b"}}},
+			conflictOn:     "b",
+			expectedErrors: []string{"b"},
+			cancelled:      1,
+		},
+	} {
+		created, cancelled := 0, 0
+		g := mockBuildGenerator(
+			func(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.BuildConfig, error) {
+				if name == "missing" {
+					return nil, errors.NewNotFound(buildv1.Resource("buildconfigs"), name)
+				}
+				bc := MockBuildConfig(MockSource(), MockSourceStrategyForImageRepository(), MockOutput())
+				bc.Name = name
+				return bc, nil
+			},
+			func(ctx context.Context, bc *buildv1.BuildConfig, _ metav1.UpdateOptions) error {
+				if bc.Name == tc.conflictOn {
+					return errors.NewConflict(buildv1.Resource("buildconfigs"), bc.Name, nil)
+				}
+				return nil
+			},
+			func(ctx context.Context, build *buildv1.Build, _ metav1.CreateOptions) error {
+				created++
+				return nil
+			},
+			nil, nil, nil, nil,
+		)
+		c := g.Client.(TestingClient)
+		c.UpdateBuildFunc = func(ctx context.Context, build *buildv1.Build, _ metav1.UpdateOptions) error {
+			if build.Status.Cancelled {
+				cancelled++
+			}
+			return nil
+		}
+		g.Client = c
+
+		builds, err := g.InstantiateBatch(apirequest.NewDefaultContext(), tc.requests, metav1.CreateOptions{})
+		if len(tc.expectedErrors) == 0 {
+			if err != nil || len(builds) != tc.expectedBuilds {
+				t.Errorf("%s: expected %d builds, got %d: %v", name, tc.expectedBuilds, len(builds), err)
+			}
+			continue
+		}
+		batchErr, ok := err.(*BatchError)
+		if !ok || len(builds) != 0 {
+			t.Errorf("%s: expected a BatchError and no builds, got %d builds: %v", name, len(builds), err)
+			continue
+		}
+		if names := batchErr.Names(); len(names) != len(tc.expectedErrors) || names[0] != tc.expectedErrors[0] {
+			t.Errorf("%s: expected errors for %v, got %v", name, tc.expectedErrors, err)
+		}
+		if cancelled != tc.cancelled || (tc.cancelled == 0 && created != 0) {
+			t.Errorf("%s: expected %d cancelled builds, got %d of %d created", name, tc.cancelled, cancelled, created)
+		}
+	}
+}
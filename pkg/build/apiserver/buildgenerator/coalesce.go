@@ -0,0 +1,135 @@
+package buildgenerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	internal "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+)
+
+// coalesceWindow returns how long after its creation a build of bc takes the instantiate requests
+// for its revision, 0 if requests are not coalesced.
+func coalesceWindow(bc *buildv1.BuildConfig) time.Duration {
+	value, ok := bc.Annotations[internal.BuildConfigCoalesceWindowAnnotation]
+	if !ok {
+		return 0
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		klog.V(2).Infof("Ignoring the invalid %s annotation %q of BuildConfig %s/%s: %v", internal.BuildConfigCoalesceWindowAnnotation, value, bc.Namespace, bc.Name, err)
+		return 0
+	}
+	return window
+}
+
+// coalesce adds the trigger causes of a request to the New or Pending build of bc for the same
+// commit created within the coalesce window of bc by a request with the same parameters, and
+// returns that build. It returns nil if the
+// request has to create a build.
+func (g *BuildGenerator) coalesce(ctx context.Context, bc *buildv1.BuildConfig, request *buildv1.BuildRequest) (*buildv1.Build, error) {
+	window := coalesceWindow(bc)
+	if window <= 0 || request.Binary != nil || request.Revision == nil || request.Revision.Git == nil || len(request.Revision.Git.Commit) == 0 {
+		return nil, nil
+	}
+	hash, err := requestParametersHash(request)
+	if err != nil {
+		return nil, err
+	}
+	builds, err := g.Client.ListBuilds(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{buildv1.BuildConfigLabel: LabelValue(bc.Name)}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pending *buildv1.Build
+	for i := range builds.Items {
+		build := &builds.Items[i]
+		if build.Status.Config == nil || build.Status.Config.Name != bc.Name {
+			continue
+		}
+		if build.Status.Phase != buildv1.BuildPhaseNew && build.Status.Phase != buildv1.BuildPhasePending {
+			continue
+		}
+		if build.Spec.Revision == nil || build.Spec.Revision.Git == nil || build.Spec.Revision.Git.Commit != request.Revision.Git.Commit {
+			continue
+		}
+		if time.Since(build.CreationTimestamp.Time) > window {
+			continue
+		}
+		// the build may have been created with other environment variables, options or metadata
+		if build.Annotations[internal.BuildRequestParametersAnnotation] != hash {
+			continue
+		}
+		if pending == nil || build.CreationTimestamp.After(pending.CreationTimestamp.Time) {
+			pending = build
+		}
+	}
+	if pending == nil {
+		return nil, nil
+	}
+
+	build := pending.DeepCopy()
+	for _, cause := range request.TriggeredBy {
+		if !hasTriggerCause(build.Spec.TriggeredBy, cause) {
+			build.Spec.TriggeredBy = append(build.Spec.TriggeredBy, cause)
+		}
+	}
+	klog.V(2).Infof("Coalescing %s into build %s/%s", describeBuildRequest(request), build.Namespace, build.Name)
+	if len(build.Spec.TriggeredBy) == len(pending.Spec.TriggeredBy) {
+		return build, nil
+	}
+	// a conflict is retried by Instantiate, the build may have started in the meantime
+	if err := g.Client.UpdateBuild(ctx, build, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+	return g.Client.GetBuild(ctx, build.Name, metav1.GetOptions{})
+}
+
+// requestParameters are the parameters of a BuildRequest that change the build it creates, besides
+// its revision. Empty lists and maps are omitted so that they hash like missing ones.
+type requestParameters struct {
+	Env                   []corev1.EnvVar                `json:"env,omitempty"`
+	DockerStrategyOptions *buildv1.DockerStrategyOptions `json:"dockerStrategyOptions,omitempty"`
+	SourceStrategyOptions *buildv1.SourceStrategyOptions `json:"sourceStrategyOptions,omitempty"`
+	Labels                map[string]string              `json:"labels,omitempty"`
+	Annotations           map[string]string              `json:"annotations,omitempty"`
+	From                  *corev1.ObjectReference        `json:"from,omitempty"`
+}
+
+// requestParametersHash returns a hash of the parameters of a request, equal for the requests
+// creating the same build from the same revision.
+func requestParametersHash(request *buildv1.BuildRequest) (string, error) {
+	data, err := json.Marshal(requestParameters{
+		Env:                   request.Env,
+		DockerStrategyOptions: request.DockerStrategyOptions,
+		SourceStrategyOptions: request.SourceStrategyOptions,
+		Labels:                request.Labels,
+		Annotations:           request.Annotations,
+		From:                  request.From,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func hasTriggerCause(causes []buildv1.BuildTriggerCause, cause buildv1.BuildTriggerCause) bool {
+	for _, c := range causes {
+		if equality.Semantic.DeepEqual(c, cause) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package buildgenerator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+)
+
+func TestInstantiateCoalesces(t *testing.T) {
+	revision := func(commit string) *buildv1.SourceRevision {
+		return &buildv1.SourceRevision{Git: &buildv1.GitSourceRevision{Commit: commit}}
+	}
+	pushCause := buildv1.BuildTriggerCause{Message: buildapi.BuildTriggerCauseGithubMsg, GitHubWebHook: &buildv1.GitHubWebHookCause{Revision: revision("abcd")}}
+	tagCause := buildv1.BuildTriggerCause{Message: buildapi.BuildTriggerCauseGenericMsg}
+	hash, err := requestParametersHash(&buildv1.BuildRequest{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}}, Env: []corev1.EnvVar{{Name: "FOO", Value: "bar"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := func(phase buildv1.BuildPhase, commit string, age time.Duration) *buildv1.Build {
+		return &buildv1.Build{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "test-build-config-1",
+				Namespace:         metav1.NamespaceDefault,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+				Annotations:       map[string]string{buildapi.BuildRequestParametersAnnotation: hash},
+			},
+			Spec: buildv1.BuildSpec{
+				CommonSpec:  buildv1.CommonSpec{Revision: revision(commit)},
+				TriggeredBy: []buildv1.BuildTriggerCause{pushCause},
+			},
+			Status: buildv1.BuildStatus{Phase: phase, Config: &corev1.ObjectReference{Name: "test-build-config"}},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		window    string
+		existing  *buildv1.Build
+		commit    string
+		mutate    func(*buildv1.BuildRequest)
+		coalesced bool
+	}{
+		"same commit within the window": {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second), commit: "abcd", coalesced: true},
+		"pending build":                 {window: "1m", existing: existing(buildv1.BuildPhasePending, "abcd", 10*time.Second), commit: "abcd", coalesced: true},
+		"no window":                     {existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second), commit: "abcd"},
+		"running build":                 {window: "1m", existing: existing(buildv1.BuildPhaseRunning, "abcd", 10*time.Second), commit: "abcd"},
+		"other commit":                  {window: "1m", existing: existing(buildv1.BuildPhaseNew, "ef01", 10*time.Second), commit: "abcd"},
+		"outside of the window":         {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 2*time.Minute), commit: "abcd"},
+		"no commit":                     {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second)},
+		"other env": {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second), commit: "abcd", mutate: func(r *buildv1.BuildRequest) {
+			r.Env[0].Value = "baz"
+		}},
+		"source strategy options": {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second), commit: "abcd", mutate: func(r *buildv1.BuildRequest) {
+			incremental := true
+			r.SourceStrategyOptions = &buildv1.SourceStrategyOptions{Incremental: &incremental}
+		}},
+		"other labels": {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second), commit: "abcd", mutate: func(r *buildv1.BuildRequest) {
+			r.Labels = map[string]string{"team": "a"}
+		}},
+		"other annotations": {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second), commit: "abcd", mutate: func(r *buildv1.BuildRequest) {
+			r.Annotations = map[string]string{"note": "a"}
+		}},
+		"other from": {window: "1m", existing: existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second), commit: "abcd", mutate: func(r *buildv1.BuildRequest) {
+			r.From = &corev1.ObjectReference{Kind: "DockerImage", Name: "builder:2"}
+		}},
+		"build without parameters": {window: "1m", existing: func() *buildv1.Build {
+			build := existing(buildv1.BuildPhaseNew, "abcd", 10*time.Second)
+			build.Annotations = nil
+			return build
+		}(), commit: "abcd"},
+	} {
+		created := false
+		var updated *buildv1.Build
+		g := mockBuildGenerator(
+			func(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.BuildConfig, error) {
+				bc := MockBuildConfig(MockSource(), MockSourceStrategyForImageRepository(), MockOutput())
+				if len(tc.window) > 0 {
+					bc.Annotations = map[string]string{buildapi.BuildConfigCoalesceWindowAnnotation: tc.window}
+				}
+				return bc, nil
+			},
+			nil,
+			func(ctx context.Context, build *buildv1.Build, _ metav1.CreateOptions) error {
+				created = true
+				return nil
+			},
+			func(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.Build, error) {
+				if updated != nil {
+					return updated, nil
+				}
+				return &buildv1.Build{}, nil
+			},
+			nil, nil, nil,
+		)
+		c := g.Client.(TestingClient)
+		c.ListBuildsFunc = func(ctx context.Context, options metav1.ListOptions) (*buildv1.BuildList, error) {
+			if options.LabelSelector != buildv1.BuildConfigLabel+"=test-build-config" {
+				t.Errorf("%s: unexpected selector %q", name, options.LabelSelector)
+			}
+			return &buildv1.BuildList{Items: []buildv1.Build{*tc.existing}}, nil
+		}
+		c.UpdateBuildFunc = func(ctx context.Context, build *buildv1.Build, _ metav1.UpdateOptions) error {
+			updated = build
+			return nil
+		}
+		g.Client = c
+
+		request := &buildv1.BuildRequest{
+			ObjectMeta:  metav1.ObjectMeta{Name: "test-build-config"},
+			TriggeredBy: []buildv1.BuildTriggerCause{tagCause},
+			Env:         []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+		}
+		if tc.mutate != nil {
+			tc.mutate(request)
+		}
+		if len(tc.commit) > 0 {
+			request.Revision = revision(tc.commit)
+		}
+		build, err := g.Instantiate(apirequest.NewDefaultContext(), request, metav1.CreateOptions{})
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		if created == tc.coalesced {
+			t.Errorf("%s: expected coalesced to be %t, got a new build: %t", name, tc.coalesced, created)
+		}
+		if !tc.coalesced {
+			continue
+		}
+		if build.Name != tc.existing.Name || len(build.Spec.TriggeredBy) != 2 || build.Spec.TriggeredBy[1].Message != tagCause.Message {
+			t.Errorf("%s: expected the trigger cause to be added to the existing build, got %#v", name, build)
+		}
+
+		// the same cause is recorded once
+		updated = nil
+		tc.existing.Spec.TriggeredBy = append(tc.existing.Spec.TriggeredBy, tagCause)
+		if _, err := g.Instantiate(apirequest.NewDefaultContext(), request, metav1.CreateOptions{}); err != nil || updated != nil {
+			t.Errorf("%s: expected the build not to be updated again, got %v", name, err)
+		}
+	}
+}
+
+func TestRequestParametersHash(t *testing.T) {
+	noCache := true
+	base := &buildv1.BuildRequest{Env: []corev1.EnvVar{{Name: "FOO", Value: "bar"}}}
+	hash, err := requestParametersHash(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		mutate func(*buildv1.BuildRequest)
+		equal  bool
+	}{
+		"same parameters": {mutate: func(r *buildv1.BuildRequest) {}, equal: true},
+		"other name and causes": {mutate: func(r *buildv1.BuildRequest) {
+			r.Name = "other"
+			r.TriggeredBy = []buildv1.BuildTriggerCause{{Message: "other"}}
+		}, equal: true},
+		"empty labels": {mutate: func(r *buildv1.BuildRequest) { r.Labels = map[string]string{} }, equal: true},
+		"other env":    {mutate: func(r *buildv1.BuildRequest) { r.Env = nil }},
+		"build args": {mutate: func(r *buildv1.BuildRequest) {
+			r.DockerStrategyOptions = &buildv1.DockerStrategyOptions{BuildArgs: []corev1.EnvVar{{Name: "ARG", Value: "1"}}}
+		}},
+		"no cache": {mutate: func(r *buildv1.BuildRequest) {
+			r.DockerStrategyOptions = &buildv1.DockerStrategyOptions{NoCache: &noCache}
+		}},
+	} {
+		request := base.DeepCopy()
+		tc.mutate(request)
+		got, err := requestParametersHash(request)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		if (got == hash) != tc.equal {
+			t.Errorf("%s: expected the hashes to be equal: %t", name, tc.equal)
+		}
+	}
+}
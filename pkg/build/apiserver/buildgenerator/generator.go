@@ -50,6 +50,7 @@ type GeneratorClient interface {
 	GetBuildConfig(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.BuildConfig, error)
 	UpdateBuildConfig(ctx context.Context, buildConfig *buildv1.BuildConfig, options metav1.UpdateOptions) error
 	GetBuild(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.Build, error)
+	ListBuilds(ctx context.Context, options metav1.ListOptions) (*buildv1.BuildList, error)
 	CreateBuild(ctx context.Context, build *buildv1.Build, options metav1.CreateOptions) error
 	UpdateBuild(ctx context.Context, build *buildv1.Build, options metav1.UpdateOptions) error
 	GetImageStream(ctx context.Context, name string, options metav1.GetOptions) (*imagev1.ImageStream, error)
@@ -82,6 +83,11 @@ func (c Client) GetBuild(ctx context.Context, name string, options metav1.GetOpt
 	return c.Builds.Builds(apirequest.NamespaceValue(ctx)).Get(ctx, name, options)
 }
 
+// ListBuilds lists the builds of the namespace
+func (c Client) ListBuilds(ctx context.Context, options metav1.ListOptions) (*buildv1.BuildList, error) {
+	return c.Builds.Builds(apirequest.NamespaceValue(ctx)).List(ctx, options)
+}
+
 // CreateBuild creates a new build
 func (c Client) CreateBuild(ctx context.Context, build *buildv1.Build, options metav1.CreateOptions) error {
 	_, err := c.Builds.Builds(apirequest.NamespaceValue(ctx)).Create(ctx, build, options)
@@ -259,32 +265,45 @@ func (g *BuildGenerator) Instantiate(ctx context.Context, request *buildv1.Build
 }
 
 func (g *BuildGenerator) instantiate(ctx context.Context, request *buildv1.BuildRequest, opts metav1.CreateOptions) (*buildv1.Build, error) {
-	klog.V(4).Infof("Generating Build from %s", describeBuildRequest(request))
 	bc, err := g.Client.GetBuildConfig(ctx, request.Name, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
+	if build, err := g.coalesce(ctx, bc, request); err != nil || build != nil {
+		return build, err
+	}
+	bc, newBuild, err := g.prepareInstantiate(ctx, bc, request)
+	if err != nil {
+		return nil, err
+	}
+	return g.commitInstantiate(ctx, bc, newBuild, opts)
+}
+
+// prepareInstantiate generates the Build of a BuildRequest and updates the BuildConfig for it,
+// without storing either of them.
+func (g *BuildGenerator) prepareInstantiate(ctx context.Context, bc *buildv1.BuildConfig, request *buildv1.BuildRequest) (*buildv1.BuildConfig, *buildv1.Build, error) {
+	klog.V(4).Infof("Generating Build from %s", describeBuildRequest(request))
 	if isPaused(bc) {
-		return nil, errors.NewBadRequest(fmt.Sprintf("can't instantiate from BuildConfig %s/%s: BuildConfig is paused", bc.Namespace, bc.Name))
+		return nil, nil, errors.NewBadRequest(fmt.Sprintf("can't instantiate from BuildConfig %s/%s: BuildConfig is paused", bc.Namespace, bc.Name))
 	}
 
 	if err := g.checkLastVersion(bc, request.LastVersion); err != nil {
-		return nil, errors.NewBadRequest(err.Error())
+		return nil, nil, errors.NewBadRequest(err.Error())
 	}
 
 	if err := g.updateImageTriggers(ctx, bc, request.From, request.TriggeredByImage); err != nil {
 		if _, ok := err.(errors.APIStatus); ok {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, errors.NewInternalError(err)
+		return nil, nil, errors.NewInternalError(err)
 	}
 
 	newBuild, err := g.generateBuildFromConfig(ctx, bc, request.Revision, request.Binary)
 	if err != nil {
 		if _, ok := err.(errors.APIStatus); ok {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, errors.NewInternalError(err)
+		return nil, nil, errors.NewInternalError(err)
 	}
 
 	// Add labels and annotations from the buildrequest.  Existing
@@ -293,6 +312,13 @@ func (g *BuildGenerator) instantiate(ctx context.Context, request *buildv1.Build
 	newBuild.Annotations = mergeMaps(request.Annotations, newBuild.Annotations)
 	newBuild.Labels = mergeMaps(request.Labels, newBuild.Labels)
 
+	if ref, ok := request.Annotations[internal.BuildSourceRefAnnotation]; ok {
+		if err := setBuildSourceRef(ref, newBuild); err != nil {
+			return nil, nil, err
+		}
+		setPullRequestOutput(bc, newBuild)
+	}
+
 	// Copy build trigger information and build arguments to the build object.
 	newBuild.Spec.TriggeredBy = request.TriggeredBy
 
@@ -307,7 +333,7 @@ func (g *BuildGenerator) instantiate(ctx context.Context, request *buildv1.Build
 		// Update the Docker build args
 		if dockerOpts.BuildArgs != nil && len(dockerOpts.BuildArgs) > 0 {
 			if newBuild.Spec.Strategy.DockerStrategy == nil {
-				return nil, errors.NewBadRequest(fmt.Sprintf("Cannot specify Docker build specific options on %s/%s, not a Docker build.", bc.Namespace, bc.ObjectMeta.Name))
+				return nil, nil, errors.NewBadRequest(fmt.Sprintf("Cannot specify Docker build specific options on %s/%s, not a Docker build.", bc.Namespace, bc.ObjectMeta.Name))
 			}
 			newBuild.Spec.Strategy.DockerStrategy.BuildArgs = updateBuildArgs(&newBuild.Spec.Strategy.DockerStrategy.BuildArgs, dockerOpts.BuildArgs)
 		}
@@ -315,7 +341,7 @@ func (g *BuildGenerator) instantiate(ctx context.Context, request *buildv1.Build
 		// Update the Docker noCache option
 		if dockerOpts.NoCache != nil {
 			if newBuild.Spec.Strategy.DockerStrategy == nil {
-				return nil, errors.NewBadRequest(fmt.Sprintf("Cannot specify Docker build specific options on %s/%s, not a Docker build.", bc.Namespace, bc.ObjectMeta.Name))
+				return nil, nil, errors.NewBadRequest(fmt.Sprintf("Cannot specify Docker build specific options on %s/%s, not a Docker build.", bc.Namespace, bc.ObjectMeta.Name))
 			}
 			newBuild.Spec.Strategy.DockerStrategy.NoCache = *dockerOpts.NoCache
 		}
@@ -328,13 +354,28 @@ func (g *BuildGenerator) instantiate(ctx context.Context, request *buildv1.Build
 		// Update the Source incremental option
 		if sourceOpts.Incremental != nil {
 			if newBuild.Spec.Strategy.SourceStrategy == nil {
-				return nil, errors.NewBadRequest(fmt.Sprintf("Cannot specify Source build specific options on %s/%s, not a Source build.", bc.Namespace, bc.ObjectMeta.Name))
+				return nil, nil, errors.NewBadRequest(fmt.Sprintf("Cannot specify Source build specific options on %s/%s, not a Source build.", bc.Namespace, bc.ObjectMeta.Name))
 			}
 			newBuild.Spec.Strategy.SourceStrategy.Incremental = sourceOpts.Incremental
 		}
 	}
+	if coalesceWindow(bc) > 0 {
+		hash, err := requestParametersHash(request)
+		if err != nil {
+			return nil, nil, errors.NewInternalError(err)
+		}
+		if newBuild.Annotations == nil {
+			newBuild.Annotations = map[string]string{}
+		}
+		newBuild.Annotations[internal.BuildRequestParametersAnnotation] = hash
+	}
 	klog.V(4).Infof("Build %s/%s has been generated from %s/%s BuildConfig", newBuild.Namespace, newBuild.ObjectMeta.Name, bc.Namespace, bc.ObjectMeta.Name)
 
+	return bc, newBuild, nil
+}
+
+// commitInstantiate stores the BuildConfig and the Build prepared for a BuildRequest.
+func (g *BuildGenerator) commitInstantiate(ctx context.Context, bc *buildv1.BuildConfig, newBuild *buildv1.Build, opts metav1.CreateOptions) (*buildv1.Build, error) {
 	// need to update the BuildConfig because LastVersion and possibly
 	// LastTriggeredImageID changed
 	if err := g.Client.UpdateBuildConfig(ctx, bc, metav1.UpdateOptions{}); err != nil {
@@ -1048,6 +1089,35 @@ func setBuildSource(binary *buildv1.BinaryBuildSource, build *buildv1.Build) {
 	}
 }
 
+// pullRequestRef matches the git references of the pull requests of GitHub, GitLab and Bitbucket
+// Server, and the branches pull requests are built from by providers without such references.
+var pullRequestRef = regexp.MustCompile(`^refs/(pull/\d+/(head|merge)|merge-requests/\d+/(head|merge)|pull-requests/\d+/(from|merge)|heads/\S+)$`)
+
+// setBuildSourceRef makes the build check out the git reference of a pull request instead of the
+// ref of the source of its BuildConfig.
+func setBuildSourceRef(ref string, build *buildv1.Build) error {
+	if build.Spec.Source.Git == nil {
+		return errors.NewBadRequest(fmt.Sprintf("cannot build %s, the build does not have a git source", ref))
+	}
+	if !pullRequestRef.MatchString(ref) {
+		return errors.NewBadRequest(fmt.Sprintf("%s is not the git reference of a pull request", ref))
+	}
+	build.Spec.Source.Git.Ref = ref
+	return nil
+}
+
+// setPullRequestOutput sets the output of a build of a pull request. Pull requests may come from
+// forks, so their builds do not push to the output of the BuildConfig, which may trigger other
+// builds and deployments, nor with its push secret. They push to the ImageStreamTag set by
+// BuildConfigWebHookPullRequestOutputAnnotation, or nowhere.
+func setPullRequestOutput(bc *buildv1.BuildConfig, build *buildv1.Build) {
+	build.Spec.Output.PushSecret = nil
+	build.Spec.Output.To = nil
+	if output, ok := bc.Annotations[internal.BuildConfigWebHookPullRequestOutputAnnotation]; ok {
+		build.Spec.Output.To = &corev1.ObjectReference{Kind: "ImageStreamTag", Name: output}
+	}
+}
+
 // setBuildAnnotationAndLabel set annotations and label info of this build
 func setBuildAnnotationAndLabel(bcCopy *buildv1.BuildConfig, build *buildv1.Build) {
 	if build.Annotations == nil {
@@ -1059,12 +1129,14 @@ func setBuildAnnotationAndLabel(bcCopy *buildv1.BuildConfig, build *buildv1.Buil
 	if build.Labels == nil {
 		build.Labels = make(map[string]string)
 	}
-	build.Labels[buildv1.BuildConfigLabelDeprecated] = labelValue(bcCopy.Name)
-	build.Labels[buildv1.BuildConfigLabel] = labelValue(bcCopy.Name)
+	build.Labels[buildv1.BuildConfigLabelDeprecated] = LabelValue(bcCopy.Name)
+	build.Labels[buildv1.BuildConfigLabel] = LabelValue(bcCopy.Name)
 	build.Labels[buildv1.BuildRunPolicyLabel] = string(bcCopy.Spec.RunPolicy)
 }
 
-func labelValue(name string) string {
+// LabelValue returns name truncated to a valid label value, the value of the BuildConfig labels
+// of the builds of the BuildConfig name.
+func LabelValue(name string) string {
 	end := len(name)
 	newName := name
 	// first, try to truncate from the end to find a valid
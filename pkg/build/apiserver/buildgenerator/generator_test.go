@@ -658,6 +658,72 @@ func TestInstantiateWithLastVersion(t *testing.T) {
 	}
 }
 
+func TestInstantiateWithSourceRef(t *testing.T) {
+	for ref, valid := range map[string]bool{
+		"refs/pull/12/head":           true,
+		"refs/merge-requests/3/merge": true,
+		"refs/pull-requests/7/from":   true,
+		"refs/heads/feature/login":    true,
+		"refs/tags/v1.0":              false,
+		"main":                        false,
+		"refs/pull/12/head; rm -rf /": false,
+	} {
+		var created *buildv1.Build
+		g := mockBuildGenerator(nil, nil, func(ctx context.Context, build *buildv1.Build, _ metav1.CreateOptions) error {
+			created = build
+			return nil
+		}, nil, nil, nil, nil)
+		request := &buildv1.BuildRequest{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{buildapi.BuildSourceRefAnnotation: ref}}}
+		_, err := g.Instantiate(apirequest.NewDefaultContext(), request, metav1.CreateOptions{})
+		if !valid {
+			if !errors.IsBadRequest(err) {
+				t.Errorf("%s: expected a bad request, got %v", ref, err)
+			}
+			continue
+		}
+		if err != nil || created == nil || created.Spec.Source.Git.Ref != ref {
+			t.Errorf("%s: expected the build to check out the ref, got %v", ref, err)
+		}
+	}
+}
+
+func TestInstantiatePullRequestOutput(t *testing.T) {
+	for output, expected := range map[string]*corev1.ObjectReference{
+		"":       nil,
+		"app:pr": {Kind: "ImageStreamTag", Name: "app:pr"},
+	} {
+		g := mockBuildGenerator(nil, nil, nil, nil, nil, nil, nil)
+		c := g.Client.(TestingClient)
+		c.GetBuildConfigFunc = func(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.BuildConfig, error) {
+			bc := MockBuildConfig(MockSource(), MockSourceStrategyForImageRepository(), MockOutput())
+			bc.Spec.Output.PushSecret = &corev1.LocalObjectReference{Name: "push"}
+			if len(output) > 0 {
+				bc.Annotations = map[string]string{buildapi.BuildConfigWebHookPullRequestOutputAnnotation: output}
+			}
+			return bc, nil
+		}
+		g.Client = c
+
+		// pull requests may come from forks, their builds never push to the output of the BuildConfig
+		request := &buildv1.BuildRequest{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{buildapi.BuildSourceRefAnnotation: "refs/pull/12/head"}}}
+		build, err := g.Instantiate(apirequest.NewDefaultContext(), request, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", output, err)
+		}
+		if !reflect.DeepEqual(build.Spec.Output.To, expected) || build.Spec.Output.PushSecret != nil {
+			t.Errorf("%q: expected the build to push to %v without the push secret, got %#v", output, expected, build.Spec.Output)
+		}
+
+		build, err = g.Instantiate(apirequest.NewDefaultContext(), &buildv1.BuildRequest{}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", output, err)
+		}
+		if !reflect.DeepEqual(build.Spec.Output.To, MockOutput().To) || build.Spec.Output.PushSecret == nil {
+			t.Errorf("%q: expected the other builds to push to the output of the BuildConfig, got %#v", output, build.Spec.Output)
+		}
+	}
+}
+
 func TestInstantiateWithMissingImageStream(t *testing.T) {
 	g := mockBuildGenerator(nil, nil, nil, nil, nil, nil, nil)
 	c := g.Client.(TestingClient)
@@ -2356,7 +2422,7 @@ func TestLabelValue(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		result := labelValue(tc.input)
+		result := LabelValue(tc.input)
 		if result != tc.expectedOutput {
 			t.Errorf("tc %s got %s for %s instead of %s", tc.name, result, tc.input, tc.expectedOutput)
 		}
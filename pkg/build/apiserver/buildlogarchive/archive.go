@@ -0,0 +1,43 @@
+package buildlogarchive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ErrNotFound is returned when the log of a build was not archived.
+var ErrNotFound = errors.New("the build log was not archived")
+
+// Archive keeps the logs of builds, keyed by the namespace, the name and the
+// UID of the build, so that a build recreated with the name of a deleted one
+// is never served the log of the deleted build.
+type Archive interface {
+	// Put stores the log of a build, replacing a previously archived one.
+	Put(ctx context.Context, namespace, name string, uid types.UID, log io.Reader) error
+	// Get returns the archived log of a build, or ErrNotFound.
+	Get(ctx context.Context, namespace, name string, uid types.UID) (io.ReadCloser, error)
+	// Delete removes the archived log of a build, if any.
+	Delete(ctx context.Context, namespace, name string, uid types.UID) error
+	// Prune removes the logs archived before the given time.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// logSuffix ends the keys of the build logs.
+const logSuffix = ".log"
+
+// key returns the relative path of the log of a build. Namespaces and build
+// names are DNS subdomains and UIDs are DNS labels, so they are safe path
+// segments.
+func key(namespace, name string, uid types.UID) (string, error) {
+	if len(validation.IsDNS1123Subdomain(namespace)) > 0 || len(validation.IsDNS1123Subdomain(name)) > 0 || len(validation.IsDNS1123Label(string(uid))) > 0 {
+		return "", fmt.Errorf("invalid build %s/%s (%s)", namespace, name, uid)
+	}
+	return path.Join(namespace, name, string(uid)+logSuffix), nil
+}
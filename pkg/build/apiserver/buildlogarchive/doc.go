@@ -0,0 +1,3 @@
+// Package buildlogarchive contains the archives build logs are kept in once
+// the pods of the builds are gone.
+package buildlogarchive
@@ -0,0 +1,132 @@
+package buildlogarchive
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// filesystemArchive keeps the build logs in a directory, one directory per
+// namespace and build.
+type filesystemArchive struct {
+	dir string
+}
+
+// NewFilesystem returns an archive keeping the build logs in dir. The logs are
+// only served by the apiservers sharing dir, so the directory has to be on a
+// volume mounted by every apiserver when there are more than one, otherwise
+// the logs archived by an apiserver are missing from the others and are never
+// deleted by them.
+func NewFilesystem(dir string) (Archive, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &filesystemArchive{dir: dir}, nil
+}
+
+func (a *filesystemArchive) path(namespace, name string, uid types.UID) (string, error) {
+	key, err := key(namespace, name, uid)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(a.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the log to a temporary file first, so that readers never see
+// a partial log.
+func (a *filesystemArchive) Put(ctx context.Context, namespace, name string, uid types.UID, log io.Reader) error {
+	path, err := a.path(namespace, name, uid)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+string(uid)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, log); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (a *filesystemArchive) Get(ctx context.Context, namespace, name string, uid types.UID) (io.ReadCloser, error) {
+	path, err := a.path(namespace, name, uid)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// Delete removes the log, and the directory of the build once it is empty.
+func (a *filesystemArchive) Delete(ctx context.Context, namespace, name string, uid types.UID) error {
+	path, err := a.path(namespace, name, uid)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// fails as long as other logs of the build are kept
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// Prune removes the logs, and the leftover temporary files, last modified
+// before the given time, then the directories left empty.
+func (a *filesystemArchive) Prune(ctx context.Context, before time.Time) error {
+	var dirs []string
+	err := filepath.WalkDir(a.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != a.dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() && info.ModTime().Before(before) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+	// the deepest directories first, so that their parents may be empty
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
+	return err
+}
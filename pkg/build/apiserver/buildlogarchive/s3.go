@@ -0,0 +1,280 @@
+package buildlogarchive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// S3Config configures an archive in a bucket of an S3 compatible object
+// store.
+type S3Config struct {
+	// Endpoint is the URL of the object store, e.g. https://s3.us-east-1.amazonaws.com.
+	Endpoint string
+	Bucket   string
+	Region   string
+	// Prefix is prepended to the keys of the build logs.
+	Prefix string
+
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// s3Archive keeps the build logs as objects addressed path style, which is
+// what most S3 compatible object stores support. Requests are signed with
+// AWS signature version 4.
+type s3Archive struct {
+	config   S3Config
+	endpoint *url.URL
+	now      func() time.Time
+}
+
+// NewS3 returns an archive keeping the build logs in an S3 bucket.
+func NewS3(config S3Config) (Archive, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: %v", config.Endpoint, err)
+	}
+	if len(endpoint.Host) == 0 || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: an http or https URL is required", config.Endpoint)
+	}
+	if len(config.Bucket) == 0 {
+		return nil, fmt.Errorf("the S3 bucket is required")
+	}
+	if len(config.Region) == 0 {
+		config.Region = "us-east-1"
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &s3Archive{config: config, endpoint: endpoint, now: time.Now}, nil
+}
+
+func (a *s3Archive) objectURL(namespace, name string, uid types.UID) (*url.URL, error) {
+	key, err := key(namespace, name, uid)
+	if err != nil {
+		return nil, err
+	}
+	return a.keyURL(path.Join(a.config.Prefix, key)), nil
+}
+
+// keyURL returns the URL of the object of the bucket with the given key.
+func (a *s3Archive) keyURL(key string) *url.URL {
+	u := *a.endpoint
+	u.Path = "/" + path.Join(strings.Trim(u.Path, "/"), a.config.Bucket, key)
+	return &u
+}
+
+// Put buffers the log, the payload hash is part of the signature.
+func (a *s3Archive) Put(ctx context.Context, namespace, name string, uid types.UID, log io.Reader) error {
+	u, err := a.objectURL(namespace, name, uid)
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(log)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	a.sign(req, body)
+	resp, err := a.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return a.responseError(resp)
+	}
+	return nil
+}
+
+func (a *s3Archive) Get(ctx context.Context, namespace, name string, uid types.UID) (io.ReadCloser, error) {
+	u, err := a.objectURL(namespace, name, uid)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	a.sign(req, nil)
+	resp, err := a.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		defer resp.Body.Close()
+		return nil, a.responseError(resp)
+	}
+	return resp.Body, nil
+}
+
+func (a *s3Archive) Delete(ctx context.Context, namespace, name string, uid types.UID) error {
+	u, err := a.objectURL(namespace, name, uid)
+	if err != nil {
+		return nil
+	}
+	return a.delete(ctx, u)
+}
+
+// delete removes the object at u, deleting a missing object succeeds.
+func (a *s3Archive) delete(ctx context.Context, u *url.URL) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	a.sign(req, nil)
+	resp, err := a.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return a.responseError(resp)
+	}
+	return nil
+}
+
+// listBucketResult is the response of a ListObjectsV2 request.
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+	}
+}
+
+// Prune lists the build logs under the prefix of the archive, page by page, and deletes the ones
+// last modified before the given time.
+func (a *s3Archive) Prune(ctx context.Context, before time.Time) error {
+	prefix := ""
+	if len(a.config.Prefix) > 0 {
+		prefix = path.Clean(a.config.Prefix) + "/"
+	}
+	token := ""
+	for {
+		u := a.keyURL("")
+		query := url.Values{"list-type": []string{"2"}, "prefix": []string{prefix}}
+		if len(token) > 0 {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = query.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		a.sign(req, nil)
+		resp, err := a.config.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode/100 != 2 {
+			defer resp.Body.Close()
+			return a.responseError(resp)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("unable to list the build logs: %v", err)
+		}
+
+		for _, object := range result.Contents {
+			if !strings.HasSuffix(object.Key, logSuffix) || !object.LastModified.Before(before) {
+				continue
+			}
+			if err := a.delete(ctx, a.keyURL(object.Key)); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (a *s3Archive) responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(message)))
+}
+
+// sign adds the AWS signature version 4 of the request to its headers.
+func (a *s3Archive) sign(req *http.Request, body []byte) {
+	now := a.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, a.config.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + a.config.SecretAccessKey)
+	for _, part := range []string{date, a.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", a.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
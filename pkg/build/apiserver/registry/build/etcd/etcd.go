@@ -9,17 +9,20 @@ import (
 	"k8s.io/apiserver/pkg/registry/generic/registry"
 	"k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/util/dryrun"
 	"k8s.io/kubernetes/pkg/printers"
 	printerstorage "k8s.io/kubernetes/pkg/printers/storage"
 
 	"github.com/openshift/api/build"
 	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	buildinternalhelpers "github.com/openshift/openshift-apiserver/pkg/build/apis/build/internal_helpers"
 	buildregistry "github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/build"
 	buildprinters "github.com/openshift/openshift-apiserver/pkg/build/printers/internalversion"
 )
 
 type REST struct {
 	*registry.Store
+	completed func(build *buildapi.Build)
 }
 
 var _ rest.StandardStorage = &REST{}
@@ -31,7 +34,10 @@ func (r *REST) Categories() []string {
 }
 
 // NewREST returns a RESTStorage object that will work against Build objects.
-func NewREST(optsGetter generic.RESTOptionsGetter) (*REST, *DetailsREST, error) {
+// If completed is set, it is called with every build that reached a terminal phase, once the
+// update is stored.
+// If deleted is set, it is called with every deleted build.
+func NewREST(optsGetter generic.RESTOptionsGetter, completed, deleted func(build *buildapi.Build)) (*REST, *DetailsREST, error) {
 	store := &registry.Store{
 		NewFunc:                   func() runtime.Object { return &buildapi.Build{} },
 		NewListFunc:               func() runtime.Object { return &buildapi.BuildList{} },
@@ -44,6 +50,13 @@ func NewREST(optsGetter generic.RESTOptionsGetter) (*REST, *DetailsREST, error)
 		UpdateStrategy: buildregistry.Strategy,
 		DeleteStrategy: buildregistry.Strategy,
 	}
+	if deleted != nil {
+		store.AfterDelete = func(obj runtime.Object, options *metav1.DeleteOptions) {
+			if build, ok := obj.(*buildapi.Build); ok && !dryrun.IsDryRun(options.DryRun) {
+				deleted(build)
+			}
+		}
+	}
 
 	options := &generic.StoreOptions{
 		RESTOptions: optsGetter,
@@ -56,11 +69,48 @@ func NewREST(optsGetter generic.RESTOptionsGetter) (*REST, *DetailsREST, error)
 	detailsStore := *store
 	detailsStore.UpdateStrategy = buildregistry.DetailsStrategy
 
-	return &REST{store}, &DetailsREST{&detailsStore}, nil
+	return &REST{store, completed}, &DetailsREST{&detailsStore, completed}, nil
+}
+
+// Update updates a build and reports it if it reached a terminal phase.
+func (r *REST) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	return updateBuild(ctx, r.Store, r.completed, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
+}
+
+// updateBuild updates a build in store and, once the update is stored, calls completed if it moved
+// the build to a terminal phase. Dry runs are not reported.
+func updateBuild(ctx context.Context, store *registry.Store, completed func(build *buildapi.Build), name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	if completed == nil || dryrun.IsDryRun(options.DryRun) {
+		return store.Update(ctx, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
+	}
+	info := &storedBuildInfo{UpdatedObjectInfo: objInfo}
+	obj, created, err := store.Update(ctx, name, info, createValidation, updateValidation, forceAllowCreate, options)
+	if err != nil || created || info.complete {
+		return obj, created, err
+	}
+	if build, ok := obj.(*buildapi.Build); ok && buildinternalhelpers.IsBuildComplete(build) {
+		completed(build)
+	}
+	return obj, created, err
+}
+
+// storedBuildInfo records whether the stored build an update was applied to was complete. The
+// update is applied again to the build stored after each conflict, so once it succeeded, this is
+// the build it replaced.
+type storedBuildInfo struct {
+	rest.UpdatedObjectInfo
+	complete bool
+}
+
+func (i *storedBuildInfo) UpdatedObject(ctx context.Context, oldObj runtime.Object) (runtime.Object, error) {
+	build, ok := oldObj.(*buildapi.Build)
+	i.complete = ok && buildinternalhelpers.IsBuildComplete(build)
+	return i.UpdatedObjectInfo.UpdatedObject(ctx, oldObj)
 }
 
 type DetailsREST struct {
-	store *registry.Store
+	store     *registry.Store
+	completed func(build *buildapi.Build)
 }
 
 var _ rest.Updater = &DetailsREST{}
@@ -73,7 +123,7 @@ func (r *DetailsREST) New() runtime.Object {
 
 // Update finds a resource in the storage and updates it.
 func (r *DetailsREST) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
-	return r.store.Update(ctx, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
+	return updateBuild(ctx, r.store, r.completed, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
 }
 
 func (r *DetailsREST) Destroy() {
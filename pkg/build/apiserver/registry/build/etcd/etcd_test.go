@@ -0,0 +1,104 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+	etcdtesting "k8s.io/apiserver/pkg/storage/etcd3/testing"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	_ "github.com/openshift/openshift-apiserver/pkg/build/apis/build/install"
+)
+
+func newStorage(t *testing.T, completed func(build *buildapi.Build)) (*REST, *DetailsREST, *etcdtesting.EtcdTestServer) {
+	server, etcdStorage := etcdtesting.NewUnsecuredEtcd3TestClientServer(t)
+	etcdStorage.Codec = legacyscheme.Codecs.LegacyCodec(schema.GroupVersion{Group: "build.openshift.io", Version: "v1"})
+	etcdStorageConfigForBuilds := &storagebackend.ConfigForResource{Config: *etcdStorage, GroupResource: schema.GroupResource{Group: "build.openshift.io", Resource: "builds"}}
+	restOptions := generic.RESTOptions{StorageConfig: etcdStorageConfigForBuilds, Decorator: generic.UndecoratedStorage, DeleteCollectionWorkers: 1, ResourcePrefix: "builds"}
+	storage, detailsStorage, err := NewREST(restOptions, completed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage, detailsStorage, server
+}
+
+func validBuild(name string) *buildapi.Build {
+	return &buildapi.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+		Spec: buildapi.BuildSpec{
+			CommonSpec: buildapi.CommonSpec{
+				Source: buildapi.BuildSource{
+					Git: &buildapi.GitBuildSource{URI: "http://github.com/my/repository"},
+				},
+				Strategy: buildapi.BuildStrategy{
+					DockerStrategy: &buildapi.DockerBuildStrategy{},
+				},
+				Output: buildapi.BuildOutput{
+					To: &kapi.ObjectReference{Kind: "DockerImage", Name: "repository/data"},
+				},
+			},
+		},
+	}
+}
+
+// setPhase returns an update setting the phase of a build, which calls before first.
+func setPhase(phase buildapi.BuildPhase, before func()) rest.UpdatedObjectInfo {
+	return rest.DefaultUpdatedObjectInfo(nil, func(ctx context.Context, obj, oldObj runtime.Object) (runtime.Object, error) {
+		if before != nil {
+			before()
+			before = nil
+		}
+		build := oldObj.DeepCopyObject().(*buildapi.Build)
+		build.Status.Phase = phase
+		return build, nil
+	})
+}
+
+func TestUpdateReportsCompletedBuilds(t *testing.T) {
+	var completed []string
+	storage, detailsStorage, server := newStorage(t, func(build *buildapi.Build) {
+		completed = append(completed, build.Name)
+	})
+	defer server.Terminate(t)
+	defer storage.Store.DestroyFunc()
+	ctx := apirequest.NewDefaultContext()
+
+	for _, name := range []string{"bc-1", "bc-2", "bc-3"} {
+		if _, err := storage.Create(ctx, validBuild(name), rest.ValidateAllObjectFunc, &metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update := func(storage rest.Updater, name string, objInfo rest.UpdatedObjectInfo, options *metav1.UpdateOptions) {
+		t.Helper()
+		if _, _, err := storage.Update(ctx, name, objInfo, rest.ValidateAllObjectFunc, rest.ValidateAllObjectUpdateFunc, false, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	update(storage, "bc-1", setPhase(buildapi.BuildPhaseRunning, nil), &metav1.UpdateOptions{})
+	update(storage, "bc-1", setPhase(buildapi.BuildPhaseComplete, nil), &metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+	if len(completed) != 0 {
+		t.Fatalf("expected running builds and dry runs not to be reported, got %v", completed)
+	}
+	update(storage, "bc-1", setPhase(buildapi.BuildPhaseComplete, nil), &metav1.UpdateOptions{})
+	update(storage, "bc-1", setPhase(buildapi.BuildPhaseComplete, nil), &metav1.UpdateOptions{})
+	// the build pod reports the completion through the details
+	update(detailsStorage, "bc-2", setPhase(buildapi.BuildPhaseFailed, nil), &metav1.UpdateOptions{})
+	// an update retried after a conflict with the completion is not reported again
+	update(storage, "bc-3", setPhase(buildapi.BuildPhaseCancelled, func() {
+		update(storage, "bc-3", setPhase(buildapi.BuildPhaseCancelled, nil), &metav1.UpdateOptions{})
+	}), &metav1.UpdateOptions{})
+
+	if len(completed) != 3 || completed[0] != "bc-1" || completed[1] != "bc-2" || completed[2] != "bc-3" {
+		t.Errorf("expected each build to be reported once, got %v", completed)
+	}
+}
@@ -0,0 +1,324 @@
+package buildcancel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/audit"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/api/build"
+	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	buildinternalhelpers "github.com/openshift/openshift-apiserver/pkg/build/apis/build/internal_helpers"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildgenerator"
+)
+
+const (
+	// maxCancelRequestBytes bounds the size of the body of a cancel request.
+	maxCancelRequestBytes = 16 * 1024
+	// maxCancelReasonLength bounds the length of the reason of a cancellation.
+	maxCancelReasonLength = 1024
+	// cancelledBuildsAuditAnnotation is set on the audit event of a cancel request to the names of
+	// the builds it cancelled.
+	cancelledBuildsAuditAnnotation = "build.openshift.io/cancelled-builds"
+)
+
+// BuildCancelRequest is the body of a request to the cancel subresource of builds. The body is
+// optional.
+type BuildCancelRequest struct {
+	// Reason is why the build is cancelled, it is set as BuildCancelReasonAnnotation on the build.
+	Reason string `json:"reason,omitempty"`
+}
+
+// BuildConfigCancelRequest is the body of a request to the cancel subresource of BuildConfigs,
+// which cancels the builds of a BuildConfig which did not complete. The body is optional.
+type BuildConfigCancelRequest struct {
+	// Reason is why the builds are cancelled, it is set as BuildCancelReasonAnnotation on the
+	// builds.
+	Reason string `json:"reason,omitempty"`
+	// Phases restricts the cancelled builds to the builds in these phases, among New, Pending and
+	// Running. All of them by default.
+	Phases []buildv1.BuildPhase `json:"phases,omitempty"`
+	// LabelSelector restricts the cancelled builds to the builds it selects.
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// Storage is the storage of builds the cancel subresources update. Builds are updated in
+// storage rather than through a client, so that a cancel request is audited as one request.
+type Storage interface {
+	List(ctx context.Context, options *metainternalversion.ListOptions) (runtime.Object, error)
+	Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error)
+}
+
+// errBuildComplete is returned when a build to cancel is already complete.
+var errBuildComplete = errors.New("the build is already complete")
+
+// cancellablePhases are the phases of the builds which can be cancelled.
+var cancellablePhases = []buildv1.BuildPhase{buildv1.BuildPhaseNew, buildv1.BuildPhasePending, buildv1.BuildPhaseRunning}
+
+// NewBuildStorage creates a new storage object for cancelling builds.
+func NewBuildStorage(builds Storage) *BuildCancelREST {
+	return &BuildCancelREST{builds: builds}
+}
+
+// BuildCancelREST cancels a build.
+type BuildCancelREST struct {
+	builds Storage
+}
+
+var _ rest.Connecter = &BuildCancelREST{}
+var _ rest.StorageMetadata = &BuildCancelREST{}
+
+// New creates a new build cancel request
+func (r *BuildCancelREST) New() runtime.Object {
+	return &buildapi.BuildRequest{}
+}
+
+func (r *BuildCancelREST) Destroy() {}
+
+// Connect returns a handler cancelling the build name.
+func (r *BuildCancelREST) Connect(ctx context.Context, name string, options runtime.Object, responder rest.Responder) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cancel := &BuildCancelRequest{}
+		if err := decodeCancelRequest(req, cancel); err != nil {
+			responder.Error(err)
+			return
+		}
+		if errs := validateReason(cancel.Reason, field.NewPath("reason")); len(errs) > 0 {
+			responder.Error(kerrors.NewInvalid(build.Kind("BuildCancelRequest"), name, errs))
+			return
+		}
+		b, err := cancelBuild(ctx, r.builds, name, cancel.Reason)
+		if err == errBuildComplete {
+			responder.Error(kerrors.NewConflict(build.Resource("builds"), name, err))
+			return
+		}
+		if err != nil {
+			responder.Error(err)
+			return
+		}
+		list := &buildapi.BuildList{Items: []buildapi.Build{*b}}
+		auditCancelledBuilds(ctx, list)
+		responder.Object(http.StatusOK, list)
+	}), nil
+}
+
+// NewConnectOptions returns no options, the request is read from the body.
+func (r *BuildCancelREST) NewConnectOptions() (runtime.Object, bool, string) {
+	return nil, false, ""
+}
+
+// ConnectMethods returns POST, the only supported cancel method.
+func (r *BuildCancelREST) ConnectMethods() []string {
+	return []string{"POST"}
+}
+
+func (r *BuildCancelREST) ProducesObject(verb string) interface{} {
+	// for documentation purposes
+	return buildv1.BuildList{}
+}
+
+func (r *BuildCancelREST) ProducesMIMETypes(verb string) []string {
+	return nil // no additional mime types
+}
+
+// NewBuildConfigStorage creates a new storage object for cancelling the builds of BuildConfigs.
+func NewBuildConfigStorage(builds Storage) *BuildConfigCancelREST {
+	return &BuildConfigCancelREST{builds: builds}
+}
+
+// BuildConfigCancelREST cancels the builds of a BuildConfig.
+type BuildConfigCancelREST struct {
+	builds Storage
+}
+
+var _ rest.Connecter = &BuildConfigCancelREST{}
+var _ rest.StorageMetadata = &BuildConfigCancelREST{}
+
+// New creates a new build cancel request
+func (r *BuildConfigCancelREST) New() runtime.Object {
+	return &buildapi.BuildRequest{}
+}
+
+func (r *BuildConfigCancelREST) Destroy() {}
+
+// Connect returns a handler cancelling the builds of the BuildConfig name.
+func (r *BuildConfigCancelREST) Connect(ctx context.Context, name string, options runtime.Object, responder rest.Responder) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cancel := &BuildConfigCancelRequest{}
+		if err := decodeCancelRequest(req, cancel); err != nil {
+			responder.Error(err)
+			return
+		}
+		list, err := r.cancel(ctx, name, cancel)
+		if err != nil {
+			responder.Error(err)
+			return
+		}
+		responder.Object(http.StatusOK, list)
+	}), nil
+}
+
+// NewConnectOptions returns no options, the request is read from the body.
+func (r *BuildConfigCancelREST) NewConnectOptions() (runtime.Object, bool, string) {
+	return nil, false, ""
+}
+
+// ConnectMethods returns POST, the only supported cancel method.
+func (r *BuildConfigCancelREST) ConnectMethods() []string {
+	return []string{"POST"}
+}
+
+func (r *BuildConfigCancelREST) ProducesObject(verb string) interface{} {
+	// for documentation purposes
+	return buildv1.BuildList{}
+}
+
+func (r *BuildConfigCancelREST) ProducesMIMETypes(verb string) []string {
+	return nil // no additional mime types
+}
+
+// cancel cancels the builds of the BuildConfig name selected by the request, and returns them.
+func (r *BuildConfigCancelREST) cancel(ctx context.Context, name string, cancel *BuildConfigCancelRequest) (*buildapi.BuildList, error) {
+	errs := validateReason(cancel.Reason, field.NewPath("reason"))
+	phases := sets.New[buildapi.BuildPhase]()
+	for i, phase := range cancel.Phases {
+		if !sets.New(cancellablePhases...).Has(phase) {
+			errs = append(errs, field.NotSupported(field.NewPath("phases").Index(i), phase, cancellablePhasesNames()))
+			continue
+		}
+		phases.Insert(buildapi.BuildPhase(phase))
+	}
+	if len(cancel.Phases) == 0 {
+		for _, phase := range cancellablePhases {
+			phases.Insert(buildapi.BuildPhase(phase))
+		}
+	}
+	selector, err := labels.Parse(cancel.LabelSelector)
+	if err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("labelSelector"), cancel.LabelSelector, err.Error()))
+	}
+	if len(errs) > 0 {
+		return nil, kerrors.NewInvalid(build.Kind("BuildConfigCancelRequest"), name, errs)
+	}
+
+	// the builds of the BuildConfig are labelled with its name, possibly truncated
+	requirements, _ := selector.Requirements()
+	selector = labels.SelectorFromSet(labels.Set{buildv1.BuildConfigLabel: buildgenerator.LabelValue(name)}).Add(requirements...)
+	obj, err := r.builds.List(ctx, &metainternalversion.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	list := &buildapi.BuildList{}
+	var cancelErrs []error
+	for _, b := range obj.(*buildapi.BuildList).Items {
+		if b.Status.Config == nil || b.Status.Config.Name != name || !phases.Has(b.Status.Phase) {
+			continue
+		}
+		cancelled, err := cancelBuild(ctx, r.builds, b.Name, cancel.Reason)
+		switch {
+		case err == errBuildComplete || kerrors.IsNotFound(err):
+		case err != nil:
+			cancelErrs = append(cancelErrs, fmt.Errorf("could not cancel build %s: %v", b.Name, err))
+		default:
+			list.Items = append(list.Items, *cancelled)
+		}
+	}
+	auditCancelledBuilds(ctx, list)
+	if len(cancelErrs) > 0 {
+		return nil, kerrors.NewInternalError(utilerrors.NewAggregate(cancelErrs))
+	}
+	klog.V(4).Infof("Cancelled %d builds of BuildConfig %s/%s", len(list.Items), apirequest.NamespaceValue(ctx), name)
+	return list, nil
+}
+
+// cancelBuild marks the build name as cancelled, with the reason and the user of ctx. Builds
+// which are already cancelled are returned as is, cancelling a complete build returns
+// errBuildComplete.
+func cancelBuild(ctx context.Context, builds Storage, name, reason string) (*buildapi.Build, error) {
+	cancelledBy := ""
+	if user, ok := apirequest.UserFrom(ctx); ok {
+		cancelledBy = user.GetName()
+	}
+	obj, _, err := builds.Update(ctx, name, rest.DefaultUpdatedObjectInfo(nil, func(ctx context.Context, _, oldObj runtime.Object) (runtime.Object, error) {
+		b := oldObj.(*buildapi.Build).DeepCopy()
+		if buildinternalhelpers.IsBuildComplete(b) {
+			return nil, errBuildComplete
+		}
+		if b.Status.Cancelled {
+			return b, nil
+		}
+		b.Status.Cancelled = true
+		if b.Annotations == nil {
+			b.Annotations = map[string]string{}
+		}
+		if len(reason) > 0 {
+			b.Annotations[buildapi.BuildCancelReasonAnnotation] = reason
+		}
+		if len(cancelledBy) > 0 {
+			b.Annotations[buildapi.BuildCancelledByAnnotation] = cancelledBy
+		}
+		return b, nil
+	}), rest.ValidateAllObjectFunc, rest.ValidateAllObjectUpdateFunc, false, &metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*buildapi.Build), nil
+}
+
+// decodeCancelRequest decodes the optional body of a cancel request into cancel.
+func decodeCancelRequest(req *http.Request, cancel interface{}) error {
+	defer req.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxCancelRequestBytes))
+	if err != nil {
+		return kerrors.NewBadRequest(fmt.Sprintf("could not read the cancel request: %v", err))
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, cancel); err != nil {
+		return kerrors.NewBadRequest(fmt.Sprintf("invalid cancel request: %v", err))
+	}
+	return nil
+}
+
+func validateReason(reason string, fldPath *field.Path) field.ErrorList {
+	if len(reason) > maxCancelReasonLength {
+		return field.ErrorList{field.TooLong(fldPath, "", maxCancelReasonLength)}
+	}
+	return nil
+}
+
+// auditCancelledBuilds records the builds cancelled by the request of ctx in its audit event.
+func auditCancelledBuilds(ctx context.Context, list *buildapi.BuildList) {
+	names := make([]string, 0, len(list.Items))
+	for _, b := range list.Items {
+		names = append(names, b.Name)
+	}
+	audit.AddAuditAnnotation(ctx, cancelledBuildsAuditAnnotation, strings.Join(names, ","))
+}
+
+func cancellablePhasesNames() []string {
+	names := make([]string, 0, len(cancellablePhases))
+	for _, phase := range cancellablePhases {
+		names = append(names, string(phase))
+	}
+	return names
+}
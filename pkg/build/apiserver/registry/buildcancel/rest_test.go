@@ -0,0 +1,175 @@
+package buildcancel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	"github.com/openshift/api/build"
+	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+)
+
+type fakeStorage struct {
+	builds map[string]*buildapi.Build
+}
+
+func (s *fakeStorage) List(ctx context.Context, options *metainternalversion.ListOptions) (runtime.Object, error) {
+	list := &buildapi.BuildList{}
+	for _, b := range s.builds {
+		if options.LabelSelector.Matches(labels.Set(b.Labels)) {
+			list.Items = append(list.Items, *b)
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list, nil
+}
+
+func (s *fakeStorage) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	old, ok := s.builds[name]
+	if !ok {
+		return nil, false, kerrors.NewNotFound(build.Resource("builds"), name)
+	}
+	obj, err := objInfo.UpdatedObject(ctx, old)
+	if err != nil {
+		return nil, false, err
+	}
+	s.builds[name] = obj.(*buildapi.Build)
+	return obj, false, nil
+}
+
+type fakeResponder struct {
+	statusCode int
+	object     runtime.Object
+	err        error
+}
+
+func (r *fakeResponder) Object(statusCode int, obj runtime.Object) {
+	r.statusCode = statusCode
+	r.object = obj
+}
+
+func (r *fakeResponder) Error(err error) {
+	r.err = err
+}
+
+func newBuild(name, config string, phase buildapi.BuildPhase, buildLabels map[string]string) *buildapi.Build {
+	if buildLabels == nil {
+		buildLabels = map[string]string{}
+	}
+	buildLabels[buildv1.BuildConfigLabel] = config
+	return &buildapi.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: buildLabels},
+		Status:     buildapi.BuildStatus{Phase: phase, Config: &kapi.ObjectReference{Name: config}},
+	}
+}
+
+func serve(t *testing.T, storage rest.Connecter, name, body string) *fakeResponder {
+	ctx := apirequest.WithUser(apirequest.WithNamespace(apirequest.NewContext(), "ns"), &user.DefaultInfo{Name: "alice"})
+	responder := &fakeResponder{}
+	handler, err := storage.Connect(ctx, name, nil, responder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cancel", strings.NewReader(body)))
+	return responder
+}
+
+func TestCancelBuild(t *testing.T) {
+	storage := &fakeStorage{builds: map[string]*buildapi.Build{
+		"app-1": newBuild("app-1", "app", buildapi.BuildPhaseRunning, nil),
+		"app-2": newBuild("app-2", "app", buildapi.BuildPhaseComplete, nil),
+	}}
+	cancel := NewBuildStorage(storage)
+
+	responder := serve(t, cancel, "app-1", `{"reason": "superseded"}`)
+	if responder.err != nil || responder.statusCode != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", responder.statusCode, responder.err)
+	}
+	if list := responder.object.(*buildapi.BuildList); len(list.Items) != 1 || list.Items[0].Name != "app-1" {
+		t.Errorf("expected the cancelled build, got %#v", list)
+	}
+	b := storage.builds["app-1"]
+	if !b.Status.Cancelled || b.Annotations[buildapi.BuildCancelReasonAnnotation] != "superseded" || b.Annotations[buildapi.BuildCancelledByAnnotation] != "alice" {
+		t.Errorf("unexpected cancelled build %#v", b)
+	}
+
+	if responder := serve(t, cancel, "app-2", ""); !kerrors.IsConflict(responder.err) || storage.builds["app-2"].Status.Cancelled {
+		t.Errorf("expected a conflict cancelling a complete build, got %v", responder.err)
+	}
+	if responder := serve(t, cancel, "app-3", ""); !kerrors.IsNotFound(responder.err) {
+		t.Errorf("expected a missing build not to be found, got %v", responder.err)
+	}
+	if responder := serve(t, cancel, "app-1", `{"reason": "`+strings.Repeat("x", maxCancelReasonLength+1)+`"}`); !kerrors.IsInvalid(responder.err) {
+		t.Errorf("expected a long reason to be invalid, got %v", responder.err)
+	}
+}
+
+func TestCancelBuildConfigBuilds(t *testing.T) {
+	newStorage := func() *fakeStorage {
+		return &fakeStorage{builds: map[string]*buildapi.Build{
+			"app-1":   newBuild("app-1", "app", buildapi.BuildPhaseNew, map[string]string{"pr": "1"}),
+			"app-2":   newBuild("app-2", "app", buildapi.BuildPhasePending, nil),
+			"app-3":   newBuild("app-3", "app", buildapi.BuildPhaseRunning, map[string]string{"pr": "1"}),
+			"app-4":   newBuild("app-4", "app", buildapi.BuildPhaseComplete, map[string]string{"pr": "1"}),
+			"other-1": newBuild("other-1", "other", buildapi.BuildPhaseNew, nil),
+		}}
+	}
+	tests := []struct {
+		name      string
+		body      string
+		cancelled []string
+		invalid   bool
+	}{
+		{name: "all the builds which did not complete", cancelled: []string{"app-1", "app-2", "app-3"}},
+		{name: "by phase", body: `{"phases": ["New", "Pending"]}`, cancelled: []string{"app-1", "app-2"}},
+		{name: "by label", body: `{"labelSelector": "pr=1"}`, cancelled: []string{"app-1", "app-3"}},
+		{name: "by phase and label", body: `{"phases": ["Running"], "labelSelector": "pr=1"}`, cancelled: []string{"app-3"}},
+		{name: "complete phase", body: `{"phases": ["Complete"]}`, invalid: true},
+		{name: "invalid selector", body: `{"labelSelector": "pr in"}`, invalid: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := newStorage()
+			responder := serve(t, NewBuildConfigStorage(storage), "app", tc.body)
+			if tc.invalid {
+				if !kerrors.IsInvalid(responder.err) {
+					t.Errorf("expected an invalid request, got %v", responder.err)
+				}
+				return
+			}
+			if responder.err != nil {
+				t.Fatal(responder.err)
+			}
+			var names []string
+			for _, b := range responder.object.(*buildapi.BuildList).Items {
+				names = append(names, b.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.cancelled, ",") {
+				t.Errorf("expected builds %v to be cancelled, got %v", tc.cancelled, names)
+			}
+			for name, b := range storage.builds {
+				expected := false
+				for _, cancelled := range tc.cancelled {
+					expected = expected || cancelled == name
+				}
+				if b.Status.Cancelled != expected {
+					t.Errorf("expected build %s to be cancelled: %t", name, expected)
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package etcd
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/generic/registry"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/util/dryrun"
 	"k8s.io/kubernetes/pkg/printers"
 	printerstorage "k8s.io/kubernetes/pkg/printers/storage"
 
@@ -33,7 +35,8 @@ func (r *REST) ShortNames() []string {
 }
 
 // NewREST returns a RESTStorage object that will work against BuildConfig.
-func NewREST(optsGetter generic.RESTOptionsGetter) (*REST, error) {
+// If deleted is set, it is called with every deleted BuildConfig.
+func NewREST(optsGetter generic.RESTOptionsGetter, deleted func(buildConfig *buildapi.BuildConfig)) (*REST, error) {
 	store := &registry.Store{
 		NewFunc:                   func() runtime.Object { return &buildapi.BuildConfig{} },
 		NewListFunc:               func() runtime.Object { return &buildapi.BuildConfigList{} },
@@ -46,6 +49,13 @@ func NewREST(optsGetter generic.RESTOptionsGetter) (*REST, error) {
 		UpdateStrategy: buildconfig.GroupStrategy,
 		DeleteStrategy: buildconfig.GroupStrategy,
 	}
+	if deleted != nil {
+		store.AfterDelete = func(obj runtime.Object, options *metav1.DeleteOptions) {
+			if buildConfig, ok := obj.(*buildapi.BuildConfig); ok && !dryrun.IsDryRun(options.DryRun) {
+				deleted(buildConfig)
+			}
+		}
+	}
 
 	options := &generic.StoreOptions{RESTOptions: optsGetter}
 	if err := store.CompleteWithOptions(options); err != nil {
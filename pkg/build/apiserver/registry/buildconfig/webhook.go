@@ -4,19 +4,24 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"k8s.io/klog/v2"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	kubetypedclient "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
 	kapi "k8s.io/kubernetes/pkg/apis/core"
 
 	"github.com/openshift/api/build"
@@ -46,12 +51,21 @@ type WebHook struct {
 	buildConfigClient buildclienttyped.BuildConfigsGetter
 	secretsClient     kubetypedclient.SecretsGetter
 	instantiator      buildclienttyped.BuildConfigsGetter
+	buildClient       buildclienttyped.BuildsGetter
 	plugins           map[string]webhook.Plugin
+	rejections        *rejectionLimiter
+	sarClient         authorizationclient.SubjectAccessReviewInterface
+	deliveries        *webhook.DeliveryLog
 }
 
-// NewWebHookREST returns the webhook handler
-func NewWebHookREST(buildConfigClient buildclienttyped.BuildV1Interface, secretsClient kubetypedclient.SecretsGetter, groupVersion schema.GroupVersion, plugins map[string]webhook.Plugin) *WebHook {
-	return newWebHookREST(buildConfigClient, secretsClient, groupVersion, plugins)
+// NewWebHookREST returns the webhook handler. The deliveries of the webhooks are recorded in
+// deliveries, if set.
+func NewWebHookREST(buildConfigClient buildclienttyped.BuildV1Interface, secretsClient kubetypedclient.SecretsGetter, sarClient authorizationclient.SubjectAccessReviewInterface, deliveries *webhook.DeliveryLog, groupVersion schema.GroupVersion, plugins map[string]webhook.Plugin) *WebHook {
+	hook := newWebHookREST(buildConfigClient, secretsClient, groupVersion, plugins)
+	hook.buildClient = buildConfigClient
+	hook.sarClient = sarClient
+	hook.deliveries = deliveries
+	return hook
 }
 
 // this supports simple unit testing
@@ -63,6 +77,7 @@ func newWebHookREST(buildConfigClient buildclienttyped.BuildConfigsGetter, secre
 		instantiator:      buildConfigClient,
 		secretsClient:     secretsClient,
 		plugins:           plugins,
+		rejections:        newRejectionLimiter(),
 	}
 }
 
@@ -85,6 +100,10 @@ func (h *WebHook) Connect(ctx context.Context, name string, options runtime.Obje
 		buildConfigClient: h.buildConfigClient,
 		secretsClient:     h.secretsClient,
 		instantiator:      h.instantiator,
+		buildClient:       h.buildClient,
+		rejections:        h.rejections,
+		sarClient:         h.sarClient,
+		deliveries:        h.deliveries,
 	}, nil
 }
 
@@ -109,6 +128,10 @@ type WebHookHandler struct {
 	buildConfigClient buildclienttyped.BuildConfigsGetter
 	secretsClient     kubetypedclient.SecretsGetter
 	instantiator      buildclienttyped.BuildConfigsGetter
+	buildClient       buildclienttyped.BuildsGetter
+	rejections        *rejectionLimiter
+	sarClient         authorizationclient.SubjectAccessReviewInterface
+	deliveries        *webhook.DeliveryLog
 }
 
 // ServeHTTP implements the standard http.Handler
@@ -123,6 +146,9 @@ func (h *WebHookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // ProcessWebHook does the actual work of processing the webhook request
 func (w *WebHookHandler) ProcessWebHook(writer http.ResponseWriter, req *http.Request, ctx context.Context, name, subpath string) error {
 	parts := strings.Split(strings.TrimPrefix(subpath, "/"), "/")
+	if len(parts) == 1 && parts[0] == replayPath {
+		return w.replay(writer, req, ctx, name)
+	}
 	if len(parts) != 2 {
 		return errors.NewBadRequest(fmt.Sprintf("unexpected hook subpath %s", subpath))
 	}
@@ -140,29 +166,38 @@ func (w *WebHookHandler) ProcessWebHook(writer http.ResponseWriter, req *http.Re
 		return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept your secret", hookType, name))
 	}
 
+	delivery := &webhook.Delivery{
+		ID:        string(uuid.NewUUID()),
+		Timestamp: metav1.Now(),
+		Provider:  hookType,
+		Event:     webhook.EventType(req),
+	}
+	err = w.processDelivery(writer, req, ctx, config, secret, hookType, plugin, delivery)
+	w.recordDelivery(ctx, config, delivery, err)
+	return err
+}
+
+// processDelivery processes a webhook request for config, and records the build it started in
+// delivery.
+func (w *WebHookHandler) processDelivery(writer http.ResponseWriter, req *http.Request, ctx context.Context, config *buildv1.BuildConfig, secret, hookType string, plugin webhook.Plugin, delivery *webhook.Delivery) error {
 	triggers, err := plugin.GetTriggers(config)
 	if err != nil {
-		return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept your secret", hookType, name))
+		return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept your secret", hookType, config.Name))
 	}
 
 	klog.V(4).Infof("checking secret for %q webhook trigger of buildconfig %s/%s", hookType, config.Namespace, config.Name)
 	trigger, err := webhook.CheckSecret(ctx, config.Namespace, secret, triggers, w.secretsClient)
 	if err != nil {
-		return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept your secret", hookType, name))
+		return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept your secret", hookType, config.Name))
+	}
+
+	if pullRequestPlugin, ok := plugin.(webhook.PullRequestPlugin); ok && pullRequestPlugin.IsPullRequest(req) {
+		return w.processPullRequest(writer, req, ctx, config, trigger, pullRequestPlugin, hookType, delivery)
 	}
 
 	revision, envvars, dockerStrategyOptions, proceed, err := plugin.Extract(config, trigger, req)
 	if !proceed {
-		switch err {
-		case webhook.ErrSecretMismatch, webhook.ErrHookNotEnabled:
-			return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept your secret", hookType, name))
-		case webhook.MethodNotSupported:
-			return errors.NewMethodNotSupported(build.Resource("buildconfighook"), req.Method)
-		}
-		if _, ok := err.(*errors.StatusError); !ok && err != nil {
-			return errors.NewInternalError(fmt.Errorf("hook failed: %v", err))
-		}
-		return err
+		return extractError(err, req, config.Name, hookType)
 	}
 	warning := err
 
@@ -170,16 +205,99 @@ func (w *WebHookHandler) ProcessWebHook(writer http.ResponseWriter, req *http.Re
 
 	request := &buildv1.BuildRequest{
 		TriggeredBy:           buildTriggerCauses,
-		ObjectMeta:            metav1.ObjectMeta{Name: name},
+		ObjectMeta:            metav1.ObjectMeta{Name: config.Name},
 		Revision:              revision,
 		Env:                   envvars,
 		DockerStrategyOptions: dockerStrategyOptions,
 	}
 
+	if err := w.instantiate(writer, ctx, config, request, delivery); err != nil {
+		return err
+	}
+	return warning
+}
+
+// processPullRequest builds the pull request of a webhook request, or cancels its builds when it
+// is closed. The builds push to the output set by BuildConfigWebHookPullRequestOutputAnnotation,
+// never to the output of config.
+func (w *WebHookHandler) processPullRequest(writer http.ResponseWriter, req *http.Request, ctx context.Context, config *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, plugin webhook.PullRequestPlugin, hookType string, delivery *webhook.Delivery) error {
+	revision, pullRequest, proceed, err := plugin.ExtractPullRequest(config, trigger, req)
+	if !proceed {
+		return extractError(err, req, config.Name, hookType)
+	}
+	if pullRequest.Closed {
+		return w.cancelPullRequestBuilds(ctx, config, pullRequest)
+	}
+
+	request := &buildv1.BuildRequest{
+		TriggeredBy: webhook.GeneratePullRequestTriggerInfo(revision, hookType, pullRequest),
+		ObjectMeta: metav1.ObjectMeta{
+			Name: config.Name,
+			Labels: map[string]string{
+				buildapi.BuildPullRequestLabel: strconv.Itoa(pullRequest.Number),
+			},
+			Annotations: map[string]string{
+				buildapi.BuildSourceRefAnnotation:               pullRequest.Ref,
+				buildapi.BuildPullRequestSourceBranchAnnotation: pullRequest.SourceBranch,
+				buildapi.BuildPullRequestTargetBranchAnnotation: pullRequest.TargetBranch,
+			},
+		},
+		Revision: revision,
+	}
+	return w.instantiate(writer, ctx, config, request, delivery)
+}
+
+// cancelPullRequestBuilds cancels the builds of config for a closed pull request which did not
+// start, if config opted in.
+func (w *WebHookHandler) cancelPullRequestBuilds(ctx context.Context, config *buildv1.BuildConfig, pullRequest *webhook.PullRequest) error {
+	if !webhook.CancelsClosedPullRequests(config) || w.buildClient == nil {
+		return webhook.NewWarning(fmt.Sprintf("skipping build. Pull request #%d is closed", pullRequest.Number))
+	}
+	builds := w.buildClient.Builds(config.Namespace)
+	list, err := builds.List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{buildapi.BuildPullRequestLabel: strconv.Itoa(pullRequest.Number)}).String(),
+	})
+	if err != nil {
+		return errors.NewInternalError(fmt.Errorf("could not list the builds of pull request #%d: %v", pullRequest.Number, err))
+	}
+	cancelled := 0
+	for _, b := range list.Items {
+		if b.Status.Config == nil || b.Status.Config.Name != config.Name {
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest, err := builds.Get(ctx, b.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if latest.Status.Cancelled || (latest.Status.Phase != buildv1.BuildPhaseNew && latest.Status.Phase != buildv1.BuildPhasePending) {
+				return errAlreadyStarted
+			}
+			latest.Status.Cancelled = true
+			_, err = builds.Update(ctx, latest, metav1.UpdateOptions{})
+			return err
+		})
+		switch {
+		case err == errAlreadyStarted || errors.IsNotFound(err):
+		case err != nil:
+			return errors.NewInternalError(fmt.Errorf("could not cancel build %s of pull request #%d: %v", b.Name, pullRequest.Number, err))
+		default:
+			cancelled++
+		}
+	}
+	klog.V(2).Infof("Cancelled %d builds of BuildConfig %s/%s for the closed pull request #%d", cancelled, config.Namespace, config.Name, pullRequest.Number)
+	return webhook.NewWarning(fmt.Sprintf("cancelled %d builds of the closed pull request #%d", cancelled, pullRequest.Number))
+}
+
+// instantiate instantiates config for a webhook request, records the new build in delivery and
+// writes it to the response.
+func (w *WebHookHandler) instantiate(writer http.ResponseWriter, ctx context.Context, config *buildv1.BuildConfig, request *buildv1.BuildRequest, delivery *webhook.Delivery) error {
+	delivery.Request = webhook.NewDeliveryRequest(request)
 	newBuild, err := w.instantiator.BuildConfigs(config.Namespace).Instantiate(ctx, config.Namespace, request, metav1.CreateOptions{})
 	if err != nil {
 		return errors.NewInternalError(fmt.Errorf("could not generate a build: %v", err))
 	}
+	delivery.Build = newBuild.Name
 
 	// Send back the build name so that the client can alert the user.
 	if newBuildEncoded, err := runtime.Encode(webhookEncodingCodecFactory.LegacyCodec(w.groupVersion), newBuild); err != nil {
@@ -187,6 +305,26 @@ func (w *WebHookHandler) ProcessWebHook(writer http.ResponseWriter, req *http.Re
 	} else {
 		writer.Write(newBuildEncoded)
 	}
+	return nil
+}
 
-	return warning
+// errAlreadyStarted is returned when the build of a closed pull request cannot be cancelled
+// anymore.
+var errAlreadyStarted = fmt.Errorf("the build already started")
+
+// extractError converts the error of a webhook plugin which did not proceed with a request.
+func extractError(err error, req *http.Request, name, hookType string) error {
+	switch err {
+	case webhook.ErrSecretMismatch, webhook.ErrHookNotEnabled:
+		return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept your secret", hookType, name))
+	case webhook.ErrSignatureMismatch:
+		webhook.RecordSignatureRejection(hookType)
+		return errors.NewUnauthorized(fmt.Sprintf("the webhook %q for %q did not accept the signature of the payload", hookType, name))
+	case webhook.MethodNotSupported:
+		return errors.NewMethodNotSupported(build.Resource("buildconfighook"), req.Method)
+	}
+	if _, ok := err.(*errors.StatusError); !ok && err != nil {
+		return errors.NewInternalError(fmt.Errorf("hook failed: %v", err))
+	}
+	return err
 }
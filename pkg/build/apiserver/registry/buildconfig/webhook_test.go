@@ -19,6 +19,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 	clientesting "k8s.io/client-go/testing"
 	kapi "k8s.io/kubernetes/pkg/apis/core"
 
@@ -27,6 +28,7 @@ import (
 	buildfake "github.com/openshift/client-go/build/clientset/versioned/fake"
 	buildclientv1 "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
 
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/apiserverbuildutil"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook/bitbucket"
@@ -164,9 +166,11 @@ func newStorage() (*WebHook, *buildConfigInstantiator, *buildfake.Clientset) {
 		},
 		"errsecret": &plugin{Err: webhook.ErrSecretMismatch},
 		"errhook":   &plugin{Err: webhook.ErrHookNotEnabled},
+		"errsig":    &plugin{Err: webhook.ErrSignatureMismatch},
 		"err":       &plugin{Err: fmt.Errorf("test error")},
 	}
 	hook := newWebHookREST(fakeBuildClient, nil, buildv1.SchemeGroupVersion, plugins)
+	hook.deliveries = webhook.NewDeliveryLog(kubefake.NewSimpleClientset().CoreV1(), "deliveries")
 
 	return hook, bci, fakeBuildClient.(*fakeBuildConfigClient).fakeclient
 }
@@ -229,6 +233,15 @@ func TestConnectWebHook(t *testing.T) {
 			ErrFn:       kerrors.IsUnauthorized,
 			Instantiate: false,
 		},
+		"hook returns unauthorized for bad signature": {
+			Name: "test",
+			Path: "secret/errsig",
+			Obj:  &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			ErrFn: func(err error) bool {
+				return kerrors.IsUnauthorized(err) && strings.Contains(err.Error(), "did not accept the signature of the payload")
+			},
+			Instantiate: false,
+		},
 		"hook returns unauthorized for missing build config": {
 			Name:        "test",
 			Path:        "secret/errhook",
@@ -423,7 +436,7 @@ func TestParseUrlError(t *testing.T) {
 	responder := &fakeResponder{}
 	client := newBuildConfigClient(&okBuildConfigInstantiator{})
 	handler, _ := newWebHookREST(client, nil, buildv1.SchemeGroupVersion,
-		map[string]webhook.Plugin{"github": github.New(), "gitlab": gitlab.New(), "bitbucket": bitbucket.New()}).
+		map[string]webhook.Plugin{"github": github.New(nil), "gitlab": gitlab.New(nil), "bitbucket": bitbucket.New()}).
 		Connect(apirequest.WithNamespace(apirequest.NewDefaultContext(), testBuildConfig.Namespace), "build100", &kapi.PodProxyOptions{Path: ""}, responder)
 	server := httptest.NewServer(handler)
 	defer server.Close()
@@ -667,3 +680,84 @@ func TestGeneratedBuildTriggerInfoBitbucketWebHook(t *testing.T) {
 		}
 	}
 }
+
+type pullRequestPlugin struct {
+	plugin
+	Revision    *buildv1.SourceRevision
+	PullRequest *webhook.PullRequest
+}
+
+func (p *pullRequestPlugin) IsPullRequest(req *http.Request) bool {
+	return req.Header.Get("X-GitHub-Event") == "pull_request"
+}
+
+func (p *pullRequestPlugin) ExtractPullRequest(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (*buildv1.SourceRevision, *webhook.PullRequest, bool, error) {
+	return p.Revision, p.PullRequest, true, nil
+}
+
+func TestProcessPullRequest(t *testing.T) {
+	config := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	pullRequestBuild := func(name string, pullRequest string, phase buildv1.BuildPhase) *buildv1.Build {
+		return &buildv1.Build{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{buildapi.BuildPullRequestLabel: pullRequest}},
+			Status:     buildv1.BuildStatus{Phase: phase, Config: &corev1.ObjectReference{Name: "test"}},
+		}
+	}
+	bci := &buildConfigInstantiator{}
+	client := newBuildConfigClient(bci, config,
+		pullRequestBuild("test-1", "42", buildv1.BuildPhaseNew),
+		pullRequestBuild("test-2", "42", buildv1.BuildPhaseRunning),
+		pullRequestBuild("test-3", "7", buildv1.BuildPhasePending),
+	)
+	plugin := &pullRequestPlugin{
+		Revision:    &buildv1.SourceRevision{Git: &buildv1.GitSourceRevision{Commit: "abcd"}},
+		PullRequest: &webhook.PullRequest{Number: 42, SourceBranch: "feature", TargetBranch: "main", Ref: "refs/pull/42/head"},
+	}
+	hook := newWebHookREST(client, nil, buildv1.SchemeGroupVersion, map[string]webhook.Plugin{"github": plugin})
+	builds := client.(*fakeBuildConfigClient).fakeclient.BuildV1()
+	hook.buildClient = builds
+	process := func() error {
+		responder := &fakeResponder{}
+		handler, err := hook.Connect(apirequest.WithNamespace(apirequest.NewDefaultContext(), "default"), "test", &kapi.PodProxyOptions{Path: "secret/github"}, responder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return responder.err
+	}
+
+	if err := process(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	request := bci.Request
+	if request == nil || request.Labels[buildapi.BuildPullRequestLabel] != "42" || request.Annotations[buildapi.BuildSourceRefAnnotation] != "refs/pull/42/head" ||
+		request.Annotations[buildapi.BuildPullRequestTargetBranchAnnotation] != "main" || request.Revision.Git.Commit != "abcd" {
+		t.Fatalf("unexpected build request %#v", request)
+	}
+	if cause := request.TriggeredBy[0]; cause.GitHubWebHook == nil || cause.Message != "GitHub WebHook for pull request #42 from feature into main" {
+		t.Errorf("unexpected trigger cause %#v", cause)
+	}
+
+	// closing the pull request does not cancel its builds unless the BuildConfig opts in
+	bci.Request = nil
+	plugin.PullRequest = &webhook.PullRequest{Number: 42, Closed: true}
+	if err := process(); err == nil || err.Error() != "skipping build. Pull request #42 is closed" || bci.Request != nil {
+		t.Errorf("expected the closed pull request to be skipped, got %v", err)
+	}
+
+	config.Annotations = map[string]string{buildapi.BuildConfigWebHookPullRequestCancelAnnotation: "true"}
+	if _, err := client.BuildConfigs("default").Update(context.TODO(), config, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := process(); err == nil || err.Error() != "cancelled 1 builds of the closed pull request #42" {
+		t.Errorf("expected the builds of the closed pull request to be cancelled, got %v", err)
+	}
+	for name, cancelled := range map[string]bool{"test-1": true, "test-2": false, "test-3": false} {
+		build, err := builds.Builds("default").Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil || build.Status.Cancelled != cancelled {
+			t.Errorf("expected build %s to be cancelled: %t, got %v", name, cancelled, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package buildconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	buildclienttyped "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+
+	apiserverrest "github.com/openshift/openshift-apiserver/pkg/apiserver/rest"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
+)
+
+const (
+	// rejectionRecordInterval is the minimum time between two rejected deliveries recorded for a
+	// BuildConfig, so that callers who do not know the secret of its webhooks cannot flood its
+	// record with updates.
+	rejectionRecordInterval = time.Minute
+	// maxRejectionLimits bounds the number of BuildConfigs whose last recorded rejection is
+	// remembered.
+	maxRejectionLimits = 10000
+)
+
+// recordDelivery records the outcome err of a webhook delivery for config. Failures to record it
+// are only logged, they do not change the response to the delivery.
+func (w *WebHookHandler) recordDelivery(ctx context.Context, config *buildv1.BuildConfig, delivery *webhook.Delivery, err error) {
+	switch status, ok := err.(*errors.StatusError); {
+	case err == nil:
+		delivery.Result = webhook.DeliverySkipped
+	case ok && status.ErrStatus.Code == 200:
+		delivery.Result = webhook.DeliverySkipped
+		delivery.Reason = status.ErrStatus.Message
+	case errors.IsUnauthorized(err):
+		delivery.Result = webhook.DeliveryRejected
+		delivery.Reason = err.Error()
+	default:
+		delivery.Result = webhook.DeliveryFailed
+		delivery.Reason = err.Error()
+	}
+	if len(delivery.Build) > 0 && delivery.Result == webhook.DeliverySkipped {
+		delivery.Result = webhook.DeliveryTriggered
+	}
+	if delivery.Result == webhook.DeliveryRejected && !w.rejections.allow(config.UID, delivery.Timestamp.Time) {
+		klog.V(4).Infof("Not recording the rejected %q webhook delivery for BuildConfig %s/%s", delivery.Provider, config.Namespace, config.Name)
+		return
+	}
+
+	if err := w.deliveries.Record(ctx, config, *delivery); err != nil {
+		utilruntime.HandleError(fmt.Errorf("could not record the %q webhook delivery for BuildConfig %s/%s: %v", delivery.Provider, config.Namespace, config.Name, err))
+	}
+}
+
+// rejectionLimiter limits how often the rejected deliveries of a BuildConfig are recorded.
+type rejectionLimiter struct {
+	lock sync.Mutex
+	last map[types.UID]time.Time
+}
+
+func newRejectionLimiter() *rejectionLimiter {
+	return &rejectionLimiter{last: map[types.UID]time.Time{}}
+}
+
+// allow returns true if a rejected delivery received at now is recorded for the BuildConfig uid.
+func (l *rejectionLimiter) allow(uid types.UID, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if last, ok := l.last[uid]; ok && now.Sub(last) < rejectionRecordInterval {
+		return false
+	}
+	if len(l.last) >= maxRejectionLimits {
+		for key, last := range l.last {
+			if now.Sub(last) >= rejectionRecordInterval {
+				delete(l.last, key)
+			}
+		}
+	}
+	if len(l.last) < maxRejectionLimits {
+		l.last[uid] = now
+	}
+	return true
+}
+
+// WebHookDeliveryList is the response of the webhookdeliveries subresource of a BuildConfig.
+type WebHookDeliveryList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Items are the webhook deliveries recorded for the BuildConfig, the most recent last.
+	Items []webhook.Delivery `json:"items"`
+}
+
+// DeliveriesREST serves the webhook deliveries recorded for BuildConfigs.
+type DeliveriesREST struct {
+	buildConfigClient buildclienttyped.BuildConfigsGetter
+	deliveries        *webhook.DeliveryLog
+}
+
+var _ rest.Getter = &DeliveriesREST{}
+var _ rest.Storage = &DeliveriesREST{}
+
+// NewDeliveriesREST returns the storage of the webhookdeliveries subresource of BuildConfigs,
+// serving the deliveries recorded in deliveries. No deliveries are served if it is nil.
+func NewDeliveriesREST(buildConfigClient buildclienttyped.BuildConfigsGetter, deliveries *webhook.DeliveryLog) *DeliveriesREST {
+	return &DeliveriesREST{buildConfigClient: buildConfigClient, deliveries: deliveries}
+}
+
+// New returns a BuildConfig, the webhook deliveries are streamed as a WebHookDeliveryList.
+func (r *DeliveriesREST) New() runtime.Object {
+	return &buildapi.BuildConfig{}
+}
+
+func (r *DeliveriesREST) Destroy() {}
+
+// Get returns the webhook deliveries recorded for the BuildConfig name.
+func (r *DeliveriesREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	config, err := r.buildConfigClient.BuildConfigs(apirequest.NamespaceValue(ctx)).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := r.deliveries.Deliveries(ctx, config)
+	if err != nil {
+		return nil, errors.NewInternalError(fmt.Errorf("could not read the webhook deliveries of %s/%s: %v", config.Namespace, config.Name, err))
+	}
+	list := &WebHookDeliveryList{
+		TypeMeta: metav1.TypeMeta{Kind: "WebHookDeliveryList", APIVersion: buildv1.GroupVersion.String()},
+		Items:    deliveries,
+	}
+	if list.Items == nil {
+		list.Items = []webhook.Delivery{}
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	return &apiserverrest.PassThroughStreamer{
+		In:          io.NopCloser(bytes.NewReader(data)),
+		ContentType: "application/json",
+	}, nil
+}
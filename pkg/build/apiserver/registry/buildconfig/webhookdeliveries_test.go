@@ -0,0 +1,82 @@
+package buildconfig
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	buildv1 "github.com/openshift/api/build/v1"
+
+	apiserverrest "github.com/openshift/openshift-apiserver/pkg/apiserver/rest"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
+)
+
+func TestRecordWebHookDeliveries(t *testing.T) {
+	hook, _, client := newStorage()
+	config := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"}}
+	if _, err := client.BuildV1().BuildConfigs("default").Create(context.TODO(), config, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := apirequest.WithNamespace(apirequest.NewDefaultContext(), "default")
+	for _, path := range []string{"secret/okenv", "secret/errsecret", "secret/errhook", "secret/err"} {
+		handler, err := hook.Connect(ctx, "test", &kapi.PodProxyOptions{Path: path}, &fakeResponder{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-GitHub-Event", "push")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	obj, err := NewDeliveriesREST(client.BuildV1(), hook.deliveries).Get(ctx, "test", &metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, _, contentType, err := obj.(*apiserverrest.PassThroughStreamer).InputStream(ctx, "v1", "*/*")
+	if err != nil || contentType != "application/json" {
+		t.Fatalf("unexpected stream %q: %v", contentType, err)
+	}
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := &WebHookDeliveryList{}
+	if err := json.Unmarshal(data, list); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second rejection is not recorded within the rejection interval
+	expected := []struct {
+		provider string
+		result   webhook.DeliveryResult
+		build    string
+	}{
+		{"okenv", webhook.DeliveryTriggered, "test"},
+		{"errsecret", webhook.DeliveryRejected, ""},
+		{"err", webhook.DeliveryFailed, ""},
+	}
+	if list.Kind != "WebHookDeliveryList" || len(list.Items) != len(expected) {
+		t.Fatalf("unexpected deliveries %s", data)
+	}
+	for i, e := range expected {
+		delivery := list.Items[i]
+		if delivery.Provider != e.provider || delivery.Result != e.result || delivery.Build != e.build || delivery.Event != "push" || len(delivery.ID) == 0 {
+			t.Errorf("unexpected delivery %d: %#v", i, delivery)
+		}
+	}
+	// the environment of the request may hold secrets and is not recorded
+	if request := list.Items[0].Request; request == nil || strings.Contains(string(data), "bar") {
+		t.Errorf("unexpected request of the delivery: %s", data)
+	}
+	if reason := list.Items[2].Reason; reason != "Internal error occurred: hook failed: test error" {
+		t.Errorf("unexpected reason %q", reason)
+	}
+}
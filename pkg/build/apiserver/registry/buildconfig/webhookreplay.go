@@ -0,0 +1,134 @@
+package buildconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/api/build"
+	buildv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/authorization/authorizationutil"
+
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/apiserverbuildutil"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
+)
+
+const (
+	// replayPath is the subpath of the webhooks of a BuildConfig replaying one of its deliveries.
+	replayPath = "replay"
+	// replayVerb is the verb on the webhooks of a BuildConfig required to replay their deliveries,
+	// knowing the secret of a webhook does not allow it.
+	replayVerb = "replay"
+	// maxReplayRequestBytes bounds the size of the body of a replay request.
+	maxReplayRequestBytes = 4 * 1024
+)
+
+// WebHookReplayRequest is the body of a request replaying a webhook delivery.
+type WebHookReplayRequest struct {
+	// DeliveryID is the ID of the recorded delivery to replay.
+	DeliveryID string `json:"deliveryID"`
+}
+
+// replay instantiates the BuildConfig name again with the request of one of its recorded webhook
+// deliveries. The build is triggered by the causes of the delivery and by the replay. The
+// environment and the Docker strategy options of the delivery are not recorded and not replayed.
+func (w *WebHookHandler) replay(writer http.ResponseWriter, req *http.Request, ctx context.Context, name string) error {
+	if req.Method != http.MethodPost {
+		return errors.NewMethodNotSupported(build.Resource("buildconfighook"), req.Method)
+	}
+	user, err := w.authorizeReplay(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	replayRequest := &WebHookReplayRequest{}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxReplayRequestBytes))
+	if err != nil {
+		return errors.NewBadRequest(fmt.Sprintf("could not read the replay request: %v", err))
+	}
+	if err := json.Unmarshal(body, replayRequest); err != nil {
+		return errors.NewBadRequest(fmt.Sprintf("could not decode the replay request: %v", err))
+	}
+	if len(replayRequest.DeliveryID) == 0 {
+		return errors.NewBadRequest("the delivery to replay must be specified")
+	}
+
+	config, err := w.buildConfigClient.BuildConfigs(apirequest.NamespaceValue(ctx)).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	original, err := w.deliveries.Find(ctx, config, replayRequest.DeliveryID)
+	if err != nil {
+		return errors.NewInternalError(fmt.Errorf("could not read the webhook deliveries of %s/%s: %v", config.Namespace, config.Name, err))
+	}
+	if original == nil {
+		return errors.NewNotFound(build.Resource("webhookdeliveries"), replayRequest.DeliveryID)
+	}
+	if original.Request == nil {
+		return errors.NewBadRequest(fmt.Sprintf("the webhook delivery %s did not instantiate the BuildConfig and cannot be replayed", original.ID))
+	}
+
+	request := &buildv1.BuildRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        config.Name,
+			Labels:      original.Request.Labels,
+			Annotations: original.Request.Annotations,
+		},
+		Revision: original.Request.Revision,
+		TriggeredBy: append(append([]buildv1.BuildTriggerCause{}, original.Request.TriggeredBy...), buildv1.BuildTriggerCause{
+			Message: fmt.Sprintf("%s %s by %s", apiserverbuildutil.BuildTriggerCauseReplayMsg, original.ID, user),
+		}),
+	}
+	delivery := &webhook.Delivery{
+		ID:        string(uuid.NewUUID()),
+		Timestamp: metav1.Now(),
+		Provider:  original.Provider,
+		Event:     original.Event,
+		ReplayOf:  original.ID,
+	}
+	klog.V(4).Infof("%s replays the %q webhook delivery %s of buildconfig %s/%s", user, original.Provider, original.ID, config.Namespace, config.Name)
+	err = w.instantiate(writer, ctx, config, request, delivery)
+	w.recordDelivery(ctx, config, delivery, err)
+	return err
+}
+
+// authorizeReplay returns the name of the user of ctx if they may replay the webhook deliveries of
+// the BuildConfig name.
+func (w *WebHookHandler) authorizeReplay(ctx context.Context, name string) (string, error) {
+	forbidden := func(err error) error {
+		return errors.NewForbidden(build.Resource("buildconfigs/webhooks"), name, err)
+	}
+	user, ok := apirequest.UserFrom(ctx)
+	if !ok || w.sarClient == nil {
+		return "", forbidden(fmt.Errorf("the deliveries cannot be replayed"))
+	}
+	sar := authorizationutil.AddUserToSAR(user, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   apirequest.NamespaceValue(ctx),
+				Verb:        replayVerb,
+				Group:       build.GroupName,
+				Resource:    "buildconfigs",
+				Subresource: "webhooks",
+				Name:        name,
+			},
+		},
+	})
+	resp, err := w.sarClient.Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		return "", forbidden(err)
+	}
+	if !resp.Status.Allowed {
+		return "", forbidden(fmt.Errorf("user %q cannot replay the webhook deliveries", user.GetName()))
+	}
+	return user.GetName(), nil
+}
@@ -0,0 +1,90 @@
+package buildconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	buildv1 "github.com/openshift/api/build/v1"
+
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
+)
+
+func TestReplayWebHookDelivery(t *testing.T) {
+	hook, bci, client := newStorage()
+	config := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"}}
+	if _, err := client.BuildV1().BuildConfigs("default").Create(context.TODO(), config, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	kubeClient := kubefake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		sar := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "alice" && attrs.Verb == "replay" && attrs.Resource == "buildconfigs" && attrs.Subresource == "webhooks" && attrs.Name == "test"
+		return true, sar, nil
+	})
+	hook.sarClient = kubeClient.AuthorizationV1().SubjectAccessReviews()
+
+	serve := func(ctx context.Context, path, body string) error {
+		responder := &fakeResponder{}
+		handler, err := hook.Connect(ctx, "test", &kapi.PodProxyOptions{Path: path}, responder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return responder.err
+	}
+	ctx := apirequest.WithNamespace(apirequest.NewDefaultContext(), "default")
+	if err := serve(ctx, "secret/okenv", ""); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := hook.deliveries.Deliveries(context.TODO(), config)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected a recorded delivery, got %#v: %v", deliveries, err)
+	}
+	original := deliveries[0]
+	body := `{"deliveryID": "` + original.ID + `"}`
+
+	bci.Request = nil
+	if err := serve(apirequest.WithUser(ctx, &user.DefaultInfo{Name: "bob"}), "replay", body); !kerrors.IsForbidden(err) || bci.Request != nil {
+		t.Errorf("expected users without the replay permission to be forbidden, got %v", err)
+	}
+	if err := serve(ctx, "replay", body); !kerrors.IsForbidden(err) || bci.Request != nil {
+		t.Errorf("expected anonymous replays to be forbidden, got %v", err)
+	}
+
+	alice := apirequest.WithUser(ctx, &user.DefaultInfo{Name: "alice"})
+	if err := serve(alice, "replay", `{"deliveryID": "unknown"}`); !kerrors.IsNotFound(err) {
+		t.Errorf("expected unknown deliveries not to be found, got %v", err)
+	}
+	if err := serve(alice, "replay", body); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	request := bci.Request
+	if request == nil || len(request.Env) != 0 {
+		t.Fatalf("expected the build request of the delivery without its environment, got %#v", request)
+	}
+	if causes := request.TriggeredBy; len(causes) != 1 || causes[0].Message != "WebHook delivery replayed "+original.ID+" by alice" {
+		t.Errorf("unexpected trigger causes %#v", causes)
+	}
+
+	deliveries, err = hook.deliveries.Deliveries(context.TODO(), config)
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("expected the replay to be recorded, got %#v: %v", deliveries, err)
+	}
+	if replay := deliveries[1]; replay.ReplayOf != original.ID || replay.Result != webhook.DeliveryTriggered || replay.Provider != "okenv" {
+		t.Errorf("unexpected replay delivery %#v", replay)
+	}
+}
@@ -0,0 +1,243 @@
+package buildconfiginstantiate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	kvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+
+	"github.com/openshift/api/build"
+	buildv1 "github.com/openshift/api/build/v1"
+	buildtypedclient "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	v1 "github.com/openshift/openshift-apiserver/pkg/build/apis/build/v1"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/admission/strategyrestrictions"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildgenerator"
+)
+
+// BuildBatchLabel is set on the builds of a batch to the name of the batch.
+const BuildBatchLabel = "openshift.io/build.batch"
+
+// maxBatchRequestBytes bounds the size of the body of a batch request.
+const maxBatchRequestBytes = 3 * 1024 * 1024
+
+// BatchBuildRequest is the body of a request to the instantiatebatch subresource of BuildConfigs,
+// which instantiates many BuildConfigs of a namespace from the same source revision. The name the
+// subresource is requested for is the name of the batch, it is set as BuildBatchLabel on the builds.
+// Either all the BuildConfigs of a batch are instantiated or none of them: the response is either
+// the list of the new builds, or an Invalid error with the causes of the failed BuildConfigs.
+type BatchBuildRequest struct {
+	// Revision is the source revision all the builds of the batch are built from. The requests
+	// in Items must not set a different revision.
+	Revision *buildv1.SourceRevision `json:"revision,omitempty"`
+	// LabelSelector selects BuildConfigs to instantiate with a default request, in addition to
+	// the BuildConfigs of Items.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Items are the requests of the BuildConfigs to instantiate.
+	Items []buildv1.BuildRequest `json:"items,omitempty"`
+}
+
+// NewBatchStorage creates a new storage object for instantiating batches of builds.
+func NewBatchStorage(generator *buildgenerator.BuildGenerator, buildConfigClient buildtypedclient.BuildConfigsGetter, sarClient authorizationclient.SubjectAccessReviewInterface) *BatchInstantiateREST {
+	return &BatchInstantiateREST{generator: generator, buildConfigClient: buildConfigClient, sarClient: sarClient}
+}
+
+// BatchInstantiateREST instantiates many BuildConfigs at once. Connect requests are not admitted,
+// so it checks the access of the user to the strategies of the BuildConfigs like the
+// BuildByStrategy admission plugin does for the instantiate subresource.
+type BatchInstantiateREST struct {
+	generator         *buildgenerator.BuildGenerator
+	buildConfigClient buildtypedclient.BuildConfigsGetter
+	sarClient         authorizationclient.SubjectAccessReviewInterface
+}
+
+var _ rest.Connecter = &BatchInstantiateREST{}
+var _ rest.StorageMetadata = &BatchInstantiateREST{}
+
+// New creates a new build generation request
+func (r *BatchInstantiateREST) New() runtime.Object {
+	return &buildapi.BuildRequest{}
+}
+
+func (r *BatchInstantiateREST) Destroy() {}
+
+// Connect returns a handler instantiating the batch of builds named name.
+func (r *BatchInstantiateREST) Connect(ctx context.Context, name string, options runtime.Object, responder rest.Responder) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+		batch := &BatchBuildRequest{}
+		if err := json.NewDecoder(io.LimitReader(req.Body, maxBatchRequestBytes)).Decode(batch); err != nil {
+			responder.Error(errors.NewBadRequest(fmt.Sprintf("invalid batch of build requests: %v", err)))
+			return
+		}
+		builds, err := r.instantiate(ctx, name, batch)
+		if err != nil {
+			responder.Error(err)
+			return
+		}
+		responder.Object(http.StatusCreated, builds)
+	}), nil
+}
+
+// NewConnectOptions returns no options, the batch is read from the body of the request.
+func (r *BatchInstantiateREST) NewConnectOptions() (runtime.Object, bool, string) {
+	return nil, false, ""
+}
+
+// ConnectMethods returns POST, the only supported batch method.
+func (r *BatchInstantiateREST) ConnectMethods() []string {
+	return []string{"POST"}
+}
+
+func (r *BatchInstantiateREST) ProducesObject(verb string) interface{} {
+	// for documentation purposes
+	return buildv1.BuildList{}
+}
+
+func (r *BatchInstantiateREST) ProducesMIMETypes(verb string) []string {
+	return nil // no additional mime types
+}
+
+// instantiate validates the requests of the batch and instantiates their BuildConfigs.
+func (r *BatchInstantiateREST) instantiate(ctx context.Context, name string, batch *BatchBuildRequest) (*buildapi.BuildList, error) {
+	var errs field.ErrorList
+	for _, msg := range kvalidation.IsValidLabelValue(name) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), name, msg))
+	}
+	selector, err := labels.Parse(batch.LabelSelector)
+	if err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("labelSelector"), batch.LabelSelector, err.Error()))
+	}
+	if len(errs) > 0 {
+		return nil, errors.NewInvalid(build.Kind("BatchBuildRequest"), name, errs)
+	}
+
+	namespace := apirequest.NamespaceValue(ctx)
+	items := batch.Items
+	selected := map[string]*buildv1.BuildConfig{}
+	if len(batch.LabelSelector) > 0 {
+		configs, err := r.buildConfigClient.BuildConfigs(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		requested := map[string]bool{}
+		for _, item := range batch.Items {
+			requested[item.Name] = true
+		}
+		for i, config := range configs.Items {
+			selected[config.Name] = &configs.Items[i]
+			if !requested[config.Name] {
+				items = append(items, buildv1.BuildRequest{ObjectMeta: metav1.ObjectMeta{Name: config.Name}})
+			}
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.NewBadRequest("the batch does not request any BuildConfig")
+	}
+	if err := r.checkStrategies(ctx, namespace, items, selected); err != nil {
+		return nil, err
+	}
+
+	requests := make([]*buildapi.BuildRequest, 0, len(items))
+	for i := range items {
+		item := items[i].DeepCopy()
+		path := field.NewPath("items").Index(i)
+		if item.Revision != nil && batch.Revision != nil && !equality.Semantic.DeepEqual(item.Revision, batch.Revision) {
+			errs = append(errs, field.Invalid(path.Child("revision"), item.Name, "must match the revision of the batch"))
+			continue
+		}
+		if item.Revision == nil {
+			item.Revision = batch.Revision
+		}
+		if item.Labels == nil {
+			item.Labels = map[string]string{}
+		}
+		item.Labels[BuildBatchLabel] = name
+
+		request := &buildapi.BuildRequest{}
+		if err := v1.Convert_v1_BuildRequest_To_build_BuildRequest(item, request, nil); err != nil {
+			return nil, errors.NewInternalError(err)
+		}
+		if len(request.TriggeredBy) == 0 {
+			request.TriggeredBy = []buildapi.BuildTriggerCause{{Message: buildapi.BuildTriggerCauseManualMsg}}
+		}
+		objectMeta, err := meta.Accessor(request)
+		if err != nil {
+			return nil, err
+		}
+		rest.FillObjectMetaSystemFields(objectMeta)
+		if err := rest.BeforeCreate(Strategy, ctx, request); err != nil {
+			errs = append(errs, field.Invalid(path, item.Name, err.Error()))
+			continue
+		}
+		requests = append(requests, request)
+	}
+	if len(errs) > 0 {
+		return nil, errors.NewInvalid(build.Kind("BatchBuildRequest"), name, errs)
+	}
+
+	builds, err := r.generator.InstantiateBatchInternal(ctx, requests, metav1.CreateOptions{})
+	if batchErr, ok := err.(*buildgenerator.BatchError); ok {
+		for _, config := range batchErr.Names() {
+			errs = append(errs, field.Invalid(field.NewPath("items").Key(config), config, batchErr.Errors[config].Error()))
+		}
+		return nil, errors.NewInvalid(build.Kind("BatchBuildRequest"), name, errs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &buildapi.BuildList{}
+	for _, b := range builds {
+		list.Items = append(list.Items, *b)
+	}
+	return list, nil
+}
+
+// checkStrategies returns a Forbidden error if the user may not build one of the requested
+// BuildConfigs with its strategy. The missing BuildConfigs are reported by the generator.
+func (r *BatchInstantiateREST) checkStrategies(ctx context.Context, namespace string, items []buildv1.BuildRequest, selected map[string]*buildv1.BuildConfig) error {
+	userInfo, ok := apirequest.UserFrom(ctx)
+	if !ok {
+		return errors.NewForbidden(build.Resource("buildconfigs"), "", fmt.Errorf("the user of the batch is unknown"))
+	}
+	checked := map[string]bool{}
+	for _, item := range items {
+		if checked[item.Name] {
+			continue
+		}
+		checked[item.Name] = true
+		config, ok := selected[item.Name]
+		if !ok {
+			var err error
+			config, err = r.buildConfigClient.BuildConfigs(namespace).Get(ctx, item.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		internal := &buildapi.BuildConfig{}
+		if err := v1.Convert_v1_BuildConfig_To_build_BuildConfig(config, internal, nil); err != nil {
+			return errors.NewInternalError(err)
+		}
+		if err := strategyrestrictions.CheckBuildConfigStrategy(ctx, r.sarClient, userInfo, internal); err != nil {
+			return errors.NewForbidden(build.Resource("buildconfigs"), item.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package buildconfiginstantiate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+	buildfakeclient "github.com/openshift/client-go/build/clientset/versioned/fake"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildgenerator"
+)
+
+func TestBatchInstantiate(t *testing.T) {
+	imageStream := MockImageStream("testImageStream", "registry.com/namespace/imagename", map[string]string{"test": "newImageID123"})
+	image := MockImage("testImage@id", "registry.com/namespace/imagename@id")
+	fakeSecrets := []runtime.Object{}
+	for _, s := range MockBuilderSecrets() {
+		fakeSecrets = append(fakeSecrets, s)
+	}
+	configs := map[string]*buildv1.BuildConfig{}
+	for name, labels := range map[string]map[string]string{
+		"frontend": {"repo": "mono"},
+		"backend":  {"repo": "mono"},
+		"other":    {"repo": "other"},
+	} {
+		bc := MockBuildConfig(MockSource(), MockSourceStrategyForImageRepository(), MockOutput())
+		bc.Name = name
+		bc.Labels = labels
+		configs[name] = bc
+	}
+	configs["other"].Spec.Strategy = buildv1.BuildStrategy{Type: buildv1.DockerBuildStrategyType, DockerStrategy: &buildv1.DockerBuildStrategy{}}
+	var created []*buildv1.Build
+	generator := &buildgenerator.BuildGenerator{
+		Secrets:         fake.NewSimpleClientset(fakeSecrets...).CoreV1(),
+		ServiceAccounts: MockBuilderServiceAccount(MockBuilderSecrets()),
+		Client: buildgenerator.TestingClient{
+			GetBuildConfigFunc: func(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.BuildConfig, error) {
+				bc, ok := configs[name]
+				if !ok {
+					return nil, kapierrors.NewNotFound(buildv1.Resource("buildconfigs"), name)
+				}
+				return bc.DeepCopy(), nil
+			},
+			UpdateBuildConfigFunc: func(ctx context.Context, buildConfig *buildv1.BuildConfig, _ metav1.UpdateOptions) error {
+				return nil
+			},
+			CreateBuildFunc: func(ctx context.Context, build *buildv1.Build, _ metav1.CreateOptions) error {
+				created = append(created, build)
+				return nil
+			},
+			GetBuildFunc: func(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.Build, error) {
+				return created[len(created)-1], nil
+			},
+			GetImageStreamFunc: func(ctx context.Context, name string, options metav1.GetOptions) (*imagev1.ImageStream, error) {
+				return imageStream, nil
+			},
+			GetImageStreamTagFunc: func(ctx context.Context, name string, options metav1.GetOptions) (*imagev1.ImageStreamTag, error) {
+				return &imagev1.ImageStreamTag{Image: *image}, nil
+			},
+			GetImageStreamImageFunc: func(ctx context.Context, name string, options metav1.GetOptions) (*imagev1.ImageStreamImage, error) {
+				return &imagev1.ImageStreamImage{Image: *image}, nil
+			},
+		},
+	}
+	var objects []runtime.Object
+	for _, bc := range configs {
+		objects = append(objects, bc)
+	}
+	// the user may only run source builds
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		sar := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "alice" && attributes.Resource == "builds" && attributes.Subresource == "source"
+		return true, sar, nil
+	})
+	storage := NewBatchStorage(generator, buildfakeclient.NewSimpleClientset(objects...).BuildV1(), kubeClient.AuthorizationV1().SubjectAccessReviews())
+
+	post := func(body string) *fakeResponder {
+		responder := &fakeResponder{}
+		ctx := apirequest.WithUser(apirequest.NewDefaultContext(), &user.DefaultInfo{Name: "alice"})
+		handler, err := storage.Connect(ctx, "push-1234", nil, responder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/instantiatebatch", strings.NewReader(body)))
+		return responder
+	}
+
+	responder := post(`{"revision": {"type": "Git", "git": {"commit": "abcd"}}, "labelSelector": "repo=mono", "items": [{"metadata": {"name": "frontend"}, "env": [{"name": "A", "value": "b"}]}]}`)
+	if responder.err != nil || responder.statusCode != http.StatusCreated {
+		t.Fatalf("unexpected response %d: %v", responder.statusCode, responder.err)
+	}
+	list, ok := responder.object.(*buildapi.BuildList)
+	if !ok || len(list.Items) != 2 {
+		t.Fatalf("expected the builds of the selected configs, got %#v", responder.object)
+	}
+	for _, build := range created {
+		if build.Labels[BuildBatchLabel] != "push-1234" || build.Spec.Revision == nil || build.Spec.Revision.Git.Commit != "abcd" {
+			t.Errorf("unexpected build %s: %v, %#v", build.Name, build.Labels, build.Spec.Revision)
+		}
+	}
+
+	// a single invalid request fails the whole batch
+	created = nil
+	responder = post(`{"items": [{"metadata": {"name": "frontend"}}, {"metadata": {"name": "missing"}}]}`)
+	if !kapierrors.IsInvalid(responder.err) || !strings.Contains(responder.err.Error(), "items[missing]") || len(created) != 0 {
+		t.Errorf("expected the batch to be rejected for the missing config, got %d builds: %v", len(created), responder.err)
+	}
+
+	responder = post(`{"revision": {"git": {"commit": "abcd"}}, "items": [{"metadata": {"name": "frontend"}, "revision": {"git": {"commit": "ef01"}}}]}`)
+	if !kapierrors.IsInvalid(responder.err) || !strings.Contains(responder.err.Error(), "items[0].revision") {
+		t.Errorf("expected the diverging revision to be rejected, got %v", responder.err)
+	}
+
+	// a BuildConfig whose strategy the user may not use fails the whole batch
+	created = nil
+	responder = post(`{"labelSelector": "repo", "items": [{"metadata": {"name": "frontend"}}]}`)
+	if !kapierrors.IsForbidden(responder.err) || !strings.Contains(responder.err.Error(), "build strategy Docker is not allowed") || len(created) != 0 {
+		t.Errorf("expected the batch to be forbidden for the Docker strategy, got %d builds: %v", len(created), responder.err)
+	}
+
+	responder = post(`{"labelSelector": "repo=none"}`)
+	if !kapierrors.IsBadRequest(responder.err) {
+		t.Errorf("expected an empty batch to be rejected, got %v", responder.err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	v1 "github.com/openshift/openshift-apiserver/pkg/build/apis/build/v1"
@@ -20,6 +21,7 @@ import (
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/api/legacyscheme"
 	kapi "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/utils/clock"
 
 	buildv1 "github.com/openshift/api/build/v1"
 	buildtypedclient "github.com/openshift/client-go/build/clientset/versioned/typed/build/v1"
@@ -93,7 +95,7 @@ func (s *InstantiateREST) ProducesMIMETypes(verb string) []string {
 	return nil // no additional mime types
 }
 
-func NewBinaryStorage(generator *buildgenerator.BuildGenerator, buildClient buildtypedclient.BuildsGetter, inClientConfig *restclient.Config) *BinaryInstantiateREST {
+func NewBinaryStorage(generator *buildgenerator.BuildGenerator, buildClient buildtypedclient.BuildsGetter, inClientConfig *restclient.Config, uploadLimits UploadLimits) *BinaryInstantiateREST {
 	clientConfig := restclient.CopyConfig(inClientConfig)
 	clientConfig.APIPath = "/api"
 	clientConfig.GroupVersion = &schema.GroupVersion{Version: "v1"}
@@ -104,6 +106,7 @@ func NewBinaryStorage(generator *buildgenerator.BuildGenerator, buildClient buil
 		BuildClient:  buildClient,
 		ClientConfig: clientConfig,
 		Timeout:      5 * time.Minute,
+		UploadLimits: uploadLimits,
 	}
 }
 
@@ -112,6 +115,12 @@ type BinaryInstantiateREST struct {
 	BuildClient  buildtypedclient.BuildsGetter
 	ClientConfig *restclient.Config
 	Timeout      time.Duration
+	// UploadLimits limits the resumable uploads in progress.
+	UploadLimits UploadLimits
+
+	uploadsOnce sync.Once
+	uploads     *uploadSessions
+	stopCh      chan struct{}
 }
 
 var _ rest.Connecter = &BinaryInstantiateREST{}
@@ -122,7 +131,11 @@ func (r *BinaryInstantiateREST) New() runtime.Object {
 	return &buildapi.BinaryBuildRequestOptions{}
 }
 
-func (r *BinaryInstantiateREST) Destroy() {}
+func (r *BinaryInstantiateREST) Destroy() {
+	if r.stopCh != nil {
+		close(r.stopCh)
+	}
+}
 
 // Connect returns a ConnectHandler that will handle the request/response for a request
 func (r *BinaryInstantiateREST) Connect(ctx context.Context, name string, options runtime.Object, responder rest.Responder) (http.Handler, error) {
@@ -145,6 +158,16 @@ func (r *BinaryInstantiateREST) ConnectMethods() []string {
 	return []string{"POST"}
 }
 
+// uploadSessions returns the resumable uploads in progress.
+func (r *BinaryInstantiateREST) uploadSessions() *uploadSessions {
+	r.uploadsOnce.Do(func() {
+		r.uploads = newUploadSessions(r.UploadLimits, clock.RealClock{})
+		r.stopCh = make(chan struct{})
+		go r.uploads.run(uploadPruneInterval, r.stopCh)
+	})
+	return r.uploads
+}
+
 func (r *BinaryInstantiateREST) ProducesObject(verb string) interface{} {
 	// for documentation purposes
 	return buildv1.Build{}
@@ -168,6 +191,14 @@ var _ http.Handler = &binaryInstantiateHandler{}
 
 func (h *binaryInstantiateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
+	if isUpload(r.Header) {
+		if !h.r.UploadLimits.Enabled {
+			h.responder.Error(errors.NewBadRequest("resumable binary uploads are not enabled on this server"))
+			return
+		}
+		h.serveUpload(w, r)
+		return
+	}
 	build, err := h.handle(r.Body)
 	if err != nil {
 		h.responder.Error(err)
@@ -176,15 +207,23 @@ func (h *binaryInstantiateHandler) ServeHTTP(w http.ResponseWriter, r *http.Requ
 	h.responder.Object(http.StatusCreated, build)
 }
 
-func (h *binaryInstantiateHandler) handle(r io.Reader) (runtime.Object, error) {
+// validate prepares and validates the options of the request.
+func (h *binaryInstantiateHandler) validate() error {
 	h.options.Name = h.name
 	objectMeta, err := meta.Accessor(h.options)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	rest.FillObjectMetaSystemFields(objectMeta)
 	if err := rest.BeforeCreate(BinaryStrategy, h.ctx, h.options); err != nil {
 		klog.Infof("failed to validate binary: %#v", h.options)
+		return err
+	}
+	return nil
+}
+
+func (h *binaryInstantiateHandler) handle(r io.Reader) (runtime.Object, error) {
+	if err := h.validate(); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,365 @@
+package buildconfiginstantiate
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	"github.com/openshift/api/build"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+)
+
+// The headers of the resumable upload protocol of binary builds. A client starts an upload by sending
+// the first chunk of the binary with UploadLengthHeader and UploadSHA256Header set to the size and the
+// SHA-256 of the whole binary. The server answers with UploadIDHeader and the number of bytes it
+// received so far in UploadOffsetHeader, and with 202 Accepted until the whole binary was received.
+// Further chunks are sent with UploadIDHeader and the UploadOffsetHeader they start at; a chunk that
+// does not start at the acknowledged offset is rejected with 409 Conflict and the acknowledged offset,
+// which is how a client resumes an interrupted upload. Once the whole binary was received and matches
+// its checksum, the build is instantiated and the binary is streamed to it.
+//
+// Resumable uploads are disabled unless UploadLimits.Enabled is set. Uploads are kept in the memory
+// and on the disk of the apiserver that received them and are not shared with the other apiservers,
+// so they are only meant for deployments where a single apiserver serves the builds. A client has to
+// resume an upload with the same apiserver, within the build timeout of the BuildConfig, counted
+// from its last chunk. The ID of an upload names the apiserver that keeps it, a chunk sent to another
+// apiserver is rejected with 410 Gone and the upload has to be restarted. The size of a binary, the
+// number of uploads of a user and the bytes of all the uploads in progress are limited by
+// UploadLimits.
+const (
+	UploadIDHeader     = "X-Upload-Id"
+	UploadOffsetHeader = "X-Upload-Offset"
+	UploadLengthHeader = "X-Upload-Length"
+	UploadSHA256Header = "X-Upload-Sha256"
+)
+
+const (
+	// DefaultMaxUploadLength is the default size limit of an uploaded binary.
+	DefaultMaxUploadLength = 2 << 30
+	// DefaultMaxUploadsPerUser is the default number of uploads a user may have in progress.
+	DefaultMaxUploadsPerUser = 5
+	// DefaultMaxUploadBytes is the default size limit of all the uploads in progress.
+	DefaultMaxUploadBytes = 4 << 30
+	// DefaultUploadTimeout is how long an upload is kept after its last chunk when the BuildConfig
+	// does not set a build timeout.
+	DefaultUploadTimeout = 30 * time.Minute
+
+	// uploadPruneInterval is how often the expired uploads are dropped.
+	uploadPruneInterval = time.Minute
+)
+
+// UploadLimits limits the resumable uploads an apiserver keeps.
+type UploadLimits struct {
+	// Enabled turns on resumable uploads. They should only be enabled when a single apiserver
+	// serves the builds, as uploads cannot be resumed with another apiserver.
+	Enabled bool
+	// Dir is where uploads are stored until they are complete, it defaults to the temporary directory.
+	Dir string
+	// MaxLength is the size limit of a binary, DefaultMaxUploadLength if not set.
+	MaxLength int64
+	// MaxPerUser is the number of uploads a user may have in progress, DefaultMaxUploadsPerUser if
+	// not set.
+	MaxPerUser int
+	// MaxBytes is the size limit of all the uploads in progress, counted by the size of their binary,
+	// DefaultMaxUploadBytes if not set.
+	MaxBytes int64
+}
+
+// isUpload returns true if the request is part of a resumable upload.
+func isUpload(header http.Header) bool {
+	return len(header.Get(UploadIDHeader)) > 0 || len(header.Get(UploadSHA256Header)) > 0
+}
+
+// uploadSession is a binary being uploaded. The received bytes are spooled to a file and hashed on
+// the fly, so that the binary can be verified as soon as the last chunk arrives.
+type uploadSession struct {
+	id        string
+	namespace string
+	name      string
+	user      string
+	options   buildapi.BinaryBuildRequestOptions
+	length    int64
+	checksum  []byte
+	// timeout is how long the session is kept after its last chunk.
+	timeout time.Duration
+
+	file    *os.File
+	hash    hash.Hash
+	offset  int64
+	busy    bool
+	expires time.Time
+}
+
+func (s *uploadSession) remove() {
+	s.file.Close()
+	if err := os.Remove(s.file.Name()); err != nil && !os.IsNotExist(err) {
+		klog.Warningf("unable to remove the binary upload %s: %v", s.file.Name(), err)
+	}
+}
+
+// uploadSessions holds the binary uploads in progress. Sessions idle for longer than their timeout
+// are dropped along with their spooled binary.
+type uploadSessions struct {
+	limits UploadLimits
+	clock  clock.PassiveClock
+	// replica prefixes the IDs of the sessions, so that the chunks sent to another apiserver are
+	// told apart from the ones of unknown uploads.
+	replica string
+
+	lock     sync.Mutex
+	sessions map[string]*uploadSession
+}
+
+func newUploadSessions(limits UploadLimits, clock clock.PassiveClock) *uploadSessions {
+	if limits.MaxLength <= 0 {
+		limits.MaxLength = DefaultMaxUploadLength
+	}
+	if limits.MaxPerUser <= 0 {
+		limits.MaxPerUser = DefaultMaxUploadsPerUser
+	}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = DefaultMaxUploadBytes
+	}
+	replica := make([]byte, 4)
+	if _, err := rand.Read(replica); err != nil {
+		panic(err)
+	}
+	return &uploadSessions{
+		limits:   limits,
+		clock:    clock,
+		replica:  hex.EncodeToString(replica),
+		sessions: make(map[string]*uploadSession),
+	}
+}
+
+// run drops the expired sessions until stopCh is closed, so that abandoned uploads do not hold
+// their disk space until the next upload.
+func (u *uploadSessions) run(interval time.Duration, stopCh <-chan struct{}) {
+	wait.Until(func() {
+		u.lock.Lock()
+		defer u.lock.Unlock()
+		u.pruneLocked()
+	}, interval, stopCh)
+}
+
+// pruneLocked drops the expired sessions that are not receiving a chunk.
+func (u *uploadSessions) pruneLocked() {
+	now := u.clock.Now()
+	for id, s := range u.sessions {
+		if !s.busy && now.After(s.expires) {
+			klog.V(4).Infof("dropping the expired binary upload %s for %s/%s at %d of %d bytes", id, s.namespace, s.name, s.offset, s.length)
+			delete(u.sessions, id)
+			s.remove()
+		}
+	}
+}
+
+// start creates a session receiving a binary of the given length and checksum, which is kept for
+// timeout after each chunk.
+func (u *uploadSessions) start(namespace, name, user string, options *buildapi.BinaryBuildRequestOptions, header http.Header, timeout time.Duration) (*uploadSession, error) {
+	length, err := strconv.ParseInt(header.Get(UploadLengthHeader), 10, 64)
+	if err != nil || length < 0 {
+		return nil, errors.NewBadRequest(fmt.Sprintf("the %s header must be set to the size of the binary", UploadLengthHeader))
+	}
+	if length > u.limits.MaxLength {
+		return nil, errors.NewRequestEntityTooLargeError(fmt.Sprintf("the binary is larger than the limit of %d bytes", u.limits.MaxLength))
+	}
+	checksum, err := hex.DecodeString(strings.TrimPrefix(header.Get(UploadSHA256Header), "sha256:"))
+	if err != nil || len(checksum) != sha256.Size {
+		return nil, errors.NewBadRequest(fmt.Sprintf("the %s header must be set to the hex encoded SHA-256 of the binary", UploadSHA256Header))
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.pruneLocked()
+	uploads, total := 0, int64(0)
+	for _, s := range u.sessions {
+		total += s.length
+		if s.user == user {
+			uploads++
+		}
+	}
+	retryAfter := int(timeout.Seconds())
+	if uploads >= u.limits.MaxPerUser {
+		return nil, errors.NewTooManyRequests(fmt.Sprintf("%s has %d binary uploads in progress, complete them or wait for them to expire", user, uploads), retryAfter)
+	}
+	if total+length > u.limits.MaxBytes {
+		return nil, errors.NewTooManyRequests("too many bytes are being uploaded, retry later", retryAfter)
+	}
+
+	file, err := os.CreateTemp(u.limits.Dir, "binary-build-")
+	if err != nil {
+		return nil, errors.NewInternalError(fmt.Errorf("unable to store the binary: %v", err))
+	}
+	s := &uploadSession{
+		id:        u.replica + "-" + hex.EncodeToString(id),
+		namespace: namespace,
+		name:      name,
+		user:      user,
+		options:   *options,
+		length:    length,
+		checksum:  checksum,
+		timeout:   timeout,
+		file:      file,
+		hash:      sha256.New(),
+		busy:      true,
+	}
+	u.sessions[s.id] = s
+	return s, nil
+}
+
+// resume returns the session of id to receive the chunk starting at the offset set in header. If the
+// chunk does not start at the acknowledged offset, the offset is returned with a Conflict error,
+// otherwise the returned offset is -1 on errors.
+func (u *uploadSessions) resume(id, namespace, name, user string, header http.Header) (*uploadSession, int64, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.pruneLocked()
+
+	if replica, _, _ := strings.Cut(id, "-"); replica != u.replica {
+		return nil, -1, errors.NewGone(fmt.Sprintf("the binary upload %s is kept by another apiserver, uploads cannot be resumed across apiservers and have to be restarted", id))
+	}
+	s, ok := u.sessions[id]
+	if !ok || s.namespace != namespace || s.name != name || s.user != user {
+		return nil, -1, errors.NewNotFound(build.Resource("binaryupload"), id)
+	}
+	if s.busy {
+		return nil, -1, errors.NewConflict(build.Resource("binaryupload"), id, fmt.Errorf("another chunk of the upload is being received"))
+	}
+	if offset := header.Get(UploadOffsetHeader); len(offset) > 0 && offset != strconv.FormatInt(s.offset, 10) {
+		return nil, s.offset, errors.NewConflict(build.Resource("binaryupload"), id, fmt.Errorf("the chunk starts at %s but %d bytes were received", offset, s.offset))
+	}
+	s.busy = true
+	return s, s.offset, nil
+}
+
+// receive appends the chunk read from r to the session and returns the number of bytes received so
+// far. The session is done once the whole binary was received and verified, it is dropped if the
+// binary does not match its length or checksum.
+func (u *uploadSessions) receive(s *uploadSession, r io.Reader) (offset int64, done bool, err error) {
+	n, err := io.Copy(io.MultiWriter(s.file, s.hash), io.LimitReader(r, s.length-s.offset))
+	s.offset += n
+	offset = s.offset
+	tooLarge := false
+	if err == nil {
+		n, _ := r.Read(make([]byte, 1))
+		tooLarge = n > 0
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	s.busy = false
+	s.expires = u.clock.Now().Add(s.timeout)
+	if err != nil {
+		klog.V(4).Infof("binary upload %s for %s/%s interrupted at %d of %d bytes: %v", s.id, s.namespace, s.name, s.offset, s.length, err)
+		return offset, false, errors.NewBadRequest(fmt.Sprintf("the upload was interrupted after %d bytes: %v", s.offset, err))
+	}
+	if tooLarge {
+		delete(u.sessions, s.id)
+		s.remove()
+		return offset, false, errors.NewBadRequest(fmt.Sprintf("the binary is larger than the %d bytes set in %s", s.length, UploadLengthHeader))
+	}
+	if s.offset < s.length {
+		return offset, false, nil
+	}
+
+	delete(u.sessions, s.id)
+	if sum := s.hash.Sum(nil); !bytes.Equal(sum, s.checksum) {
+		s.remove()
+		return offset, false, errors.NewBadRequest(fmt.Sprintf("the SHA-256 of the binary is %x, not %x as set in %s", sum, s.checksum, UploadSHA256Header))
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		s.remove()
+		return offset, false, errors.NewInternalError(err)
+	}
+	return offset, true, nil
+}
+
+// serveUpload receives a chunk of a resumable upload, and instantiates the build once the binary
+// is complete.
+func (h *binaryInstantiateHandler) serveUpload(w http.ResponseWriter, r *http.Request) {
+	uploads := h.r.uploadSessions()
+	namespace := apirequest.NamespaceValue(h.ctx)
+	user := ""
+	if u, ok := apirequest.UserFrom(h.ctx); ok {
+		user = u.GetName()
+	}
+
+	var s *uploadSession
+	var err error
+	if id := r.Header.Get(UploadIDHeader); len(id) > 0 {
+		var offset int64
+		s, offset, err = uploads.resume(id, namespace, h.name, user, r.Header)
+		if offset >= 0 && err != nil {
+			w.Header().Set(UploadOffsetHeader, strconv.FormatInt(offset, 10))
+		}
+	} else if err = h.validate(); err == nil {
+		var timeout time.Duration
+		if timeout, err = h.uploadTimeout(); err == nil {
+			s, err = uploads.start(namespace, h.name, user, h.options, r.Header, timeout)
+		}
+	}
+	if err != nil {
+		h.responder.Error(err)
+		return
+	}
+
+	offset, done, err := uploads.receive(s, r.Body)
+	w.Header().Set(UploadIDHeader, s.id)
+	w.Header().Set(UploadOffsetHeader, strconv.FormatInt(offset, 10))
+	if err != nil {
+		h.responder.Error(err)
+		return
+	}
+	if !done {
+		h.responder.Object(http.StatusAccepted, &metav1.Status{
+			Status:  metav1.StatusSuccess,
+			Code:    http.StatusAccepted,
+			Message: fmt.Sprintf("received %d of %d bytes", offset, s.length),
+		})
+		return
+	}
+
+	defer s.remove()
+	*h.options = s.options
+	build, err := h.handle(s.file)
+	if err != nil {
+		h.responder.Error(err)
+		return
+	}
+	h.responder.Object(http.StatusCreated, build)
+}
+
+// uploadTimeout returns how long an upload is kept after its last chunk, the build timeout of the
+// BuildConfig or DefaultUploadTimeout if it has none.
+func (h *binaryInstantiateHandler) uploadTimeout() (time.Duration, error) {
+	bc, err := h.r.Generator.Client.GetBuildConfig(h.ctx, h.name, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	if deadline := bc.Spec.CompletionDeadlineSeconds; deadline != nil && *deadline > 0 {
+		return time.Duration(*deadline) * time.Second, nil
+	}
+	return DefaultUploadTimeout, nil
+}
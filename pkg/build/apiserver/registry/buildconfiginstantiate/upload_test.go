@@ -0,0 +1,332 @@
+package buildconfiginstantiate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildgenerator"
+)
+
+func uploadHeader(data []byte) http.Header {
+	sum := sha256.Sum256(data)
+	header := http.Header{}
+	header.Set(UploadLengthHeader, strconv.Itoa(len(data)))
+	header.Set(UploadSHA256Header, hex.EncodeToString(sum[:]))
+	return header
+}
+
+// failingReader returns the data and then fails as if the connection dropped.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUploadSessionsResume(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	uploads := newUploadSessions(UploadLimits{Dir: t.TempDir()}, clock)
+	data := []byte("0123456789abcdefghij")
+
+	s, err := uploads.start("ns", "bc", "alice", &buildapi.BinaryBuildRequestOptions{AsFile: "app.jar"}, uploadHeader(data), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, done, err := uploads.receive(s, &failingReader{data: data[:8]})
+	if err == nil || done || offset != 8 {
+		t.Fatalf("expected the upload to be interrupted at 8 bytes, got %d, %t, %v", offset, done, err)
+	}
+
+	// only the owner of the upload may resume it
+	if _, _, err := uploads.resume(s.id, "ns", "bc", "bob", http.Header{}); !kapierrors.IsNotFound(err) {
+		t.Errorf("expected a NotFound error, got %v", err)
+	}
+	// chunks have to start at the acknowledged offset
+	header := http.Header{}
+	header.Set(UploadOffsetHeader, "12")
+	if _, offset, err := uploads.resume(s.id, "ns", "bc", "alice", header); !kapierrors.IsConflict(err) || offset != 8 {
+		t.Errorf("expected a Conflict error at offset 8, got %d, %v", offset, err)
+	}
+
+	header.Set(UploadOffsetHeader, "8")
+	s, offset, err = uploads.resume(s.id, "ns", "bc", "alice", header)
+	if err != nil || offset != 8 {
+		t.Fatalf("unexpected resume at %d: %v", offset, err)
+	}
+	if _, offset, err := uploads.resume(s.id, "ns", "bc", "alice", http.Header{}); !kapierrors.IsConflict(err) || offset != -1 {
+		t.Errorf("expected concurrent chunks to conflict without an offset, got %d, %v", offset, err)
+	}
+	offset, done, err = uploads.receive(s, bytes.NewReader(data[8:]))
+	if err != nil || !done || offset != int64(len(data)) {
+		t.Fatalf("expected the upload to be done, got %d, %t, %v", offset, done, err)
+	}
+	defer s.remove()
+
+	received, err := io.ReadAll(s.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("unexpected binary %q", received)
+	}
+	if s.options.AsFile != "app.jar" {
+		t.Errorf("expected the options of the first chunk to be kept, got %#v", s.options)
+	}
+	if len(uploads.sessions) != 0 {
+		t.Errorf("expected the completed upload to be dropped, got %v", uploads.sessions)
+	}
+}
+
+func TestUploadSessionsVerify(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	dir := t.TempDir()
+	uploads := newUploadSessions(UploadLimits{Dir: dir}, clock)
+	data := []byte("0123456789")
+
+	for name, tc := range map[string]struct {
+		header http.Header
+		body   []byte
+		err    string
+	}{
+		"missing length":   {header: http.Header{UploadSHA256Header: uploadHeader(data)[UploadSHA256Header]}, err: UploadLengthHeader},
+		"invalid checksum": {header: http.Header{UploadLengthHeader: []string{"10"}, UploadSHA256Header: []string{"abc"}}, err: UploadSHA256Header},
+		"checksum":         {header: uploadHeader(data), body: []byte("9876543210"), err: "the SHA-256 of the binary"},
+		"too large":        {header: uploadHeader(data), body: append(data, 'x'), err: "larger than"},
+	} {
+		s, err := uploads.start("ns", "bc", "", &buildapi.BinaryBuildRequestOptions{}, tc.header, time.Minute)
+		if err == nil {
+			_, _, err = uploads.receive(s, bytes.NewReader(tc.body))
+		}
+		if !kapierrors.IsBadRequest(err) || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected a BadRequest error about %s, got %v", name, tc.err, err)
+		}
+	}
+
+	if files, err := os.ReadDir(dir); err != nil || len(files) != 0 || len(uploads.sessions) != 0 {
+		t.Errorf("expected the rejected uploads to be removed, got %v, %v: %v", files, uploads.sessions, err)
+	}
+}
+
+func TestUploadSessionsExpire(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	dir := t.TempDir()
+	uploads := newUploadSessions(UploadLimits{Dir: dir}, clock)
+	data := []byte("0123456789")
+
+	s, err := uploads.start("ns", "bc", "", &buildapi.BinaryBuildRequestOptions{}, uploadHeader(data), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, done, err := uploads.receive(s, bytes.NewReader(data[:5])); err != nil || done {
+		t.Fatalf("unexpected result %t: %v", done, err)
+	}
+
+	clock.SetTime(clock.Now().Add(2 * time.Minute))
+	if _, _, err := uploads.resume(s.id, "ns", "bc", "", http.Header{}); !kapierrors.IsNotFound(err) {
+		t.Errorf("expected the expired upload to be dropped, got %v", err)
+	}
+	if files, err := os.ReadDir(dir); err != nil || len(files) != 0 {
+		t.Errorf("expected the expired upload to be removed, got %v: %v", files, err)
+	}
+}
+
+func TestUploadSessionsLimits(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	uploads := newUploadSessions(UploadLimits{Dir: t.TempDir(), MaxLength: 10, MaxPerUser: 2, MaxBytes: 25}, clock)
+	options := &buildapi.BinaryBuildRequestOptions{}
+
+	if _, err := uploads.start("ns", "bc", "alice", options, uploadHeader(make([]byte, 11)), time.Minute); !kapierrors.IsRequestEntityTooLargeError(err) {
+		t.Errorf("expected a RequestEntityTooLarge error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		s, err := uploads.start("ns", "bc", "alice", options, uploadHeader(make([]byte, 10)), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := uploads.receive(s, bytes.NewReader(make([]byte, 5))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := uploads.start("ns", "bc", "alice", options, uploadHeader(make([]byte, 1)), time.Minute); !kapierrors.IsTooManyRequests(err) || !strings.Contains(err.Error(), "2 binary uploads in progress") {
+		t.Errorf("expected the uploads of a user to be limited, got %v", err)
+	}
+	// the declared size of the uploads in progress counts, not the bytes received so far
+	if _, err := uploads.start("ns", "bc", "bob", options, uploadHeader(make([]byte, 6)), time.Minute); !kapierrors.IsTooManyRequests(err) || !strings.Contains(err.Error(), "too many bytes") {
+		t.Errorf("expected the bytes of all the uploads to be limited, got %v", err)
+	}
+	if _, err := uploads.start("ns", "bc", "bob", options, uploadHeader(make([]byte, 5)), time.Minute); err != nil {
+		t.Errorf("expected an upload within the limits to be allowed: %v", err)
+	}
+}
+
+func TestUploadSessionsOtherReplica(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	uploads := newUploadSessions(UploadLimits{Dir: t.TempDir()}, clock)
+	other := newUploadSessions(UploadLimits{Dir: t.TempDir()}, clock)
+	data := []byte("0123456789")
+
+	s, err := other.start("ns", "bc", "alice", &buildapi.BinaryBuildRequestOptions{}, uploadHeader(data), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.remove()
+	if _, _, err := uploads.resume(s.id, "ns", "bc", "alice", http.Header{}); !kapierrors.IsGone(err) || !strings.Contains(err.Error(), "another apiserver") {
+		t.Errorf("expected a Gone error naming the other apiserver, got %v", err)
+	}
+}
+
+func TestUploadSessionsRunPrunes(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	dir := t.TempDir()
+	uploads := newUploadSessions(UploadLimits{Dir: dir}, clock)
+	data := []byte("0123456789")
+
+	s, err := uploads.start("ns", "bc", "", &buildapi.BinaryBuildRequestOptions{}, uploadHeader(data), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uploads.receive(s, bytes.NewReader(data[:5])); err != nil {
+		t.Fatal(err)
+	}
+	clock.SetTime(clock.Now().Add(2 * time.Minute))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go uploads.run(time.Millisecond, stopCh)
+	err = wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		files, err := os.ReadDir(dir)
+		return len(files) == 0, err
+	})
+	if err != nil {
+		t.Errorf("expected the expired upload to be removed without another upload: %v", err)
+	}
+}
+
+type fakeResponder struct {
+	statusCode int
+	object     runtime.Object
+	err        error
+}
+
+func (r *fakeResponder) Object(statusCode int, obj runtime.Object) {
+	r.statusCode = statusCode
+	r.object = obj
+}
+
+func (r *fakeResponder) Error(err error) {
+	r.err = err
+}
+
+func uploadStorage(limits UploadLimits, deadline *int64) *BinaryInstantiateREST {
+	return &BinaryInstantiateREST{
+		Generator: &buildgenerator.BuildGenerator{
+			Client: buildgenerator.TestingClient{
+				GetBuildConfigFunc: func(ctx context.Context, name string, options metav1.GetOptions) (*buildv1.BuildConfig, error) {
+					bc := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+					bc.Spec.CompletionDeadlineSeconds = deadline
+					return bc, nil
+				},
+			},
+		},
+		Timeout:      time.Minute,
+		UploadLimits: limits,
+	}
+}
+
+func TestServeUploadDisabled(t *testing.T) {
+	storage := uploadStorage(UploadLimits{Dir: t.TempDir()}, nil)
+	ctx := apirequest.WithUser(apirequest.WithNamespace(apirequest.NewContext(), "ns"), &user.DefaultInfo{Name: "alice"})
+	data := []byte("0123456789")
+
+	responder := &fakeResponder{}
+	handler, err := storage.Connect(ctx, "bc", &buildapi.BinaryBuildRequestOptions{}, responder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/instantiatebinary", bytes.NewReader(data))
+	req.Header = uploadHeader(data)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !kapierrors.IsBadRequest(responder.err) || !strings.Contains(responder.err.Error(), "not enabled") {
+		t.Errorf("expected a BadRequest error about disabled uploads, got %v", responder.err)
+	}
+	if storage.uploads != nil {
+		t.Errorf("expected no upload to be kept")
+	}
+}
+
+func TestServeUploadAcceptsChunks(t *testing.T) {
+	deadline := int64(3600)
+	storage := uploadStorage(UploadLimits{Enabled: true, Dir: t.TempDir()}, &deadline)
+	ctx := apirequest.WithUser(apirequest.WithNamespace(apirequest.NewContext(), "ns"), &user.DefaultInfo{Name: "alice"})
+	data := []byte("0123456789")
+
+	serve := func(header http.Header, body []byte) (*httptest.ResponseRecorder, *fakeResponder) {
+		responder := &fakeResponder{}
+		handler, err := storage.Connect(ctx, "bc", &buildapi.BinaryBuildRequestOptions{}, responder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/instantiatebinary", bytes.NewReader(body))
+		req.Header = header
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w, responder
+	}
+
+	w, responder := serve(uploadHeader(data), data[:4])
+	if responder.err != nil || responder.statusCode != http.StatusAccepted {
+		t.Fatalf("expected the first chunk to be accepted, got %d: %v", responder.statusCode, responder.err)
+	}
+	if status, ok := responder.object.(*metav1.Status); !ok || status.Message != "received 4 of 10 bytes" {
+		t.Errorf("unexpected response %#v", responder.object)
+	}
+	id := w.Header().Get(UploadIDHeader)
+	if len(id) == 0 || w.Header().Get(UploadOffsetHeader) != "4" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	// the upload is kept for the build timeout of the BuildConfig
+	if timeout := storage.uploads.sessions[id].timeout; timeout != time.Hour {
+		t.Errorf("expected the upload to be kept for an hour, got %s", timeout)
+	}
+
+	header := http.Header{}
+	header.Set(UploadIDHeader, id)
+	header.Set(UploadOffsetHeader, "2")
+	w, responder = serve(header, data[2:])
+	if !kapierrors.IsConflict(responder.err) || w.Header().Get(UploadOffsetHeader) != "4" {
+		t.Errorf("expected a Conflict error with the acknowledged offset, got %v: %v", w.Header(), responder.err)
+	}
+
+	header.Set(UploadOffsetHeader, "4")
+	w, responder = serve(header, data[4:8])
+	if responder.err != nil || responder.statusCode != http.StatusAccepted || w.Header().Get(UploadOffsetHeader) != "8" {
+		t.Errorf("expected the second chunk to be accepted, got %d, %v: %v", responder.statusCode, w.Header(), responder.err)
+	}
+}
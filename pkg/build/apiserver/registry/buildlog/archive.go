@@ -0,0 +1,255 @@
+package buildlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+
+	apiserverrest "github.com/openshift/openshift-apiserver/pkg/apiserver/rest"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	buildinternalhelpers "github.com/openshift/openshift-apiserver/pkg/build/apis/build/internal_helpers"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildlogarchive"
+)
+
+const (
+	// maxArchivedLogBytes is how much of the end of a build log is archived.
+	maxArchivedLogBytes = 20 * 1024 * 1024
+	// archiveTimeout bounds the time spent archiving the log of a build.
+	archiveTimeout = 5 * time.Minute
+	// maxConcurrentArchives is how many build logs are archived at the same time.
+	maxConcurrentArchives = 4
+	// maxQueuedArchives bounds the build logs waiting to be archived or deleted, further ones are
+	// dropped until the queue drains.
+	maxQueuedArchives = 1000
+	// archiveCatchUpInterval is how often the logs of the recently completed builds which were not
+	// archived are caught up.
+	archiveCatchUpInterval = 10 * time.Minute
+	// archiveCatchUpWindow is how long after their completion the logs of builds are caught up,
+	// older builds have usually lost their pod and their log with it.
+	archiveCatchUpWindow = 24 * time.Hour
+)
+
+// archivedLog returns the archived log of a build whose pod is gone, limited as requested.
+func (r *REST) archivedLog(ctx context.Context, namespace, name string, uid types.UID, opts *buildapi.BuildLogOptions) (*apiserverrest.PassThroughStreamer, error) {
+	reader, err := r.Archive.Get(ctx, namespace, name, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &apiserverrest.PassThroughStreamer{
+		In:          io.NopCloser(bytes.NewReader(limitLog(data, opts.TailLines, opts.LimitBytes))),
+		ContentType: "text/plain",
+	}, nil
+}
+
+// limitLog returns the last tailLines lines of the log, then at most its first limitBytes bytes,
+// like the kubelet does for container logs.
+func limitLog(data []byte, tailLines, limitBytes *int64) []byte {
+	if tailLines != nil {
+		lines := *tailLines
+		end := len(data)
+		// a trailing newline does not start a line
+		if end > 0 && data[end-1] == '\n' {
+			end--
+		}
+		start := 0
+		for i := end - 1; i >= 0; i-- {
+			if data[i] != '\n' {
+				continue
+			}
+			if lines--; lines <= 0 {
+				start = i + 1
+				break
+			}
+		}
+		if *tailLines <= 0 {
+			start = len(data)
+		}
+		data = data[start:]
+	}
+	if limitBytes != nil && *limitBytes < int64(len(data)) {
+		data = data[:*limitBytes]
+	}
+	return data
+}
+
+// ArchiveBuild queues the log of a build which reached a terminal phase to be archived by
+// RunArchiver. Only the end of very long logs is kept. If the queue is full, the log is left to the
+// catch-up of RunArchiver.
+func (r *REST) ArchiveBuild(build *buildapi.Build) {
+	if r.Archive == nil {
+		return
+	}
+	if !r.queueArchive(build.Namespace, build.Name, build.UID) {
+		klog.V(2).Infof("the build log archive queue is full, the log of build %s/%s is left to the catch-up", build.Namespace, build.Name)
+	}
+}
+
+// DeleteArchivedLog queues the archived log of a deleted build to be deleted by RunArchiver. If the
+// queue is full, the log is left to the pruning of the archive.
+func (r *REST) DeleteArchivedLog(build *buildapi.Build) {
+	if r.Archive == nil {
+		return
+	}
+	namespace, name, uid := build.Namespace, build.Name, build.UID
+	if !r.queue(func(ctx context.Context) {
+		if err := r.Archive.Delete(ctx, namespace, name, uid); err != nil {
+			klog.V(2).Infof("unable to delete the archived log of build %s/%s: %v", namespace, name, err)
+		}
+	}) {
+		klog.V(2).Infof("the build log archive queue is full, the archived log of build %s/%s is left to the pruning", namespace, name)
+	}
+}
+
+// queueArchive queues the log of a build to be archived, it returns false if the queue is full.
+func (r *REST) queueArchive(namespace, name string, uid types.UID) bool {
+	return r.queue(func(ctx context.Context) {
+		if err := r.archiveBuild(ctx, namespace, name, uid); err != nil {
+			klog.V(2).Infof("unable to archive the log of build %s/%s: %v", namespace, name, err)
+		}
+	})
+}
+
+// queue queues fn to be run with the archive, it returns false if the queue is full.
+func (r *REST) queue(fn func(ctx context.Context)) bool {
+	select {
+	case r.archiveQueue <- fn:
+		return true
+	default:
+		return false
+	}
+}
+
+// RunArchiver archives and deletes the queued build logs until stopCh is closed. The logs of the
+// builds completed within archiveCatchUpWindow which are not archived, because the queue was full
+// or the apiserver restarted before archiving them, are caught up periodically.
+func (r *REST) RunArchiver(stopCh <-chan struct{}) {
+	ctx := wait.ContextForChannel(stopCh)
+	for i := 0; i < maxConcurrentArchives; i++ {
+		go wait.UntilWithContext(ctx, r.runArchiveWorker, time.Second)
+	}
+	wait.JitterUntilWithContext(ctx, r.catchUpArchive, archiveCatchUpInterval, 0.1, true)
+}
+
+// runArchiveWorker runs the queued tasks, each within archiveTimeout, until ctx is done.
+func (r *REST) runArchiveWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fn := <-r.archiveQueue:
+			func() {
+				ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+				defer cancel()
+				fn(ctx)
+			}()
+		}
+	}
+}
+
+// catchUpArchive queues the logs of the builds completed within archiveCatchUpWindow which are not
+// archived.
+func (r *REST) catchUpArchive(ctx context.Context) {
+	builds, err := r.BuildClient.Builds(metav1.NamespaceAll).List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		klog.V(2).Infof("unable to list the builds to catch up the build log archive: %v", err)
+		return
+	}
+	since := time.Now().Add(-archiveCatchUpWindow)
+	for i := range builds.Items {
+		build := &builds.Items[i]
+		if completed := build.Status.CompletionTimestamp; completed == nil || completed.Time.Before(since) || !buildinternalhelpers.IsTerminalPhase(buildapi.BuildPhase(build.Status.Phase)) {
+			continue
+		}
+		reader, err := r.Archive.Get(ctx, build.Namespace, build.Name, build.UID)
+		if err == nil {
+			reader.Close()
+			continue
+		}
+		if !errors.Is(err, buildlogarchive.ErrNotFound) {
+			klog.V(2).Infof("unable to check the archived log of build %s/%s: %v", build.Namespace, build.Name, err)
+			continue
+		}
+		if !r.queueArchive(build.Namespace, build.Name, build.UID) {
+			// the rest is caught up next time
+			return
+		}
+	}
+}
+
+// PruneArchive deletes the logs archived for longer than retention.
+func (r *REST) PruneArchive(ctx context.Context, retention time.Duration) {
+	if err := r.Archive.Prune(ctx, time.Now().Add(-retention)); err != nil {
+		klog.V(2).Infof("unable to prune the build log archive: %v", err)
+	}
+}
+
+func (r *REST) archiveBuild(ctx context.Context, namespace, name string, uid types.UID) error {
+	ctx = apirequest.WithNamespace(ctx, namespace)
+	obj, err := r.Get(ctx, name, &buildapi.BuildLogOptions{})
+	if err != nil {
+		return err
+	}
+	streamer, ok := obj.(rest.ResourceStreamer)
+	if !ok {
+		return errors.New("the build log is not a stream")
+	}
+	reader, _, _, err := streamer.InputStream(ctx, "v1", "text/plain")
+	if err != nil {
+		return err
+	}
+	if reader == nil {
+		return nil
+	}
+	defer reader.Close()
+
+	log := &tailBuffer{max: maxArchivedLogBytes}
+	if _, err := io.Copy(log, reader); err != nil {
+		return err
+	}
+	// the build may have been replaced while its log was read
+	build, err := r.BuildClient.Builds(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if build.UID != uid {
+		return fmt.Errorf("the build was replaced by %s", build.UID)
+	}
+	return r.Archive.Put(ctx, namespace, name, uid, bytes.NewReader(log.bytes()))
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max  int
+	data []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.data = append(t.data, p...)
+	// trim lazily, the buffer grows to at most twice the kept size
+	if len(t.data) > 2*t.max {
+		t.data = append(t.data[:0], t.data[len(t.data)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) bytes() []byte {
+	if len(t.data) > t.max {
+		return t.data[len(t.data)-t.max:]
+	}
+	return t.data
+}
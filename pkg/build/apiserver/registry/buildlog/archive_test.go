@@ -0,0 +1,204 @@
+package buildlog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	buildfakeclient "github.com/openshift/client-go/build/clientset/versioned/fake"
+	apiserverrest "github.com/openshift/openshift-apiserver/pkg/apiserver/rest"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildlogarchive"
+)
+
+func TestLimitLog(t *testing.T) {
+	log := []byte("one\ntwo\nthree\n")
+	for name, tc := range map[string]struct {
+		tailLines  *int64
+		limitBytes *int64
+		expected   string
+	}{
+		"unlimited":        {expected: "one\ntwo\nthree\n"},
+		"tail":             {tailLines: ptr.To[int64](2), expected: "two\nthree\n"},
+		"tail all":         {tailLines: ptr.To[int64](5), expected: "one\ntwo\nthree\n"},
+		"tail none":        {tailLines: ptr.To[int64](0), expected: ""},
+		"limit":            {limitBytes: ptr.To[int64](5), expected: "one\nt"},
+		"tail, then limit": {tailLines: ptr.To[int64](2), limitBytes: ptr.To[int64](6), expected: "two\nth"},
+	} {
+		if actual := string(limitLog(log, tc.tailLines, tc.limitBytes)); actual != tc.expected {
+			t.Errorf("%s: expected %q, got %q", name, tc.expected, actual)
+		}
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	buffer := &tailBuffer{max: 4}
+	for _, s := range []string{"ab", "cdefghi", "jk"} {
+		buffer.Write([]byte(s))
+	}
+	if actual := string(buffer.bytes()); actual != "hijk" {
+		t.Errorf("expected the end of the log, got %q", actual)
+	}
+}
+
+func TestArchivedBuildLog(t *testing.T) {
+	archive, err := buildlogarchive.NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	podClient := fake.NewSimpleClientset(mockPod(corev1.PodSucceeded, "bc-1-build"))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	podInformer := fakeCoreV1PodInformer(podClient, stopCh)
+	if !cache.WaitForCacheSync(stopCh, podInformer.Informer().HasSynced) {
+		t.Fatal("the pod informer did not sync")
+	}
+
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "bc-1", Namespace: metav1.NamespaceDefault, UID: "4c1d8c4e-0f4e-4b8e-9a3c-6f1e2d3c4b5a"},
+		Status:     buildv1.BuildStatus{Phase: buildv1.BuildPhaseComplete},
+	}
+	storage := NewREST(buildfakeclient.NewSimpleClientset(build).BuildV1(), podClient.CoreV1(), podInformer.Lister())
+	storage.Archive = archive
+
+	ctx, cancel := context.WithTimeout(apirequest.NewDefaultContext(), 10*time.Second)
+	defer cancel()
+	if err := storage.archiveBuild(ctx, metav1.NamespaceDefault, "bc-1", "9f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a"); err == nil {
+		t.Error("expected the log of a replaced build not to be archived")
+	}
+	if err := storage.archiveBuild(ctx, metav1.NamespaceDefault, "bc-1", build.UID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the archived log is served once the build pod is gone
+	if err := podClient.CoreV1().Pods(metav1.NamespaceDefault).Delete(ctx, "bc-1-build", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.WaitForCacheSync(stopCh, func() bool {
+		_, err := podInformer.Lister().Pods(metav1.NamespaceDefault).Get("bc-1-build")
+		return err != nil
+	}) {
+		t.Fatal("the pod informer did not observe the deletion")
+	}
+	obj, err := storage.Get(ctx, "bc-1", &buildapi.BuildLogOptions{LimitBytes: ptr.To[int64](4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, _, contentType, err := obj.(*apiserverrest.PassThroughStreamer).InputStream(ctx, "v1", "*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	log, err := io.ReadAll(stream)
+	if err != nil || string(log) != "fake" || contentType != "text/plain" {
+		t.Errorf("unexpected archived log %q (%s): %v", log, contentType, err)
+	}
+
+	// builds without an archived log still report the missing pod
+	build2 := build.DeepCopy()
+	build2.Name = "bc-2"
+	storage.BuildClient = buildfakeclient.NewSimpleClientset(build2).BuildV1()
+	if _, err := storage.Get(ctx, "bc-2", &buildapi.BuildLogOptions{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the missing pod to be reported, got %v", err)
+	}
+
+	// a build recreated with the name of an archived one is not served its log
+	recreated := build.DeepCopy()
+	recreated.UID = "9f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a"
+	storage.BuildClient = buildfakeclient.NewSimpleClientset(recreated).BuildV1()
+	if _, err := storage.Get(ctx, "bc-1", &buildapi.BuildLogOptions{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the log of the deleted build not to be served, got %v", err)
+	}
+
+	// the archived log is deleted with its build
+	go storage.RunArchiver(stopCh)
+	internal := &buildapi.Build{}
+	internal.Namespace, internal.Name, internal.UID = build.Namespace, build.Name, build.UID
+	storage.DeleteArchivedLog(internal)
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		_, err := archive.Get(ctx, build.Namespace, build.Name, build.UID)
+		return errors.Is(err, buildlogarchive.ErrNotFound), nil
+	})
+	if err != nil {
+		t.Errorf("expected the archived log to be deleted: %v", err)
+	}
+}
+
+func TestArchiveCatchUp(t *testing.T) {
+	archive, err := buildlogarchive.NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	podClient := fake.NewSimpleClientset(mockPod(corev1.PodSucceeded, "bc-1-build"))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	podInformer := fakeCoreV1PodInformer(podClient, stopCh)
+	if !cache.WaitForCacheSync(stopCh, podInformer.Informer().HasSynced) {
+		t.Fatal("the pod informer did not sync")
+	}
+
+	newBuild := func(name, uid string, phase buildv1.BuildPhase, completed time.Duration) *buildv1.Build {
+		build := &buildv1.Build{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault, UID: types.UID(uid)},
+			Status:     buildv1.BuildStatus{Phase: phase},
+		}
+		if completed > 0 {
+			build.Status.CompletionTimestamp = &metav1.Time{Time: time.Now().Add(-completed)}
+		}
+		return build
+	}
+	recent := newBuild("bc-1", "4c1d8c4e-0f4e-4b8e-9a3c-6f1e2d3c4b5a", buildv1.BuildPhaseComplete, time.Hour)
+	archived := newBuild("bc-2", "9f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a", buildv1.BuildPhaseFailed, time.Hour)
+	old := newBuild("bc-3", "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d", buildv1.BuildPhaseComplete, 2*archiveCatchUpWindow)
+	running := newBuild("bc-4", "5e6f7a8b-9c0d-4e1f-a2b3-c4d5e6f7a8b9", buildv1.BuildPhaseRunning, 0)
+	storage := NewREST(buildfakeclient.NewSimpleClientset(recent, archived, old, running).BuildV1(), podClient.CoreV1(), podInformer.Lister())
+	storage.Archive = archive
+
+	ctx, cancel := context.WithTimeout(apirequest.NewDefaultContext(), 10*time.Second)
+	defer cancel()
+	if err := archive.Put(ctx, archived.Namespace, archived.Name, archived.UID, strings.NewReader("archived")); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the recently completed build without an archived log is caught up
+	storage.catchUpArchive(ctx)
+	if len(storage.archiveQueue) != 1 {
+		t.Fatalf("expected the log of one build to be queued, got %d", len(storage.archiveQueue))
+	}
+	(<-storage.archiveQueue)(ctx)
+	reader, err := archive.Get(ctx, recent.Namespace, recent.Name, recent.UID)
+	if err != nil {
+		t.Fatalf("expected the log of the recent build to be archived: %v", err)
+	}
+	reader.Close()
+}
+
+func TestArchiveQueueIsBounded(t *testing.T) {
+	archive, err := buildlogarchive.NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := NewREST(buildfakeclient.NewSimpleClientset().BuildV1(), fake.NewSimpleClientset().CoreV1(), nil)
+	storage.Archive = archive
+	build := &buildapi.Build{}
+	for i := 0; i < maxQueuedArchives+10; i++ {
+		storage.ArchiveBuild(build)
+		storage.DeleteArchivedLog(build)
+	}
+	if len(storage.archiveQueue) != maxQueuedArchives {
+		t.Errorf("expected the queue to be bounded to %d, got %d", maxQueuedArchives, len(storage.archiveQueue))
+	}
+}
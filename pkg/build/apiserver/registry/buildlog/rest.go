@@ -31,6 +31,7 @@ import (
 	buildinternalhelpers "github.com/openshift/openshift-apiserver/pkg/build/apis/build/internal_helpers"
 	"github.com/openshift/openshift-apiserver/pkg/build/apis/build/validation"
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/apiserverbuildutil"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildlogarchive"
 	buildwait "github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/wait"
 )
 
@@ -40,6 +41,19 @@ type REST struct {
 	PodClient   kubetypedclient.PodsGetter
 	PodLister   corev1listers.PodLister
 	Timeout     time.Duration
+	// SplitStreams requests the stdout and stderr of the build containers separately for the
+	// structured build log. It requires the PodLogsQuerySplitStreams feature of the kube-apiserver.
+	SplitStreams bool
+	// Archive keeps the logs of completed builds, they are served from it once the build pods
+	// are gone. Optional.
+	Archive buildlogarchive.Archive
+
+	// the build logs waiting to be archived or deleted by RunArchiver
+	archiveQueue chan func(ctx context.Context)
+
+	// how long the structured build log waits for the exit code of a container whose logs ended
+	endPollInterval time.Duration
+	endPollTimeout  time.Duration
 
 	// for unit testing
 	getSimpleLogsFn func(ctx context.Context, podNamespace, podName string, logOpts *kapi.PodLogOptions) (runtime.Object, error)
@@ -56,6 +70,11 @@ func NewREST(buildClient buildtypedclient.BuildsGetter, podClient kubetypedclien
 		PodClient:   podClient,
 		PodLister:   podLister,
 		Timeout:     defaultTimeout,
+
+		endPollInterval: 500 * time.Millisecond,
+		endPollTimeout:  5 * time.Second,
+
+		archiveQueue: make(chan func(ctx context.Context), maxQueuedArchives),
 	}
 	r.getSimpleLogsFn = r.getSimpleLogs
 	return r
@@ -131,6 +150,16 @@ func (r *REST) Get(ctx context.Context, name string, opts runtime.Object) (runti
 	// error out now.
 	buildPod, err := r.PodLister.Pods(build.Namespace).Get(buildPodName)
 	if err != nil {
+		// the build pod may have been deleted after the log of the build was archived
+		if errors.IsNotFound(err) && r.Archive != nil {
+			archived, archiveErr := r.archivedLog(ctx, build.Namespace, build.Name, build.UID, buildLogOpts)
+			if archiveErr == nil {
+				return archived, nil
+			}
+			if archiveErr != buildlogarchive.ErrNotFound {
+				klog.V(2).Infof("unable to read the archived log of build %s/%s: %v", build.Namespace, build.Name, archiveErr)
+			}
+		}
 		return nil, errors.NewBadRequest(err.Error())
 	}
 
@@ -143,21 +172,15 @@ func (r *REST) Get(ctx context.Context, name string, opts runtime.Object) (runti
 
 	// new style builds w/ init containers from here out.
 
-	// we'll funnel all the initcontainer+main container logs into this single stream
-	reader, writer := io.Pipe()
-	pipeStreamer := PipeStreamer{
-		In:          writer,
-		Out:         reader,
-		Flush:       buildLogOpts.Follow,
-		ContentType: "text/plain",
-	}
-
+	// we'll funnel all the initcontainer+main container logs into a single stream, plain
+	// or structured as accepted by the client.
+	//
 	// background thread will poll the init containers until they are running/terminated
 	// and then stream the logs from them into the pipe, one by one, before streaming
 	// the primary container logs into the pipe.  Any errors that occur will result
 	// in a premature return and aborted log stream.
-	go func() {
-		defer pipeStreamer.In.Close()
+	streamer := &logStreamer{r: r, flush: buildLogOpts.Follow}
+	streamer.stream = func(out buildLogWriter) {
 
 		// containers that we've successfully streamed the logs for and don't need
 		// to worry about it anymore.
@@ -188,10 +211,9 @@ func (r *REST) Get(ctx context.Context, name string, opts runtime.Object) (runti
 			// Get the latest version of the pod so we can check init container statuses
 			buildPod, err = r.PodLister.Pods(build.Namespace).Get(buildPodName)
 			if err != nil {
-				s := fmt.Sprintf("error retrieving build pod %s/%s : %v", build.Namespace, buildPodName, err.Error())
 				// we're sending the error message as the log output so the user at least sees some indication of why
 				// they didn't get the logs they expected.
-				pipeStreamer.In.Write([]byte(s))
+				out.errorf("error retrieving build pod %s/%s : %v", build.Namespace, buildPodName, err.Error())
 				return
 			}
 
@@ -236,10 +258,11 @@ func (r *REST) Get(ctx context.Context, name string, opts runtime.Object) (runti
 					containerLogOpts.Follow = false
 				}
 
-				if err := r.pipeLogs(ctx, build.Namespace, buildPodName, containerLogOpts, pipeStreamer.In); err != nil {
+				if err := out.logs(ctx, build.Namespace, buildPodName, containerLogOpts); err != nil {
 					klog.Errorf("error: failed to stream logs for build pod: %s/%s container: %s, due to: %v", build.Namespace, buildPodName, status.Name, err)
 					return
 				}
+				out.end(ctx, build.Namespace, buildPodName, status.Name)
 
 				// if we successfully streamed anything, don't wait before the next iteration
 				// of init container checking/streaming.
@@ -278,8 +301,7 @@ func (r *REST) Get(ctx context.Context, name string, opts runtime.Object) (runti
 			err := wait.PollImmediate(time.Second, 10*time.Minute, func() (bool, error) {
 				buildPod, err = r.PodLister.Pods(build.Namespace).Get(buildPodName)
 				if err != nil {
-					s := fmt.Sprintf("error while getting build logs, could not retrieve build pod %s/%s : %v", build.Namespace, buildPodName, err.Error())
-					pipeStreamer.In.Write([]byte(s))
+					out.errorf("error while getting build logs, could not retrieve build pod %s/%s : %v", build.Namespace, buildPodName, err.Error())
 					return false, err
 				}
 				// we can get logs from a pod in any state other than pending.
@@ -304,14 +326,15 @@ func (r *REST) Get(ctx context.Context, name string, opts runtime.Object) (runti
 				containerLogOpts.Follow = false
 			}
 
-			if err := r.pipeLogs(ctx, build.Namespace, buildPodName, containerLogOpts, pipeStreamer.In); err != nil {
+			if err := out.logs(ctx, build.Namespace, buildPodName, containerLogOpts); err != nil {
 				klog.Errorf("error: failed to stream logs for build pod: %s/%s due to: %v", build.Namespace, buildPodName, err)
 				return
 			}
+			out.end(ctx, build.Namespace, buildPodName, containerLogOpts.Container)
 		}
-	}()
+	}
 
-	return &pipeStreamer, nil
+	return streamer, nil
 }
 
 // BuildNameForConfigVersion returns the name of the version-th build
@@ -339,20 +362,30 @@ func (r *REST) New() runtime.Object {
 
 func (r *REST) Destroy() {}
 
-// pipeLogs retrieves the logs for a particular container and streams them into the provided writer.
-func (r *REST) pipeLogs(ctx context.Context, namespace, buildPodName string, containerLogOpts *kapi.PodLogOptions, writer io.Writer) error {
+// openLogs returns the log stream of a particular container.
+func (r *REST) openLogs(ctx context.Context, namespace, buildPodName string, containerLogOpts *kapi.PodLogOptions) (io.ReadCloser, error) {
 	klog.V(4).Infof("pulling build pod logs for %s/%s, container %s", namespace, buildPodName, containerLogOpts.Container)
 
 	options := &corev1.PodLogOptions{}
 	if err := v1.Convert_core_PodLogOptions_To_v1_PodLogOptions(containerLogOpts, options, nil); err != nil {
-		return err
+		return nil, err
 	}
 	logRequest := r.PodClient.Pods(namespace).GetLogs(buildPodName, options)
 	readerCloser, err := logRequest.Stream(ctx)
 	if err != nil {
 		klog.Errorf("error: could not write build log for pod %q to stream due to: %v", buildPodName, err)
+		return nil, err
+	}
+	return readerCloser, nil
+}
+
+// pipeLogs retrieves the logs for a particular container and streams them into the provided writer.
+func (r *REST) pipeLogs(ctx context.Context, namespace, buildPodName string, containerLogOpts *kapi.PodLogOptions, writer io.Writer) error {
+	readerCloser, err := r.openLogs(ctx, namespace, buildPodName, containerLogOpts)
+	if err != nil {
 		return err
 	}
+	defer readerCloser.Close()
 
 	klog.V(4).Infof("retrieved logs for build pod: %s/%s container: %s", namespace, buildPodName, containerLogOpts.Container)
 	// dump all container logs from the log stream into a single output stream that we'll send back to the client.
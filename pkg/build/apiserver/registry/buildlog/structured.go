@@ -0,0 +1,260 @@
+package buildlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+)
+
+// NDJSONContentType selects the structured build log when it is accepted by the client. Every line
+// of the structured log is a JSON encoded logEntry. Builds run by pods without init containers only
+// have a plain log.
+const NDJSONContentType = "application/x-ndjson"
+
+const (
+	logEntryStart = "start"
+	logEntryLog   = "log"
+	logEntryEnd   = "end"
+	logEntryError = "error"
+)
+
+// logEntry is a line of the structured build log. The logs of every build step, that is of every
+// container of the build pod, are enclosed in a start and an end entry; the end entry carries the
+// exit code of the step once it terminated.
+type logEntry struct {
+	// Type is one of start, log, end or error.
+	Type      string     `json:"type"`
+	Container string     `json:"container,omitempty"`
+	Time      *time.Time `json:"time,omitempty"`
+	// Stream is Stdout or Stderr if the streams of the containers are split, All otherwise.
+	Stream   string `json:"stream,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode *int32 `json:"exitCode,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// buildLogWriter writes the logs of the build steps to the response.
+type buildLogWriter interface {
+	// logs streams the logs of the container selected in opts.
+	logs(ctx context.Context, namespace, podName string, opts *kapi.PodLogOptions) error
+	// end marks the end of the logs of a container.
+	end(ctx context.Context, namespace, podName, container string)
+	// errorf reports an error which interrupts the logs.
+	errorf(format string, args ...interface{})
+}
+
+// textLogWriter writes the plain logs of the containers one after another.
+type textLogWriter struct {
+	r *REST
+	w io.Writer
+}
+
+func (t *textLogWriter) logs(ctx context.Context, namespace, podName string, opts *kapi.PodLogOptions) error {
+	return t.r.pipeLogs(ctx, namespace, podName, opts, t.w)
+}
+
+func (t *textLogWriter) end(ctx context.Context, namespace, podName, container string) {}
+
+func (t *textLogWriter) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(t.w, format, args...)
+}
+
+// ndjsonLogWriter writes the logs of the containers as logEntries.
+type ndjsonLogWriter struct {
+	r   *REST
+	now func() time.Time
+
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+func newNDJSONLogWriter(r *REST, w io.Writer) *ndjsonLogWriter {
+	return &ndjsonLogWriter{r: r, now: time.Now, encoder: json.NewEncoder(w)}
+}
+
+func (n *ndjsonLogWriter) write(entry *logEntry) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.encoder.Encode(entry)
+}
+
+func (n *ndjsonLogWriter) logs(ctx context.Context, namespace, podName string, opts *kapi.PodLogOptions) error {
+	now := n.now()
+	if err := n.write(&logEntry{Type: logEntryStart, Container: opts.Container, Time: &now}); err != nil {
+		return err
+	}
+
+	opts = opts.DeepCopy()
+	opts.Timestamps = true
+	if !n.r.SplitStreams {
+		return n.copy(ctx, namespace, podName, opts, corev1.LogStreamAll)
+	}
+
+	// the streams are requested separately, their lines are interleaved as they arrive
+	var wg sync.WaitGroup
+	streams := []string{corev1.LogStreamStdout, corev1.LogStreamStderr}
+	errs := make([]error, len(streams))
+	for i, stream := range streams {
+		wg.Add(1)
+		go func(i int, stream string) {
+			defer wg.Done()
+			streamOpts := opts.DeepCopy()
+			streamOpts.Stream = &stream
+			errs[i] = n.copy(ctx, namespace, podName, streamOpts, stream)
+		}(i, stream)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+// copy writes the lines of a log stream requested with timestamps as log entries.
+func (n *ndjsonLogWriter) copy(ctx context.Context, namespace, podName string, opts *kapi.PodLogOptions, stream string) error {
+	readCloser, err := n.r.openLogs(ctx, namespace, podName, opts)
+	if err != nil {
+		return err
+	}
+	defer readCloser.Close()
+
+	reader := bufio.NewReader(readCloser)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			entry := parseLogLine(line)
+			entry.Container = opts.Container
+			entry.Stream = stream
+			if err := n.write(entry); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseLogLine splits the timestamp from a log line.
+func parseLogLine(line string) *logEntry {
+	line = strings.TrimSuffix(line, "\n")
+	entry := &logEntry{Type: logEntryLog, Message: line}
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			entry.Time = &t
+			entry.Message = line[i+1:]
+		}
+	}
+	return entry
+}
+
+// end writes the exit code of the container. The status of the build pod may lag behind the end of
+// the logs, so it is waited for shortly.
+func (n *ndjsonLogWriter) end(ctx context.Context, namespace, podName, container string) {
+	interval, timeout := n.r.endPollInterval, n.r.endPollTimeout
+	if interval <= 0 {
+		interval, timeout = 500*time.Millisecond, 5*time.Second
+	}
+	var terminated *corev1.ContainerStateTerminated
+	wait.PollUntilContextTimeout(ctx, interval, timeout, true, func(context.Context) (bool, error) {
+		pod, err := n.r.PodLister.Pods(namespace).Get(podName)
+		if err != nil {
+			return false, nil
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.Name == container {
+				terminated = status.State.Terminated
+				return terminated != nil, nil
+			}
+		}
+		return false, nil
+	})
+
+	entry := &logEntry{Type: logEntryEnd, Container: container}
+	if terminated != nil {
+		exitCode := terminated.ExitCode
+		finishedAt := terminated.FinishedAt.Time
+		entry.ExitCode = &exitCode
+		entry.Reason = terminated.Reason
+		if !finishedAt.IsZero() {
+			entry.Time = &finishedAt
+		}
+	} else {
+		now := n.now()
+		entry.Time = &now
+	}
+	if err := n.write(entry); err != nil {
+		klog.V(4).Infof("unable to write the end of the logs of container %s of build pod %s/%s: %v", container, namespace, podName, err)
+	}
+}
+
+func (n *ndjsonLogWriter) errorf(format string, args ...interface{}) {
+	now := n.now()
+	n.write(&logEntry{Type: logEntryError, Time: &now, Message: fmt.Sprintf(format, args...)})
+}
+
+// acceptsNDJSON returns true if the client accepts the structured build log.
+func acceptsNDJSON(acceptHeader string) bool {
+	for _, accepted := range strings.Split(acceptHeader, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != NDJSONContentType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err != nil || q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// logStreamer streams the logs of a build pod with init containers. The logs are written once the
+// format accepted by the client is known.
+type logStreamer struct {
+	r      *REST
+	flush  bool
+	stream func(out buildLogWriter)
+}
+
+// a logStreamer must implement a rest.ResourceStreamer
+var _ rest.ResourceStreamer = &logStreamer{}
+
+func (s *logStreamer) GetObjectKind() schema.ObjectKind {
+	return schema.EmptyObjectKind
+}
+
+func (s *logStreamer) DeepCopyObject() runtime.Object {
+	panic("buildlog.logStreamer does not implement DeepCopyObject")
+}
+
+// InputStream returns the build log, structured if the client accepts it.
+func (s *logStreamer) InputStream(ctx context.Context, apiVersion, acceptHeader string) (io.ReadCloser, bool, string, error) {
+	reader, writer := io.Pipe()
+	var out buildLogWriter = &textLogWriter{r: s.r, w: writer}
+	contentType := "text/plain"
+	if acceptsNDJSON(acceptHeader) {
+		out = newNDJSONLogWriter(s.r, writer)
+		contentType = NDJSONContentType
+	}
+	go func() {
+		defer writer.Close()
+		s.stream(out)
+	}()
+	return reader, s.flush, contentType, nil
+}
@@ -0,0 +1,150 @@
+package buildlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	buildfakeclient "github.com/openshift/client-go/build/clientset/versioned/fake"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+)
+
+func TestParseLogLine(t *testing.T) {
+	entry := parseLogLine("2024-05-07T14:12:07.123456789Z Cloning \"https://example.com/repo.git\" ...\n")
+	if entry.Time == nil || !entry.Time.Equal(time.Date(2024, 5, 7, 14, 12, 7, 123456789, time.UTC)) {
+		t.Errorf("unexpected time %v", entry.Time)
+	}
+	if entry.Message != `Cloning "https://example.com/repo.git" ...` {
+		t.Errorf("unexpected message %q", entry.Message)
+	}
+
+	entry = parseLogLine("no timestamp")
+	if entry.Time != nil || entry.Message != "no timestamp" {
+		t.Errorf("unexpected entry %#v", entry)
+	}
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"application/json, */*":             false,
+		"application/x-ndjson":              true,
+		"application/x-ndjson, */*;q=0.1":   true,
+		"text/plain, application/x-ndjson":  true,
+		"application/x-ndjson;q=0, */*":     false,
+		"application/x-ndjson;q=0.5, */*;q": true,
+	} {
+		if actual := acceptsNDJSON(accept); actual != expected {
+			t.Errorf("%q: expected %t, got %t", accept, expected, actual)
+		}
+	}
+}
+
+func TestStructuredBuildLog(t *testing.T) {
+	pod := mockPod(corev1.PodFailed, "bc-1-build")
+	pod.Spec.InitContainers = []corev1.Container{{Name: "git-clone"}}
+	pod.Spec.Containers = []corev1.Container{{Name: "sti-build"}}
+	finishedAt := metav1.NewTime(time.Date(2024, 5, 7, 14, 12, 7, 0, time.UTC))
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{Name: "git-clone", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0, Reason: "Completed", FinishedAt: finishedAt}}},
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "sti-build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error", FinishedAt: finishedAt}}},
+	}
+	podClient := fake.NewSimpleClientset(pod)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "bc-1", Namespace: metav1.NamespaceDefault},
+		Status:     buildv1.BuildStatus{Phase: buildv1.BuildPhaseFailed},
+	}
+	podInformer := fakeCoreV1PodInformer(podClient, stopCh)
+	if !cache.WaitForCacheSync(stopCh, podInformer.Informer().HasSynced) {
+		t.Fatal("the pod informer did not sync")
+	}
+	storage := REST{
+		BuildClient: buildfakeclient.NewSimpleClientset(build).BuildV1(),
+		PodClient:   podClient.CoreV1(),
+		PodLister:   podInformer.Lister(),
+		Timeout:     defaultTimeout,
+
+		endPollInterval: time.Millisecond,
+		endPollTimeout:  time.Second,
+	}
+
+	obj, err := storage.Get(apirequest.NewDefaultContext(), "bc-1", &buildapi.BuildLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, _, contentType, err := obj.(*logStreamer).InputStream(apirequest.NewDefaultContext(), "v1", "application/x-ndjson, */*;q=0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if contentType != NDJSONContentType {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+
+	var entries []logEntry
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	expected := []logEntry{
+		{Type: logEntryStart, Container: "git-clone"},
+		{Type: logEntryLog, Container: "git-clone", Stream: corev1.LogStreamAll, Message: "fake logs"},
+		{Type: logEntryEnd, Container: "git-clone", ExitCode: new(int32), Reason: "Completed"},
+		{Type: logEntryStart, Container: "sti-build"},
+		{Type: logEntryLog, Container: "sti-build", Stream: corev1.LogStreamAll, Message: "fake logs"},
+		{Type: logEntryEnd, Container: "sti-build", ExitCode: func() *int32 { i := int32(1); return &i }(), Reason: "Error"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %#v", len(expected), entries)
+	}
+	for i := range expected {
+		e, a := expected[i], entries[i]
+		if e.Type != a.Type || e.Container != a.Container || e.Stream != a.Stream || e.Message != a.Message || e.Reason != a.Reason {
+			t.Errorf("%d: expected %#v, got %#v", i, e, a)
+		}
+		if (e.ExitCode == nil) != (a.ExitCode == nil) || (e.ExitCode != nil && *e.ExitCode != *a.ExitCode) {
+			t.Errorf("%d: expected exit code %v, got %v", i, e.ExitCode, a.ExitCode)
+		}
+		if a.Type == logEntryEnd && (a.Time == nil || !a.Time.Equal(finishedAt.Time)) {
+			t.Errorf("%d: expected the end at %s, got %v", i, finishedAt, a.Time)
+		}
+	}
+
+	// the plain log is unchanged
+	obj, err = storage.Get(apirequest.NewDefaultContext(), "bc-1", &buildapi.BuildLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, _, contentType, err = obj.(*logStreamer).InputStream(apirequest.NewDefaultContext(), "v1", "*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	plain, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "text/plain" || string(plain) != "fake logsfake logs" {
+		t.Errorf("unexpected plain log %q (%s)", plain, contentType)
+	}
+}
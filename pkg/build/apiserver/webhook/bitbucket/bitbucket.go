@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -71,6 +72,48 @@ type ref struct {
 	DisplayID string `json:"displayId"`
 }
 
+// A pull request event for Bitbucket Cloud webhooks.
+type pullRequestEvent struct {
+	PullRequest pullRequest `json:"pullrequest"`
+}
+
+type pullRequest struct {
+	ID          int                 `json:"id"`
+	Title       string              `json:"title"`
+	Author      user                `json:"author"`
+	Source      pullRequestEndpoint `json:"source"`
+	Destination pullRequestEndpoint `json:"destination"`
+}
+
+type pullRequestEndpoint struct {
+	Branch     info       `json:"branch"`
+	Commit     commit     `json:"commit"`
+	Repository repository `json:"repository"`
+}
+
+type repository struct {
+	FullName string `json:"full_name"`
+}
+
+// A pull request event for Bitbucket Server webhooks.
+type pullRequestEvent54 struct {
+	PullRequest pullRequest54 `json:"pullRequest"`
+}
+
+type pullRequest54 struct {
+	ID      int            `json:"id"`
+	Title   string         `json:"title"`
+	FromRef pullRequestRef `json:"fromRef"`
+	ToRef   pullRequestRef `json:"toRef"`
+}
+
+type pullRequestRef struct {
+	DisplayID    string `json:"displayId"`
+	LatestCommit string `json:"latestCommit"`
+}
+
+var _ webhook.PullRequestPlugin = &WebHookPlugin{}
+
 // Extract services webhooks from bitbucket.com
 func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (revision *buildv1.SourceRevision, envvars []corev1.EnvVar, dockerStrategyOptions *buildv1.DockerStrategyOptions, proceed bool, err error) {
 	klog.V(4).Infof("Verifying build request for BuildConfig %s/%s", buildCfg.Namespace, buildCfg.Name)
@@ -100,12 +143,110 @@ func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.
 
 	if !webhook.GitRefMatches(branch, webhook.DefaultConfigRef, &buildCfg.Spec.Source) {
 		klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Branch reference '%s' does not match configuration", buildCfg.Namespace, buildCfg.Name, branch)
-		return revision, envvars, dockerStrategyOptions, false, err
+		return revision, envvars, dockerStrategyOptions, false, webhook.NewWarning(fmt.Sprintf("skipping build. Branch reference %q does not match configuration", branch))
 	}
+	// Bitbucket push payloads do not list the changed files, the path filters of the
+	// BuildConfig do not apply.
 
 	return revision, envvars, dockerStrategyOptions, true, err
 }
 
+// IsPullRequest returns true for the pull request events of Bitbucket Cloud and Server.
+func (p *WebHookPlugin) IsPullRequest(req *http.Request) bool {
+	event := getEvent(req.Header)
+	return strings.HasPrefix(event, "pullrequest:") || strings.HasPrefix(event, "pr:")
+}
+
+// ExtractPullRequest services the pull request webhooks from bitbucket.com and Bitbucket Server.
+// Created and updated pull requests are built. Bitbucket Cloud does not provide git references
+// for pull requests, their builds check out their source branch, which must be in the same
+// repository.
+func (p *WebHookPlugin) ExtractPullRequest(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (*buildv1.SourceRevision, *webhook.PullRequest, bool, error) {
+	klog.V(4).Infof("Verifying pull request build request for BuildConfig %s/%s", buildCfg.Namespace, buildCfg.Name)
+	if err := verifyRequest(req); err != nil {
+		return nil, nil, false, err
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, false, errors.NewBadRequest(err.Error())
+	}
+
+	var revision *buildv1.SourceRevision
+	var pullRequest *webhook.PullRequest
+	closed := false
+	switch method := getEvent(req.Header); method {
+	// https://support.atlassian.com/bitbucket-cloud/docs/event-payloads/#Pull-request-events
+	case "pullrequest:created", "pullrequest:updated", "pullrequest:fulfilled", "pullrequest:rejected":
+		var event pullRequestEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, nil, false, errors.NewBadRequest(err.Error())
+		}
+		pr := event.PullRequest
+		if err := webhook.CheckPullRequestTarget(buildCfg, pr.Destination.Branch.Name); err != nil {
+			return nil, nil, false, err
+		}
+		if pr.Source.Repository.FullName != pr.Destination.Repository.FullName {
+			return nil, nil, false, webhook.NewWarning(fmt.Sprintf("skipping build. The pull requests from the fork %s cannot be built", pr.Source.Repository.FullName))
+		}
+		if webhook.BuildsPullRequestMergeRef(buildCfg) {
+			return nil, nil, false, webhook.NewWarning("skipping build. Bitbucket Cloud does not provide the merge refs of pull requests")
+		}
+		pullRequest = &webhook.PullRequest{
+			Number:       pr.ID,
+			SourceBranch: pr.Source.Branch.Name,
+			TargetBranch: pr.Destination.Branch.Name,
+			Ref:          "refs/heads/" + pr.Source.Branch.Name,
+		}
+		author := buildv1.SourceControlUser{Name: pr.Author.DisplayName}
+		revision = &buildv1.SourceRevision{
+			Git: &buildv1.GitSourceRevision{
+				Commit:    pr.Source.Commit.Hash,
+				Author:    author,
+				Committer: author,
+				Message:   pr.Title,
+			},
+		}
+		closed = method == "pullrequest:fulfilled" || method == "pullrequest:rejected"
+
+	// https://confluence.atlassian.com/bitbucketserver/event-payload-938025882.html
+	case "pr:opened", "pr:from_ref_updated", "pr:merged", "pr:declined", "pr:deleted":
+		var event pullRequestEvent54
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, nil, false, errors.NewBadRequest(err.Error())
+		}
+		pr := event.PullRequest
+		if err := webhook.CheckPullRequestTarget(buildCfg, pr.ToRef.DisplayID); err != nil {
+			return nil, nil, false, err
+		}
+		pullRequest = &webhook.PullRequest{
+			Number:       pr.ID,
+			SourceBranch: pr.FromRef.DisplayID,
+			TargetBranch: pr.ToRef.DisplayID,
+			Ref:          fmt.Sprintf("refs/pull-requests/%d/from", pr.ID),
+		}
+		revision = &buildv1.SourceRevision{
+			Git: &buildv1.GitSourceRevision{
+				Commit:  pr.FromRef.LatestCommit,
+				Message: pr.Title,
+			},
+		}
+		if webhook.BuildsPullRequestMergeRef(buildCfg) {
+			pullRequest.Ref = fmt.Sprintf("refs/pull-requests/%d/merge", pr.ID)
+			revision.Git.Commit = ""
+		}
+		closed = method == "pr:merged" || method == "pr:declined" || method == "pr:deleted"
+
+	default:
+		return nil, nil, false, webhook.NewWarning(fmt.Sprintf("skipping build. Pull request event %q does not trigger builds", method))
+	}
+
+	if closed {
+		pullRequest.Closed = true
+		return nil, pullRequest, true, nil
+	}
+	return revision, pullRequest, true, nil
+}
+
 // GetTriggers retrieves the WebHookTriggers for this webhook type (if any)
 func (p *WebHookPlugin) GetTriggers(buildConfig *buildv1.BuildConfig) ([]*buildv1.WebHookTrigger, error) {
 	triggers := buildutil.FindTriggerPolicy(buildv1.BitbucketWebHookBuildTriggerType, buildConfig)
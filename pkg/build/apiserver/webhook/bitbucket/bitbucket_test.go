@@ -10,6 +10,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
 )
 
 var mockBuildStrategy = buildv1.BuildStrategy{
@@ -334,8 +335,8 @@ func TestExtractSkipsBuildForUnmatchedBranches(t *testing.T) {
 
 	//execute
 	_, _, _, proceed, err := context.plugin.Extract(context.buildCfg, buildConfig.Spec.Triggers[0].BitbucketWebHook, context.req)
-	if err != nil {
-		t.Errorf("unexpected error %v", err)
+	if err == nil || err.Error() != `skipping build. Branch reference "master" does not match configuration` {
+		t.Errorf("Expecting a warning for the unmatched branch, got %v", err)
 	}
 	if proceed {
 		t.Errorf("Expecting to not continue from this event because the branch is not for this buildConfig '%s'", context.buildCfg.Spec.Source.Git.Ref)
@@ -348,8 +349,8 @@ func TestExtractSkipsBuildForUnmatchedBranches54(t *testing.T) {
 
 	//execute
 	_, _, _, proceed, err := context.plugin.Extract(context.buildCfg, buildConfig.Spec.Triggers[0].BitbucketWebHook, context.req)
-	if err != nil {
-		t.Errorf("unexpected error %v", err)
+	if err == nil || err.Error() != `skipping build. Branch reference "master" does not match configuration` {
+		t.Errorf("Expecting a warning for the unmatched branch, got %v", err)
 	}
 	if proceed {
 		t.Errorf("Expecting to not continue from this event because the branch is not for this buildConfig '%s'", context.buildCfg.Spec.Source.Git.Ref)
@@ -396,3 +397,86 @@ func TestExtractErrorForWrongEventPayload54(t *testing.T) {
 	}
 
 }
+
+func TestExtractPullRequest(t *testing.T) {
+	for _, tc := range []struct {
+		filename, event, ref, expectedRef string
+		annotations                       map[string]string
+		closed                            bool
+		warning                           string
+	}{
+		{
+			filename:    "pullrequestevent.json",
+			event:       "pullrequest:created",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			expectedRef: "refs/heads/feature/login",
+		},
+		{
+			filename:    "pullrequestevent.json",
+			event:       "pullrequest:fulfilled",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			closed:      true,
+		},
+		{
+			filename:    "pullrequestevent.json",
+			event:       "pullrequest:created",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master", buildapi.BuildConfigWebHookPullRequestRefAnnotation: "merge"},
+			warning:     "skipping build. Bitbucket Cloud does not provide the merge refs of pull requests",
+		},
+		{
+			filename: "pullrequestevent.json",
+			event:    "pullrequest:created",
+			warning:  "skipping build. Pull requests do not trigger builds of the BuildConfig",
+		},
+		{
+			filename:    "pullrequestevent54.json",
+			event:       "pr:opened",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "*"},
+			expectedRef: "refs/pull-requests/12/from",
+		},
+		{
+			filename:    "pullrequestevent54.json",
+			event:       "pr:opened",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "*", buildapi.BuildConfigWebHookPullRequestRefAnnotation: "merge"},
+			expectedRef: "refs/pull-requests/12/merge",
+		},
+		{
+			filename:    "pullrequestevent54.json",
+			event:       "pr:declined",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "*"},
+			closed:      true,
+		},
+		{
+			filename:    "pullrequestevent54.json",
+			event:       "pr:opened",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "release-*"},
+			warning:     `skipping build. Pull requests into "master" do not trigger builds of the BuildConfig`,
+		},
+	} {
+		context := setup(t, tc.filename, tc.event, "")
+		context.buildCfg.Annotations = tc.annotations
+		if !context.plugin.IsPullRequest(context.req) {
+			t.Fatalf("%s: expected a pull request event", tc.event)
+		}
+		revision, pullRequest, proceed, err := context.plugin.ExtractPullRequest(context.buildCfg, context.buildCfg.Spec.Triggers[0].BitbucketWebHook, context.req)
+		if len(tc.warning) > 0 {
+			if proceed || err == nil || err.Error() != tc.warning {
+				t.Errorf("%s: expected the warning %q, got %v", tc.event, tc.warning, err)
+			}
+			continue
+		}
+		if !proceed || err != nil {
+			t.Errorf("%s: unexpected error %v", tc.event, err)
+			continue
+		}
+		if pullRequest.Number != 12 || pullRequest.SourceBranch != "feature/login" || pullRequest.TargetBranch != "master" || pullRequest.Closed != tc.closed {
+			t.Errorf("%s: unexpected pull request %#v", tc.event, pullRequest)
+		}
+		if tc.closed {
+			continue
+		}
+		if pullRequest.Ref != tc.expectedRef || revision == nil || revision.Git.Message != "Add a login page" {
+			t.Errorf("%s: unexpected ref %s and revision %#v", tc.event, pullRequest.Ref, revision)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubernetes "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+
+	buildv1 "github.com/openshift/api/build/v1"
+)
+
+const (
+	// MaxDeliveries is the number of webhook deliveries recorded for a BuildConfig.
+	MaxDeliveries = 20
+	// maxDeliveriesBytes bounds the size of the deliveries recorded for a BuildConfig, the oldest
+	// ones are dropped first.
+	maxDeliveriesBytes = 64 * 1024
+	// deliveriesConfigMapPrefix prefixes the UID of a BuildConfig in the name of the config map
+	// keeping its deliveries.
+	deliveriesConfigMapPrefix = "webhook-deliveries-"
+	// deliveriesKey is the key of the JSON encoded deliveries in their config map.
+	deliveriesKey = "deliveries"
+)
+
+// DeliveryResult is the outcome of a webhook delivery.
+type DeliveryResult string
+
+const (
+	// DeliveryTriggered is the result of the deliveries which started a build.
+	DeliveryTriggered DeliveryResult = "Triggered"
+	// DeliverySkipped is the result of the deliveries which were accepted without starting a
+	// build, e.g. for a push to another branch.
+	DeliverySkipped DeliveryResult = "Skipped"
+	// DeliveryRejected is the result of the deliveries rejected because of their secret or the
+	// signature of their payload.
+	DeliveryRejected DeliveryResult = "Rejected"
+	// DeliveryFailed is the result of the deliveries which could not be processed.
+	DeliveryFailed DeliveryResult = "Failed"
+)
+
+// Delivery is a webhook request received for a BuildConfig. The secret of the request is never
+// recorded.
+type Delivery struct {
+	// ID identifies the delivery among the deliveries of the BuildConfig.
+	ID string `json:"id"`
+	// Timestamp is the time the delivery was received.
+	Timestamp metav1.Time `json:"timestamp"`
+	// Provider is the type of the webhook, e.g. github.
+	Provider string `json:"provider"`
+	// Event is the event of the delivery, as named by the provider, if any.
+	Event string `json:"event,omitempty"`
+	// Result is the outcome of the delivery.
+	Result DeliveryResult `json:"result"`
+	// Reason explains why the delivery did not start a build, or the warning of the build it
+	// started.
+	Reason string `json:"reason,omitempty"`
+	// Build is the name of the build started by the delivery.
+	Build string `json:"build,omitempty"`
+	// Request is the request the delivery instantiated the BuildConfig with.
+	Request *DeliveryRequest `json:"request,omitempty"`
+	// ReplayOf is the ID of the delivery this delivery replayed, if any.
+	ReplayOf string `json:"replayOf,omitempty"`
+}
+
+// DeliveryRequest holds the parameters a webhook delivery extracted from its payload. The
+// environment and the Docker strategy options of the request may hold secrets and are not recorded,
+// replaying the delivery builds without them.
+type DeliveryRequest struct {
+	Revision    *buildv1.SourceRevision     `json:"revision,omitempty"`
+	TriggeredBy []buildv1.BuildTriggerCause `json:"triggeredBy,omitempty"`
+	Labels      map[string]string           `json:"labels,omitempty"`
+	Annotations map[string]string           `json:"annotations,omitempty"`
+}
+
+// NewDeliveryRequest returns the parameters of a build request for a webhook delivery.
+func NewDeliveryRequest(request *buildv1.BuildRequest) *DeliveryRequest {
+	return &DeliveryRequest{
+		Revision:    request.Revision,
+		TriggeredBy: request.TriggeredBy,
+		Labels:      request.Labels,
+		Annotations: request.Annotations,
+	}
+}
+
+// DeliveryLog records the webhook deliveries of BuildConfigs in a config map per BuildConfig, in a
+// namespace reserved to the apiserver. The deliveries are only read through the webhookdeliveries
+// subresource, and recording them does not update the BuildConfigs. A nil DeliveryLog records
+// nothing.
+type DeliveryLog struct {
+	configMaps kubernetes.ConfigMapsGetter
+	namespace  string
+}
+
+// NewDeliveryLog returns a log keeping the webhook deliveries in the config maps of namespace.
+func NewDeliveryLog(configMaps kubernetes.ConfigMapsGetter, namespace string) *DeliveryLog {
+	return &DeliveryLog{configMaps: configMaps, namespace: namespace}
+}
+
+// configMapName returns the name of the config map keeping the deliveries of the BuildConfig uid.
+// It is keyed by UID so that a BuildConfig created again with the same name does not inherit
+// deliveries.
+func configMapName(uid types.UID) string {
+	return deliveriesConfigMapPrefix + string(uid)
+}
+
+// Deliveries returns the webhook deliveries recorded for buildCfg, the most recent last.
+func (l *DeliveryLog) Deliveries(ctx context.Context, buildCfg *buildv1.BuildConfig) ([]Delivery, error) {
+	if l == nil {
+		return nil, nil
+	}
+	configMap, err := l.configMaps.ConfigMaps(l.namespace).Get(ctx, configMapName(buildCfg.UID), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeliveries(configMap)
+}
+
+func decodeDeliveries(configMap *corev1.ConfigMap) ([]Delivery, error) {
+	value, ok := configMap.Data[deliveriesKey]
+	if !ok {
+		return nil, nil
+	}
+	var deliveries []Delivery
+	if err := json.Unmarshal([]byte(value), &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Record adds delivery to the deliveries recorded for buildCfg, dropping the oldest deliveries
+// beyond MaxDeliveries or beyond the size allowed for the record. Deliveries which cannot be read
+// are dropped.
+func (l *DeliveryLog) Record(ctx context.Context, buildCfg *buildv1.BuildConfig, delivery Delivery) error {
+	if l == nil {
+		return nil
+	}
+	configMaps := l.configMaps.ConfigMaps(l.namespace)
+	name := configMapName(buildCfg.UID)
+	// concurrent deliveries conflict on the update, or on the creation of the first record
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+	}, func() error {
+		configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			data, err := appendDelivery(nil, delivery)
+			if err != nil {
+				return err
+			}
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: l.namespace},
+				Data:       map[string]string{deliveriesKey: data},
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		deliveries, err := decodeDeliveries(configMap)
+		if err != nil {
+			deliveries = nil
+		}
+		data, err := appendDelivery(deliveries, delivery)
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[deliveriesKey] = data
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// appendDelivery returns the JSON encoded deliveries with delivery appended, dropping the oldest
+// deliveries beyond MaxDeliveries or beyond maxDeliveriesBytes.
+func appendDelivery(deliveries []Delivery, delivery Delivery) (string, error) {
+	deliveries = append(deliveries, delivery)
+	if len(deliveries) > MaxDeliveries {
+		deliveries = deliveries[len(deliveries)-MaxDeliveries:]
+	}
+	for {
+		data, err := json.Marshal(deliveries)
+		if err != nil {
+			return "", err
+		}
+		if len(data) > maxDeliveriesBytes && len(deliveries) > 1 {
+			deliveries = deliveries[1:]
+			continue
+		}
+		return string(data), nil
+	}
+}
+
+// Find returns the delivery id recorded for buildCfg, or nil.
+func (l *DeliveryLog) Find(ctx context.Context, buildCfg *buildv1.BuildConfig, id string) (*Delivery, error) {
+	deliveries, err := l.Deliveries(ctx, buildCfg)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deliveries {
+		if deliveries[i].ID == id {
+			return &deliveries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Delete deletes the deliveries recorded for the deleted BuildConfig uid.
+func (l *DeliveryLog) Delete(ctx context.Context, uid types.UID) error {
+	if l == nil {
+		return nil
+	}
+	err := l.configMaps.ConfigMaps(l.namespace).Delete(ctx, configMapName(uid), metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// eventHeaders are the headers naming the event of the webhook requests of the providers.
+var eventHeaders = []string{"X-GitHub-Event", "X-Gogs-Event", "X-Gitea-Event", "X-Forgejo-Event", "X-Gitlab-Event", "X-Event-Key"}
+
+// EventType returns the event of a webhook request, as named by its provider.
+func EventType(req *http.Request) string {
+	for _, header := range eventHeaders {
+		if event := req.Header.Get(header); len(event) > 0 {
+			return event
+		}
+	}
+	return ""
+}
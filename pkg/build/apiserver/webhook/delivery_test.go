@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	buildv1 "github.com/openshift/api/build/v1"
+)
+
+func TestRecordDelivery(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset()
+	log := NewDeliveryLog(client.CoreV1(), "deliveries")
+	buildCfg := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "build", UID: "uid"}}
+	for i := 0; i < MaxDeliveries+5; i++ {
+		if err := log.Record(ctx, buildCfg, Delivery{ID: fmt.Sprintf("%d", i), Result: DeliveryTriggered}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliveries, err := log.Deliveries(ctx, buildCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != MaxDeliveries || deliveries[0].ID != "5" || deliveries[MaxDeliveries-1].ID != fmt.Sprintf("%d", MaxDeliveries+4) {
+		t.Errorf("expected the last %d deliveries, got %#v", MaxDeliveries, deliveries)
+	}
+
+	// the deliveries of another BuildConfig with the same name are not shared
+	other := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "build", UID: "other"}}
+	if deliveries, err := log.Deliveries(ctx, other); err != nil || len(deliveries) != 0 {
+		t.Errorf("expected no deliveries for another BuildConfig, got %#v: %v", deliveries, err)
+	}
+
+	// large deliveries are dropped to bound the size of the record
+	reason := strings.Repeat("x", maxDeliveriesBytes/4)
+	for i := 0; i < 5; i++ {
+		if err := log.Record(ctx, buildCfg, Delivery{ID: "large", Timestamp: metav1.Now(), Reason: reason}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configMap, err := client.CoreV1().ConfigMaps("deliveries").Get(ctx, "webhook-deliveries-uid", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := len(configMap.Data[deliveriesKey]); size > maxDeliveriesBytes {
+		t.Errorf("expected at most %d bytes of deliveries, got %d", maxDeliveriesBytes, size)
+	}
+	if deliveries, err := log.Deliveries(ctx, buildCfg); err != nil || len(deliveries) != 3 {
+		t.Errorf("expected the 3 last deliveries to be kept, got %d: %v", len(deliveries), err)
+	}
+
+	// deliveries which cannot be read are dropped
+	configMap.Data[deliveriesKey] = "{"
+	if _, err := client.CoreV1().ConfigMaps("deliveries").Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Record(ctx, buildCfg, Delivery{ID: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if delivery, err := log.Find(ctx, buildCfg, "new"); err != nil || delivery == nil {
+		t.Errorf("expected the new delivery to be found, got %#v: %v", delivery, err)
+	}
+	if deliveries, err := log.Deliveries(ctx, buildCfg); err != nil || len(deliveries) != 1 || deliveries[0].ID != "new" {
+		t.Errorf("unexpected deliveries %#v: %v", deliveries, err)
+	}
+
+	if err := log.Delete(ctx, buildCfg.UID); err != nil {
+		t.Fatal(err)
+	}
+	if deliveries, err := log.Deliveries(ctx, buildCfg); err != nil || len(deliveries) != 0 {
+		t.Errorf("expected the deliveries to be deleted, got %#v: %v", deliveries, err)
+	}
+	if err := log.Delete(ctx, buildCfg.UID); err != nil {
+		t.Errorf("unexpected error deleting missing deliveries: %v", err)
+	}
+}
+
+func TestNewDeliveryRequest(t *testing.T) {
+	request := NewDeliveryRequest(&buildv1.BuildRequest{
+		Env:                   []corev1.EnvVar{{Name: "TOKEN", Value: "secret"}},
+		DockerStrategyOptions: &buildv1.DockerStrategyOptions{BuildArgs: []corev1.EnvVar{{Name: "TOKEN", Value: "secret"}}},
+		Revision:              &buildv1.SourceRevision{Git: &buildv1.GitSourceRevision{Commit: "abcd"}},
+	})
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") || !strings.Contains(string(data), "abcd") {
+		t.Errorf("expected the revision and not the environment to be recorded, got %s", data)
+	}
+}
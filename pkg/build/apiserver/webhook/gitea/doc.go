@@ -0,0 +1,3 @@
+// Package gitea contains webhook.Plugin implementation of Gitea and Forgejo
+// webhooks according to https://docs.gitea.com/usage/webhooks
+package gitea
@@ -0,0 +1,175 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubernetes "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog/v2"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/library-go/pkg/build/buildutil"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
+)
+
+// WebHookPlugin used for processing Gitea and Forgejo webhook requests. As the
+// payloads are GitHub compatible, the plugin is enabled by the GitHub webhook
+// triggers of a BuildConfig.
+type WebHookPlugin struct {
+	secrets kubernetes.SecretsGetter
+}
+
+// New returns gitea webhook plugin. The secrets client is used to read the
+// secrets referenced by the webhook triggers.
+func New(secrets kubernetes.SecretsGetter) *WebHookPlugin {
+	return &WebHookPlugin{secrets: secrets}
+}
+
+type commit struct {
+	ID        string                    `json:"id,omitempty"`
+	Author    buildv1.SourceControlUser `json:"author,omitempty"`
+	Committer buildv1.SourceControlUser `json:"committer,omitempty"`
+	Message   string                    `json:"message,omitempty"`
+	Added     []string                  `json:"added,omitempty"`
+	Removed   []string                  `json:"removed,omitempty"`
+	Modified  []string                  `json:"modified,omitempty"`
+}
+
+type pushEvent struct {
+	Ref        string   `json:"ref,omitempty"`
+	After      string   `json:"after,omitempty"`
+	Commits    []commit `json:"commits,omitempty"`
+	HeadCommit *commit  `json:"head_commit,omitempty"`
+	// TotalCommits is the number of commits of the push, Gitea only sends the last ones.
+	TotalCommits int `json:"total_commits,omitempty"`
+}
+
+// Extract services webhooks from Gitea and Forgejo servers
+func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (revision *buildv1.SourceRevision, envvars []corev1.EnvVar, dockerStrategyOptions *buildv1.DockerStrategyOptions, proceed bool, err error) {
+	klog.V(4).Infof("Verifying build request for BuildConfig %s/%s", buildCfg.Namespace, buildCfg.Name)
+	if err = verifyRequest(req); err != nil {
+		return revision, envvars, dockerStrategyOptions, proceed, err
+	}
+	method := getEvent(req.Header)
+	if method != "push" {
+		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(fmt.Sprintf("Unknown X-Gitea-Event or X-Forgejo-Event %s", method))
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(err.Error())
+	}
+
+	// GitHub and GitLab triggers only check payload signatures when opted in, so that the webhooks
+	// configured before signing was supported keep working. Gitea and Forgejo sign the payload of
+	// every webhook and this plugin has always checked it, so there are no such webhooks to keep
+	// working. The payload is verified with the signing key of the secret the trigger references,
+	// never with the secret in the URL, which anyone who sees the URL knows.
+	secret, err := webhook.SigningKey(req.Context(), buildCfg.Namespace, trigger, p.secrets)
+	if err != nil {
+		klog.V(2).Infof("Rejecting build request for BuildConfig %s/%s, the signing key of the trigger cannot be read: %v", buildCfg.Namespace, buildCfg.Name, err)
+		return revision, envvars, dockerStrategyOptions, proceed, err
+	}
+	if !webhook.VerifyHMACSHA256(body, getSignature(req.Header), secret) {
+		klog.V(2).Infof("Rejecting build request for BuildConfig %s/%s, the signature of the payload does not match", buildCfg.Namespace, buildCfg.Name)
+		return revision, envvars, dockerStrategyOptions, proceed, webhook.ErrSignatureMismatch
+	}
+
+	var event pushEvent
+	if err = json.Unmarshal(body, &event); err != nil {
+		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(err.Error())
+	}
+	if !webhook.GitRefMatches(event.Ref, webhook.DefaultConfigRef, &buildCfg.Spec.Source) {
+		klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Branch reference from '%s' does not match configuration", buildCfg.Namespace, buildCfg.Name, event.Ref)
+		return revision, envvars, dockerStrategyOptions, proceed, webhook.NewWarning(fmt.Sprintf("skipping build. Branch reference from %q does not match configuration", event.Ref))
+	}
+	if err = webhook.CheckChangedPaths(buildCfg, event.changedPaths()); err != nil {
+		return revision, envvars, dockerStrategyOptions, proceed, err
+	}
+
+	head := event.HeadCommit
+	if head == nil && len(event.Commits) > 0 {
+		head = &event.Commits[len(event.Commits)-1]
+	}
+	if head == nil {
+		head = &commit{ID: event.After}
+	}
+
+	revision = &buildv1.SourceRevision{
+		Git: &buildv1.GitSourceRevision{
+			Commit:    head.ID,
+			Author:    head.Author,
+			Committer: head.Committer,
+			Message:   head.Message,
+		},
+	}
+	return revision, envvars, dockerStrategyOptions, true, err
+}
+
+// changedPaths returns the paths of the files changed by the commits of the event, nil if the
+// event does not list them all.
+func (e *pushEvent) changedPaths() []string {
+	if len(e.Commits) == 0 || e.TotalCommits > len(e.Commits) {
+		return nil
+	}
+	paths := []string{}
+	for _, c := range e.Commits {
+		paths = append(paths, c.Added...)
+		paths = append(paths, c.Removed...)
+		paths = append(paths, c.Modified...)
+	}
+	return paths
+}
+
+// GetTriggers retrieves the WebHookTriggers for this webhook type (if any)
+func (p *WebHookPlugin) GetTriggers(buildConfig *buildv1.BuildConfig) ([]*buildv1.WebHookTrigger, error) {
+	triggers := buildutil.FindTriggerPolicy(buildv1.GitHubWebHookBuildTriggerType, buildConfig)
+	webhookTriggers := []*buildv1.WebHookTrigger{}
+	for _, trigger := range triggers {
+		if trigger.GitHubWebHook != nil {
+			webhookTriggers = append(webhookTriggers, trigger.GitHubWebHook)
+		}
+	}
+	if len(webhookTriggers) == 0 {
+		return nil, webhook.ErrHookNotEnabled
+	}
+	return webhookTriggers, nil
+}
+
+func verifyRequest(req *http.Request) error {
+	if method := req.Method; method != "POST" {
+		return webhook.MethodNotSupported
+	}
+	contentType := req.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return errors.NewBadRequest(fmt.Sprintf("non-parseable Content-Type %s (%s)", contentType, err))
+	}
+	if mediaType != "application/json" {
+		return errors.NewBadRequest(fmt.Sprintf("unsupported Content-Type %s", contentType))
+	}
+	if len(getEvent(req.Header)) == 0 {
+		return errors.NewBadRequest("missing X-Gitea-Event or X-Forgejo-Event")
+	}
+	return nil
+}
+
+func getEvent(header http.Header) string {
+	event := header.Get("X-Gitea-Event")
+	if len(event) == 0 {
+		event = header.Get("X-Forgejo-Event")
+	}
+	return event
+}
+
+func getSignature(header http.Header) string {
+	signature := header.Get("X-Gitea-Signature")
+	if len(signature) == 0 {
+		signature = header.Get("X-Forgejo-Signature")
+	}
+	return signature
+}
@@ -0,0 +1,249 @@
+package gitea
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
+)
+
+var mockBuildStrategy = buildv1.BuildStrategy{
+	SourceStrategy: &buildv1.SourceBuildStrategy{
+		From: corev1.ObjectReference{
+			Kind: "DockerImage",
+			Name: "repository/image",
+		},
+	},
+}
+
+func buildConfigWithRef(ref string, trigger *buildv1.WebHookTrigger) *buildv1.BuildConfig {
+	return &buildv1.BuildConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "build"},
+		Spec: buildv1.BuildConfigSpec{
+			Triggers: []buildv1.BuildTriggerPolicy{
+				{
+					Type:          buildv1.GitHubWebHookBuildTriggerType,
+					GitHubWebHook: trigger,
+				},
+			},
+			CommonSpec: buildv1.CommonSpec{
+				Source: buildv1.BuildSource{
+					Git: &buildv1.GitBuildSource{
+						URI: "https://gitea.example.com/my/repo.git",
+						Ref: ref,
+					},
+				},
+				Strategy: mockBuildStrategy,
+			},
+		},
+	}
+}
+
+// signedTrigger references the secret webhook, whose signing key is signing100 and URL secret is
+// secret100.
+var signedTrigger = &buildv1.WebHookTrigger{SecretReference: &buildv1.SecretLocalReference{Name: "webhook"}}
+
+// newPlugin returns a plugin reading the secrets webhook, unsigned without a signing key and reused
+// whose signing key is its URL secret.
+func newPlugin() *WebHookPlugin {
+	return New(fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "webhook"},
+			Data:       map[string][]byte{buildv1.WebHookSecretKey: []byte("secret100"), webhook.SigningKeyKey: []byte("signing100")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "unsigned"},
+			Data:       map[string][]byte{buildv1.WebHookSecretKey: []byte("secret100")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "reused"},
+			Data:       map[string][]byte{buildv1.WebHookSecretKey: []byte("secret100"), webhook.SigningKeyKey: []byte("secret100")},
+		},
+	).CoreV1())
+}
+
+func sign(data []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func postFile(t *testing.T, eventHeader, eventName, filename, signatureHeader, secret string) *http.Request {
+	data, err := ioutil.ReadFile("testdata/" + filename)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", filename, err)
+	}
+	req, err := http.NewRequest("POST", "http://some.url", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error creating POST request: %v", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add(eventHeader, eventName)
+	if len(signatureHeader) > 0 {
+		req.Header.Add(signatureHeader, sign(data, secret))
+	}
+	return req
+}
+
+func TestVerifyRequestForMethod(t *testing.T) {
+	buildCfg := buildConfigWithRef("", signedTrigger)
+	req, _ := http.NewRequest("GET", "http://someurl.com", nil)
+	revision, _, _, proceed, err := newPlugin().Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, req)
+
+	if err != webhook.MethodNotSupported {
+		t.Errorf("Expected unsupported HTTP method, got %v", err)
+	}
+	if proceed || revision != nil {
+		t.Error("Expected the request not to proceed")
+	}
+}
+
+func TestMissingEvent(t *testing.T) {
+	buildCfg := buildConfigWithRef("", signedTrigger)
+	req, _ := http.NewRequest("POST", "http://someurl.com", nil)
+	req.Header.Add("Content-Type", "application/json")
+	_, _, _, proceed, err := newPlugin().Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, req)
+
+	if err == nil || !strings.Contains(err.Error(), "missing X-Gitea-Event") {
+		t.Errorf("Expected missing X-Gitea-Event, got %v", err)
+	}
+	if proceed {
+		t.Error("Expected 'proceed' return value to be 'false'")
+	}
+}
+
+func TestWrongGiteaEvent(t *testing.T) {
+	buildCfg := buildConfigWithRef("", signedTrigger)
+	req := postFile(t, "X-Gitea-Event", "issues", "pushevent.json", "X-Gitea-Signature", "signing100")
+	_, _, _, proceed, err := newPlugin().Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, req)
+
+	if err == nil || !strings.Contains(err.Error(), "Unknown X-Gitea-Event") {
+		t.Errorf("Expected Unknown X-Gitea-Event, got %v", err)
+	}
+	if proceed {
+		t.Error("Expected 'proceed' return value to be 'false'")
+	}
+}
+
+func TestExtractProvidesValidBuildForAPushEvent(t *testing.T) {
+	for _, headers := range [][2]string{
+		{"X-Gitea-Event", "X-Gitea-Signature"},
+		{"X-Forgejo-Event", "X-Forgejo-Signature"},
+	} {
+		buildCfg := buildConfigWithRef("", signedTrigger)
+		req := postFile(t, headers[0], "push", "pushevent.json", headers[1], "signing100")
+		revision, _, _, proceed, err := newPlugin().Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, req)
+
+		if err != nil {
+			t.Fatalf("%s: Error while extracting build info: %v", headers[0], err)
+		}
+		if !proceed {
+			t.Errorf("%s: The 'proceed' return value should equal 'true'", headers[0])
+		}
+		if revision == nil {
+			t.Fatalf("%s: Expecting the revision to not be nil", headers[0])
+		}
+		if revision.Git.Commit != "bffeb74224043ba2feb48d137756c8a9331c449a" {
+			t.Errorf("%s: Expecting the revision to contain the commit id from the push event, got %q", headers[0], revision.Git.Commit)
+		}
+		if revision.Git.Author.Email != "jane@example.com" || revision.Git.Message != "Update README\n" {
+			t.Errorf("%s: Unexpected revision: %#v", headers[0], revision.Git)
+		}
+	}
+}
+
+func TestExtractProvidesValidBuildForAPushEventOtherThanMaster(t *testing.T) {
+	buildCfg := buildConfigWithRef("my_other_branch", signedTrigger)
+	req := postFile(t, "X-Gitea-Event", "push", "pushevent-not-master-branch.json", "X-Gitea-Signature", "signing100")
+	revision, _, _, proceed, err := newPlugin().Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, req)
+
+	if err != nil {
+		t.Fatalf("Error while extracting build info: %v", err)
+	}
+	if !proceed {
+		t.Error("The 'proceed' return value should equal 'true'")
+	}
+	if revision == nil || revision.Git.Commit != "7e9c2d3e8a1f41c4b27f0a5d1e6c3b9f8a7d6e5c" {
+		t.Errorf("Unexpected revision: %#v", revision)
+	}
+}
+
+func TestExtractSkipsBuildForUnmatchedBranches(t *testing.T) {
+	buildCfg := buildConfigWithRef("adfj32qrafdavckeaewra", signedTrigger)
+	req := postFile(t, "X-Gitea-Event", "push", "pushevent.json", "X-Gitea-Signature", "signing100")
+	_, _, _, proceed, err := newPlugin().Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, req)
+
+	if err == nil || err.Error() != `skipping build. Branch reference from "refs/heads/master" does not match configuration` {
+		t.Errorf("Expecting a warning for the unmatched branch, got %v", err)
+	}
+	if proceed {
+		t.Error("Expecting the build not to proceed for an unmatched branch")
+	}
+}
+
+func TestExtractRejectsInvalidSignatures(t *testing.T) {
+	for name, tc := range map[string]struct {
+		trigger *buildv1.WebHookTrigger
+		req     *http.Request
+	}{
+		"missing":              {trigger: signedTrigger, req: postFile(t, "X-Gitea-Event", "push", "pushevent.json", "", "")},
+		"wrong":                {trigger: signedTrigger, req: postFile(t, "X-Gitea-Event", "push", "pushevent.json", "X-Gitea-Signature", "signing101")},
+		"signed with URL":      {trigger: signedTrigger, req: postFile(t, "X-Gitea-Event", "push", "pushevent.json", "X-Gitea-Signature", "secret100")},
+		"inline secret":        {trigger: &buildv1.WebHookTrigger{Secret: "secret100"}, req: postFile(t, "X-Gitea-Event", "push", "pushevent.json", "X-Gitea-Signature", "secret100")},
+		"no signing key":       {trigger: &buildv1.WebHookTrigger{SecretReference: &buildv1.SecretLocalReference{Name: "unsigned"}}, req: postFile(t, "X-Gitea-Event", "push", "pushevent.json", "X-Gitea-Signature", "secret100")},
+		"signing key from URL": {trigger: &buildv1.WebHookTrigger{SecretReference: &buildv1.SecretLocalReference{Name: "reused"}}, req: postFile(t, "X-Gitea-Event", "push", "pushevent.json", "X-Gitea-Signature", "secret100")},
+		"missing secret":       {trigger: &buildv1.WebHookTrigger{SecretReference: &buildv1.SecretLocalReference{Name: "missing"}}, req: postFile(t, "X-Gitea-Event", "push", "pushevent.json", "X-Gitea-Signature", "signing100")},
+	} {
+		buildCfg := buildConfigWithRef("", tc.trigger)
+		revision, _, _, proceed, err := newPlugin().Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, tc.req)
+
+		if err != webhook.ErrSignatureMismatch {
+			t.Errorf("%s: Expected a signature mismatch, got %v", name, err)
+		}
+		if proceed || revision != nil {
+			t.Errorf("%s: Expected the request not to proceed", name)
+		}
+	}
+}
+
+func TestGetTriggers(t *testing.T) {
+	buildCfg := buildConfigWithRef("", &buildv1.WebHookTrigger{Secret: "secret100"})
+	triggers, err := New(nil).GetTriggers(buildCfg)
+	if err != nil || len(triggers) != 1 || triggers[0].Secret != "secret100" {
+		t.Errorf("Unexpected triggers %#v: %v", triggers, err)
+	}
+
+	buildCfg.Spec.Triggers = nil
+	if _, err := New(nil).GetTriggers(buildCfg); err != webhook.ErrHookNotEnabled {
+		t.Errorf("Expected the hook not to be enabled, got %v", err)
+	}
+}
+
+func TestChangedPaths(t *testing.T) {
+	commits := []commit{{Added: []string{"a"}}, {Modified: []string{"b"}, Removed: []string{"c"}}}
+	for name, tc := range map[string]struct {
+		event pushEvent
+		paths []string
+	}{
+		"all commits":       {event: pushEvent{Commits: commits, TotalCommits: 2}, paths: []string{"a", "c", "b"}},
+		"no total":          {event: pushEvent{Commits: commits}, paths: []string{"a", "c", "b"}},
+		"truncated commits": {event: pushEvent{Commits: commits, TotalCommits: 11}},
+		"no commits":        {event: pushEvent{TotalCommits: 1}},
+	} {
+		if paths := tc.event.changedPaths(); !reflect.DeepEqual(paths, tc.paths) {
+			t.Errorf("%s: expected paths %v, got %v", name, tc.paths, paths)
+		}
+	}
+}
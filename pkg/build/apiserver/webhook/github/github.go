@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	kubernetes "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/klog/v2"
 
 	buildv1 "github.com/openshift/api/build/v1"
@@ -17,11 +19,14 @@ import (
 )
 
 // WebHookPlugin used for processing github webhook requests.
-type WebHookPlugin struct{}
+type WebHookPlugin struct {
+	secrets kubernetes.SecretsGetter
+}
 
-// New returns github webhook plugin.
-func New() *WebHookPlugin {
-	return &WebHookPlugin{}
+// New returns github webhook plugin. The secrets client is used to read the
+// secrets referenced by the webhook triggers, which may require signed payloads.
+func New(secrets kubernetes.SecretsGetter) *WebHookPlugin {
+	return &WebHookPlugin{secrets: secrets}
 }
 
 type commit struct {
@@ -29,14 +34,44 @@ type commit struct {
 	Author    buildv1.SourceControlUser `json:"author,omitempty"`
 	Committer buildv1.SourceControlUser `json:"committer,omitempty"`
 	Message   string                    `json:"message,omitempty"`
+	Added     []string                  `json:"added,omitempty"`
+	Removed   []string                  `json:"removed,omitempty"`
+	Modified  []string                  `json:"modified,omitempty"`
 }
 
 type pushEvent struct {
-	Ref        string `json:"ref,omitempty"`
-	After      string `json:"after,omitempty"`
-	HeadCommit commit `json:"head_commit,omitempty"`
+	Ref        string   `json:"ref,omitempty"`
+	After      string   `json:"after,omitempty"`
+	HeadCommit commit   `json:"head_commit,omitempty"`
+	Commits    []commit `json:"commits,omitempty"`
+	// Size is the number of commits of the push, GitHub only sends the last 20.
+	Size int `json:"size,omitempty"`
+}
+
+type pullRequestEvent struct {
+	Action      string      `json:"action,omitempty"`
+	Number      int         `json:"number,omitempty"`
+	PullRequest pullRequest `json:"pull_request,omitempty"`
+}
+
+type pullRequest struct {
+	Title string         `json:"title,omitempty"`
+	User  user           `json:"user,omitempty"`
+	Head  pullRequestRef `json:"head,omitempty"`
+	Base  pullRequestRef `json:"base,omitempty"`
+}
+
+type pullRequestRef struct {
+	Ref string `json:"ref,omitempty"`
+	SHA string `json:"sha,omitempty"`
+}
+
+type user struct {
+	Login string `json:"login,omitempty"`
 }
 
+var _ webhook.PullRequestPlugin = &WebHookPlugin{}
+
 // Extract services webhooks from github.com
 func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (revision *buildv1.SourceRevision, envvars []corev1.EnvVar, dockerStrategyOptions *buildv1.DockerStrategyOptions, proceed bool, err error) {
 	klog.V(4).Infof("Verifying build request for BuildConfig %s/%s", buildCfg.Namespace, buildCfg.Name)
@@ -47,19 +82,25 @@ func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.
 	if method != "ping" && method != "push" {
 		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(fmt.Sprintf("Unknown X-GitHub-Event or X-Gogs-Event %s", method))
 	}
-	if method == "ping" {
-		return revision, envvars, dockerStrategyOptions, proceed, err
-	}
 	body, err := ioutil.ReadAll(req.Body)
 	if err != nil {
 		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(err.Error())
 	}
+	if err = p.verifySignature(buildCfg, trigger, req, body); err != nil {
+		return revision, envvars, dockerStrategyOptions, proceed, err
+	}
+	if method == "ping" {
+		return revision, envvars, dockerStrategyOptions, proceed, err
+	}
 	var event pushEvent
 	if err = json.Unmarshal(body, &event); err != nil {
 		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(err.Error())
 	}
 	if !webhook.GitRefMatches(event.Ref, webhook.DefaultConfigRef, &buildCfg.Spec.Source) {
-		klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Branch reference from '%s' does not match configuration", buildCfg.Namespace, buildCfg.Name, event)
+		klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Branch reference from '%s' does not match configuration", buildCfg.Namespace, buildCfg.Name, event.Ref)
+		return revision, envvars, dockerStrategyOptions, proceed, webhook.NewWarning(fmt.Sprintf("skipping build. Branch reference from %q does not match configuration", event.Ref))
+	}
+	if err = webhook.CheckChangedPaths(buildCfg, event.changedPaths()); err != nil {
 		return revision, envvars, dockerStrategyOptions, proceed, err
 	}
 
@@ -74,6 +115,80 @@ func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.
 	return revision, envvars, dockerStrategyOptions, true, err
 }
 
+// IsPullRequest returns true for the pull_request events.
+func (p *WebHookPlugin) IsPullRequest(req *http.Request) bool {
+	return getEvent(req.Header) == "pull_request"
+}
+
+// ExtractPullRequest services the pull request webhooks from github.com. Opened, reopened and
+// synchronized pull requests are built from their head, or their merge ref.
+func (p *WebHookPlugin) ExtractPullRequest(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (*buildv1.SourceRevision, *webhook.PullRequest, bool, error) {
+	klog.V(4).Infof("Verifying pull request build request for BuildConfig %s/%s", buildCfg.Namespace, buildCfg.Name)
+	if err := verifyRequest(req); err != nil {
+		return nil, nil, false, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, false, errors.NewBadRequest(err.Error())
+	}
+	if err := p.verifySignature(buildCfg, trigger, req, body); err != nil {
+		return nil, nil, false, err
+	}
+	var event pullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, nil, false, errors.NewBadRequest(err.Error())
+	}
+	if err := webhook.CheckPullRequestTarget(buildCfg, event.PullRequest.Base.Ref); err != nil {
+		return nil, nil, false, err
+	}
+
+	pullRequest := &webhook.PullRequest{
+		Number:       event.Number,
+		SourceBranch: event.PullRequest.Head.Ref,
+		TargetBranch: event.PullRequest.Base.Ref,
+		Ref:          fmt.Sprintf("refs/pull/%d/head", event.Number),
+	}
+	switch event.Action {
+	case "opened", "reopened", "synchronize":
+	case "closed":
+		pullRequest.Closed = true
+		return nil, pullRequest, true, nil
+	default:
+		return nil, nil, false, webhook.NewWarning(fmt.Sprintf("skipping build. Pull request action %q does not trigger builds", event.Action))
+	}
+
+	author := buildv1.SourceControlUser{Name: event.PullRequest.User.Login}
+	revision := &buildv1.SourceRevision{
+		Git: &buildv1.GitSourceRevision{
+			Commit:    event.PullRequest.Head.SHA,
+			Author:    author,
+			Committer: author,
+			Message:   event.PullRequest.Title,
+		},
+	}
+	if webhook.BuildsPullRequestMergeRef(buildCfg) {
+		// GitHub computes the merge commit after sending the event, the build checks out the ref
+		pullRequest.Ref = fmt.Sprintf("refs/pull/%d/merge", event.Number)
+		revision.Git.Commit = ""
+	}
+	return revision, pullRequest, true, nil
+}
+
+// changedPaths returns the paths of the files changed by the commits of the event, nil if the
+// event does not list them all.
+func (e *pushEvent) changedPaths() []string {
+	if len(e.Commits) == 0 || e.Size > len(e.Commits) {
+		return nil
+	}
+	paths := []string{}
+	for _, c := range e.Commits {
+		paths = append(paths, c.Added...)
+		paths = append(paths, c.Removed...)
+		paths = append(paths, c.Modified...)
+	}
+	return paths
+}
+
 // GetTriggers retrieves the WebHookTriggers for this webhook type (if any)
 func (p *WebHookPlugin) GetTriggers(buildConfig *buildv1.BuildConfig) ([]*buildv1.WebHookTrigger, error) {
 	triggers := buildutil.FindTriggerPolicy(buildv1.GitHubWebHookBuildTriggerType, buildConfig)
@@ -89,6 +204,24 @@ func (p *WebHookPlugin) GetTriggers(buildConfig *buildv1.BuildConfig) ([]*buildv
 	return webhookTriggers, nil
 }
 
+// verifySignature checks the X-Hub-Signature-256 header of the request if the
+// trigger requires signed payloads. Gogs sends the bare digest in X-Gogs-Signature.
+func (p *WebHookPlugin) verifySignature(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request, body []byte) error {
+	secret, err := webhook.SignatureSecret(req.Context(), buildCfg, trigger, p.secrets)
+	if err != nil || secret == nil {
+		return err
+	}
+	signature, ok := strings.CutPrefix(req.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		signature = req.Header.Get("X-Gogs-Signature")
+	}
+	if !webhook.VerifyHMACSHA256(body, signature, secret) {
+		klog.V(2).Infof("Rejecting build request for BuildConfig %s/%s, the signature of the payload does not match", buildCfg.Namespace, buildCfg.Name)
+		return webhook.ErrSignatureMismatch
+	}
+	return nil
+}
+
 func verifyRequest(req *http.Request) error {
 	if method := req.Method; method != "POST" {
 		return webhook.MethodNotSupported
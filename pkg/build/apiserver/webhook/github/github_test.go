@@ -2,14 +2,23 @@ package github
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 
 	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
 )
 
 var mockBuildStrategy = buildv1.BuildStrategy{
@@ -46,7 +55,7 @@ func GivenRequest(method string) *http.Request {
 
 func TestVerifyRequestForMethod(t *testing.T) {
 	req := GivenRequest("GET")
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitHubWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "unsupported HTTP method") {
@@ -63,7 +72,7 @@ func TestVerifyRequestForMethod(t *testing.T) {
 func TestMissingEvent(t *testing.T) {
 	req := GivenRequest("POST")
 	req.Header.Add("Content-Type", "application/json")
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "missing X-GitHub-Event or X-Gogs-Event") {
@@ -81,7 +90,7 @@ func TestWrongGitHubEvent(t *testing.T) {
 	req := GivenRequest("POST")
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("X-GitHub-Event", "wrong")
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "Unknown X-GitHub-Event or X-Gogs-Event") {
@@ -97,7 +106,7 @@ func TestWrongGitHubEvent(t *testing.T) {
 
 func TestJsonPingEvent(t *testing.T) {
 	req := postFile("X-GitHub-Event", "ping", "pingevent.json", "http://some.url", http.StatusOK, t)
-	plugin := New()
+	plugin := New(nil)
 	_, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err != nil {
@@ -110,7 +119,7 @@ func TestJsonPingEvent(t *testing.T) {
 
 func TestJsonPushEventError(t *testing.T) {
 	req := post("X-GitHub-Event", "push", []byte{}, "http://some.url", http.StatusBadRequest, t)
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "unexpected end of JSON input") {
@@ -126,7 +135,7 @@ func TestJsonPushEventError(t *testing.T) {
 
 func TestJsonGitHubPushEvent(t *testing.T) {
 	req := postFile("X-GitHub-Event", "push", "pushevent.json", "http://some.url", http.StatusOK, t)
-	plugin := New()
+	plugin := New(nil)
 	_, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err != nil {
@@ -139,7 +148,7 @@ func TestJsonGitHubPushEvent(t *testing.T) {
 
 func TestJsonGitHubPushEventWithCharset(t *testing.T) {
 	req := postFileWithCharset("X-GitHub-Event", "push", "pushevent.json", "http://some.url", "application/json; charset=utf-8", http.StatusOK, t)
-	plugin := New()
+	plugin := New(nil)
 	_, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err != nil {
@@ -152,7 +161,7 @@ func TestJsonGitHubPushEventWithCharset(t *testing.T) {
 
 func TestJsonGogsPushEvent(t *testing.T) {
 	req := postFile("X-Gogs-Event", "push", "pushevent.json", "http://some.url", http.StatusOK, t)
-	plugin := New()
+	plugin := New(nil)
 	_, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err != nil {
@@ -322,3 +331,202 @@ func TestExtractSkipsBuildForUnmatchedBranches(t *testing.T) {
 		t.Errorf("Expecting to not continue from this event because the branch is not for this buildConfig '%s'", context.buildCfg.Spec.Source.Git.Ref)
 	}
 }
+
+func TestExtractSkipsBuildForUnmatchedPaths(t *testing.T) {
+	for _, tc := range []struct {
+		contextDir, include, exclude string
+		proceed                      bool
+	}{
+		{include: "LICENSE", proceed: true},
+		{include: "src", proceed: false},
+		{exclude: "LICENSE", proceed: false},
+		{contextDir: "src", exclude: "docs", proceed: false},
+	} {
+		context := setup(t, "pushevent.json", "push", "")
+		context.buildCfg.Spec.Source.ContextDir = tc.contextDir
+		context.buildCfg.Annotations = map[string]string{}
+		if len(tc.include) > 0 {
+			context.buildCfg.Annotations[buildapi.BuildConfigWebHookIncludePathsAnnotation] = tc.include
+		}
+		if len(tc.exclude) > 0 {
+			context.buildCfg.Annotations[buildapi.BuildConfigWebHookExcludePathsAnnotation] = tc.exclude
+		}
+
+		_, _, _, proceed, err := context.plugin.Extract(context.buildCfg, buildConfig.Spec.Triggers[0].GitHubWebHook, context.req)
+		if proceed != tc.proceed {
+			t.Errorf("%#v: expected proceed to be %t", tc, tc.proceed)
+		}
+		if !tc.proceed && (err == nil || !strings.HasPrefix(err.Error(), "skipping build. None of the changed files")) {
+			t.Errorf("%#v: expected a warning for the unmatched paths, got %v", tc, err)
+		}
+	}
+}
+
+// signedBuildConfig returns a BuildConfig with a GitHub trigger referencing the secret webhook,
+// which holds the signing key if it is not empty. requireSignature lists the secrets of the triggers
+// requiring signed payloads.
+func signedBuildConfig(requireSignature, signingKey string) (*buildv1.BuildConfig, *fake.Clientset) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "webhook"},
+		Data:       map[string][]byte{buildv1.WebHookSecretKey: []byte("secret200")},
+	}
+	if len(signingKey) > 0 {
+		secret.Data[webhook.SigningKeyKey] = []byte(signingKey)
+	}
+	buildCfg := &buildv1.BuildConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "build"},
+		Spec: buildv1.BuildConfigSpec{
+			Triggers: []buildv1.BuildTriggerPolicy{
+				{
+					Type:          buildv1.GitHubWebHookBuildTriggerType,
+					GitHubWebHook: &buildv1.WebHookTrigger{SecretReference: &buildv1.SecretLocalReference{Name: "webhook"}},
+				},
+			},
+			CommonSpec: buildv1.CommonSpec{
+				Source: buildv1.BuildSource{
+					Git: &buildv1.GitBuildSource{},
+				},
+				Strategy: mockBuildStrategy,
+			},
+		},
+	}
+	if len(requireSignature) > 0 {
+		buildCfg.Annotations = map[string]string{buildapi.BuildConfigWebHookRequireSignatureAnnotation: requireSignature}
+	}
+	return buildCfg, fake.NewSimpleClientset(secret)
+}
+
+func TestExtractVerifiesSignature(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/pushevent.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(key string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(data)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	valid := sign("signing300")
+
+	for _, tc := range []struct {
+		name             string
+		requireSignature string
+		signingKey       string
+		header           string
+		signature        string
+		proceed          bool
+		err              error
+	}{
+		{name: "not required", proceed: true},
+		{name: "required for another trigger", requireSignature: "other", signingKey: "signing300", proceed: true},
+		{name: "valid", requireSignature: "other, webhook", signingKey: "signing300", header: "X-Hub-Signature-256", signature: valid, proceed: true},
+		{name: "valid gogs", requireSignature: "webhook", signingKey: "signing300", header: "X-Gogs-Signature", signature: strings.TrimPrefix(valid, "sha256="), proceed: true},
+		{name: "signed with the URL secret", requireSignature: "webhook", signingKey: "signing300", header: "X-Hub-Signature-256", signature: sign("secret200"), err: webhook.ErrSignatureMismatch},
+		{name: "signing key of the URL", requireSignature: "webhook", signingKey: "secret200", header: "X-Hub-Signature-256", signature: sign("secret200"), err: webhook.ErrSignatureMismatch},
+		{name: "no signing key", requireSignature: "webhook", header: "X-Hub-Signature-256", signature: sign("secret200"), err: webhook.ErrSignatureMismatch},
+		{name: "missing", requireSignature: "webhook", signingKey: "signing300", err: webhook.ErrSignatureMismatch},
+		{name: "invalid", requireSignature: "webhook", signingKey: "signing300", header: "X-Hub-Signature-256", signature: "sha256=0123", err: webhook.ErrSignatureMismatch},
+		{name: "sha1", requireSignature: "webhook", signingKey: "signing300", header: "X-Hub-Signature-256", signature: strings.TrimPrefix(valid, "sha256="), err: webhook.ErrSignatureMismatch},
+	} {
+		buildCfg, client := signedBuildConfig(tc.requireSignature, tc.signingKey)
+		req := post("X-GitHub-Event", "push", data, "http://some.url", http.StatusOK, t)
+		if len(tc.header) > 0 {
+			req.Header.Add(tc.header, tc.signature)
+		}
+		_, _, _, proceed, err := New(client.CoreV1()).Extract(buildCfg, buildCfg.Spec.Triggers[0].GitHubWebHook, req)
+		if err != tc.err {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.err, err)
+		}
+		if proceed != tc.proceed {
+			t.Errorf("%s: expected 'proceed' to be %t", tc.name, tc.proceed)
+		}
+	}
+}
+
+func TestExtractPullRequest(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/pullrequestevent.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		action, expectedRef, expectedCommit string
+		annotations                         map[string]string
+		closed                              bool
+		warning                             string
+	}{
+		{
+			action:         "opened",
+			annotations:    map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			expectedRef:    "refs/pull/42/head",
+			expectedCommit: "3b1c1bb36ab1e0d1a4a5e5c1a2f4d8a0e6c9b7d2",
+		},
+		{
+			action:      "synchronize",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "main,mas*", buildapi.BuildConfigWebHookPullRequestRefAnnotation: "merge"},
+			expectedRef: "refs/pull/42/merge",
+		},
+		{
+			action:      "closed",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			closed:      true,
+		},
+		{
+			action:      "labeled",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			warning:     `skipping build. Pull request action "labeled" does not trigger builds`,
+		},
+		{
+			action:      "opened",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "main"},
+			warning:     `skipping build. Pull requests into "master" do not trigger builds of the BuildConfig`,
+		},
+		{
+			action:  "opened",
+			warning: "skipping build. Pull requests do not trigger builds of the BuildConfig",
+		},
+	} {
+		buildCfg := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+		req := post("X-GitHub-Event", "pull_request", []byte(strings.Replace(string(data), `"opened"`, strconv.Quote(tc.action), 1)), "http://some.url", http.StatusOK, t)
+		plugin := New(nil)
+		if !plugin.IsPullRequest(req) {
+			t.Fatalf("%s: expected a pull request event", tc.action)
+		}
+		revision, pullRequest, proceed, err := plugin.ExtractPullRequest(buildCfg, &buildv1.WebHookTrigger{}, req)
+		if len(tc.warning) > 0 {
+			if proceed || err == nil || err.Error() != tc.warning {
+				t.Errorf("%s: expected the warning %q, got %v", tc.action, tc.warning, err)
+			}
+			continue
+		}
+		if !proceed || err != nil {
+			t.Errorf("%s: unexpected error %v", tc.action, err)
+			continue
+		}
+		if pullRequest.Number != 42 || pullRequest.SourceBranch != "feature/login" || pullRequest.TargetBranch != "master" || pullRequest.Closed != tc.closed {
+			t.Errorf("%s: unexpected pull request %#v", tc.action, pullRequest)
+		}
+		if tc.closed {
+			continue
+		}
+		if pullRequest.Ref != tc.expectedRef || revision == nil || revision.Git.Commit != tc.expectedCommit || revision.Git.Author.Name != "octocat" {
+			t.Errorf("%s: unexpected ref %s and revision %#v", tc.action, pullRequest.Ref, revision)
+		}
+	}
+}
+
+func TestChangedPaths(t *testing.T) {
+	commits := []commit{{Added: []string{"a"}}, {Modified: []string{"b"}, Removed: []string{"c"}}}
+	for name, tc := range map[string]struct {
+		event pushEvent
+		paths []string
+	}{
+		"all commits":       {event: pushEvent{Commits: commits, Size: 2}, paths: []string{"a", "c", "b"}},
+		"no size":           {event: pushEvent{Commits: commits}, paths: []string{"a", "c", "b"}},
+		"truncated commits": {event: pushEvent{Commits: commits, Size: 21}},
+		"no commits":        {event: pushEvent{Size: 1}},
+	} {
+		if paths := tc.event.changedPaths(); !reflect.DeepEqual(paths, tc.paths) {
+			t.Errorf("%s: expected paths %v, got %v", name, tc.paths, paths)
+		}
+	}
+}
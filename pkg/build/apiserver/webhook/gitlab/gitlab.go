@@ -1,6 +1,7 @@
 package gitlab
 
 import (
+	"crypto/hmac"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +10,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	kubernetes "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/klog/v2"
 
 	buildv1 "github.com/openshift/api/build/v1"
@@ -17,18 +19,24 @@ import (
 )
 
 // WebHookPlugin used for processing gitlab webhook requests.
-type WebHookPlugin struct{}
+type WebHookPlugin struct {
+	secrets kubernetes.SecretsGetter
+}
 
-// New returns gitlab webhook plugin.
-func New() *WebHookPlugin {
-	return &WebHookPlugin{}
+// New returns gitlab webhook plugin. The secrets client is used to read the
+// secrets referenced by the webhook triggers, which may require signed payloads.
+func New(secrets kubernetes.SecretsGetter) *WebHookPlugin {
+	return &WebHookPlugin{secrets: secrets}
 }
 
 // NOTE - unlike github, there is no separate commiter, just the author
 type commit struct {
-	ID      string                    `json:"id,omitempty"`
-	Author  buildv1.SourceControlUser `json:"author,omitempty"`
-	Message string                    `json:"message,omitempty"`
+	ID       string                    `json:"id,omitempty"`
+	Author   buildv1.SourceControlUser `json:"author,omitempty"`
+	Message  string                    `json:"message,omitempty"`
+	Added    []string                  `json:"added,omitempty"`
+	Removed  []string                  `json:"removed,omitempty"`
+	Modified []string                  `json:"modified,omitempty"`
 }
 
 // NOTE - unlike github, the head commit is not highlighted ... only the commit array is provided,
@@ -37,14 +45,36 @@ type pushEvent struct {
 	Ref     string   `json:"ref,omitempty"`
 	After   string   `json:"after,omitempty"`
 	Commits []commit `json:"commits,omitempty"`
+	// TotalCommitsCount is the number of commits of the push, GitLab only sends the last 20.
+	TotalCommitsCount int `json:"total_commits_count,omitempty"`
+}
+
+type mergeRequestEvent struct {
+	ObjectAttributes mergeRequest `json:"object_attributes,omitempty"`
+}
+
+type mergeRequest struct {
+	IID          int    `json:"iid,omitempty"`
+	Title        string `json:"title,omitempty"`
+	SourceBranch string `json:"source_branch,omitempty"`
+	TargetBranch string `json:"target_branch,omitempty"`
+	Action       string `json:"action,omitempty"`
+	// OldRev is only set for the updates pushing commits to the merge request.
+	OldRev     string `json:"oldrev,omitempty"`
+	LastCommit commit `json:"last_commit,omitempty"`
 }
 
+var _ webhook.PullRequestPlugin = &WebHookPlugin{}
+
 // Extract services webhooks from GitLab server
 func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (revision *buildv1.SourceRevision, envvars []corev1.EnvVar, dockerStrategyOptions *buildv1.DockerStrategyOptions, proceed bool, err error) {
 	klog.V(4).Infof("Verifying build request for BuildConfig %s/%s", buildCfg.Namespace, buildCfg.Name)
 	if err = verifyRequest(req); err != nil {
 		return revision, envvars, dockerStrategyOptions, proceed, err
 	}
+	if err = p.verifyToken(buildCfg, trigger, req); err != nil {
+		return revision, envvars, dockerStrategyOptions, proceed, err
+	}
 	method := getEvent(req.Header)
 	if method != "Push Hook" {
 		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(fmt.Sprintf("Unknown X-Gitlab-Event %s", method))
@@ -58,7 +88,10 @@ func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.
 		return revision, envvars, dockerStrategyOptions, proceed, errors.NewBadRequest(err.Error())
 	}
 	if !webhook.GitRefMatches(event.Ref, webhook.DefaultConfigRef, &buildCfg.Spec.Source) {
-		klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Branch reference from '%s' does not match configuration", buildCfg.Namespace, buildCfg.Name, event)
+		klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Branch reference from '%s' does not match configuration", buildCfg.Namespace, buildCfg.Name, event.Ref)
+		return revision, envvars, dockerStrategyOptions, proceed, webhook.NewWarning(fmt.Sprintf("skipping build. Branch reference from %q does not match configuration", event.Ref))
+	}
+	if err = webhook.CheckChangedPaths(buildCfg, event.changedPaths()); err != nil {
 		return revision, envvars, dockerStrategyOptions, proceed, err
 	}
 
@@ -75,6 +108,81 @@ func (p *WebHookPlugin) Extract(buildCfg *buildv1.BuildConfig, trigger *buildv1.
 	return revision, envvars, dockerStrategyOptions, true, err
 }
 
+// IsPullRequest returns true for the merge request events.
+func (p *WebHookPlugin) IsPullRequest(req *http.Request) bool {
+	return getEvent(req.Header) == "Merge Request Hook"
+}
+
+// ExtractPullRequest services the merge request webhooks from GitLab server. Opened and reopened
+// merge requests, and the updates pushing commits to them, are built from their head or their
+// merge ref.
+func (p *WebHookPlugin) ExtractPullRequest(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (*buildv1.SourceRevision, *webhook.PullRequest, bool, error) {
+	klog.V(4).Infof("Verifying merge request build request for BuildConfig %s/%s", buildCfg.Namespace, buildCfg.Name)
+	if err := verifyRequest(req); err != nil {
+		return nil, nil, false, err
+	}
+	if err := p.verifyToken(buildCfg, trigger, req); err != nil {
+		return nil, nil, false, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, false, errors.NewBadRequest(err.Error())
+	}
+	var event mergeRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, nil, false, errors.NewBadRequest(err.Error())
+	}
+	mr := event.ObjectAttributes
+	if err := webhook.CheckPullRequestTarget(buildCfg, mr.TargetBranch); err != nil {
+		return nil, nil, false, err
+	}
+
+	pullRequest := &webhook.PullRequest{
+		Number:       mr.IID,
+		SourceBranch: mr.SourceBranch,
+		TargetBranch: mr.TargetBranch,
+		Ref:          fmt.Sprintf("refs/merge-requests/%d/head", mr.IID),
+	}
+	switch {
+	case mr.Action == "open" || mr.Action == "reopen" || (mr.Action == "update" && len(mr.OldRev) > 0):
+	case mr.Action == "close" || mr.Action == "merge":
+		pullRequest.Closed = true
+		return nil, pullRequest, true, nil
+	default:
+		return nil, nil, false, webhook.NewWarning(fmt.Sprintf("skipping build. Merge request action %q does not push commits", mr.Action))
+	}
+
+	revision := &buildv1.SourceRevision{
+		Git: &buildv1.GitSourceRevision{
+			Commit:    mr.LastCommit.ID,
+			Author:    mr.LastCommit.Author,
+			Committer: mr.LastCommit.Author,
+			Message:   mr.LastCommit.Message,
+		},
+	}
+	if webhook.BuildsPullRequestMergeRef(buildCfg) {
+		// the merge ref is updated by GitLab after sending the event, the build checks out the ref
+		pullRequest.Ref = fmt.Sprintf("refs/merge-requests/%d/merge", mr.IID)
+		revision.Git.Commit = ""
+	}
+	return revision, pullRequest, true, nil
+}
+
+// changedPaths returns the paths of the files changed by the commits of the event, nil if the
+// event does not list them.
+func (e *pushEvent) changedPaths() []string {
+	if len(e.Commits) == 0 || e.TotalCommitsCount > len(e.Commits) {
+		return nil
+	}
+	paths := []string{}
+	for _, c := range e.Commits {
+		paths = append(paths, c.Added...)
+		paths = append(paths, c.Removed...)
+		paths = append(paths, c.Modified...)
+	}
+	return paths
+}
+
 // GetTriggers retrieves the WebHookTriggers for this webhook type (if any)
 func (p *WebHookPlugin) GetTriggers(buildConfig *buildv1.BuildConfig) ([]*buildv1.WebHookTrigger, error) {
 	triggers := buildutil.FindTriggerPolicy(buildv1.GitLabWebHookBuildTriggerType, buildConfig)
@@ -90,6 +198,22 @@ func (p *WebHookPlugin) GetTriggers(buildConfig *buildv1.BuildConfig) ([]*buildv
 	return webhookTriggers, nil
 }
 
+// verifyToken checks the X-Gitlab-Token header of the request if the trigger
+// requires signed payloads. GitLab does not sign the payload, it sends the
+// secret token configured for the webhook instead, which must be the signing
+// key of the trigger.
+func (p *WebHookPlugin) verifyToken(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) error {
+	secret, err := webhook.SignatureSecret(req.Context(), buildCfg, trigger, p.secrets)
+	if err != nil || secret == nil {
+		return err
+	}
+	if !hmac.Equal([]byte(req.Header.Get("X-Gitlab-Token")), secret) {
+		klog.V(2).Infof("Rejecting build request for BuildConfig %s/%s, the X-Gitlab-Token does not match", buildCfg.Namespace, buildCfg.Name)
+		return webhook.ErrSignatureMismatch
+	}
+	return nil
+}
+
 func verifyRequest(req *http.Request) error {
 	if method := req.Method; method != "POST" {
 		return webhook.MethodNotSupported
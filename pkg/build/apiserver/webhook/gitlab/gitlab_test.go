@@ -2,14 +2,19 @@ package gitlab
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 
 	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/webhook"
 )
 
 var mockBuildStrategy = buildv1.BuildStrategy{
@@ -46,7 +51,7 @@ func GivenRequest(method string) *http.Request {
 
 func TestVerifyRequestForMethod(t *testing.T) {
 	req := GivenRequest("GET")
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "unsupported HTTP method") {
@@ -63,7 +68,7 @@ func TestVerifyRequestForMethod(t *testing.T) {
 func TestMissingEvent(t *testing.T) {
 	req := GivenRequest("POST")
 	req.Header.Add("Content-Type", "application/json")
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "missing X-Gitlab-Event") {
@@ -81,7 +86,7 @@ func TestWrongGitLabEvent(t *testing.T) {
 	req := GivenRequest("POST")
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("X-Gitlab-Event", "wrong")
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "Unknown X-Gitlab-Event") {
@@ -97,7 +102,7 @@ func TestWrongGitLabEvent(t *testing.T) {
 
 func TestJsonPushEventError(t *testing.T) {
 	req := post("X-Gitlab-Event", "Push Hook", []byte{}, "http://some.url", http.StatusBadRequest, t)
-	plugin := New()
+	plugin := New(nil)
 	revision, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err == nil || !strings.Contains(err.Error(), "unexpected end of JSON input") {
@@ -113,7 +118,7 @@ func TestJsonPushEventError(t *testing.T) {
 
 func TestJsonGitLabPushEvent(t *testing.T) {
 	req := postFile("X-Gitlab-Event", "Push Hook", "pushevent.json", "http://some.url", http.StatusOK, t)
-	plugin := New()
+	plugin := New(nil)
 	_, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err != nil {
@@ -126,7 +131,7 @@ func TestJsonGitLabPushEvent(t *testing.T) {
 
 func TestJsonGitLabPushEventWithCharset(t *testing.T) {
 	req := postFileWithCharset("X-Gitlab-Event", "Push Hook", "pushevent.json", "http://some.url", "application/json; charset=utf-8", http.StatusOK, t)
-	plugin := New()
+	plugin := New(nil)
 	_, _, _, proceed, err := plugin.Extract(buildConfig, buildConfig.Spec.Triggers[0].GitLabWebHook, req)
 
 	if err != nil {
@@ -276,10 +281,125 @@ func TestExtractSkipsBuildForUnmatchedBranches(t *testing.T) {
 
 	//execute
 	_, _, _, proceed, err := context.plugin.Extract(context.buildCfg, buildConfig.Spec.Triggers[0].GitLabWebHook, context.req)
-	if err != nil {
-		t.Errorf("Error while extracting build info: %s", err)
+	if err == nil || err.Error() != `skipping build. Branch reference from "refs/heads/master" does not match configuration` {
+		t.Errorf("Expecting a warning for the unmatched branch, got %v", err)
 	}
 	if proceed {
 		t.Errorf("Expecting to not continue from this event because the branch is not for this buildConfig '%s'", context.buildCfg.Spec.Source.Git.Ref)
 	}
 }
+
+func TestExtractVerifiesToken(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		requireToken string
+		token        string
+		proceed      bool
+		err          error
+	}{
+		{name: "not required", proceed: true},
+		{name: "required for another trigger", requireToken: "other", proceed: true},
+		{name: "valid", requireToken: "webhook", token: "signing300", proceed: true},
+		{name: "URL secret", requireToken: "webhook", token: "secret200", err: webhook.ErrSignatureMismatch},
+		{name: "missing", requireToken: "webhook", err: webhook.ErrSignatureMismatch},
+		{name: "invalid", requireToken: "webhook", token: "secret201", err: webhook.ErrSignatureMismatch},
+	} {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "webhook"},
+			Data:       map[string][]byte{buildv1.WebHookSecretKey: []byte("secret200"), webhook.SigningKeyKey: []byte("signing300")},
+		}
+		trigger := &buildv1.WebHookTrigger{SecretReference: &buildv1.SecretLocalReference{Name: "webhook"}}
+		buildCfg := &buildv1.BuildConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "build", Annotations: map[string]string{}},
+			Spec: buildv1.BuildConfigSpec{
+				Triggers: []buildv1.BuildTriggerPolicy{{Type: buildv1.GitLabWebHookBuildTriggerType, GitLabWebHook: trigger}},
+				CommonSpec: buildv1.CommonSpec{
+					Source: buildv1.BuildSource{Git: &buildv1.GitBuildSource{}},
+				},
+			},
+		}
+		if len(tc.requireToken) > 0 {
+			buildCfg.Annotations[buildapi.BuildConfigWebHookRequireSignatureAnnotation] = tc.requireToken
+		}
+		req := postFile("X-Gitlab-Event", "Push Hook", "pushevent.json", "http://some.url", http.StatusOK, t)
+		if len(tc.token) > 0 {
+			req.Header.Add("X-Gitlab-Token", tc.token)
+		}
+		_, _, _, proceed, err := New(fake.NewSimpleClientset(secret).CoreV1()).Extract(buildCfg, trigger, req)
+		if err != tc.err {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.err, err)
+		}
+		if proceed != tc.proceed {
+			t.Errorf("%s: expected 'proceed' to be %t", tc.name, tc.proceed)
+		}
+	}
+}
+
+func TestExtractMergeRequest(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/mergerequestevent.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		action, oldrev, expectedRef, expectedCommit string
+		annotations                                 map[string]string
+		closed                                      bool
+		warning                                     string
+	}{
+		{
+			action:         "open",
+			annotations:    map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			expectedRef:    "refs/merge-requests/7/head",
+			expectedCommit: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		},
+		{
+			action:      "update",
+			oldrev:      "b6568db1bc1dcd7f8b4d5a946b0b91f9dacd7327",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master", buildapi.BuildConfigWebHookPullRequestRefAnnotation: "merge"},
+			expectedRef: "refs/merge-requests/7/merge",
+		},
+		{
+			action:      "update",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			warning:     `skipping build. Merge request action "update" does not push commits`,
+		},
+		{
+			action:      "merge",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "master"},
+			closed:      true,
+		},
+		{
+			action:      "open",
+			annotations: map[string]string{buildapi.BuildConfigWebHookPullRequestBranchesAnnotation: "release-*"},
+			warning:     `skipping build. Pull requests into "master" do not trigger builds of the BuildConfig`,
+		},
+	} {
+		event := strings.Replace(string(data), `"action": "open"`, fmt.Sprintf(`"action": %q, "oldrev": %q`, tc.action, tc.oldrev), 1)
+		buildCfg := &buildv1.BuildConfig{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+		req := post("X-Gitlab-Event", "Merge Request Hook", []byte(event), "http://some.url", http.StatusOK, t)
+		plugin := New(nil)
+		if !plugin.IsPullRequest(req) {
+			t.Fatalf("%s: expected a merge request event", tc.action)
+		}
+		revision, pullRequest, proceed, err := plugin.ExtractPullRequest(buildCfg, &buildv1.WebHookTrigger{}, req)
+		if len(tc.warning) > 0 {
+			if proceed || err == nil || err.Error() != tc.warning {
+				t.Errorf("%s: expected the warning %q, got %v", tc.action, tc.warning, err)
+			}
+			continue
+		}
+		if !proceed || err != nil {
+			t.Errorf("%s: unexpected error %v", tc.action, err)
+			continue
+		}
+		if pullRequest.Number != 7 || pullRequest.SourceBranch != "feature/login" || pullRequest.TargetBranch != "master" || pullRequest.Closed != tc.closed {
+			t.Errorf("%s: unexpected merge request %#v", tc.action, pullRequest)
+		}
+		if tc.closed {
+			continue
+		}
+		if pullRequest.Ref != tc.expectedRef || revision == nil || revision.Git.Commit != tc.expectedCommit || revision.Git.Author.Name != "GitLab dev user" {
+			t.Errorf("%s: unexpected ref %s and revision %#v", tc.action, pullRequest.Ref, revision)
+		}
+	}
+}
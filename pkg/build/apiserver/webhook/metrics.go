@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var signatureRejections = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Subsystem:      "openshift_build_webhook",
+		Name:           "signature_rejections_total",
+		Help:           "Number of build webhook requests rejected because of a missing or invalid payload signature partitioned by webhook type.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"type"},
+)
+
+func init() {
+	legacyregistry.MustRegister(signatureRejections)
+}
+
+// RecordSignatureRejection counts a request to a webhook of hookType rejected
+// because of its payload signature.
+func RecordSignatureRejection(hookType string) {
+	signatureRejections.WithLabelValues(hookType).Inc()
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+)
+
+// PullRequest is the pull request, or merge request, of a webhook event.
+type PullRequest struct {
+	// Number identifies the pull request in its repository.
+	Number int
+	// SourceBranch is the branch the pull request merges.
+	SourceBranch string
+	// TargetBranch is the branch the pull request merges into.
+	TargetBranch string
+	// Ref is the git reference the builds of the pull request check out.
+	Ref string
+	// Closed is true if the event closed the pull request, merged or not.
+	Closed bool
+}
+
+// PullRequestPlugin is implemented by the plugins of the webhook providers sending pull request
+// events. The requests for which IsPullRequest returns true are extracted with ExtractPullRequest
+// instead of Extract.
+type PullRequestPlugin interface {
+	// IsPullRequest returns true if req is a pull request event.
+	IsPullRequest(req *http.Request) bool
+	// ExtractPullRequest extracts a pull request event and returns:
+	// - the revision to build, nil for the events closing the pull request
+	// - the pull request
+	// - information whether to proceed with the event
+	// - eventual error.
+	ExtractPullRequest(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, req *http.Request) (*buildv1.SourceRevision, *PullRequest, bool, error)
+}
+
+// CheckPullRequestTarget returns a warning if the pull requests into targetBranch do not trigger
+// builds of buildCfg. Pull requests only trigger builds of the BuildConfigs opted in with
+// BuildConfigWebHookPullRequestBranchesAnnotation, for the target branches it matches.
+func CheckPullRequestTarget(buildCfg *buildv1.BuildConfig, targetBranch string) error {
+	targetBranch = strings.TrimPrefix(targetBranch, refPrefix)
+	branches, ok := buildCfg.Annotations[buildapi.BuildConfigWebHookPullRequestBranchesAnnotation]
+	if !ok {
+		klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Pull requests do not trigger builds of the BuildConfig", buildCfg.Namespace, buildCfg.Name)
+		return NewWarning("skipping build. Pull requests do not trigger builds of the BuildConfig")
+	}
+	for _, branch := range strings.Split(branches, ",") {
+		if matched, err := path.Match(strings.TrimSpace(branch), targetBranch); err == nil && matched {
+			return nil
+		}
+	}
+	klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  Pull requests into '%s' do not trigger builds of the BuildConfig", buildCfg.Namespace, buildCfg.Name, targetBranch)
+	return NewWarning(fmt.Sprintf("skipping build. Pull requests into %q do not trigger builds of the BuildConfig", targetBranch))
+}
+
+// BuildsPullRequestMergeRef returns true if the builds of the pull requests of buildCfg check out
+// the result of their merge into the target branch rather than their head.
+func BuildsPullRequestMergeRef(buildCfg *buildv1.BuildConfig) bool {
+	return buildCfg.Annotations[buildapi.BuildConfigWebHookPullRequestRefAnnotation] == "merge"
+}
+
+// CancelsClosedPullRequests returns true if the builds of the pull requests of buildCfg which did
+// not start are cancelled when the pull requests are closed.
+func CancelsClosedPullRequests(buildCfg *buildv1.BuildConfig) bool {
+	return buildCfg.Annotations[buildapi.BuildConfigWebHookPullRequestCancelAnnotation] == "true"
+}
+
+// GeneratePullRequestTriggerInfo returns the trigger causes of a build of a pull request, the
+// causes of a push of the revision which describe the pull request.
+func GeneratePullRequestTriggerInfo(revision *buildv1.SourceRevision, hookType string, pullRequest *PullRequest) []buildv1.BuildTriggerCause {
+	causes := GenerateBuildTriggerInfo(revision, hookType)
+	for i := range causes {
+		causes[i].Message = fmt.Sprintf("%s for pull request #%d from %s into %s", causes[i].Message, pullRequest.Number, pullRequest.SourceBranch, pullRequest.TargetBranch)
+	}
+	return causes
+}
@@ -3,8 +3,12 @@ package webhook
 import (
 	"context"
 	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
+	"path"
 	"strings"
 
 	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/apiserverbuildutil"
@@ -16,17 +20,26 @@ import (
 	"k8s.io/klog/v2"
 
 	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
 )
 
 const (
 	refPrefix        = "refs/heads/"
 	DefaultConfigRef = "master"
+
+	// SigningKeyKey is the key of the data of the secret referenced by a
+	// webhook trigger holding the key the payloads sent to the trigger are
+	// signed with. It must differ from the WebHookSecretKey of the secret.
+	SigningKeyKey = "WebHookSigningKey"
 )
 
 var (
 	ErrSecretMismatch  = errors.New("the provided secret does not match")
 	ErrHookNotEnabled  = errors.New("the specified hook is not enabled")
 	MethodNotSupported = errors.New("unsupported HTTP method")
+	// ErrSignatureMismatch is returned when the signature of a webhook payload
+	// does not match the signing key of the webhook trigger.
+	ErrSignatureMismatch = errors.New("the signature of the payload does not match")
 )
 
 // Plugin for Webhook verification is dependent on the sending side, it can be
@@ -53,6 +66,81 @@ func GitRefMatches(eventRef, configRef string, buildSource *buildv1.BuildSource)
 	return configRef == eventRef
 }
 
+// CheckChangedPaths returns a warning if a webhook event changing the files at paths, relative to
+// the root of the repository, must not start a build of buildCfg because none of the files is
+// matched by the path filters of buildCfg. The filters are set by the
+// BuildConfigWebHookIncludePathsAnnotation and BuildConfigWebHookExcludePathsAnnotation and are
+// relative to the context directory of the source. paths is nil for events which do not list
+// the files they change, those always start a build.
+func CheckChangedPaths(buildCfg *buildv1.BuildConfig, paths []string) error {
+	include := pathGlobs(buildCfg.Annotations[buildapi.BuildConfigWebHookIncludePathsAnnotation])
+	exclude := pathGlobs(buildCfg.Annotations[buildapi.BuildConfigWebHookExcludePathsAnnotation])
+	if (len(include) == 0 && len(exclude) == 0) || paths == nil {
+		return nil
+	}
+	contextDir := strings.Trim(path.Clean("/"+buildCfg.Spec.Source.ContextDir), "/")
+	for _, p := range paths {
+		name := strings.TrimPrefix(path.Clean("/"+p), "/")
+		if len(contextDir) > 0 {
+			var ok bool
+			if name, ok = strings.CutPrefix(name, contextDir+"/"); !ok {
+				continue
+			}
+		}
+		if (len(include) == 0 || matchesAnyGlob(include, name)) && !matchesAnyGlob(exclude, name) {
+			return nil
+		}
+	}
+	klog.V(2).Infof("Skipping build for BuildConfig %s/%s.  None of the %d changed files match its paths", buildCfg.Namespace, buildCfg.Name, len(paths))
+	if len(contextDir) > 0 {
+		return NewWarning(fmt.Sprintf("skipping build. None of the changed files under %q match the paths of the BuildConfig", contextDir))
+	}
+	return NewWarning("skipping build. None of the changed files match the paths of the BuildConfig")
+}
+
+// pathGlobs splits a comma separated list of globs.
+func pathGlobs(value string) [][]string {
+	var globs [][]string
+	for _, glob := range strings.Split(value, ",") {
+		glob = strings.TrimSuffix(strings.TrimSpace(glob), "/")
+		if len(glob) > 0 {
+			globs = append(globs, strings.Split(glob, "/"))
+		}
+	}
+	return globs
+}
+
+func matchesAnyGlob(globs [][]string, name string) bool {
+	segments := strings.Split(name, "/")
+	for _, glob := range globs {
+		if matchGlob(glob, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches the segments of a glob against the segments of a path. A glob matching a
+// parent directory of the path matches the path, a "**" segment matches any number of segments.
+func matchGlob(glob, segments []string) bool {
+	if len(glob) == 0 {
+		return true
+	}
+	if glob[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlob(glob[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(glob[0], segments[0])
+	return err == nil && matched && matchGlob(glob[1:], segments[1:])
+}
+
 // NewWarning returns an StatusError object with a http.StatusOK (200) code.
 func NewWarning(message string) *kerrors.StatusError {
 	return &kerrors.StatusError{ErrStatus: metav1.Status{
@@ -90,6 +178,70 @@ func CheckSecret(ctx context.Context, namespace, userSecret string, triggers []*
 	return nil, ErrSecretMismatch
 }
 
+// SigningKey returns the key the payloads sent to a webhook trigger are
+// signed with, the SigningKeyKey of the secret the trigger references. The
+// secret of the trigger is part of the webhook URL, anyone who sees the URL
+// knows it, so payloads are never verified with it: the signing key must be
+// set and differ from it.
+func SigningKey(ctx context.Context, namespace string, trigger *buildv1.WebHookTrigger, secretsClient kubernetes.SecretsGetter) ([]byte, error) {
+	if trigger.SecretReference == nil || secretsClient == nil {
+		klog.V(4).Infof("the webhook trigger does not reference a secret holding a signing key")
+		return nil, ErrSignatureMismatch
+	}
+	s, err := secretsClient.Secrets(namespace).Get(ctx, trigger.SecretReference.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, ErrSignatureMismatch
+	}
+	if err != nil {
+		return nil, err
+	}
+	key := s.Data[SigningKeyKey]
+	if len(key) == 0 || hmac.Equal(key, s.Data[buildv1.WebHookSecretKey]) || hmac.Equal(key, []byte(trigger.Secret)) {
+		klog.V(4).Infof("the secret %s has no %s distinct from the secret of the webhook", trigger.SecretReference.Name, SigningKeyKey)
+		return nil, ErrSignatureMismatch
+	}
+	return key, nil
+}
+
+// SignatureSecret returns the key the payload signature of a webhook request
+// has to be computed with, or nil if the trigger does not require signed
+// payloads. Only the triggers referencing the secrets listed in the
+// BuildConfigWebHookRequireSignatureAnnotation of the BuildConfig require
+// them, so that the setting is not shared by the BuildConfigs referencing the
+// same secret.
+func SignatureSecret(ctx context.Context, buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger, secretsClient kubernetes.SecretsGetter) ([]byte, error) {
+	if trigger == nil || !requiresSignature(buildCfg, trigger) {
+		return nil, nil
+	}
+	return SigningKey(ctx, buildCfg.Namespace, trigger, secretsClient)
+}
+
+// requiresSignature returns true if the BuildConfig requires signed payloads
+// for the webhook trigger.
+func requiresSignature(buildCfg *buildv1.BuildConfig, trigger *buildv1.WebHookTrigger) bool {
+	if trigger.SecretReference == nil {
+		return false
+	}
+	for _, name := range strings.Split(buildCfg.Annotations[buildapi.BuildConfigWebHookRequireSignatureAnnotation], ",") {
+		if strings.TrimSpace(name) == trigger.SecretReference.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyHMACSHA256 returns true if signature is the hex encoded HMAC-SHA256 of
+// body keyed with secret.
+func VerifyHMACSHA256(body []byte, signature string, secret []byte) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(secret) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
 func GenerateBuildTriggerInfo(revision *buildv1.SourceRevision, hookType string) (buildTriggerCauses []buildv1.BuildTriggerCause) {
 	hiddenSecret := "<secret>"
 	switch {
@@ -111,6 +263,16 @@ func GenerateBuildTriggerInfo(revision *buildv1.SourceRevision, hookType string)
 					Secret:   hiddenSecret,
 				},
 			})
+	case hookType == "gitea":
+		// there is no Gitea cause in the API, Gitea payloads are GitHub compatible
+		buildTriggerCauses = append(buildTriggerCauses,
+			buildv1.BuildTriggerCause{
+				Message: apiserverbuildutil.BuildTriggerCauseGiteaMsg,
+				GitHubWebHook: &buildv1.GitHubWebHookCause{
+					Revision: revision,
+					Secret:   hiddenSecret,
+				},
+			})
 	case hookType == "gitlab":
 		buildTriggerCauses = append(buildTriggerCauses,
 			buildv1.BuildTriggerCause{
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	buildapi "github.com/openshift/openshift-apiserver/pkg/build/apis/build"
+)
+
+func TestCheckChangedPaths(t *testing.T) {
+	for name, tc := range map[string]struct {
+		contextDir, include, exclude string
+		paths                        []string
+		proceed                      bool
+	}{
+		"no filters":                 {paths: []string{"README.md"}, proceed: true},
+		"no changed files listed":    {include: "src", proceed: true},
+		"directory":                  {include: "src", paths: []string{"README.md", "src/main.go"}, proceed: true},
+		"nested directory":           {include: "src", paths: []string{"src/pkg/main.go"}, proceed: true},
+		"no match":                   {include: "src", paths: []string{"README.md", "source/main.go"}},
+		"double star":                {include: "**/*.go", paths: []string{"cmd/app/main.go"}, proceed: true},
+		"double star at the end":     {include: "cmd/**", paths: []string{"cmd/app/main.go"}, proceed: true},
+		"single star is a segment":   {include: "*.go", paths: []string{"cmd/main.go"}},
+		"several globs":              {include: "docs, *.go", paths: []string{"main.go"}, proceed: true},
+		"excluded":                   {exclude: "**/*.md", paths: []string{"README.md", "docs/index.md"}},
+		"partly excluded":            {exclude: "**/*.md", paths: []string{"README.md", "main.go"}, proceed: true},
+		"included and excluded":      {include: "src", exclude: "src/**/*_test.go", paths: []string{"src/pkg/a_test.go"}},
+		"outside of the context":     {contextDir: "services/api", paths: []string{"services/web/main.go"}, exclude: "docs"},
+		"inside of the context":      {contextDir: "./services/api/", paths: []string{"services/api/main.go"}, exclude: "docs", proceed: true},
+		"relative to the context":    {contextDir: "services/api", paths: []string{"services/api/src/main.go"}, include: "src", proceed: true},
+		"context prefix is no match": {contextDir: "services/api", paths: []string{"services/api-v2/main.go"}, include: "**"},
+	} {
+		buildCfg := &buildv1.BuildConfig{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+			Spec: buildv1.BuildConfigSpec{
+				CommonSpec: buildv1.CommonSpec{Source: buildv1.BuildSource{ContextDir: tc.contextDir}},
+			},
+		}
+		if len(tc.include) > 0 {
+			buildCfg.Annotations[buildapi.BuildConfigWebHookIncludePathsAnnotation] = tc.include
+		}
+		if len(tc.exclude) > 0 {
+			buildCfg.Annotations[buildapi.BuildConfigWebHookExcludePathsAnnotation] = tc.exclude
+		}
+		if err := CheckChangedPaths(buildCfg, tc.paths); (err == nil) != tc.proceed {
+			t.Errorf("%s: expected the build to proceed to be %t, got %v", name, tc.proceed, err)
+		}
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/openshift/library-go/pkg/quota/clusterquotamapping"
 	openshiftapiserveradmission "github.com/openshift/openshift-apiserver/pkg/admission"
 	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/admission/imagepolicy/originimagereferencemutators"
+	"github.com/openshift/openshift-apiserver/pkg/project/apiserver/admission/requestlimit"
 	"github.com/openshift/openshift-apiserver/pkg/quota/image"
 )
 
@@ -56,6 +57,7 @@ func NewPluginInitializer(
 	featureGates featuregate.FeatureGate,
 	restMapper meta.RESTMapper,
 	clusterQuotaMappingController *clusterquotamapping.ClusterQuotaMappingController,
+	projectRequestLimits *requestlimit.Limits,
 ) (admission.PluginInitializer, error) {
 	kubeClient, err := kubeclientgoclient.NewForConfig(privilegedLoopbackConfig)
 	if err != nil {
@@ -148,5 +150,6 @@ func NewPluginInitializer(
 			quotaRegistry,
 		),
 		admissionrestconfig.NewInitializer(*rest.CopyConfig(privilegedLoopbackConfig)),
+		requestlimit.NewInitializer(projectRequestLimits),
 	}, nil
 }
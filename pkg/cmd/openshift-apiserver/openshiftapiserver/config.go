@@ -3,7 +3,11 @@ package openshiftapiserver
 import (
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	openshiftfeatures "github.com/openshift/api/features"
@@ -15,14 +19,20 @@ import (
 	"github.com/openshift/library-go/pkg/config/serving"
 	"github.com/openshift/library-go/pkg/features"
 	routehostassignment "github.com/openshift/library-go/pkg/route/hostassignment"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildlogarchive"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/buildconfiginstantiate"
 	"github.com/openshift/openshift-apiserver/pkg/cmd/openshift-apiserver/openshiftadmission"
 	"github.com/openshift/openshift-apiserver/pkg/cmd/openshift-apiserver/openshiftapiserver/configprocessing"
 	apisimage "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+	imageimporter "github.com/openshift/openshift-apiserver/pkg/image/apiserver/importer"
+	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/registry/imagestreamimport"
 	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/registryhostname"
+	"github.com/openshift/openshift-apiserver/pkg/project/apiserver/admission/requestlimit"
 	"github.com/openshift/openshift-apiserver/pkg/version"
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apiserver/pkg/admission"
 	admissionmetrics "k8s.io/apiserver/pkg/admission/metrics"
 	"k8s.io/apiserver/pkg/endpoints/discovery/aggregated"
@@ -190,7 +200,8 @@ func NewOpenshiftAPIConfig(config *openshiftcontrolplanev1.OpenShiftAPIServerCon
 	clusterQuotaMappingController := NewClusterQuotaMappingController(informers.kubernetesInformers.Core().V1().Namespaces(), informers.quotaInformers.Quota().V1().ClusterResourceQuotas())
 	discoveryClient := cacheddiscovery.NewMemCacheClient(kubeClient.Discovery())
 	restMapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
-	admissionInitializer, err := openshiftadmission.NewPluginInitializer(config, genericConfig, dynamicClient, kubeClientConfig, informers, feature.DefaultFeatureGate, restMapper, clusterQuotaMappingController)
+	projectRequestLimits := requestlimit.NewLimits()
+	admissionInitializer, err := openshiftadmission.NewPluginInitializer(config, genericConfig, dynamicClient, kubeClientConfig, informers, feature.DefaultFeatureGate, restMapper, clusterQuotaMappingController, projectRequestLimits)
 	if err != nil {
 		return nil, err
 	}
@@ -225,6 +236,73 @@ func NewOpenshiftAPIConfig(config *openshiftcontrolplanev1.OpenShiftAPIServerCon
 	}
 	registryHostnameRetriever := registryhostname.DefaultRegistryHostnameRetriever(externalRegistryHostname, config.ImagePolicyConfig.InternalRegistryHostname)
 
+	imageImportWorkers, err := intArgument(config.APIServerArguments, "image-import-workers")
+	if err != nil {
+		return nil, err
+	}
+	imageImportWorkersPerRegistry, err := intArgument(config.APIServerArguments, "image-import-workers-per-registry")
+	if err != nil {
+		return nil, err
+	}
+
+	mirrorCooldownSeconds, err := intArgument(config.APIServerArguments, "image-import-mirror-cooldown-seconds")
+	if err != nil {
+		return nil, err
+	}
+	mirrorFailureThreshold, err := intArgument(config.APIServerArguments, "image-import-mirror-failure-threshold")
+	if err != nil {
+		return nil, err
+	}
+	mirrorHealth := imageimporter.NewMirrorHealthTracker(time.Duration(mirrorCooldownSeconds)*time.Second, mirrorFailureThreshold)
+
+	importRateLimit, err := imageImportRateLimit(config.APIServerArguments)
+	if err != nil {
+		return nil, err
+	}
+
+	projectRequestTemplateFlavors, err := projectRequestTemplateFlavors(config.APIServerArguments)
+	if err != nil {
+		return nil, err
+	}
+	projectRequestApprovalNamespace, err := stringArgument(config.APIServerArguments, "project-request-approval-namespace")
+	if err != nil {
+		return nil, err
+	}
+	if errs := validation.IsDNS1123Label(projectRequestApprovalNamespace); len(projectRequestApprovalNamespace) > 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("invalid project request approval namespace %q: %s", projectRequestApprovalNamespace, strings.Join(errs, ", "))
+	}
+	buildLogSplitStreams, err := boolArgument(config.APIServerArguments, "build-log-split-streams")
+	if err != nil {
+		return nil, err
+	}
+	buildLogArchive, buildLogArchiveRetention, err := buildLogArchive(config.APIServerArguments)
+	if err != nil {
+		return nil, err
+	}
+	binaryUploadLimits, err := binaryUploadLimits(config.APIServerArguments)
+	if err != nil {
+		return nil, err
+	}
+	buildWebHookDeliveryNamespace, err := stringArgument(config.APIServerArguments, "build-webhook-delivery-namespace")
+	if err != nil {
+		return nil, err
+	}
+	if errs := validation.IsDNS1123Label(buildWebHookDeliveryNamespace); len(buildWebHookDeliveryNamespace) > 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("invalid build webhook delivery namespace %q: %s", buildWebHookDeliveryNamespace, strings.Join(errs, ", "))
+	}
+
+	var signaturePolicy *imageimporter.SignaturePolicy
+	if path := config.APIServerArguments["image-import-signature-policy"]; len(path) > 0 {
+		if len(path) > 1 {
+			return nil, fmt.Errorf("argument %q must have exactly one value", "image-import-signature-policy")
+		}
+		klog.V(2).Infof("Image import using signature policy: %s", path[0])
+		signaturePolicy, err = imageimporter.LoadSignaturePolicy(path[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signature policy for image importing: %v", err)
+		}
+	}
+
 	var caData []byte
 	if len(config.ImagePolicyConfig.AdditionalTrustedCA) != 0 {
 		klog.V(2).Infof("Image import using additional CA path: %s", config.ImagePolicyConfig.AdditionalTrustedCA)
@@ -264,6 +342,16 @@ func NewOpenshiftAPIConfig(config *openshiftcontrolplanev1.OpenShiftAPIServerCon
 			RegistryHostnameRetriever:          registryHostnameRetriever,
 			AllowedRegistriesForImport:         config.ImagePolicyConfig.AllowedRegistriesForImport,
 			MaxImagesBulkImportedPerRepository: config.ImagePolicyConfig.MaxImagesBulkImportedPerRepository,
+			ImageImportWorkers:                 imageImportWorkers,
+			ImageImportWorkersPerRegistry:      imageImportWorkersPerRegistry,
+			ImageImportSignaturePolicy:         signaturePolicy,
+			ImageImportMirrorHealth:            mirrorHealth,
+			ImageImportRateLimit:               importRateLimit,
+			BuildLogSplitStreams:               buildLogSplitStreams,
+			BuildLogArchive:                    buildLogArchive,
+			BuildLogArchiveRetention:           buildLogArchiveRetention,
+			BuildBinaryUploadLimits:            binaryUploadLimits,
+			BuildWebHookDeliveryNamespace:      buildWebHookDeliveryNamespace,
 			AdditionalTrustedCA:                caData,
 			ImageStreamImportMode:              apisimage.ImportModeType(config.ImagePolicyConfig.ImageStreamImportMode),
 			RouteAllocator:                     routeAllocator,
@@ -272,6 +360,9 @@ func NewOpenshiftAPIConfig(config *openshiftcontrolplanev1.OpenShiftAPIServerCon
 			ProjectCache:                       projectCache,
 			ProjectRequestTemplate:             config.ProjectConfig.ProjectRequestTemplate,
 			ProjectRequestMessage:              config.ProjectConfig.ProjectRequestMessage,
+			ProjectRequestTemplateFlavors:      projectRequestTemplateFlavors,
+			ProjectRequestApprovalNamespace:    projectRequestApprovalNamespace,
+			ProjectRequestLimits:               projectRequestLimits,
 			ClusterQuotaMappingController:      clusterQuotaMappingController,
 			RESTMapper:                         restMapper,
 			APIServers:                         config.APIServers,
@@ -281,6 +372,211 @@ func NewOpenshiftAPIConfig(config *openshiftcontrolplanev1.OpenShiftAPIServerCon
 	return ret, ret.ExtraConfig.Validate()
 }
 
+// intArgument returns the value of the integer argument name from args, or 0 if it is not set.
+func intArgument(args map[string][]string, name string) (int, error) {
+	values := args[name]
+	if len(values) == 0 {
+		return 0, nil
+	}
+	if len(values) > 1 {
+		return 0, fmt.Errorf("argument %q must have exactly one value", name)
+	}
+	value, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for argument %q: %v", name, err)
+	}
+	return value, nil
+}
+
+// floatArgument returns the value of the floating point argument name from args, or 0 if it is not set.
+func floatArgument(args map[string][]string, name string) (float64, error) {
+	values := args[name]
+	if len(values) == 0 {
+		return 0, nil
+	}
+	if len(values) > 1 {
+		return 0, fmt.Errorf("argument %q must have exactly one value", name)
+	}
+	value, err := strconv.ParseFloat(values[0], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid value for argument %q: %q is not a number", name, values[0])
+	}
+	return value, nil
+}
+
+// imageImportRateLimit returns the rate limit of image imports configured in args.
+func imageImportRateLimit(args map[string][]string) (imagestreamimport.RateLimitConfig, error) {
+	limit := imagestreamimport.RateLimitConfig{}
+	tagsPerSecond, err := floatArgument(args, "image-import-rate-limit-tags-per-second")
+	if err != nil {
+		return limit, err
+	}
+	burst, err := intArgument(args, "image-import-rate-limit-burst")
+	if err != nil {
+		return limit, err
+	}
+	maxWaitSeconds, err := intArgument(args, "image-import-rate-limit-max-wait-seconds")
+	if err != nil {
+		return limit, err
+	}
+	globalTagsPerSecond, err := floatArgument(args, "image-import-rate-limit-global-tags-per-second")
+	if err != nil {
+		return limit, err
+	}
+	globalBurst, err := intArgument(args, "image-import-rate-limit-global-burst")
+	if err != nil {
+		return limit, err
+	}
+	if tagsPerSecond < 0 || burst < 0 || maxWaitSeconds < 0 || globalTagsPerSecond < 0 || globalBurst < 0 {
+		return limit, fmt.Errorf("image import rate limit arguments must not be negative")
+	}
+	if globalTagsPerSecond > 0 && tagsPerSecond == 0 {
+		return limit, fmt.Errorf("argument %q requires argument %q", "image-import-rate-limit-global-tags-per-second", "image-import-rate-limit-tags-per-second")
+	}
+	limit.TagsPerSecond = tagsPerSecond
+	limit.Burst = burst
+	limit.MaxWait = time.Duration(maxWaitSeconds) * time.Second
+	limit.GlobalTagsPerSecond = globalTagsPerSecond
+	limit.GlobalBurst = globalBurst
+
+	if limit.PerUser, err = boolArgument(args, "image-import-rate-limit-per-user"); err != nil {
+		return limit, err
+	}
+	return limit, nil
+}
+
+// boolArgument returns the value of a boolean argument, false if it is not set.
+func boolArgument(args map[string][]string, name string) (bool, error) {
+	values := args[name]
+	if len(values) == 0 {
+		return false, nil
+	}
+	if len(values) > 1 {
+		return false, fmt.Errorf("argument %q must have exactly one value", name)
+	}
+	value, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return false, fmt.Errorf("invalid value for argument %q: %v", name, err)
+	}
+	return value, nil
+}
+
+// stringArgument returns the value of a string argument, empty if it is not set.
+func stringArgument(args map[string][]string, name string) (string, error) {
+	values := args[name]
+	if len(values) == 0 {
+		return "", nil
+	}
+	if len(values) > 1 {
+		return "", fmt.Errorf("argument %q must have exactly one value", name)
+	}
+	return values[0], nil
+}
+
+// projectRequestTemplateFlavors returns the templates of the project flavors configured in args,
+// by flavor name. Each value of the argument is a flavor, <flavor>=<namespace>/<template>.
+func projectRequestTemplateFlavors(args map[string][]string) (map[string]string, error) {
+	flavors := map[string]string{}
+	for _, value := range args["project-request-template-flavors"] {
+		name, template, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("argument \"project-request-template-flavors\" must be of the form <flavor>=<namespace>/<template>, not %q", value)
+		}
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid project request flavor %q: %s", name, strings.Join(errs, ", "))
+		}
+		if namespace, templateName, ok := strings.Cut(template, "/"); !ok || len(namespace) == 0 || len(templateName) == 0 || strings.Contains(templateName, "/") {
+			return nil, fmt.Errorf("the template of project request flavor %q must be of the form <namespace>/<template>, not %q", name, template)
+		}
+		if _, ok := flavors[name]; ok {
+			return nil, fmt.Errorf("project request flavor %q is configured more than once", name)
+		}
+		flavors[name] = template
+	}
+	return flavors, nil
+}
+
+// defaultBuildLogArchiveRetentionDays is how long archived build logs are kept by default.
+const defaultBuildLogArchiveRetentionDays = 30
+
+// buildLogArchive returns the archive of build logs configured in args, either a directory or an
+// S3 bucket, and how long the archived logs are kept. The credentials of the bucket are read from
+// the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables. A directory only serves
+// the logs to the apiservers it is shared with, it has to be on a volume shared by all of them when
+// there are more than one.
+func buildLogArchive(args map[string][]string) (buildlogarchive.Archive, time.Duration, error) {
+	dir, err := stringArgument(args, "build-log-archive-dir")
+	if err != nil {
+		return nil, 0, err
+	}
+	s3 := buildlogarchive.S3Config{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+	for name, value := range map[string]*string{
+		"build-log-archive-s3-endpoint": &s3.Endpoint,
+		"build-log-archive-s3-bucket":   &s3.Bucket,
+		"build-log-archive-s3-region":   &s3.Region,
+		"build-log-archive-s3-prefix":   &s3.Prefix,
+	} {
+		if *value, err = stringArgument(args, name); err != nil {
+			return nil, 0, err
+		}
+	}
+	// 0 keeps the logs until their build is deleted
+	retentionDays := defaultBuildLogArchiveRetentionDays
+	if len(args["build-log-archive-retention-days"]) > 0 {
+		if retentionDays, err = intArgument(args, "build-log-archive-retention-days"); err != nil {
+			return nil, 0, err
+		}
+		if retentionDays < 0 {
+			return nil, 0, fmt.Errorf("argument %q must not be negative", "build-log-archive-retention-days")
+		}
+	}
+	retention := time.Duration(retentionDays) * 24 * time.Hour
+
+	switch {
+	case len(dir) > 0 && len(s3.Endpoint) > 0:
+		return nil, 0, fmt.Errorf("only one of the arguments \"build-log-archive-dir\" and \"build-log-archive-s3-endpoint\" may be set")
+	case len(dir) > 0:
+		archive, err := buildlogarchive.NewFilesystem(dir)
+		return archive, retention, err
+	case len(s3.Endpoint) > 0:
+		archive, err := buildlogarchive.NewS3(s3)
+		return archive, retention, err
+	}
+	return nil, 0, nil
+}
+
+// binaryUploadLimits returns the limits of the resumable uploads of binary builds configured in args.
+func binaryUploadLimits(args map[string][]string) (buildconfiginstantiate.UploadLimits, error) {
+	limits := buildconfiginstantiate.UploadLimits{}
+	var err error
+	if limits.Enabled, err = boolArgument(args, "build-binary-upload-resumable"); err != nil {
+		return limits, err
+	}
+	if limits.Dir, err = stringArgument(args, "build-binary-upload-dir"); err != nil {
+		return limits, err
+	}
+	maxLength, err := intArgument(args, "build-binary-upload-max-length")
+	if err != nil {
+		return limits, err
+	}
+	if limits.MaxPerUser, err = intArgument(args, "build-binary-upload-max-per-user"); err != nil {
+		return limits, err
+	}
+	maxBytes, err := intArgument(args, "build-binary-upload-max-bytes")
+	if err != nil {
+		return limits, err
+	}
+	if maxLength < 0 || limits.MaxPerUser < 0 || maxBytes < 0 {
+		return limits, fmt.Errorf("binary upload limit arguments must not be negative")
+	}
+	limits.MaxLength = int64(maxLength)
+	limits.MaxBytes = int64(maxBytes)
+	return limits, nil
+}
+
 func OpenshiftHandlerChain(apiHandler http.Handler, genericConfig *genericapiserver.Config) http.Handler {
 	// this is the normal kube handler chain
 	handler := genericapiserver.DefaultBuildHandlerChain(apiHandler, genericConfig)
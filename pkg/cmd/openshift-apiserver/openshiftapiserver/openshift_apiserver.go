@@ -43,11 +43,16 @@ import (
 	authorizationapiserver "github.com/openshift/openshift-apiserver/pkg/authorization/apiserver"
 	"github.com/openshift/openshift-apiserver/pkg/bootstrappolicy"
 	buildapiserver "github.com/openshift/openshift-apiserver/pkg/build/apiserver"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/buildlogarchive"
+	"github.com/openshift/openshift-apiserver/pkg/build/apiserver/registry/buildconfiginstantiate"
 	"github.com/openshift/openshift-apiserver/pkg/cmd/openshift-apiserver/openshiftapiserver/configprocessing"
 	apisimage "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
 	imageapiserver "github.com/openshift/openshift-apiserver/pkg/image/apiserver"
+	imageimporter "github.com/openshift/openshift-apiserver/pkg/image/apiserver/importer"
+	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/registry/imagestreamimport"
 	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/registryhostname"
 	projectapiserver "github.com/openshift/openshift-apiserver/pkg/project/apiserver"
+	"github.com/openshift/openshift-apiserver/pkg/project/apiserver/admission/requestlimit"
 	projectauth "github.com/openshift/openshift-apiserver/pkg/project/auth"
 	projectcache "github.com/openshift/openshift-apiserver/pkg/project/cache"
 	quotaapiserver "github.com/openshift/openshift-apiserver/pkg/quota/apiserver"
@@ -87,8 +92,34 @@ type OpenshiftAPIExtraConfig struct {
 	RegistryHostnameRetriever          registryhostname.RegistryHostnameRetriever
 	AllowedRegistriesForImport         openshiftcontrolplanev1.AllowedRegistries
 	MaxImagesBulkImportedPerRepository int
-	AdditionalTrustedCA                []byte
-	ImageStreamImportMode              apisimage.ImportModeType
+	// ImageImportWorkers and ImageImportWorkersPerRegistry bound the number of
+	// manifests fetched concurrently by all the image imports, 0 means default.
+	ImageImportWorkers            int
+	ImageImportWorkersPerRegistry int
+	// ImageImportSignaturePolicy selects the imported images whose signatures
+	// are verified, nil disables the verification.
+	ImageImportSignaturePolicy *imageimporter.SignaturePolicy
+	// ImageImportMirrorHealth tracks the health of the registries and
+	// mirrors images are imported from.
+	ImageImportMirrorHealth *imageimporter.MirrorHealthTracker
+	// ImageImportRateLimit limits the number of tags imported per namespace, and by all namespaces together.
+	ImageImportRateLimit  imagestreamimport.RateLimitConfig
+	AdditionalTrustedCA   []byte
+	ImageStreamImportMode apisimage.ImportModeType
+
+	// BuildLogSplitStreams requests the stdout and stderr of build containers
+	// separately for structured build logs.
+	BuildLogSplitStreams bool
+	// BuildLogArchive keeps the logs of completed builds once their pods are
+	// gone. Optional.
+	BuildLogArchive buildlogarchive.Archive
+	// BuildLogArchiveRetention is how long the archived build logs are kept.
+	BuildLogArchiveRetention time.Duration
+	// BuildBinaryUploadLimits limits the resumable uploads of binary builds.
+	BuildBinaryUploadLimits buildconfiginstantiate.UploadLimits
+	// BuildWebHookDeliveryNamespace is the namespace the webhook deliveries of
+	// BuildConfigs are recorded in, they are not recorded if it is empty.
+	BuildWebHookDeliveryNamespace string
 
 	RouteAllocator                 *routehostassignment.SimpleAllocationPlugin
 	AllowRouteExternalCertificates bool
@@ -97,7 +128,16 @@ type OpenshiftAPIExtraConfig struct {
 	ProjectCache              *projectcache.ProjectCache
 	ProjectRequestTemplate    string
 	ProjectRequestMessage     string
-	RESTMapper                *restmapper.DeferredDiscoveryRESTMapper
+	// ProjectRequestTemplateFlavors are the <namespace>/<name> of the templates of the project
+	// flavors users may request, by flavor name. Optional.
+	ProjectRequestTemplateFlavors map[string]string
+	// ProjectRequestApprovalNamespace is the namespace the project requests pending approval are
+	// kept in. Optional, project requests are provisioned without approval if unset.
+	ProjectRequestApprovalNamespace string
+	// ProjectRequestLimits are the limits of the ProjectRequestLimit admission plugin the project
+	// requests pending approval count towards.
+	ProjectRequestLimits *requestlimit.Limits
+	RESTMapper           *restmapper.DeferredDiscoveryRESTMapper
 
 	ClusterQuotaMappingController *clusterquotamapping.ClusterQuotaMappingController
 
@@ -235,6 +275,11 @@ func (c *completedConfig) withBuildAPIServer(delegateAPIServer genericapiserver.
 		GenericConfig: &genericapiserver.RecommendedConfig{Config: shallowCopyAndSanitizeGenericConfig(c.GenericConfig.Config), SharedInformerFactory: c.GenericConfig.SharedInformerFactory},
 		ExtraConfig: buildapiserver.ExtraConfig{
 			KubeAPIServerClientConfig: c.ExtraConfig.KubeAPIServerClientConfig,
+			LogSplitStreams:           c.ExtraConfig.BuildLogSplitStreams,
+			LogArchive:                c.ExtraConfig.BuildLogArchive,
+			LogArchiveRetention:       c.ExtraConfig.BuildLogArchiveRetention,
+			BinaryUploadLimits:        c.ExtraConfig.BuildBinaryUploadLimits,
+			WebHookDeliveryNamespace:  c.ExtraConfig.BuildWebHookDeliveryNamespace,
 			Codecs:                    legacyscheme.Codecs,
 			Scheme:                    legacyscheme.Scheme,
 		},
@@ -258,6 +303,11 @@ func (c *completedConfig) withImageAPIServer(delegateAPIServer genericapiserver.
 			RegistryHostnameRetriever:          c.ExtraConfig.RegistryHostnameRetriever,
 			AllowedRegistriesForImport:         c.ExtraConfig.AllowedRegistriesForImport,
 			MaxImagesBulkImportedPerRepository: c.ExtraConfig.MaxImagesBulkImportedPerRepository,
+			ImportWorkers:                      c.ExtraConfig.ImageImportWorkers,
+			ImportWorkersPerRegistry:           c.ExtraConfig.ImageImportWorkersPerRegistry,
+			ImportSignaturePolicy:              c.ExtraConfig.ImageImportSignaturePolicy,
+			ImportMirrorHealth:                 c.ExtraConfig.ImageImportMirrorHealth,
+			ImportRateLimit:                    c.ExtraConfig.ImageImportRateLimit,
 			Codecs:                             legacyscheme.Codecs,
 			Scheme:                             legacyscheme.Scheme,
 			AdditionalTrustedCA:                c.ExtraConfig.AdditionalTrustedCA,
@@ -280,14 +330,17 @@ func (c *completedConfig) withProjectAPIServer(delegateAPIServer genericapiserve
 	cfg := &projectapiserver.ProjectAPIServerConfig{
 		GenericConfig: &genericapiserver.RecommendedConfig{Config: shallowCopyAndSanitizeGenericConfig(c.GenericConfig.Config), SharedInformerFactory: c.GenericConfig.SharedInformerFactory},
 		ExtraConfig: projectapiserver.ExtraConfig{
-			KubeAPIServerClientConfig: c.ExtraConfig.KubeAPIServerClientConfig,
-			ProjectAuthorizationCache: c.ExtraConfig.ProjectAuthorizationCache,
-			ProjectCache:              c.ExtraConfig.ProjectCache,
-			ProjectRequestTemplate:    c.ExtraConfig.ProjectRequestTemplate,
-			ProjectRequestMessage:     c.ExtraConfig.ProjectRequestMessage,
-			RESTMapper:                c.ExtraConfig.RESTMapper,
-			Codecs:                    legacyscheme.Codecs,
-			Scheme:                    legacyscheme.Scheme,
+			KubeAPIServerClientConfig:       c.ExtraConfig.KubeAPIServerClientConfig,
+			ProjectAuthorizationCache:       c.ExtraConfig.ProjectAuthorizationCache,
+			ProjectCache:                    c.ExtraConfig.ProjectCache,
+			ProjectRequestTemplate:          c.ExtraConfig.ProjectRequestTemplate,
+			ProjectRequestMessage:           c.ExtraConfig.ProjectRequestMessage,
+			ProjectRequestTemplateFlavors:   c.ExtraConfig.ProjectRequestTemplateFlavors,
+			ProjectRequestApprovalNamespace: c.ExtraConfig.ProjectRequestApprovalNamespace,
+			ProjectRequestLimits:            c.ExtraConfig.ProjectRequestLimits,
+			RESTMapper:                      c.ExtraConfig.RESTMapper,
+			Codecs:                          legacyscheme.Codecs,
+			Scheme:                          legacyscheme.Scheme,
 		},
 	}
 	// server is required to install OpenAPI to register and serve openapi spec for its types
@@ -518,6 +571,10 @@ func (c completedConfig) New(delegationTarget genericapiserver.DelegationTarget)
 	// this remains a non-healthz endpoint so that you can be healthy without being ready.
 	addReadinessCheckRoute(s.GenericAPIServer.Handler.NonGoRestfulMux, "/healthz/ready", c.ExtraConfig.ProjectAuthorizationCache.ReadyForAccess)
 
+	if c.ExtraConfig.ImageImportMirrorHealth != nil {
+		s.GenericAPIServer.Handler.NonGoRestfulMux.Handle("/debug/image-import/mirrors", c.ExtraConfig.ImageImportMirrorHealth)
+	}
+
 	// this remains here and separate so that you can check both kube and openshift levels
 	AddOpenshiftVersionRoute(s.GenericAPIServer.Handler.GoRestfulContainer, "/version/openshift")
 
@@ -38,6 +38,11 @@ type DockerImageManifest struct {
 	// schema2
 	Layers []Descriptor `json:"layers"`
 	Config Descriptor   `json:"config"`
+
+	// OCI artifacts
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Subject      *Descriptor       `json:"subject,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
 }
 
 // DockerFSLayer is a container struct for BlobSums defined in an image manifest
@@ -68,6 +68,22 @@ type Image struct {
 	DockerImageManifests []ImageManifest
 }
 
+const (
+	// ImageArtifactTypeAnnotation is set on images imported from OCI artifacts, like Helm charts or
+	// SBOMs. It holds the artifact type of the manifest, or the media type of its config if the
+	// manifest does not specify an artifact type.
+	ImageArtifactTypeAnnotation = "image.openshift.io/artifact-type"
+	// ImageReferrersAnnotation holds a JSON list of the artifacts referring to the image, for example
+	// signatures or attestations. It is set only if referrers are discovered during import.
+	ImageReferrersAnnotation = "image.openshift.io/referrers"
+	// ImporterDiscoverReferrersAnnotation enables discovery of the artifacts referring to imported
+	// images when set to "true" on an ImageStreamImport or on the annotations of an image stream tag.
+	ImporterDiscoverReferrersAnnotation = "importer.image.openshift.io/discover-referrers"
+	// ImageStreamImportDiffAnnotation is set on the result of a dry run image stream import to
+	// the JSON encoded changes the import would make to the image stream.
+	ImageStreamImportDiffAnnotation = "image.openshift.io/import-diff"
+)
+
 // ImageManifest represents sub-manifests of a manifest list. The Digest field points to a regular
 // Image object.
 type ImageManifest struct {
@@ -100,6 +116,9 @@ type ImageLayer struct {
 const (
 	// The supported type of image signature.
 	ImageSignatureTypeAtomicImageV1 string = "AtomicImageV1"
+	// ImageSignatureTypeCosignV1 is the type of sigstore signatures verified by the importer. The
+	// content of the signature is the signed cosign simple signing payload.
+	ImageSignatureTypeCosignV1 string = "CosignV1"
 )
 
 // +genclient
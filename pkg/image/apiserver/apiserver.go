@@ -53,6 +53,11 @@ type ExtraConfig struct {
 	RegistryHostnameRetriever          registryhostname.RegistryHostnameRetriever
 	AllowedRegistriesForImport         openshiftcontrolplanev1.AllowedRegistries
 	MaxImagesBulkImportedPerRepository int
+	ImportWorkers                      int
+	ImportWorkersPerRegistry           int
+	ImportSignaturePolicy              *imageimporter.SignaturePolicy
+	ImportMirrorHealth                 *imageimporter.MirrorHealthTracker
+	ImportRateLimit                    imagestreamimport.RateLimitConfig
 	AdditionalTrustedCA                []byte
 	OperatorInformers                  operatorinformers.SharedInformerFactory
 	ConfigInformers                    configinformers.SharedInformerFactory
@@ -265,14 +270,30 @@ func (c *completedConfig) newV1RESTStorage() (map[string]rest.Storage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error building REST storage: %v", err)
 	}
+	manifestCache, err := imageimporter.NewInMemoryManifestCache(imageimporter.DefaultManifestCacheSize, imageimporter.DefaultManifestCacheTagTTL)
+	if err != nil {
+		return nil, fmt.Errorf("error building REST storage: %v", err)
+	}
+	// the workers are shared so that concurrent imports do not multiply the fetches from a registry
+	importWorkers := imageimporter.NewImportWorkers(c.ExtraConfig.ImportWorkers, c.ExtraConfig.ImportWorkersPerRegistry)
 	importerFn := func(r importer.RepositoryRetriever, regConf *sysregistriesv2.V2RegistriesConf) imageimporter.Interface {
-		return imageimporter.NewImageStreamImporter(r, regConf, c.ExtraConfig.MaxImagesBulkImportedPerRepository, flowcontrol.NewTokenBucketRateLimiter(2.0, 3), &importerCache)
+		return imageimporter.NewImageStreamImporter(r, regConf, c.ExtraConfig.MaxImagesBulkImportedPerRepository, flowcontrol.NewTokenBucketRateLimiter(2.0, 3), &importerCache).
+			WithImportWorkers(importWorkers).
+			WithManifestCache(manifestCache).
+			WithSignaturePolicy(c.ExtraConfig.ImportSignaturePolicy).
+			WithMirrorHealth(c.ExtraConfig.ImportMirrorHealth)
+	}
+	importRateLimit := c.ExtraConfig.ImportRateLimit
+	if importRateLimit.RepositoryTags == 0 {
+		importRateLimit.RepositoryTags = c.ExtraConfig.MaxImagesBulkImportedPerRepository
 	}
 	imageStreamImportStorage := imagestreamimport.NewREST(
 		importerFn,
 		imageStreamRegistry,
 		internalImageStreamStorage,
 		imageStorage,
+		imageStorage,
+		imagestreametcd.ImageLimitVerifier(c.GenericConfig.SharedInformerFactory.Core().V1().LimitRanges()),
 		imageV1Client.ImageV1(),
 		importTransport,
 		insecureImportTransport,
@@ -282,6 +303,7 @@ func (c *completedConfig) newV1RESTStorage() (map[string]rest.Storage, error) {
 		c.ExtraConfig.ConfigInformers.Config().V1().ImageDigestMirrorSets().Lister(),
 		c.ExtraConfig.ConfigInformers.Config().V1().ImageTagMirrorSets().Lister(),
 		configV1Client.ConfigV1(),
+		importRateLimit,
 	)
 	imageStreamImageStorage := imagestreamimage.NewREST(imageRegistry, imageStreamRegistry)
 
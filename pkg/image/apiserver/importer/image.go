@@ -3,21 +3,30 @@ package importer
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/distribution/distribution/v3"
 	"github.com/distribution/distribution/v3/manifest/manifestlist"
+	"github.com/distribution/distribution/v3/manifest/ocischema"
 	"github.com/distribution/distribution/v3/manifest/schema1"
 	"github.com/distribution/distribution/v3/registry/api/errcode"
 	godigest "github.com/opencontainers/go-digest"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/openshift/api/image/dockerpre012"
 
 	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+	dockerapi10 "github.com/openshift/openshift-apiserver/pkg/image/apis/image/docker10"
 	imagedockerpre012 "github.com/openshift/openshift-apiserver/pkg/image/apis/image/dockerpre012"
+	internalimageutil "github.com/openshift/openshift-apiserver/pkg/image/apiserver/internal/imageutil"
 )
 
+// unknownPlatform is the architecture and the operating system of manifests that are not bound to a
+// platform.
+const unknownPlatform = "unknown"
+
 func schema1ToImage(manifest *schema1.SignedManifest, d godigest.Digest) (*imageapi.Image, error) {
 	if len(manifest.History) == 0 {
 		return nil, fmt.Errorf("image has no v1Compatibility history and cannot be used")
@@ -53,6 +62,21 @@ func schema1ToImage(manifest *schema1.SignedManifest, d godigest.Digest) (*image
 	return image, nil
 }
 
+// manifestDigest returns the digest of the manifest as it is stored in the registry.
+func manifestDigest(manifest distribution.Manifest) (godigest.Digest, error) {
+	if signedManifest, ok := manifest.(*schema1.SignedManifest); ok {
+		if len(signedManifest.Canonical) == 0 {
+			return "", fmt.Errorf("unable to load canonical representation from schema1 manifest")
+		}
+		return godigest.FromBytes(signedManifest.Canonical), nil
+	}
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return "", err
+	}
+	return godigest.FromBytes(payload), nil
+}
+
 // schema2OrOCIToImage converts a docker schema 2 or an oci schema manifest into an Image.
 func schema2OrOCIToImage(manifest distribution.Manifest, imageConfig []byte, d godigest.Digest) (*imageapi.Image, error) {
 	mediatype, payload, err := manifest.Payload()
@@ -92,6 +116,70 @@ func schema2OrOCIToImage(manifest distribution.Manifest, imageConfig []byte, d g
 	return image, nil
 }
 
+// ociArtifactType returns the artifact type and the annotations of an oci schema manifest. The artifact
+// type is empty if the manifest describes a container image.
+func ociArtifactType(manifest *ocischema.DeserializedManifest) (string, map[string]string, error) {
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return "", nil, err
+	}
+	ociManifest := dockerapi10.DockerImageManifest{}
+	if err := json.Unmarshal(payload, &ociManifest); err != nil {
+		return "", nil, err
+	}
+	return internalimageutil.ArtifactType(ociManifest), ociManifest.Annotations, nil
+}
+
+// ociArtifactToImage converts an oci schema manifest describing an artifact into an Image. The config
+// of an artifact is not an image configuration, the image carries the manifest only.
+func ociArtifactToImage(manifest *ocischema.DeserializedManifest, annotations map[string]string, d godigest.Digest) (*imageapi.Image, error) {
+	mediatype, payload, err := manifest.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	payloadDigest := godigest.FromBytes(payload)
+	if len(d) > 0 && payloadDigest != d {
+		return nil, fmt.Errorf(
+			"content integrity error: the manifest retrieved (media type: %s) "+
+				"with digest %s does not match the digest calculated from "+
+				"the content %s",
+			mediatype,
+			d,
+			payloadDigest,
+		)
+	}
+
+	image := &imageapi.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: payloadDigest.String(),
+		},
+		DockerImageMetadata: imageapi.DockerImage{
+			ID: payloadDigest.String(),
+		},
+		DockerImageManifest:          string(payload),
+		DockerImageManifestMediaType: mediatype,
+		DockerImageMetadataVersion:   "1.0",
+	}
+
+	// surface the annotations of the artifact that are valid object annotations,
+	// the openshift.io domain is reserved for annotations set by the platform.
+	for key, value := range annotations {
+		if len(validation.IsQualifiedName(key)) > 0 {
+			continue
+		}
+		if prefix, _, ok := strings.Cut(key, "/"); ok && (prefix == "openshift.io" || strings.HasSuffix(prefix, ".openshift.io")) {
+			continue
+		}
+		if image.Annotations == nil {
+			image.Annotations = map[string]string{}
+		}
+		image.Annotations[key] = value
+	}
+
+	return image, nil
+}
+
 func manifestListToImage(
 	manifest *manifestlist.DeserializedManifestList,
 	d godigest.Digest,
@@ -133,6 +221,14 @@ func manifestListToImage(
 			OS:           manifest.Platform.OS,
 			Variant:      manifest.Platform.Variant,
 		}
+		// artifacts in an image index, like attestations, do not have
+		// a platform.
+		if len(m.Architecture) == 0 {
+			m.Architecture = unknownPlatform
+		}
+		if len(m.OS) == 0 {
+			m.OS = unknownPlatform
+		}
 		image.DockerImageManifests = append(image.DockerImageManifests, m)
 	}
 
@@ -7,6 +7,8 @@ import (
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/containers/image/v5/pkg/sysregistriesv2"
 	"github.com/distribution/distribution/v3"
@@ -25,6 +27,7 @@ import (
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/klog/v2"
 
@@ -49,10 +52,14 @@ type RepositoryRetriever interface {
 }
 
 // ImageStreamImporter implements an import strategy for container images. It keeps a cache of images
-// per distinct auth context to reduce duplicate loads. This type is not thread safe.
+// per distinct auth context to reduce duplicate loads. Manifests are fetched concurrently, but the
+// type itself is not thread safe.
 type ImageStreamImporter struct {
 	maximumTagsPerRepo int
 
+	// workers bounds the number of concurrent manifest fetches, it may be shared across importers.
+	workers *ImportWorkers
+
 	retriever RepositoryRetriever
 	limiter   flowcontrol.RateLimiter
 	regConf   *sysregistriesv2.V2RegistriesConf
@@ -61,6 +68,19 @@ type ImageStreamImporter struct {
 
 	// digestToLayerSizeCache maps layer digests to size.
 	digestToLayerSizeCache *ImageStreamLayerCache
+
+	// manifestCache is shared across requests, it is nil if disabled.
+	manifestCache ManifestCache
+
+	// signaturePolicy selects the images whose signatures are verified, it is nil if disabled.
+	signaturePolicy *SignaturePolicy
+
+	// retryBackoff bounds the retries of requests to a pull source that failed with a transient error.
+	retryBackoff wait.Backoff
+	sleep        func(ctx context.Context, d time.Duration) error
+
+	// mirrorHealth orders the pull sources by health, it is shared across requests and nil if disabled.
+	mirrorHealth *MirrorHealthTracker
 }
 
 // NewImageStreamImporter creates an importer that will load images from a remote container image
@@ -81,6 +101,11 @@ func NewImageStreamImporter(
 	return &ImageStreamImporter{
 		maximumTagsPerRepo: maximumTagsPerRepo,
 
+		workers: NewImportWorkers(DefaultImportWorkers, DefaultImportWorkersPerRegistry),
+
+		retryBackoff: DefaultRetryBackoff,
+		sleep:        sleepWithContext,
+
 		retriever: retriever,
 		limiter:   limiter,
 		regConf:   regConf,
@@ -93,6 +118,38 @@ func NewImageStreamImporter(
 	}
 }
 
+// WithImportWorkers sets the workers bounding the manifests fetched concurrently by the importer.
+// Importers sharing the workers share their limits.
+func (imp *ImageStreamImporter) WithImportWorkers(workers *ImportWorkers) *ImageStreamImporter {
+	imp.workers = workers
+	return imp
+}
+
+// WithManifestCache sets the cache used to share imported images across requests.
+func (imp *ImageStreamImporter) WithManifestCache(cache ManifestCache) *ImageStreamImporter {
+	imp.manifestCache = cache
+	return imp
+}
+
+// WithSignaturePolicy sets the policy used to verify the signatures of imported images.
+func (imp *ImageStreamImporter) WithSignaturePolicy(policy *SignaturePolicy) *ImageStreamImporter {
+	imp.signaturePolicy = policy
+	return imp
+}
+
+// WithRetryBackoff sets the backoff used to retry requests to a pull source that failed with a
+// transient or rate limited error. A backoff with less than two steps disables retries.
+func (imp *ImageStreamImporter) WithRetryBackoff(backoff wait.Backoff) *ImageStreamImporter {
+	imp.retryBackoff = backoff
+	return imp
+}
+
+// WithMirrorHealth sets the tracker used to try healthy mirrors first and skip failing ones.
+func (imp *ImageStreamImporter) WithMirrorHealth(tracker *MirrorHealthTracker) *ImageStreamImporter {
+	imp.mirrorHealth = tracker
+	return imp
+}
+
 // Import tries to complete the provided isi object with images loaded from remote registries.
 func (imp *ImageStreamImporter) Import(ctx context.Context, isi *imageapi.ImageStreamImport, stream *imageapi.ImageStream) error {
 	// Initialize layer size cache if not given.
@@ -156,6 +213,8 @@ func (imp *ImageStreamImporter) importImages(ctx context.Context, isi *imageapi.
 	repositories := make(map[repositoryKey]*importRepository)
 	cache := imp.digestToRepositoryCache[ctx]
 
+	discoverReferrers := isi.Annotations[imageapi.ImporterDiscoverReferrersAnnotation] == "true"
+
 	isi.Status.Images = make([]imageapi.ImageImportStatus, len(isi.Spec.Images))
 	for i := range isi.Spec.Images {
 		spec := &isi.Spec.Images[i]
@@ -194,66 +253,82 @@ func (imp *ImageStreamImporter) importImages(ctx context.Context, isi *imageapi.
 		repo, ok := repositories[key]
 		if !ok {
 			repo = &importRepository{
-				Ref:      ref,
-				Registry: &key.url,
-				Name:     key.name,
-				Insecure: imp.allowRegistryInsecureAccess(spec.ImportPolicy, ref),
+				Namespace: isi.Namespace,
+				Ref:       ref,
+				Registry:  &key.url,
+				Name:      key.name,
+				Insecure:  imp.allowRegistryInsecureAccess(spec.ImportPolicy, ref),
 			}
 			repositories[key] = repo
 		}
 
+		var toName string
+		if spec.To != nil {
+			toName = spec.To.Name
+		} else {
+			toName = defaultRef.Tag
+		}
+		tagReference := stream.Spec.Tags[toName]
+		discover := discoverReferrers || tagReference.Annotations[imageapi.ImporterDiscoverReferrersAnnotation] == "true"
+
 		if len(defaultRef.ID) > 0 {
-			id := manifestKey{repositoryKey: key, importMode: spec.ImportPolicy.ImportMode}
+			id := manifestKey{repositoryKey: key, importMode: spec.ImportPolicy.ImportMode, discoverReferrers: discover}
 			id.value = defaultRef.ID
 			ids[id] = append(ids[id], i)
 			if len(ids[id]) == 1 {
 				repo.Digests = append(repo.Digests, importDigest{
-					Name:       defaultRef.ID,
-					Image:      cache[id],
-					ImportMode: spec.ImportPolicy.ImportMode,
+					Name:              defaultRef.ID,
+					Image:             cache[id],
+					ImportMode:        spec.ImportPolicy.ImportMode,
+					DiscoverReferrers: discover,
 				})
 			}
 		} else {
-			var toName string
-			if spec.To != nil {
-				toName = spec.To.Name
-			} else {
-				toName = defaultRef.Tag
-			}
-			tagReference := stream.Spec.Tags[toName]
-
 			preferArch := tagReference.Annotations[imagev1.ImporterPreferArchAnnotation]
 			preferOS := tagReference.Annotations[imagev1.ImporterPreferOSAnnotation]
 
 			tag := manifestKey{
-				repositoryKey: key,
-				preferArch:    preferArch,
-				preferOS:      preferOS,
-				importMode:    spec.ImportPolicy.ImportMode,
+				repositoryKey:     key,
+				preferArch:        preferArch,
+				preferOS:          preferOS,
+				importMode:        spec.ImportPolicy.ImportMode,
+				discoverReferrers: discover,
 			}
 			tag.value = defaultRef.Tag
 			tags[tag] = append(tags[tag], i)
 			if len(tags[tag]) == 1 {
 				repo.Tags = append(repo.Tags, importTag{
-					Name:       defaultRef.Tag,
-					PreferArch: preferArch,
-					PreferOS:   preferOS,
-					ImportMode: spec.ImportPolicy.ImportMode,
-					Image:      cache[tag],
+					Name:              defaultRef.Tag,
+					PreferArch:        preferArch,
+					PreferOS:          preferOS,
+					ImportMode:        spec.ImportPolicy.ImportMode,
+					DiscoverReferrers: discover,
+					Image:             cache[tag],
 				})
 			}
 		}
 	}
 
-	// for each repository we found, import all tags and digests
+	// import all tags and digests of the repositories we found in parallel, the
+	// results are applied to the status in the order of the spec afterwards
+	var wg sync.WaitGroup
+	for _, repo := range repositories {
+		wg.Add(1)
+		go func(repo *importRepository) {
+			defer wg.Done()
+			imp.importRepositoryFromDocker(ctx, repo)
+		}(repo)
+	}
+	wg.Wait()
+
 	for key, repo := range repositories {
-		imp.importRepositoryFromDocker(ctx, repo)
 		for _, tag := range repo.Tags {
 			j := manifestKey{
-				repositoryKey: key,
-				preferArch:    tag.PreferArch,
-				preferOS:      tag.PreferOS,
-				importMode:    tag.ImportMode,
+				repositoryKey:     key,
+				preferArch:        tag.PreferArch,
+				preferOS:          tag.PreferOS,
+				importMode:        tag.ImportMode,
+				discoverReferrers: tag.DiscoverReferrers,
 			}
 			j.value = tag.Name
 			if tag.Image != nil {
@@ -292,7 +367,7 @@ func (imp *ImageStreamImporter) importImages(ctx context.Context, isi *imageapi.
 			}
 		}
 		for _, digest := range repo.Digests {
-			j := manifestKey{repositoryKey: key, importMode: digest.ImportMode}
+			j := manifestKey{repositoryKey: key, importMode: digest.ImportMode, discoverReferrers: digest.DiscoverReferrers}
 			j.value = digest.Name
 			if digest.Image != nil {
 				cache[j] = digest.Image
@@ -331,6 +406,15 @@ func (imp *ImageStreamImporter) importImages(ctx context.Context, isi *imageapi.
 	}
 }
 
+const (
+	// RepositoryImportFailedReason is the reason of the status of a repository none of whose tags could
+	// be imported.
+	RepositoryImportFailedReason metav1.StatusReason = "ImportFailed"
+	// RepositoryPartiallyImportedReason is the reason of the successful status of a repository some of
+	// whose tags could not be imported.
+	RepositoryPartiallyImportedReason metav1.StatusReason = "PartiallyImported"
+)
+
 // importFromRepository imports the repository named on the ImageStreamImport, if any, importing
 // up to maximumTagsPerRepo, and reporting status on each image that is attempted to be imported.
 // If the repository cannot be found or tags cannot be retrieved, the repository status field is
@@ -375,12 +459,15 @@ func (imp *ImageStreamImporter) importFromRepository(ctx context.Context, isi *i
 
 	key := repositoryKey{url: *registryURL, name: repoName}
 	repo := &importRepository{
+		Namespace:   isi.Namespace,
 		Ref:         ref,
 		Registry:    &key.url,
 		Name:        key.name,
 		Insecure:    imp.allowRegistryInsecureAccess(spec.ImportPolicy, ref),
 		MaximumTags: imp.maximumTagsPerRepo,
 		ImportMode:  spec.ImportPolicy.ImportMode,
+
+		DiscoverReferrers: isi.Annotations[imageapi.ImporterDiscoverReferrersAnnotation] == "true",
 	}
 	imp.importRepositoryFromDocker(ctx, repo)
 
@@ -390,7 +477,7 @@ func (imp *ImageStreamImporter) importFromRepository(ctx context.Context, isi *i
 	}
 
 	additional := []string{}
-	tagKey := manifestKey{repositoryKey: key, importMode: spec.ImportPolicy.ImportMode}
+	tagKey := manifestKey{repositoryKey: key, importMode: spec.ImportPolicy.ImportMode, discoverReferrers: repo.DiscoverReferrers}
 	for _, s := range repo.AdditionalTags {
 		tagKey.value = s
 		if image, ok := cache[tagKey]; ok {
@@ -443,8 +530,14 @@ func (imp *ImageStreamImporter) importFromRepository(ctx context.Context, isi *i
 		status.Images[i].Manifests = tag.Manifests
 	}
 	if failures > 0 {
-		status.Status.Status = metav1.StatusFailure
-		status.Status.Reason = metav1.StatusReason("ImportFailed")
+		// a repository with some tags imported is reported as successful so that the imported tags
+		// are not considered failed by the clients checking the status of the repository.
+		if failures < len(repo.Tags) {
+			status.Status.Reason = RepositoryPartiallyImportedReason
+		} else {
+			status.Status.Status = metav1.StatusFailure
+			status.Status.Reason = RepositoryImportFailedReason
+		}
 		switch failures {
 		case 1:
 			status.Status.Message = "one of the images from this repository failed to import"
@@ -477,7 +570,7 @@ func formatPingError(imageRef imageref.DockerImageReference, insecure bool, err
 	case strings.HasSuffix(err.Error(), "no basic auth credentials"):
 		err = kapierrors.NewUnauthorized(fmt.Sprintf("you may not have access to the container image %q and did not have credentials to the repository", imageRef.Exact()))
 	default:
-		err = fmt.Errorf("%s: %s", imageRef.Exact(), err)
+		err = fmt.Errorf("%s: %w", imageRef.Exact(), err)
 	}
 	return err
 }
@@ -493,7 +586,7 @@ func formatRepositoryError(ref reference.Named, err error) error {
 	case strings.HasSuffix(err.Error(), "incorrect username or password"):
 		err = kapierrors.NewUnauthorized(fmt.Sprintf("incorrect username or password for image %q", ref.String()))
 	default:
-		err = fmt.Errorf("%s: %s", ref.String(), err)
+		err = fmt.Errorf("%s: %w", ref.String(), err)
 	}
 	return err
 }
@@ -635,6 +728,7 @@ func (imp *ImageStreamImporter) getManifest(
 	if err != nil {
 		errs = append(errs, err)
 	}
+	pullSources = imp.mirrorHealth.order(pullSources)
 
 	if klog.V(5).Enabled() {
 		out := make([]string, len(pullSources))
@@ -647,7 +741,20 @@ func (imp *ImageStreamImporter) getManifest(
 	for _, pullSource := range pullSources {
 		klog.V(5).Infof("importing %s: trying to fetch manifest from %s...", ref, pullSource.Reference)
 
-		manifest, ms, bs, err := imp.getManifestFromSource(ctx, pullSource.Reference, insecure)
+		var (
+			manifest distribution.Manifest
+			ms       distribution.ManifestService
+			bs       distribution.BlobStore
+		)
+		err := imp.retry(ctx, pullSource.Reference.String(), func() (err error) {
+			start := time.Now()
+			manifest, ms, bs, err = imp.getManifestFromSource(ctx, pullSource.Reference, insecure)
+			// only the registries with mirrors configured are tracked
+			if len(pullSources) > 1 {
+				imp.mirrorHealth.observe(pullSource.Reference, time.Since(start), err)
+			}
+			return err
+		})
 		if err != nil {
 			klog.V(5).Infof("importing %s: failed to get manifest from %s: %s", ref, pullSource.Reference, err)
 			errs = append(errs, err)
@@ -707,6 +814,18 @@ func manifestFromManifestList(
 		}
 	}
 
+	// artifacts stored in an image index, like attestations, are not bound to a
+	// platform and cannot stand for the image.
+	if manifestDigest == "" {
+		for _, manifestDescriptor := range manifestList.Manifests {
+			if manifestDescriptor.Platform.OS != unknownPlatform {
+				klog.V(5).Infof("unable to find %s/%s manifest in manifest list %s, doing conservative fail by switching to the first one: %#+v", preferOS, preferArch, ref.String(), manifestDescriptor)
+				manifestDigest = manifestDescriptor.Digest
+				break
+			}
+		}
+	}
+
 	if manifestDigest == "" {
 		klog.V(5).Infof("unable to find %s/%s manifest in manifest list %s, doing conservative fail by switching to the first one: %#+v", preferOS, preferArch, ref.String(), manifestList.Manifests[0])
 		manifestDigest = manifestList.Manifests[0].Digest
@@ -746,19 +865,36 @@ func (imp *ImageStreamImporter) importManifest(
 	} else if signedManifest, isSchema1 := manifest.(*schema1.SignedManifest); isSchema1 {
 		image, err = schema1ToImage(signedManifest, d)
 	} else if deserializedManifest, isSchema2 := manifest.(*schema2.DeserializedManifest); isSchema2 {
-		imageConfig, getImportConfigErr := b.Get(ctx, deserializedManifest.Config.Digest)
+		var imageConfig []byte
+		getImportConfigErr := imp.retry(ctx, ref.String(), func() (err error) {
+			imageConfig, err = b.Get(ctx, deserializedManifest.Config.Digest)
+			return err
+		})
 		if getImportConfigErr != nil {
 			klog.V(5).Infof("unable to get image config by digest %q for image %s: %#v", d, ref.String(), getImportConfigErr)
 			return image, formatRepositoryError(ref, getImportConfigErr)
 		}
 		image, err = schema2OrOCIToImage(deserializedManifest, imageConfig, d)
 	} else if deserializedManifest, isOCISchema := manifest.(*ocischema.DeserializedManifest); isOCISchema {
-		imageConfig, getImportConfigErr := b.Get(ctx, deserializedManifest.Config.Digest)
-		if getImportConfigErr != nil {
-			klog.V(5).Infof("unable to get image config by digest %q for image %s: %#v", d, ref.String(), getImportConfigErr)
-			return image, formatRepositoryError(ref, getImportConfigErr)
+		artifactType, annotations, artifactErr := ociArtifactType(deserializedManifest)
+		if artifactErr != nil {
+			return nil, artifactErr
+		}
+		if len(artifactType) > 0 {
+			klog.V(5).Infof("importing %s as an artifact of type %s", ref.String(), artifactType)
+			image, err = ociArtifactToImage(deserializedManifest, annotations, d)
+		} else {
+			var imageConfig []byte
+			getImportConfigErr := imp.retry(ctx, ref.String(), func() (err error) {
+				imageConfig, err = b.Get(ctx, deserializedManifest.Config.Digest)
+				return err
+			})
+			if getImportConfigErr != nil {
+				klog.V(5).Infof("unable to get image config by digest %q for image %s: %#v", d, ref.String(), getImportConfigErr)
+				return image, formatRepositoryError(ref, getImportConfigErr)
+			}
+			image, err = schema2OrOCIToImage(manifest, imageConfig, d)
 		}
-		image, err = schema2OrOCIToImage(manifest, imageConfig, d)
 	} else {
 		err = fmt.Errorf("unsupported image manifest type: %T", manifest)
 		klog.V(5).Info(err)
@@ -779,67 +915,168 @@ func (imp *ImageStreamImporter) importManifest(
 	return
 }
 
-// importRepositoryFromDocker loads the tags and images requested in the passed importRepository, obeying the
-// optional rate limiter. Errors are set onto the individual tags and digest objects.
-func (imp *ImageStreamImporter) importRepositoryFromDocker(ctx context.Context, repository *importRepository) {
-	klog.V(5).Infof("importing remote Docker repository registry=%s repository=%s insecure=%t", repository.Registry, repository.Name, repository.Insecure)
+// getImage pulls the manifest referenced by ref and imports it into an image. If the importer has a
+// manifest cache, the image is served from the cache once the manifest is known to be accessible. The
+// digest d is empty when ref is a tagged reference.
+func (imp *ImageStreamImporter) getImage(
+	ctx context.Context,
+	ref reference.Named,
+	d godigest.Digest,
+	insecure bool,
+	preferArch, preferOS string,
+	importMode imageapi.ImportModeType,
+) (*imageapi.Image, error) {
+	if imp.manifestCache != nil {
+		if image, ok := imp.getCachedImage(ctx, ref, d, insecure, preferArch, preferOS, importMode); ok {
+			return image, nil
+		}
+	}
 
-	// load digests
-	for i := range repository.Digests {
-		importDigest := &repository.Digests[i]
-		if importDigest.Err != nil || importDigest.Image != nil {
-			continue
+	manifest, ms, bs, err := imp.getManifest(ctx, ref, insecure)
+	if err != nil {
+		klog.V(5).Infof("unable to get manifest for image %s: %v", ref.String(), err)
+		return nil, err
+	}
+
+	image, err := imp.importManifest(ctx, manifest, ref, d, ms, bs, preferArch, preferOS, importMode)
+	if err != nil || imp.manifestCache == nil {
+		return image, err
+	}
+
+	if len(d) == 0 {
+		if d, err = manifestDigest(manifest); err != nil {
+			klog.V(5).Infof("unable to calculate digest of manifest for image %s: %v", ref.String(), err)
+			return image, nil
 		}
+		imp.manifestCache.AddTag(ref.String(), d)
+	}
+	imp.manifestCache.AddImage(ManifestCacheKey{
+		Digest:     d,
+		PreferArch: preferArch,
+		PreferOS:   preferOS,
+		ImportMode: importMode,
+	}, image)
+	return image, nil
+}
 
-		d, err := godigest.Parse(importDigest.Name)
-		if err != nil {
-			importDigest.Err = err
-			continue
+// getCachedImage returns the image for ref from the manifest cache. Tags are resolved to digests
+// through the cache as well. The image is returned only if the manifest can be accessed by the
+// importer's credentials, so that the cache does not disclose images from private repositories.
+func (imp *ImageStreamImporter) getCachedImage(
+	ctx context.Context,
+	ref reference.Named,
+	d godigest.Digest,
+	insecure bool,
+	preferArch, preferOS string,
+	importMode imageapi.ImportModeType,
+) (*imageapi.Image, bool) {
+	if len(d) == 0 {
+		var ok bool
+		if d, ok = imp.manifestCache.GetTag(ref.String()); !ok {
+			return nil, false
 		}
+	}
 
-		ref := repository.Ref
-		ref.Tag = ""
-		ref.ID = string(d)
+	image, ok := imp.manifestCache.GetImage(ManifestCacheKey{
+		Digest:     d,
+		PreferArch: preferArch,
+		PreferOS:   preferOS,
+		ImportMode: importMode,
+	})
+	if !ok {
+		return nil, false
+	}
 
-		dockerRef, err := reference.ParseNormalizedNamed(ref.Exact())
-		if err != nil {
-			importDigest.Err = fmt.Errorf("unable to parse docker reference %s: %v", ref.Exact(), err)
-			continue
-		}
+	digestRef, err := reference.WithDigest(reference.TrimNamed(ref), d)
+	if err != nil {
+		return nil, false
+	}
+	if err := imp.manifestExists(ctx, digestRef, insecure); err != nil {
+		klog.V(5).Infof("ignoring cached image for %s: %v", ref.String(), err)
+		return nil, false
+	}
 
-		// TODO: can we remove this?
-		imp.limiter.Accept()
+	klog.V(5).Infof("importing %s: using cached image %s", ref.String(), image.Name)
+	return image, true
+}
 
-		manifest, ms, bs, err := imp.getManifest(ctx, dockerRef, repository.Insecure)
-		if err != nil {
-			klog.V(5).Infof("unable to get manifest by digest %s for image %s: %v", d, ref.Exact(), err)
-			importDigest.Err = err
-			continue
-		}
+// manifestExists checks that the manifest referenced by ref can be accessed from one of its pull
+// sources.
+func (imp *ImageStreamImporter) manifestExists(ctx context.Context, ref reference.Canonical, insecure bool) error {
+	var errs []error
 
-		importDigest.Image, importDigest.Err = imp.importManifest(ctx, manifest, dockerRef, d, ms, bs, "", "", importDigest.ImportMode)
+	pullSources, err := imp.getPullSources(ref)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	pullSources = imp.mirrorHealth.order(pullSources)
 
-		if importDigest.Err == nil {
-			images, err := imp.importSubManifests(
-				ctx,
-				importDigest.Image.DockerImageManifests,
-				repository,
-			)
-			if err != nil {
-				klog.V(5).Infof(
-					"unable to import manifest list %q: %v",
-					ref.Exact(), err)
-				importDigest.Err = err
-				continue
+	for _, pullSource := range pullSources {
+		err := imp.retry(ctx, pullSource.Reference.String(), func() error {
+			start := time.Now()
+			err := imp.manifestExistsInSource(ctx, pullSource.Reference, ref.Digest(), insecure)
+			if len(pullSources) > 1 {
+				imp.mirrorHealth.observe(pullSource.Reference, time.Since(start), err)
 			}
-			importDigest.Manifests = images
+			return err
+		})
+		if err == nil {
+			return nil
 		}
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (imp *ImageStreamImporter) manifestExistsInSource(ctx context.Context, ref reference.Named, d godigest.Digest, insecure bool) error {
+	imageRef, err := imageref.Parse(ref.String())
+	if err != nil {
+		return fmt.Errorf("unable to parse reference %q: %v", ref.String(), err)
+	}
+
+	repo, err := imp.retriever.Repository(ctx, imageRef, insecure)
+	if err != nil {
+		return formatPingError(imageRef, insecure, err)
+	}
+
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to create manifests service for %s: %v", ref.String(), err)
 	}
 
+	exists, err := ms.Exists(ctx, d)
+	if err != nil {
+		return formatRepositoryError(ref, err)
+	}
+	if !exists {
+		return kapierrors.NewNotFound(image.Resource("dockerimage"), ref.String())
+	}
+	return nil
+}
+
+// importRepositoryFromDocker loads the tags and images requested in the passed importRepository, obeying the
+// optional rate limiter. Tags and digests are fetched concurrently within the limits of the importer's
+// workers. Errors are set onto the individual tags and digest objects.
+func (imp *ImageStreamImporter) importRepositoryFromDocker(ctx context.Context, repository *importRepository) {
+	klog.V(5).Infof("importing remote Docker repository registry=%s repository=%s insecure=%t", repository.Registry, repository.Name, repository.Insecure)
+
+	// load digests
+	imp.workers.run(ctx, repository.Registry.Host, len(repository.Digests), func(i int) {
+		imp.importDigestFromDocker(ctx, repository, &repository.Digests[i])
+	}, func(i int, err error) {
+		if repository.Digests[i].Image == nil {
+			repository.Digests[i].Err = err
+		}
+	})
+
 	// if repository import is requested (MaximumTags), attempt to load the tags, sort them, and request the first N
 	if count := repository.MaximumTags; count > 0 || count == -1 {
 		// retrieve the repository
-		repo, err := imp.retriever.Repository(ctx, repository.Ref, repository.Insecure)
+		var repo distribution.Repository
+		err := imp.retry(ctx, repository.Ref.Exact(), func() (err error) {
+			repo, err = imp.retriever.Repository(ctx, repository.Ref, repository.Insecure)
+			return err
+		})
 		if err != nil {
 			klog.V(5).Infof("unable to access repository %#v: %#v", repository, err)
 			if strings.HasSuffix(err.Error(), "does not support v2 API") {
@@ -848,12 +1085,18 @@ func (imp *ImageStreamImporter) importRepositoryFromDocker(ctx context.Context,
 				applyErrorToRepository(repository, err)
 				return
 			}
-			err = formatPingError(repository.Ref, repository.Insecure, err)
+			if _, ok := err.(kapierrors.APIStatus); !ok {
+				err = formatPingError(repository.Ref, repository.Insecure, err)
+			}
 			applyErrorToRepository(repository, err)
 			return
 		}
 
-		tags, err := repo.Tags(ctx).All(ctx)
+		var tags []string
+		err = imp.retry(ctx, repository.Ref.Exact(), func() (err error) {
+			tags, err = repo.Tags(ctx).All(ctx)
+			return err
+		})
 		if err != nil {
 			klog.V(5).Infof("unable to access tags for repository %#v: %#v", repository, err)
 			switch {
@@ -881,55 +1124,121 @@ func (imp *ImageStreamImporter) importRepositoryFromDocker(ctx context.Context,
 			}
 			count--
 			repository.Tags = append(repository.Tags, importTag{
-				Name:       s,
-				ImportMode: repository.ImportMode,
+				Name:              s,
+				ImportMode:        repository.ImportMode,
+				DiscoverReferrers: repository.DiscoverReferrers,
 			})
 		}
 	}
 
-	for i := range repository.Tags {
-		importTag := &repository.Tags[i]
-		if importTag.Err != nil || importTag.Image != nil {
-			continue
+	imp.workers.run(ctx, repository.Registry.Host, len(repository.Tags), func(i int) {
+		imp.importTagFromDocker(ctx, repository, &repository.Tags[i])
+	}, func(i int, err error) {
+		if repository.Tags[i].Image == nil {
+			repository.Tags[i].Err = err
 		}
+	})
+}
 
-		ref := repository.Ref
-		ref.Tag = importTag.Name
-		ref.ID = ""
+// importDigestFromDocker loads the image referenced by importDigest from repository, obeying the
+// optional rate limiter. The error, if any, is set onto importDigest.
+func (imp *ImageStreamImporter) importDigestFromDocker(ctx context.Context, repository *importRepository, importDigest *importDigest) {
+	if importDigest.Err != nil || importDigest.Image != nil {
+		return
+	}
 
-		dockerRef, err := reference.ParseNormalizedNamed(ref.Exact())
-		if err != nil {
-			importTag.Err = fmt.Errorf("unable to parse docker reference %s: %v", ref.Exact(), err)
-			continue
-		}
+	d, err := godigest.Parse(importDigest.Name)
+	if err != nil {
+		importDigest.Err = err
+		return
+	}
+
+	ref := repository.Ref
+	ref.Tag = ""
+	ref.ID = string(d)
 
-		imp.limiter.Accept()
+	dockerRef, err := reference.ParseNormalizedNamed(ref.Exact())
+	if err != nil {
+		importDigest.Err = fmt.Errorf("unable to parse docker reference %s: %v", ref.Exact(), err)
+		return
+	}
+
+	// TODO: can we remove this?
+	imp.limiter.Accept()
+
+	importDigest.Image, importDigest.Err = imp.getImage(ctx, dockerRef, d, repository.Insecure, "", "", importDigest.ImportMode)
+	if importDigest.Err == nil && importDigest.DiscoverReferrers {
+		imp.discoverReferrers(ctx, dockerRef, importDigest.Image, repository.Insecure)
+	}
+	if importDigest.Err == nil {
+		if err := imp.verifySignatures(ctx, repository.Namespace, dockerRef, importDigest.Image, repository.Insecure); err != nil {
+			importDigest.Image, importDigest.Err = nil, err
+			return
+		}
+	}
 
-		manifest, ms, bs, err := imp.getManifest(ctx, dockerRef, repository.Insecure)
+	if importDigest.Err == nil {
+		images, err := imp.importSubManifests(
+			ctx,
+			importDigest.Image.DockerImageManifests,
+			repository,
+		)
 		if err != nil {
-			klog.V(5).Infof("unable to get manifest by tag %q for image %s: %#v", importTag.Name, ref.Exact(), err)
-			importTag.Err = err
-			continue
+			klog.V(5).Infof(
+				"unable to import manifest list %q: %v",
+				ref.Exact(), err)
+			importDigest.Err = err
+			return
 		}
+		importDigest.Manifests = images
+	}
+}
 
-		importTag.Image, importTag.Err = imp.importManifest(ctx, manifest, dockerRef, "", ms, bs, importTag.PreferArch, importTag.PreferOS, importTag.ImportMode)
+// importTagFromDocker loads the image referenced by importTag from repository, obeying the
+// optional rate limiter. The error, if any, is set onto importTag.
+func (imp *ImageStreamImporter) importTagFromDocker(ctx context.Context, repository *importRepository, importTag *importTag) {
+	if importTag.Err != nil || importTag.Image != nil {
+		return
+	}
 
-		if importTag.Err == nil {
-			images, err := imp.importSubManifests(
-				ctx,
-				importTag.Image.DockerImageManifests,
-				repository,
-			)
-			if err != nil {
-				klog.V(5).Infof(
-					"unable to import manifest list %q: %v",
-					ref.Exact(), err)
-				importTag.Err = err
-				continue
-			}
-			importTag.Manifests = images
+	ref := repository.Ref
+	ref.Tag = importTag.Name
+	ref.ID = ""
+
+	dockerRef, err := reference.ParseNormalizedNamed(ref.Exact())
+	if err != nil {
+		importTag.Err = fmt.Errorf("unable to parse docker reference %s: %v", ref.Exact(), err)
+		return
+	}
+
+	imp.limiter.Accept()
+
+	importTag.Image, importTag.Err = imp.getImage(ctx, dockerRef, "", repository.Insecure, importTag.PreferArch, importTag.PreferOS, importTag.ImportMode)
+	if importTag.Err == nil && importTag.DiscoverReferrers {
+		imp.discoverReferrers(ctx, dockerRef, importTag.Image, repository.Insecure)
+	}
+	if importTag.Err == nil {
+		if err := imp.verifySignatures(ctx, repository.Namespace, dockerRef, importTag.Image, repository.Insecure); err != nil {
+			importTag.Image, importTag.Err = nil, err
+			return
 		}
 	}
+
+	if importTag.Err == nil {
+		images, err := imp.importSubManifests(
+			ctx,
+			importTag.Image.DockerImageManifests,
+			repository,
+		)
+		if err != nil {
+			klog.V(5).Infof(
+				"unable to import manifest list %q: %v",
+				ref.Exact(), err)
+			importTag.Err = err
+			return
+		}
+		importTag.Manifests = images
+	}
 }
 
 func (imp *ImageStreamImporter) importSubManifests(
@@ -946,13 +1255,8 @@ func (imp *ImageStreamImporter) importSubManifests(
 		if err != nil {
 			return nil, err
 		}
-		manifest, ms, bs, err := imp.getManifest(ctx, dockerRef, repository.Insecure)
-		if err != nil {
-			return nil, err
-		}
-
 		manifestDigest := godigest.Digest(imageManifest.Digest)
-		image, err := imp.importManifest(ctx, manifest, dockerRef, manifestDigest, ms, bs, "", "", "")
+		image, err := imp.getImage(ctx, dockerRef, manifestDigest, repository.Insecure, "", "", "")
 		if err != nil {
 			return nil, err
 		}
@@ -963,29 +1267,33 @@ func (imp *ImageStreamImporter) importSubManifests(
 }
 
 type importTag struct {
-	Name       string
-	PreferArch string
-	PreferOS   string
-	ImportMode imageapi.ImportModeType
-	Image      *imageapi.Image
-	Manifests  []imageapi.Image
-	Err        error
+	Name              string
+	PreferArch        string
+	PreferOS          string
+	ImportMode        imageapi.ImportModeType
+	DiscoverReferrers bool
+	Image             *imageapi.Image
+	Manifests         []imageapi.Image
+	Err               error
 }
 
 type importDigest struct {
-	Name       string
-	ImportMode imageapi.ImportModeType
-	Image      *imageapi.Image
-	Manifests  []imageapi.Image
-	Err        error
+	Name              string
+	ImportMode        imageapi.ImportModeType
+	DiscoverReferrers bool
+	Image             *imageapi.Image
+	Manifests         []imageapi.Image
+	Err               error
 }
 
 type importRepository struct {
-	Ref        imageapi.DockerImageReference
-	Registry   *url.URL
-	Name       string
-	Insecure   bool
-	ImportMode imageapi.ImportModeType
+	Namespace         string
+	Ref               imageapi.DockerImageReference
+	Registry          *url.URL
+	Name              string
+	Insecure          bool
+	ImportMode        imageapi.ImportModeType
+	DiscoverReferrers bool
 
 	Tags    []importTag
 	Digests []importDigest
@@ -1015,6 +1323,8 @@ type manifestKey struct {
 	preferOS string
 	// the import mode of the manifest
 	importMode imageapi.ImportModeType
+	// whether the artifacts referring to the image are discovered
+	discoverReferrers bool
 }
 
 func imageImportStatus(err error, kind, position string) metav1.Status {
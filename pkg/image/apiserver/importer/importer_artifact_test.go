@@ -0,0 +1,207 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/manifest/ocischema"
+	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	godigest "github.com/opencontainers/go-digest"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+const helmChartManifest = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+   "config": {
+      "mediaType": "application/vnd.cncf.helm.config.v1+json",
+      "size": 117,
+      "digest": "sha256:8ec7c0f2f6860037c19b54c3cfbab48d9b4b21b485a93d87b64690fdb68c2111"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+         "size": 2487,
+         "digest": "sha256:1b251d38cfe948dfc0a5745b7af5ca574ecb61e52aed10b19039db39af6e1617"
+      }
+   ],
+   "annotations": {
+      "org.opencontainers.image.title": "mychart",
+      "image.openshift.io/reserved": "ignored"
+   }
+}`
+
+// artifactRepository is a mockRepository which serves manifests by tag and
+// by digest.
+type artifactRepository struct {
+	*mockRepository
+	manifests map[godigest.Digest]distribution.Manifest
+	tagged    map[string]godigest.Digest
+}
+
+func (r *artifactRepository) Manifests(ctx context.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
+	return r, r.repoErr
+}
+
+func (r *artifactRepository) Get(ctx context.Context, dgst godigest.Digest, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	for _, option := range options {
+		if tag, ok := option.(distribution.WithTagOption); ok {
+			d, ok := r.tagged[tag.Tag]
+			if !ok {
+				return nil, v2.ErrorCodeManifestUnknown.WithDetail(dgst)
+			}
+			dgst = d
+		}
+	}
+	r.lock.Lock()
+	r.manifestReqs = append(r.manifestReqs, dgst)
+	r.lock.Unlock()
+	manifest, ok := r.manifests[dgst]
+	if !ok {
+		return nil, v2.ErrorCodeManifestUnknown.WithDetail(dgst)
+	}
+	return manifest, nil
+}
+
+func (r *artifactRepository) add(t *testing.T, tag string, payload string) godigest.Digest {
+	manifest := &ocischema.DeserializedManifest{}
+	if err := manifest.UnmarshalJSON([]byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+	dgst := godigest.FromString(payload)
+	r.manifests[dgst] = manifest
+	if len(tag) > 0 {
+		r.tagged[tag] = dgst
+	}
+	return dgst
+}
+
+func newArtifactRepository() *artifactRepository {
+	return &artifactRepository{
+		mockRepository: &mockRepository{
+			blobs: &mockBlobStore{blobs: map[godigest.Digest][]byte{}},
+		},
+		manifests: map[godigest.Digest]distribution.Manifest{},
+		tagged:    map[string]godigest.Digest{},
+	}
+}
+
+func TestImportArtifact(t *testing.T) {
+	repo := newArtifactRepository()
+	dgst := repo.add(t, "1.0.0", helmChartManifest)
+
+	isi := &imageapi.ImageStreamImport{
+		Spec: imageapi.ImageStreamImportSpec{
+			Images: []imageapi.ImageImportSpec{
+				{From: kapi.ObjectReference{Kind: "DockerImage", Name: "test:1.0.0"}},
+			},
+		},
+	}
+	im := NewImageStreamImporter(&mockRetriever{repo: repo}, nil, 5, nil, nil)
+	if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+		t.Fatal(err)
+	}
+
+	status := isi.Status.Images[0]
+	if status.Status.Status != metav1.StatusSuccess {
+		t.Fatalf("unexpected status: %#v", status.Status)
+	}
+	image := status.Image
+	if image.Name != dgst.String() {
+		t.Errorf("expected image %s, got %s", dgst, image.Name)
+	}
+	if e, a := "application/vnd.cncf.helm.config.v1+json", image.Annotations[imageapi.ImageArtifactTypeAnnotation]; e != a {
+		t.Errorf("expected artifact type %q, got %q", e, a)
+	}
+	if e, a := "mychart", image.Annotations["org.opencontainers.image.title"]; e != a {
+		t.Errorf("expected title annotation %q, got %q", e, a)
+	}
+	if _, ok := image.Annotations["image.openshift.io/reserved"]; ok {
+		t.Errorf("expected annotations in the openshift.io domain to be ignored, got %v", image.Annotations)
+	}
+	if len(image.DockerImageLayers) != 1 {
+		t.Errorf("expected one layer, got %#v", image.DockerImageLayers)
+	}
+}
+
+func TestImportDiscoverReferrers(t *testing.T) {
+	const signatureManifest = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+   "artifactType": "application/vnd.dev.cosign.artifact.sig.v1+json",
+   "config": {
+      "mediaType": "application/vnd.oci.empty.v1+json",
+      "size": 2,
+      "digest": "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+   },
+   "layers": []
+}`
+
+	repo := newArtifactRepository()
+	dgst := repo.add(t, "1.0.0", helmChartManifest)
+	signatureDigest := repo.add(t, "", signatureManifest)
+	index, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.index.v1+json",
+		"manifests": []map[string]interface{}{
+			{
+				"mediaType":    "application/vnd.oci.image.manifest.v1+json",
+				"digest":       signatureDigest,
+				"size":         len(signatureManifest),
+				"artifactType": "application/vnd.dev.cosign.artifact.sig.v1+json",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexManifest, _, err := distribution.UnmarshalManifest("application/vnd.oci.image.index.v1+json", index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexDigest := godigest.FromBytes(index)
+	repo.manifests[indexDigest] = indexManifest
+	repo.tagged[referrersTag(dgst)] = indexDigest
+
+	for _, discover := range []bool{false, true} {
+		isi := &imageapi.ImageStreamImport{
+			Spec: imageapi.ImageStreamImportSpec{
+				Images: []imageapi.ImageImportSpec{
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "test:1.0.0"}},
+				},
+			},
+		}
+		if discover {
+			isi.Annotations = map[string]string{imageapi.ImporterDiscoverReferrersAnnotation: "true"}
+		}
+		im := NewImageStreamImporter(&mockRetriever{repo: repo}, nil, 5, nil, nil)
+		if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+			t.Fatal(err)
+		}
+
+		status := isi.Status.Images[0]
+		if status.Status.Status != metav1.StatusSuccess {
+			t.Fatalf("unexpected status: %#v", status.Status)
+		}
+		value, ok := status.Image.Annotations[imageapi.ImageReferrersAnnotation]
+		if !discover {
+			if ok {
+				t.Errorf("expected referrers not to be discovered, got %s", value)
+			}
+			continue
+		}
+		referrers := []imageReferrer{}
+		if err := json.Unmarshal([]byte(value), &referrers); err != nil {
+			t.Fatalf("unable to parse referrers %q: %v", value, err)
+		}
+		if len(referrers) != 1 || referrers[0].Digest != signatureDigest.String() || referrers[0].ArtifactType != "application/vnd.dev.cosign.artifact.sig.v1+json" {
+			t.Errorf("unexpected referrers: %#v", referrers)
+		}
+	}
+}
@@ -363,7 +363,8 @@ func TestImportManifestList(t *testing.T) {
 				mockRepo.tags = map[string]string{"latest": "foo-digest"}
 			}
 
-			im := NewImageStreamImporter(retriever, nil, 5, nil, nil)
+			// fetch manifests one by one to get a stable order of requests
+			im := NewImageStreamImporter(retriever, nil, 5, nil, nil).WithImportWorkers(NewImportWorkers(1, 1))
 			if err := im.Import(nil, &imageStreamImport, &imageapi.ImageStream{}); err != nil {
 				t.Errorf("importing manifest list returned: %v", err)
 			}
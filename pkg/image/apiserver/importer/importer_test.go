@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/containers/image/v5/pkg/sysregistriesv2"
@@ -38,11 +39,14 @@ func (r mockRetrieverFunc) Repository(ctx context.Context, ref imageref.DockerIm
 
 type mockRetriever struct {
 	repo     distribution.Repository
+	lock     sync.Mutex
 	insecure bool
 	err      error
 }
 
 func (r *mockRetriever) Repository(ctx context.Context, ref imageref.DockerImageReference, insecure bool) (distribution.Repository, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	r.insecure = insecure
 	return r.repo, r.err
 }
@@ -53,6 +57,7 @@ type mockRepository struct {
 	blobs *mockBlobStore
 
 	manifest       distribution.Manifest
+	lock           sync.Mutex
 	manifestReqs   []godigest.Digest
 	extraManifests map[godigest.Digest]distribution.Manifest
 	tags           map[string]string
@@ -73,7 +78,9 @@ func (r *mockRepository) Exists(ctx context.Context, dgst godigest.Digest) (bool
 }
 
 func (r *mockRepository) Get(ctx context.Context, dgst godigest.Digest, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	r.lock.Lock()
 	r.manifestReqs = append(r.manifestReqs, dgst)
+	r.lock.Unlock()
 	for d, manifest := range r.extraManifests {
 		if dgst == d {
 			return manifest, nil
@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	godigest "github.com/opencontainers/go-digest"
+
+	"k8s.io/utils/clock"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+const (
+	// DefaultManifestCacheSize is the default number of images kept by the
+	// in-memory manifest cache.
+	DefaultManifestCacheSize = 1024
+	// DefaultManifestCacheTagTTL is the default time for which a resolved
+	// tag is considered to still point to the same digest.
+	DefaultManifestCacheTagTTL = 30 * time.Second
+)
+
+// ManifestCacheKey identifies an image imported from a manifest.
+type ManifestCacheKey struct {
+	// Digest is the digest of the manifest the image was imported from.
+	Digest godigest.Digest
+	// PreferArch and PreferOS select the image from a manifest list.
+	PreferArch string
+	PreferOS   string
+	// ImportMode is the import mode used for the manifest.
+	ImportMode imageapi.ImportModeType
+}
+
+// ManifestCache caches images imported from remote registries across
+// requests, so that a manifest, its image configuration and the sizes of its
+// layers are not fetched again when the same digest is imported. The cache is
+// shared by all namespaces, the importer always verifies that the requester
+// can access the manifest before an image is served from the cache.
+// Implementations must be safe for concurrent use.
+type ManifestCache interface {
+	// GetImage returns the image imported for key.
+	GetImage(key ManifestCacheKey) (*imageapi.Image, bool)
+	// AddImage stores the image imported for key.
+	AddImage(key ManifestCacheKey, image *imageapi.Image)
+	// GetTag returns the digest the tagged reference ref resolved to, unless
+	// the resolution has expired.
+	GetTag(ref string) (godigest.Digest, bool)
+	// AddTag stores the digest the tagged reference ref resolved to.
+	AddTag(ref string, dgst godigest.Digest)
+}
+
+type tagCacheEntry struct {
+	digest  godigest.Digest
+	expires time.Time
+}
+
+// inMemoryManifestCache is a ManifestCache that keeps a bounded number of
+// images and tags in LRU caches.
+type inMemoryManifestCache struct {
+	images *lru.Cache
+	tags   *lru.Cache
+	tagTTL time.Duration
+	clock  clock.PassiveClock
+}
+
+// NewInMemoryManifestCache returns a ManifestCache that keeps up to size
+// images and tags in memory. Tags are resolved from the cache for tagTTL
+// after they have been fetched.
+func NewInMemoryManifestCache(size int, tagTTL time.Duration) (ManifestCache, error) {
+	return newInMemoryManifestCache(size, tagTTL, clock.RealClock{})
+}
+
+func newInMemoryManifestCache(size int, tagTTL time.Duration, clock clock.PassiveClock) (*inMemoryManifestCache, error) {
+	images, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &inMemoryManifestCache{
+		images: images,
+		tags:   tags,
+		tagTTL: tagTTL,
+		clock:  clock,
+	}, nil
+}
+
+func (c *inMemoryManifestCache) GetImage(key ManifestCacheKey) (*imageapi.Image, bool) {
+	value, ok := c.images.Get(key)
+	if !ok {
+		manifestCacheRequests.WithLabelValues("image", "miss").Inc()
+		return nil, false
+	}
+	manifestCacheRequests.WithLabelValues("image", "hit").Inc()
+	return value.(*imageapi.Image).DeepCopy(), true
+}
+
+func (c *inMemoryManifestCache) AddImage(key ManifestCacheKey, image *imageapi.Image) {
+	c.images.Add(key, image.DeepCopy())
+}
+
+func (c *inMemoryManifestCache) GetTag(ref string) (godigest.Digest, bool) {
+	value, ok := c.tags.Get(ref)
+	if !ok {
+		manifestCacheRequests.WithLabelValues("tag", "miss").Inc()
+		return "", false
+	}
+	entry := value.(tagCacheEntry)
+	if !c.clock.Now().Before(entry.expires) {
+		c.tags.Remove(ref)
+		manifestCacheRequests.WithLabelValues("tag", "miss").Inc()
+		return "", false
+	}
+	manifestCacheRequests.WithLabelValues("tag", "hit").Inc()
+	return entry.digest, true
+}
+
+func (c *inMemoryManifestCache) AddTag(ref string, dgst godigest.Digest) {
+	if c.tagTTL <= 0 {
+		return
+	}
+	c.tags.Add(ref, tagCacheEntry{
+		digest:  dgst,
+		expires: c.clock.Now().Add(c.tagTTL),
+	})
+}
@@ -0,0 +1,165 @@
+package importer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/manifest/schema2"
+	godigest "github.com/opencontainers/go-digest"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+func TestInMemoryManifestCacheTagTTL(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	cache, err := newInMemoryManifestCache(10, time.Minute, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.AddTag("docker.io/library/busybox:latest", busyboxDigest)
+	if dgst, ok := cache.GetTag("docker.io/library/busybox:latest"); !ok || dgst != busyboxDigest {
+		t.Errorf("expected tag to resolve to %s, got %q (found=%t)", busyboxDigest, dgst, ok)
+	}
+
+	clock.SetTime(clock.Now().Add(time.Minute))
+	if dgst, ok := cache.GetTag("docker.io/library/busybox:latest"); ok {
+		t.Errorf("expected tag resolution to expire, got %s", dgst)
+	}
+}
+
+func TestInMemoryManifestCacheImageCopy(t *testing.T) {
+	cache, err := NewInMemoryManifestCache(10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := ManifestCacheKey{Digest: busyboxDigest}
+	image := &imageapi.Image{ObjectMeta: metav1.ObjectMeta{Name: busyboxDigest}}
+	cache.AddImage(key, image)
+	image.Name = "modified"
+
+	cached, ok := cache.GetImage(key)
+	if !ok {
+		t.Fatalf("expected image to be cached")
+	}
+	if cached.Name != busyboxDigest {
+		t.Errorf("expected cache to keep a copy of the image, got %s", cached.Name)
+	}
+	cached.Name = "modified"
+	if cached, _ := cache.GetImage(key); cached.Name != busyboxDigest {
+		t.Errorf("expected cache to return a copy of the image, got %s", cached.Name)
+	}
+
+	if _, ok := cache.GetImage(ManifestCacheKey{Digest: busyboxDigest, ImportMode: imageapi.ImportModePreserveOriginal}); ok {
+		t.Errorf("expected images imported with a different import mode not to be cached")
+	}
+}
+
+// accessibleRepository is a mockRepository which reports all manifests as
+// existing, unless denied is set.
+type accessibleRepository struct {
+	*mockRepository
+	denied bool
+}
+
+func (r *accessibleRepository) Manifests(ctx context.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
+	return r, r.repoErr
+}
+
+func (r *accessibleRepository) Exists(ctx context.Context, dgst godigest.Digest) (bool, error) {
+	return !r.denied, nil
+}
+
+func TestImportWithManifestCache(t *testing.T) {
+	manifest := &schema2.DeserializedManifest{}
+	if err := manifest.UnmarshalJSON([]byte(busyboxManifest)); err != nil {
+		t.Fatal(err)
+	}
+	configDigest := godigest.FromBytes([]byte(busyboxManifestConfig))
+	manifest.Config = distribution.Descriptor{
+		Digest:    configDigest,
+		Size:      int64(len(busyboxManifestConfig)),
+		MediaType: schema2.MediaTypeImageConfig,
+	}
+	manifestDigest, err := manifestDigest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRepository := func() *accessibleRepository {
+		return &accessibleRepository{
+			mockRepository: &mockRepository{
+				manifest: manifest,
+				blobs: &mockBlobStore{
+					blobs: map[godigest.Digest][]byte{
+						configDigest: []byte(busyboxManifestConfig),
+					},
+				},
+			},
+		}
+	}
+	newImport := func() *imageapi.ImageStreamImport {
+		return &imageapi.ImageStreamImport{
+			Spec: imageapi.ImageStreamImportSpec{
+				Images: []imageapi.ImageImportSpec{
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "test:latest"}},
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "test@" + string(manifestDigest)}},
+				},
+			},
+		}
+	}
+
+	cache, err := NewInMemoryManifestCache(10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := newRepository()
+	isi := newImport()
+	im := NewImageStreamImporter(&mockRetriever{repo: first}, nil, 5, nil, nil).WithManifestCache(cache)
+	if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.manifestReqs) != 2 {
+		t.Errorf("expected manifests to be fetched from the registry, got requests %v", first.manifestReqs)
+	}
+
+	second := newRepository()
+	isi = newImport()
+	im = NewImageStreamImporter(&mockRetriever{repo: second}, nil, 5, nil, nil).WithManifestCache(cache)
+	if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.manifestReqs) != 0 {
+		t.Errorf("expected manifests to be served from the cache, got requests %v", second.manifestReqs)
+	}
+	for i, image := range isi.Status.Images {
+		if image.Status.Status != metav1.StatusSuccess {
+			t.Fatalf("%d: unexpected status: %#v", i, image.Status)
+		}
+		if image.Image.Name != string(manifestDigest) {
+			t.Errorf("%d: unexpected image %s", i, image.Image.Name)
+		}
+		if image.Image.DockerImageMetadata.Size != busyboxImageSize {
+			t.Errorf("%d: unexpected image size: %d != %d", i, image.Image.DockerImageMetadata.Size, busyboxImageSize)
+		}
+	}
+
+	denied := newRepository()
+	denied.denied = true
+	isi = newImport()
+	im = NewImageStreamImporter(&mockRetriever{repo: denied}, nil, 5, nil, nil).WithManifestCache(cache)
+	if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(denied.manifestReqs) != 2 {
+		t.Errorf("expected inaccessible manifests to be fetched from the registry, got requests %v", denied.manifestReqs)
+	}
+}
@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	manifestCacheRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      "openshift_image_import",
+			Name:           "manifest_cache_requests_total",
+			Help:           "Number of lookups in the image import manifest cache partitioned by cache and result.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"cache", "result"},
+	)
+
+	signatureVerifications = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      "openshift_image_import",
+			Name:           "signature_verifications_total",
+			Help:           "Number of images whose signatures were verified during imports partitioned by policy mode and result.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"mode", "result"},
+	)
+
+	importRetries = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      "openshift_image_import",
+			Name:           "retries_total",
+			Help:           "Number of registry requests retried during imports partitioned by error class.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"class"},
+	)
+
+	mirrorRequestDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Subsystem:      "openshift_image_import",
+			Name:           "mirror_request_duration_seconds",
+			Help:           "Latency of the manifest requests made to registries and mirrors partitioned by mirror and result.",
+			Buckets:        metrics.ExponentialBuckets(0.05, 2, 10),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"mirror", "result"},
+	)
+
+	mirrorCooldowns = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      "openshift_image_import",
+			Name:           "mirror_cooldowns_total",
+			Help:           "Number of times a failing registry or mirror started to be skipped partitioned by mirror.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"mirror"},
+	)
+
+	mirrorSkips = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      "openshift_image_import",
+			Name:           "mirror_skips_total",
+			Help:           "Number of times a registry or mirror was skipped during its cool-down period partitioned by mirror.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"mirror"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(manifestCacheRequests)
+	legacyregistry.MustRegister(signatureVerifications)
+	legacyregistry.MustRegister(importRetries)
+	legacyregistry.MustRegister(mirrorRequestDuration)
+	legacyregistry.MustRegister(mirrorCooldowns)
+	legacyregistry.MustRegister(mirrorSkips)
+}
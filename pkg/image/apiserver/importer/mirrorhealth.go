@@ -0,0 +1,245 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/distribution/distribution/v3/reference"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// DefaultMirrorCooldown is the time a mirror that keeps failing is skipped for.
+	DefaultMirrorCooldown = 2 * time.Minute
+	// DefaultMirrorFailureThreshold is the number of consecutive failures after which a mirror is
+	// skipped.
+	DefaultMirrorFailureThreshold = 3
+
+	// slowMirrorLatency is the average latency above which a mirror is tried after the faster ones.
+	slowMirrorLatency = 2 * time.Second
+	// latencyWeight is the weight of the latest request in the moving average of the latency.
+	latencyWeight = 0.3
+	// maxTrackedMirrors bounds the number of mirrors tracked, and of the series of their metrics.
+	maxTrackedMirrors = 64
+	// otherMirrors is the metrics label of the mirrors requested once maxTrackedMirrors are tracked.
+	otherMirrors = "other"
+)
+
+// MirrorHealth describes the health of a registry or mirror images are pulled from.
+type MirrorHealth struct {
+	// Mirror is the host of the registry or mirror.
+	Mirror string `json:"mirror"`
+	// Healthy is false while the mirror is skipped.
+	Healthy bool `json:"healthy"`
+	// Latency is the moving average of the latency of the successful requests.
+	Latency string `json:"latency,omitempty"`
+	// Requests and Failures count the requests made to the mirror.
+	Requests int64 `json:"requests"`
+	Failures int64 `json:"failures"`
+	// ConsecutiveFailures is the number of failed requests since the last successful one.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// LastErrorClass is the class of the last error, the error itself may name the repositories
+	// of any namespace.
+	LastErrorClass ImportErrorClass `json:"lastErrorClass,omitempty"`
+	LastFailure    *time.Time       `json:"lastFailure,omitempty"`
+	// SkippedUntil is the end of the cool-down period of a failing mirror.
+	SkippedUntil *time.Time `json:"skippedUntil,omitempty"`
+}
+
+type mirrorHealth struct {
+	latency             time.Duration
+	requests            int64
+	failures            int64
+	consecutiveFailures int
+	lastErrorClass      ImportErrorClass
+	lastFailure         time.Time
+	skippedUntil        time.Time
+}
+
+// MirrorHealthTracker keeps track of the health of the registries and mirrors images are pulled from,
+// so that the importer tries the healthy ones first and skips the ones that keep failing for a
+// cool-down period. Only the registries with mirrors configured are tracked, at most
+// maxTrackedMirrors of them. It is shared across imports and safe for concurrent use. A nil tracker
+// orders pull sources as configured.
+type MirrorHealthTracker struct {
+	cooldown         time.Duration
+	failureThreshold int
+	clock            clock.PassiveClock
+
+	lock    sync.Mutex
+	mirrors map[string]*mirrorHealth
+}
+
+// NewMirrorHealthTracker returns a tracker which skips a mirror for cooldown once failureThreshold
+// requests in a row failed. Non-positive values select the defaults.
+func NewMirrorHealthTracker(cooldown time.Duration, failureThreshold int) *MirrorHealthTracker {
+	return newMirrorHealthTracker(cooldown, failureThreshold, clock.RealClock{})
+}
+
+func newMirrorHealthTracker(cooldown time.Duration, failureThreshold int, clock clock.PassiveClock) *MirrorHealthTracker {
+	if cooldown <= 0 {
+		cooldown = DefaultMirrorCooldown
+	}
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultMirrorFailureThreshold
+	}
+	return &MirrorHealthTracker{
+		cooldown:         cooldown,
+		failureThreshold: failureThreshold,
+		clock:            clock,
+		mirrors:          make(map[string]*mirrorHealth),
+	}
+}
+
+func mirrorName(ref reference.Named) string {
+	return reference.Domain(ref)
+}
+
+// observe records the outcome of a request to the mirror of ref. Errors telling that the image is
+// missing or not accessible come from a live mirror and do not count as failures.
+func (t *MirrorHealthTracker) observe(ref reference.Named, latency time.Duration, err error) {
+	if t == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	name := mirrorName(ref)
+
+	failed := false
+	var class ImportErrorClass
+	if err != nil {
+		class, _ = classifyImportError(err)
+		failed = class != ImportErrorNotFound && class != ImportErrorAuth
+	}
+	result := "success"
+	if failed {
+		result = "failure"
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	mirror, ok := t.mirrors[name]
+	if !ok && len(t.mirrors) >= maxTrackedMirrors {
+		mirrorRequestDuration.WithLabelValues(otherMirrors, result).Observe(latency.Seconds())
+		return
+	}
+	mirrorRequestDuration.WithLabelValues(name, result).Observe(latency.Seconds())
+	if !ok {
+		mirror = &mirrorHealth{}
+		t.mirrors[name] = mirror
+	}
+	mirror.requests++
+
+	if !failed {
+		mirror.consecutiveFailures = 0
+		if mirror.latency == 0 {
+			mirror.latency = latency
+		} else {
+			mirror.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(mirror.latency))
+		}
+		return
+	}
+
+	now := t.clock.Now()
+	mirror.failures++
+	mirror.consecutiveFailures++
+	mirror.lastErrorClass = class
+	mirror.lastFailure = now
+	if mirror.consecutiveFailures >= t.failureThreshold && !now.Before(mirror.skippedUntil) {
+		mirror.skippedUntil = now.Add(t.cooldown)
+		mirrorCooldowns.WithLabelValues(name).Inc()
+		klog.V(2).Infof("skipping image mirror %s for %s after %d consecutive failures: %v", name, t.cooldown, mirror.consecutiveFailures, err)
+	}
+}
+
+// order returns the pull sources to try, healthy mirrors first. Mirrors in their cool-down period
+// are left out, unless all the sources are.
+func (t *MirrorHealthTracker) order(sources []sysregistriesv2.PullSource) []sysregistriesv2.PullSource {
+	if t == nil || len(sources) == 0 {
+		return sources
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.clock.Now()
+	var healthy, degraded, skipped []sysregistriesv2.PullSource
+	for _, source := range sources {
+		mirror, ok := t.mirrors[mirrorName(source.Reference)]
+		switch {
+		case !ok:
+			healthy = append(healthy, source)
+		case now.Before(mirror.skippedUntil):
+			skipped = append(skipped, source)
+		case mirror.consecutiveFailures > 0 || mirror.latency > slowMirrorLatency:
+			degraded = append(degraded, source)
+		default:
+			healthy = append(healthy, source)
+		}
+	}
+	if len(healthy) == 0 && len(degraded) == 0 {
+		return sources
+	}
+	for _, source := range skipped {
+		mirrorSkips.WithLabelValues(mirrorName(source.Reference)).Inc()
+	}
+	return append(healthy, degraded...)
+}
+
+// Mirrors returns the health of the mirrors requests were made to, sorted by name.
+func (t *MirrorHealthTracker) Mirrors() []MirrorHealth {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.clock.Now()
+	mirrors := make([]MirrorHealth, 0, len(t.mirrors))
+	for name, mirror := range t.mirrors {
+		health := MirrorHealth{
+			Mirror:              name,
+			Healthy:             !now.Before(mirror.skippedUntil),
+			Requests:            mirror.requests,
+			Failures:            mirror.failures,
+			ConsecutiveFailures: mirror.consecutiveFailures,
+			LastErrorClass:      mirror.lastErrorClass,
+		}
+		if mirror.latency > 0 {
+			health.Latency = mirror.latency.String()
+		}
+		if !mirror.lastFailure.IsZero() {
+			lastFailure := mirror.lastFailure
+			health.LastFailure = &lastFailure
+		}
+		if !health.Healthy {
+			skippedUntil := mirror.skippedUntil
+			health.SkippedUntil = &skippedUntil
+		}
+		mirrors = append(mirrors, health)
+	}
+	sort.Slice(mirrors, func(i, j int) bool {
+		return mirrors[i].Mirror < mirrors[j].Mirror
+	})
+	return mirrors
+}
+
+// ServeHTTP serves the health of the mirrors as JSON.
+func (t *MirrorHealthTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := json.MarshalIndent(t.Mirrors(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,200 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/manifest/schema2"
+	"github.com/distribution/distribution/v3/reference"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	godigest "github.com/opencontainers/go-digest"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+func pullSources(t *testing.T, names ...string) []sysregistriesv2.PullSource {
+	var sources []sysregistriesv2.PullSource
+	for _, name := range names {
+		ref, err := reference.ParseNormalizedNamed(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, sysregistriesv2.PullSource{Reference: ref})
+	}
+	return sources
+}
+
+func sourceNames(sources []sysregistriesv2.PullSource) []string {
+	var names []string
+	for _, source := range sources {
+		names = append(names, mirrorName(source.Reference))
+	}
+	return names
+}
+
+func TestMirrorHealthTrackerOrder(t *testing.T) {
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	tracker := newMirrorHealthTracker(time.Minute, 2, clock)
+	sources := pullSources(t, "a.example.com/test", "b.example.com/test", "c.example.com/test")
+	a, b, c := sources[0].Reference, sources[1].Reference, sources[2].Reference
+	unavailable := errcode.ErrorCodeUnavailable.WithMessage("down")
+
+	expectOrder := func(expected ...string) {
+		t.Helper()
+		names := sourceNames(tracker.order(sources))
+		if len(names) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Fatalf("expected %v, got %v", expected, names)
+			}
+		}
+	}
+
+	expectOrder("a.example.com", "b.example.com", "c.example.com")
+
+	tracker.observe(a, time.Millisecond, unavailable)
+	tracker.observe(b, 5*time.Second, nil)
+	tracker.observe(c, time.Millisecond, v2.ErrorCodeManifestUnknown)
+	expectOrder("c.example.com", "a.example.com", "b.example.com")
+
+	tracker.observe(a, time.Millisecond, unavailable)
+	expectOrder("c.example.com", "b.example.com")
+
+	tracker.observe(b, time.Millisecond, unavailable)
+	tracker.observe(b, time.Millisecond, unavailable)
+	tracker.observe(c, time.Millisecond, unavailable)
+	tracker.observe(c, time.Millisecond, unavailable)
+	expectOrder("a.example.com", "b.example.com", "c.example.com")
+
+	clock.SetTime(clock.Now().Add(time.Minute))
+	tracker.observe(a, time.Millisecond, nil)
+	expectOrder("a.example.com", "b.example.com", "c.example.com")
+
+	mirrors := tracker.Mirrors()
+	if len(mirrors) != 3 {
+		t.Fatalf("unexpected mirrors: %#v", mirrors)
+	}
+	if m := mirrors[0]; !m.Healthy || m.Requests != 3 || m.Failures != 2 || m.ConsecutiveFailures != 0 || m.LastFailure == nil {
+		t.Errorf("unexpected health of the recovered mirror: %#v", m)
+	}
+	if m := mirrors[1]; !m.Healthy || m.ConsecutiveFailures != 2 || m.SkippedUntil != nil {
+		t.Errorf("unexpected health of the mirror after its cool-down: %#v", m)
+	}
+}
+
+func TestMirrorHealthTrackerServeHTTP(t *testing.T) {
+	tracker := NewMirrorHealthTracker(time.Minute, 1)
+	sources := pullSources(t, "a.example.com/test")
+	tracker.observe(sources[0].Reference, time.Millisecond, errors.New("connection refused"))
+
+	recorder := httptest.NewRecorder()
+	tracker.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/image-import/mirrors", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", recorder.Code)
+	}
+	var mirrors []MirrorHealth
+	if err := json.Unmarshal(recorder.Body.Bytes(), &mirrors); err != nil {
+		t.Fatal(err)
+	}
+	if len(mirrors) != 1 || mirrors[0].Mirror != "a.example.com" || mirrors[0].Healthy || mirrors[0].SkippedUntil == nil || mirrors[0].LastErrorClass != ImportErrorPermanent {
+		t.Errorf("unexpected mirrors: %#v", mirrors)
+	}
+	if strings.Contains(recorder.Body.String(), "connection refused") {
+		t.Errorf("expected the error of the mirror to be redacted, got %s", recorder.Body.String())
+	}
+}
+
+func TestMirrorHealthTrackerBounded(t *testing.T) {
+	tracker := NewMirrorHealthTracker(time.Minute, 1)
+	for i := 0; i < maxTrackedMirrors+10; i++ {
+		sources := pullSources(t, fmt.Sprintf("mirror-%d.example.com/test", i))
+		tracker.observe(sources[0].Reference, time.Millisecond, nil)
+	}
+	if mirrors := tracker.Mirrors(); len(mirrors) != maxTrackedMirrors {
+		t.Errorf("expected %d tracked mirrors, got %d", maxTrackedMirrors, len(mirrors))
+	}
+}
+
+func TestImportSkipsFailingMirror(t *testing.T) {
+	manifest := &schema2.DeserializedManifest{}
+	if err := manifest.UnmarshalJSON([]byte(busyboxManifest)); err != nil {
+		t.Fatal(err)
+	}
+	configDigest := godigest.FromBytes([]byte(busyboxManifestConfig))
+	manifest.Config = distribution.Descriptor{
+		Digest:    configDigest,
+		Size:      int64(len(busyboxManifestConfig)),
+		MediaType: schema2.MediaTypeImageConfig,
+	}
+
+	regConf := &sysregistriesv2.V2RegistriesConf{
+		Registries: []sysregistriesv2.Registry{
+			{
+				Prefix:   "quay.io/openshift",
+				Endpoint: sysregistriesv2.Endpoint{Location: "quay.io/openshift"},
+				Mirrors: []sysregistriesv2.Endpoint{
+					{Location: "dead.example.com/openshift"},
+				},
+			},
+		},
+	}
+
+	var lock sync.Mutex
+	requests := map[string]int{}
+	retriever := mockRetrieverFunc(func(registry *url.URL, repoName string, insecure bool) (distribution.Repository, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests[registry.Host]++
+		if registry.Host == "dead.example.com" {
+			return nil, errcode.ErrorCodeUnavailable.WithMessage("down")
+		}
+		return &mockRepository{
+			blobs: &mockBlobStore{
+				blobs: map[godigest.Digest][]byte{configDigest: []byte(busyboxManifestConfig)},
+			},
+			manifest: manifest,
+		}, nil
+	})
+
+	tracker := NewMirrorHealthTracker(time.Minute, 1)
+	for i := 0; i < 2; i++ {
+		isi := &imageapi.ImageStreamImport{
+			Spec: imageapi.ImageStreamImportSpec{
+				Images: []imageapi.ImageImportSpec{
+					{From: kapi.ObjectReference{Kind: "DockerImage", Name: "quay.io/openshift/test:latest"}},
+				},
+			},
+		}
+		im := NewImageStreamImporter(retriever, regConf, 5, nil, nil).
+			WithRetryBackoff(wait.Backoff{Steps: 1}).
+			WithMirrorHealth(tracker)
+		if err := im.Import(nil, isi, &imageapi.ImageStream{}); err != nil {
+			t.Fatal(err)
+		}
+		if status := isi.Status.Images[0].Status; status.Status != metav1.StatusSuccess {
+			t.Fatalf("%d: unexpected status: %#v", i, status)
+		}
+	}
+
+	if requests["dead.example.com"] != 1 || requests["quay.io"] == 0 {
+		t.Errorf("expected the failing mirror to be skipped after its first failure, got requests %v", requests)
+	}
+}
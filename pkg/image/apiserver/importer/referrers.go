@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/distribution/distribution/v3/reference"
+	godigest "github.com/opencontainers/go-digest"
+
+	"k8s.io/klog/v2"
+
+	imageref "github.com/openshift/library-go/pkg/image/reference"
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+// ReferrersService may be implemented by the repositories returned by a RepositoryRetriever to list
+// the artifacts referring to a manifest through the OCI referrers API. Referrers of manifests in
+// repositories that do not implement it are discovered through the referrers tag schema.
+type ReferrersService interface {
+	// Referrers returns the raw OCI image index listing the manifests referring to dgst.
+	Referrers(ctx context.Context, dgst godigest.Digest) ([]byte, error)
+}
+
+// imageReferrer describes an artifact referring to an image. A list of referrers is stored in the
+// ImageReferrersAnnotation of the image.
+type imageReferrer struct {
+	Digest       string `json:"digest"`
+	MediaType    string `json:"mediaType,omitempty"`
+	ArtifactType string `json:"artifactType,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+}
+
+// referrersIndex is the part of an OCI image index needed to list referrers. It is decoded from the
+// raw index as the descriptors of a manifest list do not carry the artifact type.
+type referrersIndex struct {
+	Manifests []imageReferrer `json:"manifests"`
+}
+
+// referrersTag returns the tag under which the referrers of dgst are stored according to the
+// referrers tag schema.
+func referrersTag(dgst godigest.Digest) string {
+	return strings.Replace(dgst.String(), ":", "-", 1)
+}
+
+// getReferrers returns the artifacts referring to the manifest dgst in the repository of ref. The
+// referrers API is used if the repository supports it, otherwise the referrers are read from the
+// index tagged according to the referrers tag schema. A missing index means there are no referrers.
+func (imp *ImageStreamImporter) getReferrers(ctx context.Context, ref reference.Named, dgst godigest.Digest, insecure bool) ([]imageReferrer, error) {
+	imageRef, err := imageref.Parse(ref.Name())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse reference %q: %v", ref.Name(), err)
+	}
+
+	if repo, err := imp.retriever.Repository(ctx, imageRef, insecure); err == nil {
+		if referrers, ok := repo.(ReferrersService); ok {
+			payload, err := referrers.Referrers(ctx, dgst)
+			if err == nil {
+				return parseReferrers(payload)
+			}
+			klog.V(5).Infof("unable to list referrers of %s@%s, falling back to the tag schema: %v", ref.Name(), dgst, err)
+		}
+	}
+
+	tagRef, err := reference.WithTag(reference.TrimNamed(ref), referrersTag(dgst))
+	if err != nil {
+		return nil, err
+	}
+	manifest, _, _, err := imp.getManifest(ctx, tagRef, insecure)
+	if err != nil {
+		klog.V(5).Infof("no referrers of %s found with the tag schema: %v", ref.Name(), err)
+		return nil, nil
+	}
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return nil, err
+	}
+	return parseReferrers(payload)
+}
+
+func parseReferrers(payload []byte) ([]imageReferrer, error) {
+	index := referrersIndex{}
+	if err := json.Unmarshal(payload, &index); err != nil {
+		return nil, fmt.Errorf("unable to parse referrers index: %v", err)
+	}
+	return index.Manifests, nil
+}
+
+// discoverReferrers records the artifacts referring to image on its ImageReferrersAnnotation. Failing to
+// discover the referrers does not fail the import of the image.
+func (imp *ImageStreamImporter) discoverReferrers(ctx context.Context, ref reference.Named, image *imageapi.Image, insecure bool) {
+	referrers, err := imp.getReferrers(ctx, ref, godigest.Digest(image.Name), insecure)
+	if err != nil {
+		klog.V(4).Infof("unable to discover referrers of %s@%s: %v", ref.Name(), image.Name, err)
+		return
+	}
+	if len(referrers) == 0 {
+		return
+	}
+
+	value, err := json.Marshal(referrers)
+	if err != nil {
+		klog.V(4).Infof("unable to record referrers of %s@%s: %v", ref.Name(), image.Name, err)
+		return
+	}
+	if image.Annotations == nil {
+		image.Annotations = map[string]string{}
+	}
+	image.Annotations[imageapi.ImageReferrersAnnotation] = string(value)
+}
@@ -0,0 +1,276 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	registryclient "github.com/distribution/distribution/v3/registry/client"
+
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// ImportErrorClass classifies the errors returned by registries to decide whether a request is
+// worth retrying.
+type ImportErrorClass string
+
+const (
+	// ImportErrorTransient is a server error, a timeout or a broken connection.
+	ImportErrorTransient ImportErrorClass = "Transient"
+	// ImportErrorRateLimited means the registry throttles the importer.
+	ImportErrorRateLimited ImportErrorClass = "RateLimited"
+	// ImportErrorAuth means the importer is not allowed to access the image.
+	ImportErrorAuth ImportErrorClass = "Auth"
+	// ImportErrorNotFound means the image or the repository does not exist.
+	ImportErrorNotFound ImportErrorClass = "NotFound"
+	// ImportErrorPermanent is any other error.
+	ImportErrorPermanent ImportErrorClass = "Permanent"
+)
+
+var (
+	// DefaultRetryBackoff is the backoff used to retry requests to a single pull source that failed
+	// with a transient error. Steps is the number of attempts.
+	DefaultRetryBackoff = wait.Backoff{
+		Steps:    3,
+		Duration: 500 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.5,
+	}
+
+	// MaxRetryAfter caps the delay a registry can request through the Retry-After header.
+	MaxRetryAfter = 10 * time.Second
+)
+
+// rateLimitedError is returned by the retryAfterRoundTripper when a registry asks the importer to slow
+// down.
+type rateLimitedError struct {
+	host       string
+	retryAfter time.Duration
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("registry %s is rate limiting requests, retry after %s", e.host, e.retryAfter)
+}
+
+// retryAfterRoundTripper turns throttled responses that carry a Retry-After header into errors, as the
+// registry client does not expose the headers of failed responses.
+type retryAfterRoundTripper struct {
+	rt  http.RoundTripper
+	now func() time.Time
+}
+
+func newRetryAfterRoundTripper(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		return nil
+	}
+	return &retryAfterRoundTripper{rt: rt, now: time.Now}
+}
+
+func (t *retryAfterRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return resp, nil
+	}
+	retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), t.now())
+	if !ok {
+		return resp, nil
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return nil, &rateLimitedError{host: req.URL.Host, retryAfter: retryAfter}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either a number of seconds or a date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// classifyImportError returns the class of err and the delay the registry asked for, if any.
+func classifyImportError(err error) (ImportErrorClass, time.Duration) {
+	var rateLimited *rateLimitedError
+	if errors.As(err, &rateLimited) {
+		return ImportErrorRateLimited, rateLimited.retryAfter
+	}
+
+	var status kapierrors.APIStatus
+	if errors.As(err, &status) {
+		s := status.Status()
+		switch s.Reason {
+		case metav1.StatusReasonNotFound:
+			return ImportErrorNotFound, 0
+		case metav1.StatusReasonUnauthorized, metav1.StatusReasonForbidden:
+			return ImportErrorAuth, 0
+		case metav1.StatusReasonTooManyRequests:
+			var retryAfter time.Duration
+			if s.Details != nil {
+				retryAfter = time.Duration(s.Details.RetryAfterSeconds) * time.Second
+			}
+			return ImportErrorRateLimited, retryAfter
+		case metav1.StatusReasonServiceUnavailable, metav1.StatusReasonTimeout, metav1.StatusReasonServerTimeout:
+			return ImportErrorTransient, 0
+		}
+		return ImportErrorPermanent, 0
+	}
+
+	var errs errcode.Errors
+	if errors.As(err, &errs) {
+		class := ImportErrorPermanent
+		for _, err := range errs {
+			if c, _ := classifyImportError(err); c != ImportErrorPermanent {
+				class = c
+				break
+			}
+		}
+		return class, 0
+	}
+	var coder errcode.ErrorCoder
+	if errors.As(err, &coder) {
+		switch coder.ErrorCode() {
+		case errcode.ErrorCodeUnauthorized, errcode.ErrorCodeDenied:
+			return ImportErrorAuth, 0
+		case v2.ErrorCodeManifestUnknown, v2.ErrorCodeNameUnknown, v2.ErrorCodeBlobUnknown:
+			return ImportErrorNotFound, 0
+		case errcode.ErrorCodeTooManyRequests:
+			return ImportErrorRateLimited, 0
+		case errcode.ErrorCodeUnavailable:
+			return ImportErrorTransient, 0
+		}
+		return ImportErrorPermanent, 0
+	}
+
+	var statusErr *registryclient.UnexpectedHTTPStatusError
+	if errors.As(err, &statusErr) {
+		code, _, _ := strings.Cut(statusErr.Status, " ")
+		if code, err := strconv.Atoi(code); err == nil {
+			return classifyStatusCode(code), 0
+		}
+		return ImportErrorPermanent, 0
+	}
+	var responseErr *registryclient.UnexpectedHTTPResponseError
+	if errors.As(err, &responseErr) {
+		return classifyStatusCode(responseErr.StatusCode), 0
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ImportErrorPermanent, 0
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return ImportErrorTransient, 0
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ImportErrorTransient, 0
+	}
+	return ImportErrorPermanent, 0
+}
+
+func classifyStatusCode(code int) ImportErrorClass {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ImportErrorRateLimited
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return ImportErrorAuth
+	case code == http.StatusNotFound:
+		return ImportErrorNotFound
+	case code == http.StatusRequestTimeout, code >= 500:
+		return ImportErrorTransient
+	}
+	return ImportErrorPermanent
+}
+
+// retry calls fn until it succeeds, fails with an error that is not worth retrying, or the retry
+// backoff of the importer is exhausted. Transient and rate limited errors that remain once the
+// retries are exhausted are returned as ServiceUnavailable and TooManyRequests errors, so that the
+// import status tells them apart from permanent failures.
+func (imp *ImageStreamImporter) retry(ctx context.Context, source string, fn func() error) error {
+	backoff := imp.retryBackoff
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		class, retryAfter := classifyImportError(err)
+		if class != ImportErrorTransient && class != ImportErrorRateLimited {
+			return err
+		}
+		if backoff.Steps <= 1 {
+			return retriesExhaustedError(err, class, retryAfter)
+		}
+
+		delay := backoff.Step()
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if delay > MaxRetryAfter {
+			delay = MaxRetryAfter
+		}
+		importRetries.WithLabelValues(string(class)).Inc()
+		klog.V(4).Infof("retrying request to %s in %s after %s error: %v", source, delay, strings.ToLower(string(class)), err)
+		if sleepErr := imp.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func retriesExhaustedError(err error, class ImportErrorClass, retryAfter time.Duration) error {
+	var status kapierrors.APIStatus
+	if errors.As(err, &status) {
+		return err
+	}
+	switch class {
+	case ImportErrorRateLimited:
+		return kapierrors.NewTooManyRequests(err.Error(), int(retryAfter.Round(time.Second)/time.Second))
+	default:
+		return kapierrors.NewServiceUnavailable(err.Error())
+	}
+}
+
+// sleepWithContext waits for d or until ctx is done. The context may be nil.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case <-timer.C:
+		return nil
+	case <-done:
+		return ctx.Err()
+	}
+}
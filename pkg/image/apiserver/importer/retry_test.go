@@ -0,0 +1,299 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	registryclient "github.com/distribution/distribution/v3/registry/client"
+	godigest "github.com/opencontainers/go-digest"
+
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+func TestClassifyImportError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		class      ImportErrorClass
+		retryAfter time.Duration
+	}{
+		{
+			name:  "unavailable",
+			err:   errcode.ErrorCodeUnavailable.WithMessage("try later"),
+			class: ImportErrorTransient,
+		},
+		{
+			name:  "wrapped unexpected status",
+			err:   fmt.Errorf("test: %w", &registryclient.UnexpectedHTTPStatusError{Status: "502 Bad Gateway"}),
+			class: ImportErrorTransient,
+		},
+		{
+			name:  "unexpected response",
+			err:   &registryclient.UnexpectedHTTPResponseError{StatusCode: http.StatusTooManyRequests, ParseErr: io.EOF},
+			class: ImportErrorRateLimited,
+		},
+		{
+			name:  "connection reset",
+			err:   fmt.Errorf("read: %w", syscall.ECONNRESET),
+			class: ImportErrorTransient,
+		},
+		{
+			name:       "retry after",
+			err:        fmt.Errorf("test: %w", &rateLimitedError{host: "registry", retryAfter: 3 * time.Second}),
+			class:      ImportErrorRateLimited,
+			retryAfter: 3 * time.Second,
+		},
+		{
+			name:  "errors with a denied code",
+			err:   errcode.Errors{errcode.ErrorCodeUnknown, errcode.ErrorCodeDenied},
+			class: ImportErrorAuth,
+		},
+		{
+			name:  "manifest unknown",
+			err:   v2.ErrorCodeManifestUnknown.WithDetail("sha256:abc"),
+			class: ImportErrorNotFound,
+		},
+		{
+			name:  "formatted not found",
+			err:   kapierrors.NewNotFound(imageapi.Resource("dockerimage"), "test"),
+			class: ImportErrorNotFound,
+		},
+		{
+			name:       "too many requests status",
+			err:        kapierrors.NewTooManyRequests("slow down", 5),
+			class:      ImportErrorRateLimited,
+			retryAfter: 5 * time.Second,
+		},
+		{
+			name:  "canceled",
+			err:   fmt.Errorf("test: %w", context.Canceled),
+			class: ImportErrorPermanent,
+		},
+		{
+			name:  "unknown",
+			err:   errors.New("unsupported image manifest type"),
+			class: ImportErrorPermanent,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			class, retryAfter := classifyImportError(tc.err)
+			if class != tc.class || retryAfter != tc.retryAfter {
+				t.Errorf("expected %s after %s, got %s after %s", tc.class, tc.retryAfter, class, retryAfter)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "", ok: false},
+		{value: "7", expected: 7 * time.Second, ok: true},
+		{value: "-1", ok: false},
+		{value: now.Add(time.Minute).Format(http.TimeFormat), expected: time.Minute, ok: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), expected: 0, ok: true},
+		{value: "soon", ok: false},
+	}
+	for _, tc := range testCases {
+		d, ok := parseRetryAfter(tc.value, now)
+		if d != tc.expected || ok != tc.ok {
+			t.Errorf("%q: expected %s (%t), got %s (%t)", tc.value, tc.expected, tc.ok, d, ok)
+		}
+	}
+}
+
+func TestRetryAfterRoundTripper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/throttled":
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRetryAfterRoundTripper(http.DefaultTransport)}
+
+	_, err := client.Get(server.URL + "/throttled")
+	var rateLimited *rateLimitedError
+	if !errors.As(err, &rateLimited) || rateLimited.retryAfter != 2*time.Second {
+		t.Errorf("expected a rate limited error, got %v", err)
+	}
+
+	for _, path := range []string{"/unavailable", "/"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// flakyRepository is an artifactRepository whose manifests fail to be fetched
+// by tag with the listed errors before they are served.
+type flakyRepository struct {
+	*artifactRepository
+	failures map[string][]error
+}
+
+func (r *flakyRepository) Manifests(ctx context.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
+	return r, r.repoErr
+}
+
+func (r *flakyRepository) Get(ctx context.Context, dgst godigest.Digest, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	for _, option := range options {
+		if tag, ok := option.(distribution.WithTagOption); ok {
+			r.lock.Lock()
+			errs := r.failures[tag.Tag]
+			if len(errs) > 0 {
+				r.failures[tag.Tag] = errs[1:]
+			}
+			r.lock.Unlock()
+			if len(errs) > 0 && errs[0] != nil {
+				return nil, errs[0]
+			}
+		}
+	}
+	return r.artifactRepository.Get(ctx, dgst, options...)
+}
+
+func TestImportRetries(t *testing.T) {
+	throttled := &rateLimitedError{host: "registry", retryAfter: 3 * time.Second}
+	unavailable := errcode.ErrorCodeUnavailable.WithMessage("try later")
+
+	testCases := []struct {
+		name     string
+		failures map[string][]error
+		status   metav1.Status
+		tags     map[string]metav1.StatusReason
+		sleeps   int
+	}{
+		{
+			name: "transient errors are retried",
+			failures: map[string][]error{
+				"1.0.0": {unavailable, unavailable},
+				"2.0.0": {unavailable},
+			},
+			status: metav1.Status{Status: metav1.StatusSuccess},
+			tags:   map[string]metav1.StatusReason{"1.0.0": "", "2.0.0": ""},
+			sleeps: 3,
+		},
+		{
+			name: "some tags fail",
+			failures: map[string][]error{
+				"1.0.0": {throttled, throttled, throttled},
+				"2.0.0": {v2.ErrorCodeManifestUnknown},
+				"3.0.0": {unavailable},
+			},
+			status: metav1.Status{Status: metav1.StatusSuccess, Reason: RepositoryPartiallyImportedReason},
+			tags: map[string]metav1.StatusReason{
+				"1.0.0": metav1.StatusReasonTooManyRequests,
+				"2.0.0": metav1.StatusReasonNotFound,
+				"3.0.0": "",
+			},
+			sleeps: 3,
+		},
+		{
+			name: "all tags fail",
+			failures: map[string][]error{
+				"1.0.0": {unavailable, unavailable, unavailable},
+			},
+			status: metav1.Status{Status: metav1.StatusFailure, Reason: RepositoryImportFailedReason},
+			tags:   map[string]metav1.StatusReason{"1.0.0": metav1.StatusReasonServiceUnavailable},
+			sleeps: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &flakyRepository{artifactRepository: newArtifactRepository(), failures: tc.failures}
+			repo.tags = map[string]string{}
+			for tag := range tc.tags {
+				repo.add(t, tag, helmChartManifest)
+				repo.tags[tag] = ""
+			}
+
+			var lock sync.Mutex
+			var sleeps []time.Duration
+			im := NewImageStreamImporter(&mockRetriever{repo: repo}, nil, 5, nil, nil).WithRetryBackoff(wait.Backoff{
+				Steps:    3,
+				Duration: time.Millisecond,
+				Factor:   2,
+			})
+			im.sleep = func(ctx context.Context, d time.Duration) error {
+				lock.Lock()
+				defer lock.Unlock()
+				sleeps = append(sleeps, d)
+				return nil
+			}
+
+			isi := &imageapi.ImageStreamImport{
+				Spec: imageapi.ImageStreamImportSpec{
+					Repository: &imageapi.RepositoryImportSpec{
+						From: kapi.ObjectReference{Kind: "DockerImage", Name: "test"},
+					},
+				},
+			}
+			if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+				t.Fatal(err)
+			}
+
+			status := isi.Status.Repository
+			if status.Status.Status != tc.status.Status || status.Status.Reason != tc.status.Reason {
+				t.Errorf("unexpected repository status: %#v", status.Status)
+			}
+			if len(status.Images) != len(tc.tags) {
+				t.Fatalf("unexpected images: %#v", status.Images)
+			}
+			for _, image := range status.Images {
+				reason, ok := tc.tags[image.Tag]
+				if !ok {
+					t.Errorf("unexpected tag %s", image.Tag)
+					continue
+				}
+				if image.Status.Reason != reason {
+					t.Errorf("%s: expected reason %q, got %#v", image.Tag, reason, image.Status)
+				}
+				if reason == "" && image.Image == nil {
+					t.Errorf("%s: expected the image to be imported", image.Tag)
+				}
+				if reason == metav1.StatusReasonTooManyRequests && (image.Status.Details == nil || image.Status.Details.RetryAfterSeconds != 3) {
+					t.Errorf("%s: expected the status to carry the Retry-After of the registry, got %#v", image.Tag, image.Status.Details)
+				}
+			}
+
+			if len(sleeps) != tc.sleeps {
+				t.Errorf("expected %d retries, got %v", tc.sleeps, sleeps)
+			}
+			for _, d := range sleeps {
+				if d > MaxRetryAfter {
+					t.Errorf("unexpected delay %s", d)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package importer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/manifest/ocischema"
+	"github.com/distribution/distribution/v3/manifest/schema2"
+	"github.com/distribution/distribution/v3/reference"
+	"github.com/ghodss/yaml"
+	godigest "github.com/opencontainers/go-digest"
+
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+// SignatureVerificationMode defines what happens to images that do not carry a trusted signature.
+type SignatureVerificationMode string
+
+const (
+	// SignatureVerificationAudit records the outcome of the verification on the imported images, but
+	// imports images without trusted signatures.
+	SignatureVerificationAudit SignatureVerificationMode = "Audit"
+	// SignatureVerificationEnforce refuses to import images without a trusted signature.
+	SignatureVerificationEnforce SignatureVerificationMode = "Enforce"
+)
+
+const (
+	// cosignSignatureAnnotation holds the base64 encoded signature of a cosign signature layer.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// maxSignaturePayloadSize is the size above which signature payloads are not fetched.
+	maxSignaturePayloadSize = 64 * 1024
+)
+
+// SignaturePolicy selects the images whose sigstore signatures are verified during imports.
+type SignaturePolicy struct {
+	// Rules are matched in order, the first rule matching an image applies to it. Images not matched
+	// by any rule are imported without verification.
+	Rules []SignaturePolicyRule `json:"rules"`
+}
+
+// SignaturePolicyRule configures the verification of signatures of images from a registry or repository.
+type SignaturePolicyRule struct {
+	// Registry is a registry host optionally followed by a repository path, e.g. quay.io/openshift.
+	// It matches images in the repository and the repositories below it, images from the Docker Hub
+	// use the docker.io host. An empty value matches all images.
+	Registry string `json:"registry,omitempty"`
+	// Namespaces limits the rule to imports into image streams in these namespaces. An empty list
+	// matches all namespaces. Images are shared by all namespaces, so the signatures verified by a
+	// rule limited to some namespaces are only enforced and never recorded on the images.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Mode is either Audit or Enforce.
+	Mode SignatureVerificationMode `json:"mode"`
+	// PublicKeys are the paths of PEM encoded public keys trusted to sign the images.
+	PublicKeys []string `json:"publicKeys"`
+
+	keys []signatureKey
+	// keySetID identifies the keys of the rule in the names of the signatures it verifies, so that
+	// rules trusting different keys do not overwrite the verdicts of each other on an image.
+	keySetID string
+}
+
+type signatureKey struct {
+	name string
+	key  crypto.PublicKey
+}
+
+// LoadSignaturePolicy reads a signature policy from a YAML or JSON file and loads the public keys
+// it refers to.
+func LoadSignaturePolicy(path string) (*SignaturePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &SignaturePolicy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("unable to parse signature policy %s: %v", path, err)
+	}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		switch rule.Mode {
+		case SignatureVerificationAudit, SignatureVerificationEnforce:
+		default:
+			return nil, fmt.Errorf("rule %d of signature policy %s: mode must be %s or %s", i, path, SignatureVerificationAudit, SignatureVerificationEnforce)
+		}
+		if len(rule.PublicKeys) == 0 {
+			return nil, fmt.Errorf("rule %d of signature policy %s: at least one public key is required", i, path)
+		}
+		for _, keyPath := range rule.PublicKeys {
+			key, err := loadPublicKey(keyPath)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d of signature policy %s: %v", i, path, err)
+			}
+			rule.keys = append(rule.keys, signatureKey{name: filepath.Base(keyPath), key: key})
+		}
+		if rule.keySetID, err = keySetID(rule.keys); err != nil {
+			return nil, fmt.Errorf("rule %d of signature policy %s: %v", i, path, err)
+		}
+	}
+	return policy, nil
+}
+
+// keySetID returns a short identifier of a set of keys, independent of their order.
+func keySetID(keys []signatureKey) (string, error) {
+	var ders []string
+	for _, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(key.key)
+		if err != nil {
+			return "", err
+		}
+		ders = append(ders, string(der))
+	}
+	sort.Strings(ders)
+	hash := sha256.New()
+	for _, der := range ders {
+		hash.Write([]byte(der))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded public key", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key %s: %v", path, err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("public key %s has unsupported type %T", path, key)
+	}
+}
+
+// ruleFor returns the rule that applies to imports of ref into namespace, or nil if the signatures
+// of the image are not verified.
+func (p *SignaturePolicy) ruleFor(namespace string, ref reference.Named) *SignaturePolicyRule {
+	if p == nil {
+		return nil
+	}
+	name := ref.Name()
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if len(rule.Namespaces) > 0 && !containsString(rule.Namespaces, namespace) {
+			continue
+		}
+		registry := strings.TrimSuffix(rule.Registry, "/")
+		if len(registry) == 0 || name == registry || strings.HasPrefix(name, registry+"/") {
+			return rule
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// cosignSignature is a signature stored by cosign as a layer of the signature manifest of an image.
+type cosignSignature struct {
+	// digest is the digest of the signed payload.
+	digest    godigest.Digest
+	payload   []byte
+	signature []byte
+}
+
+// simpleSigningPayload is the part of a cosign simple signing payload needed to verify it.
+type simpleSigningPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// signatureTag returns the tag under which cosign stores the signatures of the manifest dgst.
+func signatureTag(dgst godigest.Digest) string {
+	return referrersTag(dgst) + ".sig"
+}
+
+// getSignatures returns the cosign signatures of the manifest dgst in the repository of ref. A missing
+// signature manifest means the image is not signed.
+func (imp *ImageStreamImporter) getSignatures(ctx context.Context, ref reference.Named, dgst godigest.Digest, insecure bool) ([]cosignSignature, error) {
+	tagRef, err := reference.WithTag(reference.TrimNamed(ref), signatureTag(dgst))
+	if err != nil {
+		return nil, err
+	}
+	manifest, _, bs, err := imp.getManifest(ctx, tagRef, insecure)
+	if err != nil {
+		if kapierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var layers []distribution.Descriptor
+	switch t := manifest.(type) {
+	case *ocischema.DeserializedManifest:
+		layers = t.Layers
+	case *schema2.DeserializedManifest:
+		layers = t.Layers
+	default:
+		return nil, fmt.Errorf("unsupported signature manifest type %T", manifest)
+	}
+
+	var signatures []cosignSignature
+	for _, layer := range layers {
+		value, ok := layer.Annotations[cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		if layer.Size > maxSignaturePayloadSize {
+			klog.V(4).Infof("ignoring signature %s of %s@%s: payload of %d bytes is too large", layer.Digest, ref.Name(), dgst, layer.Size)
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			klog.V(4).Infof("ignoring signature %s of %s@%s: %v", layer.Digest, ref.Name(), dgst, err)
+			continue
+		}
+		payload, err := bs.Get(ctx, layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch signature payload %s: %v", layer.Digest, err)
+		}
+		if layer.Digest.Validate() != nil || layer.Digest.Algorithm().FromBytes(payload) != layer.Digest {
+			return nil, fmt.Errorf("content integrity error: the signature payload does not match the digest %s", layer.Digest)
+		}
+		signatures = append(signatures, cosignSignature{
+			digest:    layer.Digest,
+			payload:   payload,
+			signature: signature,
+		})
+	}
+	return signatures, nil
+}
+
+// verifySignature checks signature of payload with key.
+func verifySignature(key crypto.PublicKey, payload, signature []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		hash := sha256.Sum256(payload)
+		return ecdsa.VerifyASN1(k, hash[:], signature)
+	case *rsa.PublicKey:
+		hash := sha256.Sum256(payload)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, signature)
+	default:
+		return false
+	}
+}
+
+// verify verifies the cosign signature of the image imageName against the keys of the rule and returns
+// it as an ImageSignature of the image. The signed claims are only recorded for trusted signatures.
+func (r *SignaturePolicyRule) verify(imageName string, sig cosignSignature, now metav1.Time) (signature imageapi.ImageSignature) {
+	name := fmt.Sprintf("%s@cosign-%s", imageName, sig.digest.Encoded())
+	if len(r.keySetID) > 0 {
+		name = fmt.Sprintf("%s-%s", name, r.keySetID)
+	}
+	signature = imageapi.ImageSignature{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Type:    imageapi.ImageSignatureTypeCosignV1,
+		Content: sig.payload,
+	}
+	trusted := imageapi.SignatureCondition{
+		Type:               imageapi.SignatureTrusted,
+		Status:             kapi.ConditionFalse,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             "UntrustedKey",
+		Message:            "the signature was not made by any of the trusted keys",
+	}
+	forImage := imageapi.SignatureCondition{
+		Type:               imageapi.SignatureForImage,
+		Status:             kapi.ConditionFalse,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             "UntrustedKey",
+		Message:            "the payload of an untrusted signature is not evaluated",
+	}
+	defer func() {
+		signature.Conditions = []imageapi.SignatureCondition{trusted, forImage}
+	}()
+
+	var key *signatureKey
+	for i := range r.keys {
+		if verifySignature(r.keys[i].key, sig.payload, sig.signature) {
+			key = &r.keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return signature
+	}
+	trusted.Status, trusted.Reason, trusted.Message = kapi.ConditionTrue, "", ""
+
+	payload := simpleSigningPayload{}
+	if err := json.Unmarshal(sig.payload, &payload); err != nil {
+		forImage.Reason, forImage.Message = "InvalidPayload", fmt.Sprintf("unable to parse the signed payload: %v", err)
+		return signature
+	}
+	if payload.Critical.Image.DockerManifestDigest != imageName {
+		forImage.Reason = "DigestMismatch"
+		forImage.Message = fmt.Sprintf("the signature is for the image %s", payload.Critical.Image.DockerManifestDigest)
+		return signature
+	}
+	forImage.Status, forImage.Reason, forImage.Message = kapi.ConditionTrue, "", ""
+
+	signature.ImageIdentity = payload.Critical.Identity.DockerReference
+	for name, value := range payload.Optional {
+		if s, ok := value.(string); ok {
+			if signature.SignedClaims == nil {
+				signature.SignedClaims = map[string]string{}
+			}
+			signature.SignedClaims[name] = s
+		}
+	}
+	signature.IssuedBy = &imageapi.SignatureIssuer{
+		SignatureGenericEntity: imageapi.SignatureGenericEntity{CommonName: key.name},
+	}
+	return signature
+}
+
+// isTrustedSignature returns true if signature was verified by a trusted key and is for its image.
+func isTrustedSignature(signature *imageapi.ImageSignature) bool {
+	trusted, forImage := false, false
+	for _, condition := range signature.Conditions {
+		switch condition.Type {
+		case imageapi.SignatureTrusted:
+			trusted = condition.Status == kapi.ConditionTrue
+		case imageapi.SignatureForImage:
+			forImage = condition.Status == kapi.ConditionTrue
+		}
+	}
+	return trusted && forImage
+}
+
+// setImageSignature adds signature to the image, replacing a signature of the same name.
+func setImageSignature(image *imageapi.Image, signature imageapi.ImageSignature) {
+	for i := range image.Signatures {
+		if image.Signatures[i].Name == signature.Name {
+			image.Signatures[i] = signature
+			return
+		}
+	}
+	image.Signatures = append(image.Signatures, signature)
+}
+
+// verifySignatures verifies the cosign signatures of image imported from ref into namespace according to
+// the signature policy and, if the rule applies to all namespaces, records them as signatures of the
+// image. An error is returned if the policy enforces signatures and the image has no trusted signature.
+func (imp *ImageStreamImporter) verifySignatures(ctx context.Context, namespace string, ref reference.Named, image *imageapi.Image, insecure bool) error {
+	rule := imp.signaturePolicy.ruleFor(namespace, ref)
+	if rule == nil {
+		return nil
+	}
+	enforce := rule.Mode == SignatureVerificationEnforce
+
+	signatures, err := imp.getSignatures(ctx, ref, godigest.Digest(image.Name), insecure)
+	if err != nil {
+		signatureVerifications.WithLabelValues(string(rule.Mode), "error").Inc()
+		if enforce {
+			return kapierrors.NewForbidden(imageapi.Resource("images"), image.Name, fmt.Errorf("unable to verify the signatures of %s: %v", ref.Name(), err))
+		}
+		klog.V(4).Infof("unable to verify the signatures of %s@%s: %v", ref.Name(), image.Name, err)
+		return nil
+	}
+
+	now := metav1.Now()
+	trusted := false
+	for _, sig := range signatures {
+		signature := rule.verify(image.Name, sig, now)
+		trusted = trusted || isTrustedSignature(&signature)
+		// the image is shared by all namespaces, only the verdicts of rules of all namespaces are recorded
+		if len(rule.Namespaces) == 0 {
+			setImageSignature(image, signature)
+		}
+	}
+
+	result := "untrusted"
+	switch {
+	case trusted:
+		result = "trusted"
+	case len(signatures) == 0:
+		result = "unsigned"
+	}
+	signatureVerifications.WithLabelValues(string(rule.Mode), result).Inc()
+
+	if !trusted {
+		if enforce {
+			return kapierrors.NewForbidden(imageapi.Resource("images"), image.Name, fmt.Errorf("the image %s@%s has no signature from a trusted key", ref.Name(), image.Name))
+		}
+		klog.V(4).Infof("the image %s@%s has no signature from a trusted key", ref.Name(), image.Name)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package importer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/reference"
+	godigest "github.com/opencontainers/go-digest"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+	"github.com/openshift/openshift-apiserver/pkg/image/apis/image/validation"
+)
+
+func writePublicKey(t *testing.T, dir, name string, key *ecdsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign stores a cosign signature of the manifest dgst made with key in repo.
+func sign(t *testing.T, repo *artifactRepository, key *ecdsa.PrivateKey, dgst godigest.Digest) {
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"docker.io/library/test"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":{"creator":"test"}}`, dgst))
+	hash := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadDigest := godigest.FromBytes(payload)
+	repo.blobs.blobs[payloadDigest] = payload
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config": map[string]interface{}{
+			"mediaType": "application/vnd.oci.image.config.v1+json",
+			"size":      2,
+			"digest":    "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+		},
+		"layers": []map[string]interface{}{
+			{
+				"mediaType": "application/vnd.dev.cosign.simplesigning.v1+json",
+				"size":      len(payload),
+				"digest":    payloadDigest,
+				"annotations": map[string]string{
+					cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature),
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.add(t, signatureTag(dgst), string(manifest))
+}
+
+func TestImportVerifySignatures(t *testing.T) {
+	trustedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrustedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	keyPath := writePublicKey(t, dir, "trusted.pub", trustedKey)
+	policyPath := filepath.Join(dir, "policy.yaml")
+	policy := fmt.Sprintf(`rules:
+- registry: docker.io/library/test
+  namespaces: [audit]
+  mode: Audit
+  publicKeys: [%[1]s]
+- registry: docker.io/library
+  namespaces: [enforce]
+  mode: Enforce
+  publicKeys: [%[1]s]
+- registry: docker.io/library/test
+  mode: Audit
+  publicKeys: [%[1]s]
+`, keyPath)
+	if err := os.WriteFile(policyPath, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	signaturePolicy, err := LoadSignaturePolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		from      string
+		key       *ecdsa.PrivateKey
+
+		expectForbidden bool
+		expectTrusted   *bool
+	}{
+		{
+			name:      "no matching rule",
+			namespace: "other",
+			from:      "other:1.0.0",
+			key:       untrustedKey,
+		},
+		{
+			name:          "audit trusted",
+			namespace:     "other",
+			key:           trustedKey,
+			expectTrusted: &[]bool{true}[0],
+		},
+		{
+			name:          "audit untrusted",
+			namespace:     "other",
+			key:           untrustedKey,
+			expectTrusted: &[]bool{false}[0],
+		},
+		{
+			name:      "audit unsigned",
+			namespace: "other",
+		},
+		{
+			// the verdict of a rule of some namespaces is not recorded on the shared image
+			name:      "namespace audit trusted",
+			namespace: "audit",
+			key:       trustedKey,
+		},
+		{
+			name:      "namespace audit untrusted",
+			namespace: "audit",
+			key:       untrustedKey,
+		},
+		{
+			name:      "enforce trusted",
+			namespace: "enforce",
+			key:       trustedKey,
+		},
+		{
+			name:            "enforce untrusted",
+			namespace:       "enforce",
+			key:             untrustedKey,
+			expectForbidden: true,
+		},
+		{
+			name:            "enforce unsigned",
+			namespace:       "enforce",
+			expectForbidden: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := newArtifactRepository()
+			dgst := repo.add(t, "1.0.0", helmChartManifest)
+			if test.key != nil {
+				sign(t, repo, test.key, dgst)
+			}
+
+			from := test.from
+			if len(from) == 0 {
+				from = "test:1.0.0"
+			}
+			isi := &imageapi.ImageStreamImport{
+				ObjectMeta: metav1.ObjectMeta{Namespace: test.namespace},
+				Spec: imageapi.ImageStreamImportSpec{
+					Images: []imageapi.ImageImportSpec{
+						{From: kapi.ObjectReference{Kind: "DockerImage", Name: from}},
+					},
+				},
+			}
+			im := NewImageStreamImporter(&mockRetriever{repo: repo}, nil, 5, nil, nil).WithSignaturePolicy(signaturePolicy)
+			if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+				t.Fatal(err)
+			}
+
+			status := isi.Status.Images[0]
+			if test.expectForbidden {
+				if status.Status.Reason != metav1.StatusReasonForbidden || status.Image != nil {
+					t.Fatalf("expected the import to be forbidden, got %#v", status)
+				}
+				return
+			}
+			if status.Status.Status != metav1.StatusSuccess {
+				t.Fatalf("unexpected status: %#v", status.Status)
+			}
+			signatures := status.Image.Signatures
+			if test.expectTrusted == nil {
+				if len(signatures) != 0 {
+					t.Errorf("expected no signatures, got %#v", signatures)
+				}
+				return
+			}
+			if len(signatures) != 1 {
+				t.Fatalf("expected one signature, got %#v", signatures)
+			}
+			signature := signatures[0]
+			if !strings.HasSuffix(signature.Name, "-"+signaturePolicy.Rules[2].keySetID) {
+				t.Errorf("expected the name of the signature to identify the keys of the rule, got %s", signature.Name)
+			}
+			if signature.Type != imageapi.ImageSignatureTypeCosignV1 {
+				t.Errorf("unexpected signature type %q", signature.Type)
+			}
+			if errs := validation.ValidateImageSignature(&signature); len(errs) > 0 {
+				t.Errorf("unexpected validation errors: %v", errs)
+			}
+			if trusted := isTrustedSignature(&signature); trusted != *test.expectTrusted {
+				t.Errorf("expected trusted to be %t, got conditions %#v", *test.expectTrusted, signature.Conditions)
+			}
+			if *test.expectTrusted {
+				if signature.IssuedBy == nil || signature.IssuedBy.CommonName != "trusted.pub" {
+					t.Errorf("unexpected issuer %#v", signature.IssuedBy)
+				}
+				if signature.SignedClaims["creator"] != "test" {
+					t.Errorf("unexpected signed claims %#v", signature.SignedClaims)
+				}
+			} else if len(signature.SignedClaims) != 0 || signature.IssuedBy != nil {
+				t.Errorf("expected no claims to be recorded for untrusted signature, got %#v", signature)
+			}
+		})
+	}
+}
+
+func TestSignaturePolicyRuleVerifyDigestMismatch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newArtifactRepository()
+	sign(t, repo, key, busyboxDigest)
+	rule := &SignaturePolicyRule{keys: []signatureKey{{name: "key", key: &key.PublicKey}}}
+
+	im := NewImageStreamImporter(&mockRetriever{repo: repo}, nil, 5, nil, nil)
+	ref, err := reference.ParseNormalizedNamed("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatures, err := im.getSignatures(context.Background(), ref, busyboxDigest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 1 {
+		t.Fatalf("expected one signature, got %d", len(signatures))
+	}
+
+	signature := rule.verify("sha256:0000000000000000000000000000000000000000000000000000000000000000", signatures[0], metav1.Now())
+	if isTrustedSignature(&signature) {
+		t.Errorf("expected signature for another image not to be trusted")
+	}
+	for _, condition := range signature.Conditions {
+		if condition.Type == imageapi.SignatureForImage && condition.Reason != "DigestMismatch" {
+			t.Errorf("unexpected condition %#v", condition)
+		}
+	}
+}
@@ -30,8 +30,8 @@ func NewStaticCredentialsContext(
 	secrets []corev1.Secret,
 ) *StaticCredentialsContext {
 	return &StaticCredentialsContext{
-		transport:         transport,
-		insecureTransport: insecureTransport,
+		transport:         newRetryAfterRoundTripper(transport),
+		insecureTransport: newRetryAfterRoundTripper(insecureTransport),
 		secrets:           secrets,
 	}
 }
@@ -87,9 +87,18 @@ func (s *StaticCredentialsContext) Repository(
 	importCtx := registryclient.NewContext(
 		s.transport, s.insecureTransport,
 	).WithCredentials(cred)
-	s.contexts.Store(repo, importCtx)
+	// requests are retried by the importer, which knows which errors are
+	// worth retrying and honors the Retry-After of registries.
+	importCtx.Retries = 0
 
-	return importCtx.Repository(
+	repository, err := importCtx.Repository(
 		ctx, defRef.RegistryURL(), defRef.RepositoryName(), insecure,
 	)
+	if err != nil {
+		// the context remembers failed pings, it is not kept so that the
+		// importer can retry transient failures.
+		return nil, err
+	}
+	s.contexts.Store(repo, importCtx)
+	return repository, nil
 }
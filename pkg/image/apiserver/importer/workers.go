@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// DefaultImportWorkers is the default number of manifests the importers
+	// sharing ImportWorkers fetch concurrently.
+	DefaultImportWorkers = 8
+	// DefaultImportWorkersPerRegistry is the default number of manifests the
+	// importers sharing ImportWorkers fetch concurrently from a single
+	// registry.
+	DefaultImportWorkersPerRegistry = 4
+)
+
+// ImportWorkers bounds the number of concurrent fetches issued by the
+// importers sharing it, both in total and per registry host, so that
+// concurrent imports do not multiply the load on a registry. It is safe for
+// concurrent use.
+type ImportWorkers struct {
+	total       chan struct{}
+	perRegistry int
+
+	lock       sync.Mutex
+	registries map[string]*registrySlots
+}
+
+// registrySlots are the slots of a registry host, with the number of fetches
+// holding or waiting for one. They are dropped when unused.
+type registrySlots struct {
+	slots chan struct{}
+	users int
+}
+
+// NewImportWorkers returns workers fetching at most total manifests
+// concurrently, and at most perRegistry from a single registry host.
+// Non-positive values select the defaults.
+func NewImportWorkers(total, perRegistry int) *ImportWorkers {
+	if total <= 0 {
+		total = DefaultImportWorkers
+	}
+	if perRegistry <= 0 {
+		perRegistry = DefaultImportWorkersPerRegistry
+	}
+	if perRegistry > total {
+		perRegistry = total
+	}
+	return &ImportWorkers{
+		total:       make(chan struct{}, total),
+		perRegistry: perRegistry,
+		registries:  make(map[string]*registrySlots),
+	}
+}
+
+// registrySlots returns the slots of registry, which must be released with
+// releaseRegistrySlots.
+func (w *ImportWorkers) registrySlots(registry string) chan struct{} {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	slots, ok := w.registries[registry]
+	if !ok {
+		slots = &registrySlots{slots: make(chan struct{}, w.perRegistry)}
+		w.registries[registry] = slots
+	}
+	slots.users++
+	return slots.slots
+}
+
+func (w *ImportWorkers) releaseRegistrySlots(registry string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	slots := w.registries[registry]
+	slots.users--
+	if slots.users == 0 {
+		delete(w.registries, registry)
+	}
+}
+
+// acquire blocks until a slot for registry is available. The registry slot
+// is always taken before the global one so that workers waiting on a busy
+// registry do not starve fetches from other registries. The returned
+// function must be called to release the slots.
+func (w *ImportWorkers) acquire(ctx context.Context, registry string) (func(), error) {
+	var done <-chan struct{}
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		done = ctx.Done()
+	}
+
+	registrySlots := w.registrySlots(registry)
+	select {
+	case registrySlots <- struct{}{}:
+	case <-done:
+		w.releaseRegistrySlots(registry)
+		return nil, ctx.Err()
+	}
+	select {
+	case w.total <- struct{}{}:
+	case <-done:
+		<-registrySlots
+		w.releaseRegistrySlots(registry)
+		return nil, ctx.Err()
+	}
+	return func() {
+		<-w.total
+		<-registrySlots
+		w.releaseRegistrySlots(registry)
+	}, nil
+}
+
+// run calls fn for every index in [0, n) using the slots for registry and
+// waits until all calls return. The calls are started in the order of their
+// indexes. fn must only write to the state identified by its index so that
+// callers keep a deterministic ordering of results. If a slot cannot be
+// acquired, onErr is called for the index instead of fn.
+func (w *ImportWorkers) run(ctx context.Context, registry string, n int, fn func(i int), onErr func(i int, err error)) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		release, err := w.acquire(ctx, registry)
+		if err != nil {
+			onErr(i, err)
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer release()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	godigest "github.com/opencontainers/go-digest"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+// concurrencyTracker records the highest number of concurrent callers.
+type concurrencyTracker struct {
+	lock    sync.Mutex
+	current int
+	max     int
+}
+
+func (c *concurrencyTracker) enter() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.current++
+	if c.current > c.max {
+		c.max = c.current
+	}
+}
+
+func (c *concurrencyTracker) leave() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.current--
+}
+
+func TestImportWorkersLimits(t *testing.T) {
+	workers := NewImportWorkers(3, 2)
+
+	trackers := map[string]*concurrencyTracker{
+		"a.example.com": {},
+		"b.example.com": {},
+	}
+	total := &concurrencyTracker{}
+
+	var wg sync.WaitGroup
+	for registry, tracker := range trackers {
+		wg.Add(1)
+		go func(registry string, tracker *concurrencyTracker) {
+			defer wg.Done()
+			workers.run(context.Background(), registry, 10, func(i int) {
+				tracker.enter()
+				total.enter()
+				time.Sleep(5 * time.Millisecond)
+				total.leave()
+				tracker.leave()
+			}, func(i int, err error) {
+				t.Errorf("unexpected error for %s/%d: %v", registry, i, err)
+			})
+		}(registry, tracker)
+	}
+	wg.Wait()
+
+	for registry, tracker := range trackers {
+		if tracker.max > 2 {
+			t.Errorf("registry %s: expected at most 2 concurrent fetches, got %d", registry, tracker.max)
+		}
+	}
+	if total.max > 3 {
+		t.Errorf("expected at most 3 concurrent fetches, got %d", total.max)
+	}
+}
+
+func TestImportWorkersCancelled(t *testing.T) {
+	workers := NewImportWorkers(1, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errs := make([]error, 3)
+	workers.run(ctx, "example.com", len(errs), func(i int) {
+		errs[i] = fmt.Errorf("unexpected call")
+	}, func(i int, err error) {
+		errs[i] = err
+	})
+	for i, err := range errs {
+		if err != context.Canceled {
+			t.Errorf("%d: expected %v, got %v", i, context.Canceled, err)
+		}
+	}
+}
+
+// slowRepository delays every manifest request so that concurrent requests
+// overlap.
+type slowRepository struct {
+	*mockRepository
+	tracker *concurrencyTracker
+}
+
+func (r *slowRepository) Manifests(ctx context.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
+	return r, r.repoErr
+}
+
+func (r *slowRepository) Get(ctx context.Context, dgst godigest.Digest, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	r.tracker.enter()
+	defer r.tracker.leave()
+	time.Sleep(5 * time.Millisecond)
+	return r.mockRepository.Get(ctx, dgst, options...)
+}
+
+func TestImportRepositoryConcurrently(t *testing.T) {
+	tags := map[string]string{}
+	for i := 0; i < 20; i++ {
+		tags[fmt.Sprintf("v%02d", i)] = "sha256:958608f8ecc1dc62c93b6c610f3a834dae4220c9642e6e8b4e0f2b3ad7cbd238"
+	}
+	tracker := &concurrencyTracker{}
+	retriever := &mockRetriever{
+		repo: &slowRepository{
+			mockRepository: &mockRepository{
+				tags:        tags,
+				getByTagErr: fmt.Errorf("no such manifest tag"),
+			},
+			tracker: tracker,
+		},
+	}
+
+	isi := &imageapi.ImageStreamImport{
+		Spec: imageapi.ImageStreamImportSpec{
+			Repository: &imageapi.RepositoryImportSpec{
+				From: kapi.ObjectReference{Kind: "DockerImage", Name: "test"},
+			},
+		},
+	}
+
+	im := NewImageStreamImporter(retriever, nil, -1, nil, nil).WithImportWorkers(NewImportWorkers(4, 4))
+	if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tracker.max > 4 {
+		t.Errorf("expected at most 4 concurrent fetches, got %d", tracker.max)
+	}
+	if len(isi.Status.Repository.Images) != len(tags) {
+		t.Fatalf("unexpected number of images: %#v", isi.Status.Repository.Images)
+	}
+	for i, image := range isi.Status.Repository.Images {
+		expected := fmt.Sprintf("v%02d", i)
+		if image.Tag != expected {
+			t.Errorf("%d: expected tag %s, got %s", i, expected, image.Tag)
+		}
+		if image.Status.Status != metav1.StatusFailure || image.Status.Message != "Internal error occurred: docker.io/library/test:"+expected+": no such manifest tag" {
+			t.Errorf("%d: unexpected status: %#v", i, image.Status)
+		}
+	}
+}
+
+func TestImportWorkersShared(t *testing.T) {
+	tags := map[string]string{}
+	for i := 0; i < 10; i++ {
+		tags[fmt.Sprintf("v%02d", i)] = "sha256:958608f8ecc1dc62c93b6c610f3a834dae4220c9642e6e8b4e0f2b3ad7cbd238"
+	}
+	tracker := &concurrencyTracker{}
+	workers := NewImportWorkers(8, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retriever := &mockRetriever{
+				repo: &slowRepository{
+					mockRepository: &mockRepository{tags: tags, getByTagErr: fmt.Errorf("no such manifest tag")},
+					tracker:        tracker,
+				},
+			}
+			isi := &imageapi.ImageStreamImport{
+				Spec: imageapi.ImageStreamImportSpec{
+					Repository: &imageapi.RepositoryImportSpec{From: kapi.ObjectReference{Kind: "DockerImage", Name: "test"}},
+				},
+			}
+			im := NewImageStreamImporter(retriever, nil, -1, nil, nil).WithImportWorkers(workers)
+			if err := im.Import(context.Background(), isi, &imageapi.ImageStream{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if tracker.max > 2 {
+		t.Errorf("expected at most 2 concurrent fetches from the registry across imports, got %d", tracker.max)
+	}
+	if len(workers.registries) != 0 {
+		t.Errorf("expected the slots of the registries to be released, got %v", workers.registries)
+	}
+}
@@ -81,6 +81,17 @@ func InternalImageWithMetadata(image *imageapi.Image) error {
 			image.DockerImageManifestMediaType = schema2.MediaTypeManifest
 		}
 
+		if artifactType := ArtifactType(manifest); len(artifactType) > 0 {
+			// the config of an artifact is not an image configuration, the
+			// metadata of the image is limited to what the manifest holds.
+			if image.Annotations == nil {
+				image.Annotations = map[string]string{}
+			}
+			image.Annotations[imageapi.ImageArtifactTypeAnnotation] = artifactType
+			image.DockerImageMetadata.ID = manifest.Config.Digest
+			break
+		}
+
 		if len(image.DockerImageConfig) == 0 {
 			return fmt.Errorf(
 				"dockerImageConfig must not be empty for manifest type %q",
@@ -106,6 +117,9 @@ func InternalImageWithMetadata(image *imageapi.Image) error {
 	if manifest.SchemaVersion == 2 {
 		layerSet.Insert(manifest.Config.Digest)
 		image.DockerImageMetadata.Size = int64(len(image.DockerImageConfig))
+		if len(image.DockerImageConfig) == 0 {
+			image.DockerImageMetadata.Size = manifest.Config.Size
+		}
 	} else {
 		image.DockerImageMetadata.Size = 0
 	}
@@ -120,6 +134,23 @@ func InternalImageWithMetadata(image *imageapi.Image) error {
 	return nil
 }
 
+// ArtifactType returns the artifact type of an OCI manifest that describes an artifact rather than
+// a container image. If the manifest does not specify an artifact type, the media type of its config
+// is used instead. An empty string is returned for container images.
+func ArtifactType(manifest dockerapi10.DockerImageManifest) string {
+	if len(manifest.ArtifactType) > 0 {
+		return manifest.ArtifactType
+	}
+	if manifest.SchemaVersion != 2 || manifest.MediaType == schema2.MediaTypeManifest {
+		return ""
+	}
+	switch manifest.Config.MediaType {
+	case "", imgspecv1.MediaTypeImageConfig, schema2.MediaTypeImageConfig:
+		return ""
+	}
+	return manifest.Config.MediaType
+}
+
 func fillImageLayers(image *imageapi.Image, manifest dockerapi10.DockerImageManifest) error {
 	if len(image.DockerImageLayers) != 0 {
 		// DockerImageLayers is already filled by the registry.
@@ -1,6 +1,7 @@
 package imageutil
 
 import (
+	"encoding/json"
 	"reflect"
 	"strings"
 	"testing"
@@ -13,6 +14,7 @@ import (
 
 	imagev1 "github.com/openshift/api/image/v1"
 	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+	dockerapi10 "github.com/openshift/openshift-apiserver/pkg/image/apis/image/docker10"
 )
 
 func TestImageWithMetadata(t *testing.T) {
@@ -248,6 +250,82 @@ func TestImageWithMetadataWithManifestList(t *testing.T) {
 	}
 }
 
+func TestImageWithMetadataWithArtifact(t *testing.T) {
+	image := imageapi.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sha256:2b7b6d2d3a7b8b0e7bcb3bfc43a7c1d5f6b1de5e6c43d1c5c3ab8a4cc3a3e8b1",
+		},
+		DockerImageManifest: `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+   "config": {
+      "mediaType": "application/vnd.cncf.helm.config.v1+json",
+      "size": 117,
+      "digest": "sha256:8ec7c0f2f6860037c19b54c3cfbab48d9b4b21b485a93d87b64690fdb68c2111"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+         "size": 2487,
+         "digest": "sha256:1b251d38cfe948dfc0a5745b7af5ca574ecb61e52aed10b19039db39af6e1617"
+      }
+   ]
+}`,
+	}
+	if err := InternalImageWithMetadata(&image); err != nil {
+		t.Fatalf("error getting metadata for artifact: %v", err)
+	}
+
+	if e, a := "application/vnd.cncf.helm.config.v1+json", image.Annotations[imageapi.ImageArtifactTypeAnnotation]; e != a {
+		t.Errorf("expected artifact type %q, got %q", e, a)
+	}
+	if e, a := "sha256:8ec7c0f2f6860037c19b54c3cfbab48d9b4b21b485a93d87b64690fdb68c2111", image.DockerImageMetadata.ID; e != a {
+		t.Errorf("expected metadata ID %q, got %q", e, a)
+	}
+	if e, a := int64(117+2487), image.DockerImageMetadata.Size; e != a {
+		t.Errorf("expected size %d, got %d", e, a)
+	}
+	if len(image.DockerImageLayers) != 1 || image.DockerImageLayers[0].MediaType != "application/vnd.cncf.helm.chart.content.v1.tar+gzip" {
+		t.Errorf("unexpected layers: %#v", image.DockerImageLayers)
+	}
+}
+
+func TestArtifactType(t *testing.T) {
+	tests := map[string]struct {
+		manifest string
+		expected string
+	}{
+		"docker schema 2 image": {
+			manifest: `{"schemaVersion": 2, "mediaType": "application/vnd.docker.distribution.manifest.v2+json", "config": {"mediaType": "application/vnd.docker.container.image.v1+json"}}`,
+		},
+		"oci image": {
+			manifest: `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"mediaType": "application/vnd.oci.image.config.v1+json"}}`,
+		},
+		"oci image without media type": {
+			manifest: `{"schemaVersion": 2, "config": {"mediaType": "application/vnd.oci.image.config.v1+json"}}`,
+		},
+		"artifact with artifact type": {
+			manifest: `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json", "artifactType": "application/spdx+json", "config": {"mediaType": "application/vnd.oci.empty.v1+json"}}`,
+			expected: "application/spdx+json",
+		},
+		"artifact with custom config": {
+			manifest: `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"mediaType": "application/vnd.cncf.helm.config.v1+json"}}`,
+			expected: "application/vnd.cncf.helm.config.v1+json",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			manifest := dockerapi10.DockerImageManifest{}
+			if err := json.Unmarshal([]byte(test.manifest), &manifest); err != nil {
+				t.Fatal(err)
+			}
+			if artifactType := ArtifactType(manifest); artifactType != test.expected {
+				t.Errorf("expected artifact type %q, got %q", test.expected, artifactType)
+			}
+		})
+	}
+}
+
 func validImageWithManifestData() imageapi.Image {
 	return imageapi.Image{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,202 @@
+package imagestreamimport
+
+import (
+	"context"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	kapihelper "k8s.io/kubernetes/pkg/apis/core/helper"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+	"github.com/openshift/openshift-apiserver/pkg/image/apis/image/validation"
+)
+
+// SpecTagChange describes how an import changes a spec tag.
+type SpecTagChange string
+
+const (
+	// SpecTagAdded means the import adds the spec tag to the image stream.
+	SpecTagAdded SpecTagChange = "Added"
+	// SpecTagMoved means the import points an existing spec tag to another image.
+	SpecTagMoved SpecTagChange = "Moved"
+)
+
+// Reasons for a tag to fail a dry run import.
+const (
+	TagFailureImportFailed       = "ImportFailed"
+	TagFailureRegistryNotAllowed = "RegistryNotAllowed"
+	TagFailureLimitExceeded      = "LimitExceeded"
+)
+
+// ImportDiff describes the changes an import would make to an image stream. It is returned in the
+// ImageStreamImportDiffAnnotation of dry run imports.
+type ImportDiff struct {
+	// SpecTags are the spec tags that would be added or moved.
+	SpecTags []SpecTagDiff `json:"specTags,omitempty"`
+	// TagEvents are the events that would be appended to the status tags.
+	TagEvents []TagEventDiff `json:"tagEvents,omitempty"`
+	// NewImages are the names of the images that would be created.
+	NewImages []string `json:"newImages,omitempty"`
+	// Failures are the tags that would not be updated.
+	Failures []TagFailure `json:"failures,omitempty"`
+}
+
+// SpecTagDiff describes a change of a spec tag.
+type SpecTagDiff struct {
+	Tag    string        `json:"tag"`
+	Change SpecTagChange `json:"change"`
+	// From is the pull spec the tag pointed to before the import, if any.
+	From string `json:"from,omitempty"`
+	// To is the pull spec the tag would point to.
+	To string `json:"to"`
+}
+
+// TagEventDiff describes a tag event appended to a status tag.
+type TagEventDiff struct {
+	Tag                  string `json:"tag"`
+	Image                string `json:"image"`
+	DockerImageReference string `json:"dockerImageReference"`
+}
+
+// TagFailure describes why a tag would not be updated.
+type TagFailure struct {
+	Tag     string `json:"tag"`
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+}
+
+// diffImageStream computes the changes the import isi makes to the image stream original, resulting in
+// the image stream updated. The changes of every tag are checked against the registry whitelist and
+// the limit ranges of namespace one tag after another, tags that fail the checks are reported as
+// failures instead of changes.
+func (r *REST) diffImageStream(
+	ctx context.Context,
+	namespace string,
+	isi *imageapi.ImageStreamImport,
+	original, updated *imageapi.ImageStream,
+	newImages []string,
+) *ImportDiff {
+	diff := &ImportDiff{NewImages: newImages}
+
+	for i, status := range isi.Status.Images {
+		if status.Status.Status == metav1.StatusSuccess || isi.Spec.Images[i].To == nil {
+			continue
+		}
+		diff.Failures = append(diff.Failures, importFailure(isi.Spec.Images[i].To.Name, status))
+	}
+	if isi.Status.Repository != nil {
+		for _, status := range isi.Status.Repository.Images {
+			if status.Status.Status == metav1.StatusSuccess {
+				continue
+			}
+			diff.Failures = append(diff.Failures, importFailure(status.Tag, status))
+		}
+	}
+
+	tags := sets.NewString()
+	for tag := range updated.Spec.Tags {
+		tags.Insert(tag)
+	}
+	for tag := range updated.Status.Tags {
+		tags.Insert(tag)
+	}
+
+	current := original.DeepCopy()
+	for _, tag := range tags.List() {
+		specTag, tagEvent := diffTag(original, updated, tag)
+		if specTag == nil && tagEvent == nil {
+			continue
+		}
+
+		candidate := current.DeepCopy()
+		if specTag != nil {
+			if candidate.Spec.Tags == nil {
+				candidate.Spec.Tags = make(map[string]imageapi.TagReference)
+			}
+			candidate.Spec.Tags[tag] = updated.Spec.Tags[tag]
+		}
+		if tagEvent != nil {
+			if candidate.Status.Tags == nil {
+				candidate.Status.Tags = make(map[string]imageapi.TagEventList)
+			}
+			candidate.Status.Tags[tag] = updated.Status.Tags[tag]
+		}
+
+		if errs := r.whitelistErrors(ctx, candidate, current); len(errs) > 0 {
+			diff.Failures = append(diff.Failures, TagFailure{Tag: tag, Reason: TagFailureRegistryNotAllowed, Message: errs.ToAggregate().Error()})
+			continue
+		}
+		if r.limitVerifier != nil {
+			if err := r.limitVerifier.VerifyLimits(namespace, current, candidate); err != nil {
+				diff.Failures = append(diff.Failures, TagFailure{Tag: tag, Reason: TagFailureLimitExceeded, Message: err.Error()})
+				continue
+			}
+		}
+
+		current = candidate
+		if specTag != nil {
+			diff.SpecTags = append(diff.SpecTags, *specTag)
+		}
+		if tagEvent != nil {
+			diff.TagEvents = append(diff.TagEvents, *tagEvent)
+		}
+	}
+
+	sort.SliceStable(diff.Failures, func(i, j int) bool {
+		return diff.Failures[i].Tag < diff.Failures[j].Tag
+	})
+	return diff
+}
+
+// diffTag returns the change of the spec tag and the tag event appended to the status tag between
+// the original and the updated image stream, if any.
+func diffTag(original, updated *imageapi.ImageStream, tag string) (*SpecTagDiff, *TagEventDiff) {
+	var specTag *SpecTagDiff
+	if newRef, ok := updated.Spec.Tags[tag]; ok && newRef.From != nil {
+		oldRef, existed := original.Spec.Tags[tag]
+		switch {
+		case !existed:
+			specTag = &SpecTagDiff{Tag: tag, Change: SpecTagAdded, To: newRef.From.Name}
+		case oldRef.From == nil || oldRef.From.Kind != newRef.From.Kind || oldRef.From.Name != newRef.From.Name:
+			specTag = &SpecTagDiff{Tag: tag, Change: SpecTagMoved, To: newRef.From.Name}
+			if oldRef.From != nil {
+				specTag.From = oldRef.From.Name
+			}
+		}
+	}
+
+	var tagEvent *TagEventDiff
+	newEvents := updated.Status.Tags[tag].Items
+	oldEvents := original.Status.Tags[tag].Items
+	if len(newEvents) > 0 && (len(oldEvents) == 0 || !kapihelper.Semantic.DeepEqual(newEvents[0], oldEvents[0])) {
+		tagEvent = &TagEventDiff{
+			Tag:                  tag,
+			Image:                newEvents[0].Image,
+			DockerImageReference: newEvents[0].DockerImageReference,
+		}
+	}
+
+	return specTag, tagEvent
+}
+
+// whitelistErrors returns the errors of the references in the updated image stream the registry
+// whitelist does not allow.
+func (r *REST) whitelistErrors(ctx context.Context, updated, old *imageapi.ImageStream) field.ErrorList {
+	var errs field.ErrorList
+	for _, err := range validation.ValidateImageStreamUpdateWithWhitelister(ctx, r.strategy.registryWhitelister, updated, old) {
+		if err.Type == field.ErrorTypeForbidden {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func importFailure(tag string, status imageapi.ImageImportStatus) TagFailure {
+	message := status.Status.Message
+	if len(message) == 0 {
+		message = "unknown error prevented import"
+	}
+	return TagFailure{Tag: tag, Reason: TagFailureImportFailed, Message: message}
+}
@@ -0,0 +1,134 @@
+package imagestreamimport
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+
+	openshiftcontrolplanev1 "github.com/openshift/api/openshiftcontrolplane/v1"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+	"github.com/openshift/openshift-apiserver/pkg/image/apis/image/validation/whitelist"
+	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/admission/fake"
+)
+
+func TestDiffImageStream(t *testing.T) {
+	const (
+		oldDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		newDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+
+	specTag := func(from string) imageapi.TagReference {
+		zero := int64(0)
+		return imageapi.TagReference{
+			From:       &kapi.ObjectReference{Kind: "DockerImage", Name: from},
+			Generation: &zero,
+		}
+	}
+	tagEvents := func(refs ...string) imageapi.TagEventList {
+		list := imageapi.TagEventList{}
+		for _, ref := range refs {
+			list.Items = append(list.Items, imageapi.TagEvent{DockerImageReference: ref, Image: ref[len(ref)-71:]})
+		}
+		return list
+	}
+
+	original := &imageapi.ImageStream{
+		ObjectMeta: metav1.ObjectMeta{Name: "stream", Namespace: "ns"},
+		Spec: imageapi.ImageStreamSpec{
+			Tags: map[string]imageapi.TagReference{
+				"moved":     specTag("registry.com/app:v1"),
+				"unchanged": specTag("registry.com/app:v1"),
+			},
+		},
+		Status: imageapi.ImageStreamStatus{
+			Tags: map[string]imageapi.TagEventList{
+				"moved":     tagEvents("registry.com/app@" + oldDigest),
+				"unchanged": tagEvents("registry.com/app@" + oldDigest),
+			},
+		},
+	}
+
+	updated := original.DeepCopy()
+	updated.Spec.Tags["moved"] = specTag("registry.com/app:v2")
+	updated.Status.Tags["moved"] = tagEvents("registry.com/app@"+newDigest, "registry.com/app@"+oldDigest)
+	updated.Spec.Tags["added"] = specTag("registry.com/app:v2")
+	updated.Status.Tags["added"] = tagEvents("registry.com/app@" + newDigest)
+	updated.Spec.Tags["blocked"] = specTag("blocked.com/app:v2")
+	updated.Status.Tags["blocked"] = tagEvents("blocked.com/app@" + newDigest)
+	updated.Spec.Tags["limited"] = specTag("registry.com/limited:v1")
+	updated.Status.Tags["limited"] = tagEvents("registry.com/limited@" + newDigest)
+
+	isi := &imageapi.ImageStreamImport{
+		Spec: imageapi.ImageStreamImportSpec{
+			Images: []imageapi.ImageImportSpec{
+				{To: &kapi.LocalObjectReference{Name: "failed"}},
+				{},
+			},
+		},
+		Status: imageapi.ImageStreamImportStatus{
+			Images: []imageapi.ImageImportStatus{
+				{Status: metav1.Status{Status: metav1.StatusFailure, Message: "manifest unknown"}},
+				{Status: metav1.Status{Status: metav1.StatusFailure}},
+			},
+		},
+	}
+
+	whitelister, err := whitelist.NewRegistryWhitelister(openshiftcontrolplanev1.AllowedRegistries{
+		{DomainName: "registry.com"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := &REST{
+		strategy: NewStrategy(whitelister),
+		limitVerifier: &fake.ImageStreamLimitVerifier{
+			ImageStreamEvaluator: func(ns string, oldStream, newStream *imageapi.ImageStream) error {
+				if _, ok := newStream.Spec.Tags["limited"]; ok {
+					return fmt.Errorf("exceeds the limit")
+				}
+				return nil
+			},
+		},
+	}
+
+	diff := storage.diffImageStream(context.Background(), "ns", isi, original, updated, []string{newDigest})
+
+	expected := &ImportDiff{
+		SpecTags: []SpecTagDiff{
+			{Tag: "added", Change: SpecTagAdded, To: "registry.com/app:v2"},
+			{Tag: "moved", Change: SpecTagMoved, From: "registry.com/app:v1", To: "registry.com/app:v2"},
+		},
+		TagEvents: []TagEventDiff{
+			{Tag: "added", Image: newDigest, DockerImageReference: "registry.com/app@" + newDigest},
+			{Tag: "moved", Image: newDigest, DockerImageReference: "registry.com/app@" + newDigest},
+		},
+		NewImages: []string{newDigest},
+	}
+	if !reflect.DeepEqual(diff.SpecTags, expected.SpecTags) {
+		t.Errorf("unexpected spec tags: %#v", diff.SpecTags)
+	}
+	if !reflect.DeepEqual(diff.TagEvents, expected.TagEvents) {
+		t.Errorf("unexpected tag events: %#v", diff.TagEvents)
+	}
+	if !reflect.DeepEqual(diff.NewImages, expected.NewImages) {
+		t.Errorf("unexpected new images: %#v", diff.NewImages)
+	}
+
+	reasons := map[string]string{}
+	for _, failure := range diff.Failures {
+		reasons[failure.Tag] = failure.Reason
+	}
+	expectedReasons := map[string]string{
+		"blocked": TagFailureRegistryNotAllowed,
+		"failed":  TagFailureImportFailed,
+		"limited": TagFailureLimitExceeded,
+	}
+	if !reflect.DeepEqual(reasons, expectedReasons) {
+		t.Errorf("unexpected failures: %#v", diff.Failures)
+	}
+}
@@ -2,9 +2,12 @@ package imagestreamimport
 
 import (
 	"context"
+	"fmt"
 
 	kapierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/klog/v2"
 
@@ -13,27 +16,40 @@ import (
 )
 
 type cachedImageCreater struct {
-	strategy *strategy
-	images   rest.Creater
-	cache    map[string]*imageapi.Image
+	strategy     *strategy
+	images       rest.Creater
+	imageUpdater rest.Updater
+	cache        map[string]*imageapi.Image
+
+	// dryRun validates the images without persisting them.
+	dryRun bool
+	// created are the names of the images that did not exist before.
+	created []string
 }
 
-func newCachedImageCreater(strategy *strategy, images rest.Creater) *cachedImageCreater {
+func newCachedImageCreater(strategy *strategy, images rest.Creater, imageUpdater rest.Updater) *cachedImageCreater {
 	return &cachedImageCreater{
-		strategy: strategy,
-		images:   images,
-		cache:    make(map[string]*imageapi.Image),
+		strategy:     strategy,
+		images:       images,
+		imageUpdater: imageUpdater,
+		cache:        make(map[string]*imageapi.Image),
 	}
 }
 
 func (ic *cachedImageCreater) Create(ctx context.Context, image *imageapi.Image) (*imageapi.Image, error) {
+	// signatures verified by the importer are recorded once the image exists
+	signatures := image.Signatures
 	ic.strategy.PrepareImageForCreate(image)
 
 	if cachedImage, ok := ic.cache[image.Name]; ok {
 		return cachedImage, nil
 	}
 
-	createdImage, err := ic.images.Create(ctx, image, rest.ValidateAllObjectFunc, &metav1.CreateOptions{})
+	options := &metav1.CreateOptions{}
+	if ic.dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	createdImage, err := ic.images.Create(ctx, image, rest.ValidateAllObjectFunc, options)
 	switch {
 	case kapierrors.IsAlreadyExists(err):
 		if err := internalimageutil.InternalImageWithMetadata(image); err != nil {
@@ -41,11 +57,48 @@ func (ic *cachedImageCreater) Create(ctx context.Context, image *imageapi.Image)
 		}
 	case err == nil:
 		image = createdImage.(*imageapi.Image)
+		ic.created = append(ic.created, image.Name)
 	default:
 		return nil, err
 	}
+	if len(signatures) > 0 && !ic.dryRun {
+		ic.addSignatures(ctx, image, signatures)
+	}
 
 	ic.cache[image.Name] = image
 
 	return image, nil
 }
+
+// addSignatures records the signatures verified during the import on the stored image. Signatures
+// the image already has are replaced, so that their conditions reflect the last import. Failing to
+// record the signatures does not fail the import.
+func (ic *cachedImageCreater) addSignatures(ctx context.Context, image *imageapi.Image, signatures []imageapi.ImageSignature) {
+	if ic.imageUpdater == nil {
+		return
+	}
+	addSignatures := func(ctx context.Context, obj, oldObj runtime.Object) (runtime.Object, error) {
+		updated := oldObj.(*imageapi.Image).DeepCopy()
+		for _, signature := range signatures {
+			replaced := false
+			for i := range updated.Signatures {
+				if updated.Signatures[i].Name == signature.Name {
+					updated.Signatures[i] = signature
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				updated.Signatures = append(updated.Signatures, signature)
+			}
+		}
+		return updated, nil
+	}
+	updated, _, err := ic.imageUpdater.Update(ctx, image.Name, rest.DefaultUpdatedObjectInfo(nil, addSignatures),
+		rest.ValidateAllObjectFunc, rest.ValidateAllObjectUpdateFunc, false, &metav1.UpdateOptions{})
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("unable to record signatures of imported image %s: %v", image.Name, err))
+		return
+	}
+	image.Signatures = updated.(*imageapi.Image).Signatures
+}
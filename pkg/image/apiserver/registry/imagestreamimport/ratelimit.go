@@ -0,0 +1,247 @@
+package imagestreamimport
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/utils/clock"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+const (
+	// DefaultRateLimitMaxWait is the default time an import waits for its turn before it is rejected.
+	DefaultRateLimitMaxWait = 10 * time.Second
+
+	// maxIdleRateLimitBuckets is the number of buckets kept before the idle ones are dropped.
+	maxIdleRateLimitBuckets = 1024
+)
+
+// RateLimitConfig configures the rate limiting of image imports. Imports are limited per namespace,
+// or per user within a namespace, so that a single tenant cannot saturate the registry traffic of the
+// apiserver. A global limit bounds the imports of all the tenants together, many tenants cannot
+// saturate it either: once it is exhausted, a tenant only queues its fair share of it.
+type RateLimitConfig struct {
+	// TagsPerSecond is the number of tags a tenant may import per second, 0 disables rate limiting.
+	TagsPerSecond float64
+	// Burst is the number of tags a tenant may import at once, it defaults to ten seconds worth of
+	// tags.
+	Burst int
+	// MaxWait is the time an import is queued before it is rejected.
+	MaxWait time.Duration
+	// PerUser limits the users of a namespace independently.
+	PerUser bool
+	// RepositoryTags is the number of tags an import of a whole repository counts for.
+	RepositoryTags int
+	// GlobalTagsPerSecond is the number of tags all the tenants together may import per second, 0
+	// leaves them unlimited.
+	GlobalTagsPerSecond float64
+	// GlobalBurst is the number of tags all the tenants together may import at once, it defaults to
+	// ten seconds worth of tags and is never below Burst.
+	GlobalBurst int
+}
+
+// tokenBucket holds the tags a tenant may import. Imports queued for their turn take the tokens in
+// advance, so the bucket goes below zero while imports are waiting.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last refill, up to burst.
+func (b *tokenBucket) refill(now time.Time, rate, burst float64) {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+}
+
+// importRateLimiter queues the imports of every tenant in a token bucket of its own, so that the
+// imports of a tenant only wait for the imports of the same tenant. If a global limit is set, the
+// imports also take their tokens from a bucket shared by all the tenants, and the tokens a tenant
+// queues for in it are capped to an equal share of the burst among the tenants queuing. It is safe
+// for concurrent use.
+type importRateLimiter struct {
+	config RateLimitConfig
+	clock  clock.Clock
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	// global is the bucket shared by all the tenants, nil without a global limit.
+	global *tokenBucket
+	// queued is the number of tokens of the global bucket each tenant is waiting for.
+	queued map[string]float64
+}
+
+// newImportRateLimiter returns a limiter for config, or nil if rate limiting is disabled.
+func newImportRateLimiter(config RateLimitConfig, clock clock.Clock) *importRateLimiter {
+	if config.TagsPerSecond <= 0 {
+		return nil
+	}
+	if config.Burst <= 0 {
+		config.Burst = int(math.Ceil(10 * config.TagsPerSecond))
+	}
+	if config.MaxWait <= 0 {
+		config.MaxWait = DefaultRateLimitMaxWait
+	}
+	l := &importRateLimiter{
+		config:  config,
+		clock:   clock,
+		buckets: make(map[string]*tokenBucket),
+		queued:  make(map[string]float64),
+	}
+	if config.GlobalTagsPerSecond > 0 {
+		if l.config.GlobalBurst <= 0 {
+			l.config.GlobalBurst = int(math.Ceil(10 * config.GlobalTagsPerSecond))
+		}
+		if l.config.GlobalBurst < config.Burst {
+			l.config.GlobalBurst = config.Burst
+		}
+		l.global = &tokenBucket{tokens: float64(l.config.GlobalBurst), last: clock.Now()}
+	}
+	return l
+}
+
+// cost returns the number of tags isi imports, capped to the burst so that any import can succeed.
+func (l *importRateLimiter) cost(isi *imageapi.ImageStreamImport) int {
+	cost := len(isi.Spec.Images)
+	if isi.Spec.Repository != nil {
+		if l.config.RepositoryTags > 0 {
+			cost += l.config.RepositoryTags
+		} else {
+			cost += l.config.Burst
+		}
+	}
+	if cost < 1 {
+		cost = 1
+	}
+	if cost > l.config.Burst {
+		cost = l.config.Burst
+	}
+	return cost
+}
+
+func (l *importRateLimiter) key(namespace string, u user.Info) string {
+	if l.config.PerUser && u != nil {
+		return namespace + "/" + u.GetName()
+	}
+	return namespace
+}
+
+// reserve takes cost tokens from the bucket of key, and from the global bucket, and returns the
+// time to wait for them and the number of global tokens queued for. If the wait is longer than
+// allowed, or the tenant would queue more than its share of the global bucket, nothing is taken and
+// the time after which to retry is returned.
+func (l *importRateLimiter) reserve(key string, cost int) (wait, retryAfter time.Duration, queued float64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.clock.Now()
+	burst := float64(l.config.Burst)
+	if len(l.buckets) > maxIdleRateLimitBuckets {
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.config.TagsPerSecond >= burst && l.queued[k] == 0 {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.refill(now, l.config.TagsPerSecond, burst)
+	remaining := bucket.tokens - float64(cost)
+	if remaining < 0 {
+		wait = time.Duration(-remaining / l.config.TagsPerSecond * float64(time.Second))
+	}
+
+	globalRemaining := 0.0
+	if l.global != nil {
+		globalBurst := float64(l.config.GlobalBurst)
+		l.global.refill(now, l.config.GlobalTagsPerSecond, globalBurst)
+		globalRemaining = l.global.tokens - float64(cost)
+		if globalRemaining < 0 {
+			queued = math.Min(float64(cost), -globalRemaining)
+			tenants := len(l.queued)
+			if _, ok := l.queued[key]; !ok {
+				tenants++
+			}
+			if l.queued[key]+queued > globalBurst/float64(tenants) {
+				// retry once the global bucket has refilled the tokens of this import
+				return 0, time.Duration(float64(cost) / l.config.GlobalTagsPerSecond * float64(time.Second)), 0
+			}
+			if globalWait := time.Duration(-globalRemaining / l.config.GlobalTagsPerSecond * float64(time.Second)); globalWait > wait {
+				wait = globalWait
+			}
+		}
+	}
+
+	if wait > l.config.MaxWait {
+		return 0, wait - l.config.MaxWait, 0
+	}
+	bucket.tokens = remaining
+	if l.global != nil {
+		l.global.tokens = globalRemaining
+		if queued > 0 {
+			l.queued[key] += queued
+		}
+	}
+	return wait, 0, queued
+}
+
+// done releases the global tokens key queued for once its import is no longer waiting.
+func (l *importRateLimiter) done(key string, queued float64) {
+	if queued == 0 {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.queued[key] -= queued; l.queued[key] <= 0 {
+		delete(l.queued, key)
+	}
+}
+
+// cancel returns the tokens of an import that gave up waiting.
+func (l *importRateLimiter) cancel(key string, cost int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if bucket, ok := l.buckets[key]; ok {
+		bucket.tokens = math.Min(float64(l.config.Burst), bucket.tokens+float64(cost))
+	}
+	if l.global != nil {
+		l.global.tokens = math.Min(float64(l.config.GlobalBurst), l.global.tokens+float64(cost))
+	}
+}
+
+// wait blocks until isi may be imported. It returns a TooManyRequests error if the import would have
+// to wait longer than allowed.
+func (l *importRateLimiter) wait(ctx context.Context, namespace string, u user.Info, isi *imageapi.ImageStreamImport) error {
+	if l == nil {
+		return nil
+	}
+	key, cost := l.key(namespace, u), l.cost(isi)
+	wait, retryAfter, queued := l.reserve(key, cost)
+	if retryAfter > 0 {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		return kapierrors.NewTooManyRequests(fmt.Sprintf("too many images are being imported into namespace %s, retry in %d seconds", namespace, seconds), seconds)
+	}
+	if wait == 0 {
+		return nil
+	}
+
+	defer l.done(key, queued)
+	timer := l.clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		l.cancel(key, cost)
+		return kapierrors.NewTimeoutError(fmt.Sprintf("the import into namespace %s was canceled while waiting for its turn", namespace), 0)
+	}
+}
@@ -0,0 +1,172 @@
+package imagestreamimport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/authentication/user"
+	kapi "k8s.io/kubernetes/pkg/apis/core"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
+)
+
+func importOfTags(n int) *imageapi.ImageStreamImport {
+	isi := &imageapi.ImageStreamImport{}
+	for i := 0; i < n; i++ {
+		isi.Spec.Images = append(isi.Spec.Images, imageapi.ImageImportSpec{
+			From: kapi.ObjectReference{Kind: "DockerImage", Name: "test"},
+		})
+	}
+	return isi
+}
+
+func TestImportRateLimiterDisabled(t *testing.T) {
+	if l := newImportRateLimiter(RateLimitConfig{}, clocktesting.NewFakeClock(time.Now())); l != nil {
+		t.Fatalf("expected rate limiting to be disabled, got %#v", l)
+	}
+	var l *importRateLimiter
+	if err := l.wait(context.Background(), "ns", nil, importOfTags(100)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportRateLimiterCost(t *testing.T) {
+	l := newImportRateLimiter(RateLimitConfig{TagsPerSecond: 1, Burst: 20, RepositoryTags: 5}, clocktesting.NewFakeClock(time.Now()))
+
+	repository := importOfTags(2)
+	repository.Spec.Repository = &imageapi.RepositoryImportSpec{}
+	for _, tc := range []struct {
+		isi  *imageapi.ImageStreamImport
+		cost int
+	}{
+		{isi: importOfTags(0), cost: 1},
+		{isi: importOfTags(3), cost: 3},
+		{isi: repository, cost: 7},
+		{isi: importOfTags(50), cost: 20},
+	} {
+		if cost := l.cost(tc.isi); cost != tc.cost {
+			t.Errorf("expected a cost of %d, got %d", tc.cost, cost)
+		}
+	}
+}
+
+func TestImportRateLimiterQueuesAndRejects(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Now())
+	l := newImportRateLimiter(RateLimitConfig{TagsPerSecond: 2, Burst: 10, MaxWait: 4 * time.Second}, clock)
+	alice := &user.DefaultInfo{Name: "alice"}
+
+	if err := l.wait(context.Background(), "ns", alice, importOfTags(10)); err != nil {
+		t.Fatalf("expected the burst to be allowed: %v", err)
+	}
+
+	// the next import waits for its tags to be refilled
+	done := make(chan error)
+	go func() {
+		done <- l.wait(context.Background(), "ns", alice, importOfTags(4))
+	}()
+	for !clock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Step(2 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the queued import to succeed: %v", err)
+	}
+
+	// importing 10 more tags would take longer than allowed
+	err := l.wait(context.Background(), "ns", alice, importOfTags(10))
+	if !kapierrors.IsTooManyRequests(err) {
+		t.Fatalf("expected a TooManyRequests error, got %v", err)
+	}
+	if seconds, ok := kapierrors.SuggestsClientDelay(err); !ok || seconds != 1 {
+		t.Errorf("expected the client to be asked to retry in 1 second, got %d (%t)", seconds, ok)
+	}
+
+	// other namespaces are not affected
+	if err := l.wait(context.Background(), "other", alice, importOfTags(10)); err != nil {
+		t.Errorf("expected the import into another namespace to be allowed: %v", err)
+	}
+}
+
+func TestImportRateLimiterPerUser(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Now())
+	l := newImportRateLimiter(RateLimitConfig{TagsPerSecond: 1, Burst: 5, MaxWait: time.Second, PerUser: true}, clock)
+
+	if err := l.wait(context.Background(), "ns", &user.DefaultInfo{Name: "alice"}, importOfTags(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.wait(context.Background(), "ns", &user.DefaultInfo{Name: "alice"}, importOfTags(5)); !kapierrors.IsTooManyRequests(err) {
+		t.Errorf("expected a TooManyRequests error, got %v", err)
+	}
+	if err := l.wait(context.Background(), "ns", &user.DefaultInfo{Name: "bob"}, importOfTags(5)); err != nil {
+		t.Errorf("expected the import of another user to be allowed: %v", err)
+	}
+}
+
+func TestImportRateLimiterCanceled(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Now())
+	l := newImportRateLimiter(RateLimitConfig{TagsPerSecond: 1, Burst: 5, MaxWait: 10 * time.Second}, clock)
+
+	if err := l.wait(context.Background(), "ns", nil, importOfTags(5)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, "ns", nil, importOfTags(5)); !kapierrors.IsTimeout(err) {
+		t.Fatalf("expected a Timeout error, got %v", err)
+	}
+
+	// the tags of the canceled import are given back
+	clock.Step(5 * time.Second)
+	if wait, retryAfter, _ := l.reserve("ns", 5); wait != 0 || retryAfter != 0 {
+		t.Errorf("expected the bucket to be full, got a wait of %s and a retry after %s", wait, retryAfter)
+	}
+}
+
+func TestImportRateLimiterFractionalRate(t *testing.T) {
+	l := newImportRateLimiter(RateLimitConfig{TagsPerSecond: 0.5}, clocktesting.NewFakeClock(time.Now()))
+	if l == nil || l.config.Burst != 5 {
+		t.Fatalf("expected a burst of 5 tags, got %#v", l)
+	}
+}
+
+func TestImportRateLimiterGlobal(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Now())
+	l := newImportRateLimiter(RateLimitConfig{TagsPerSecond: 10, Burst: 10, MaxWait: 10 * time.Second, GlobalTagsPerSecond: 1, GlobalBurst: 10}, clock)
+
+	// the tenants together exhaust the global bucket
+	if err := l.wait(context.Background(), "a", nil, importOfTags(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.wait(context.Background(), "b", nil, importOfTags(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a third tenant queues for the refill of the global bucket
+	done := make(chan error)
+	go func() {
+		done <- l.wait(context.Background(), "c", nil, importOfTags(4))
+	}()
+	for !clock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// while it is queuing, a tenant may not queue for more than its share of the global bucket
+	if _, retryAfter, _ := l.reserve("a", 6); retryAfter == 0 {
+		t.Errorf("expected the tenant to be rejected for exceeding its share of the global bucket")
+	}
+	if wait, retryAfter, queued := l.reserve("a", 5); retryAfter != 0 || wait != 9*time.Second || queued != 5 {
+		t.Errorf("expected the tenant to queue for its share, got a wait of %s, a retry after %s and %v queued tags", wait, retryAfter, queued)
+	}
+	l.done("a", 5)
+
+	clock.Step(4 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("expected the queued import to succeed: %v", err)
+	}
+	if len(l.queued) != 0 {
+		t.Errorf("expected no queued tags, got %v", l.queued)
+	}
+}
@@ -2,6 +2,7 @@ package imagestreamimport
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -19,11 +20,13 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/util/dryrun"
 	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/api/legacyscheme"
 	kapi "k8s.io/kubernetes/pkg/apis/core"
 	kapihelper "k8s.io/kubernetes/pkg/apis/core/helper"
+	"k8s.io/utils/clock"
 
 	"github.com/openshift/api/image"
 	imagev1 "github.com/openshift/api/image/v1"
@@ -38,6 +41,7 @@ import (
 	imageapi "github.com/openshift/openshift-apiserver/pkg/image/apis/image"
 	"github.com/openshift/openshift-apiserver/pkg/image/apis/image/validation"
 	"github.com/openshift/openshift-apiserver/pkg/image/apis/image/validation/whitelist"
+	imageadmission "github.com/openshift/openshift-apiserver/pkg/image/apiserver/admission/limitrange"
 	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/importer"
 	internalimageutil "github.com/openshift/openshift-apiserver/pkg/image/apiserver/internal/imageutil"
 	"github.com/openshift/openshift-apiserver/pkg/image/apiserver/registry/imagestream"
@@ -53,6 +57,8 @@ type REST struct {
 	streams           imagestream.Registry
 	internalStreams   rest.CreaterUpdater
 	images            rest.Creater
+	limitVerifier     imageadmission.LimitVerifier
+	imageUpdater      rest.Updater
 	isV1Client        imageclientv1.ImageStreamsGetter
 	transport         http.RoundTripper
 	insecureTransport http.RoundTripper
@@ -62,6 +68,7 @@ type REST struct {
 	idmsLister        configv1lister.ImageDigestMirrorSetLister
 	itmsLister        configv1lister.ImageTagMirrorSetLister
 	imageCfgV1Client  configclientv1.ImagesGetter
+	rateLimiter       *importRateLimiter
 }
 
 var _ rest.Creater = &REST{}
@@ -75,6 +82,8 @@ var _ rest.SingularNameProvider = &REST{}
 // those certs.
 func NewREST(importFn ImporterFunc, streams imagestream.Registry, internalStreams rest.CreaterUpdater,
 	images rest.Creater,
+	imageUpdater rest.Updater,
+	limitVerifier imageadmission.LimitVerifier,
 	isV1Client imageclientv1.ImageStreamsGetter,
 	transport, insecureTransport http.RoundTripper,
 	registryWhitelister whitelist.RegistryWhitelister,
@@ -83,12 +92,15 @@ func NewREST(importFn ImporterFunc, streams imagestream.Registry, internalStream
 	idmsLister configv1lister.ImageDigestMirrorSetLister,
 	itmsLister configv1lister.ImageTagMirrorSetLister,
 	imageCfgV1Client configclientv1.ImagesGetter,
+	rateLimit RateLimitConfig,
 ) *REST {
 	return &REST{
 		importFn:          importFn,
 		streams:           streams,
 		internalStreams:   internalStreams,
 		images:            images,
+		imageUpdater:      imageUpdater,
+		limitVerifier:     limitVerifier,
 		isV1Client:        isV1Client,
 		transport:         transport,
 		insecureTransport: insecureTransport,
@@ -98,6 +110,7 @@ func NewREST(importFn ImporterFunc, streams imagestream.Registry, internalStream
 		idmsLister:        idmsLister,
 		itmsLister:        itmsLister,
 		imageCfgV1Client:  imageCfgV1Client,
+		rateLimiter:       newImportRateLimiter(rateLimit, clock.RealClock{}),
 	}
 }
 
@@ -123,8 +136,8 @@ func (r *REST) createImages(
 	stream *imageapi.ImageStream,
 	nextGeneration int64,
 	now metav1.Time,
+	imageCreater *cachedImageCreater,
 ) error {
-	imageCreater := newCachedImageCreater(r.strategy, r.images)
 
 	if spec := isi.Spec.Repository; spec != nil {
 		for i, status := range isi.Status.Repository.Images {
@@ -263,6 +276,11 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		return nil, kapierrors.NewBadRequest("a namespace must be specified to import images")
 	}
 
+	// wait for the turn of the tenant before any registry is contacted
+	if err := r.rateLimiter.wait(ctx, namespace, user, isi); err != nil {
+		return nil, err
+	}
+
 	create := false
 	stream, err := r.streams.GetImageStream(ctx, isi.Name, &metav1.GetOptions{})
 	if err != nil {
@@ -368,9 +386,13 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		}
 	}
 
+	// a dry run performs the import as if it was requested, but nothing is persisted and the changes
+	// to the image stream are returned in the ImageStreamImportDiffAnnotation.
+	dryRun := dryrun.IsDryRun(options.DryRun)
+
 	// TODO: perform the transformation of the image stream and return it with the ISI if import is false
 	//   so that clients can see what the resulting object would look like.
-	if !isi.Spec.Import {
+	if !isi.Spec.Import && !dryRun {
 		clearManifests(isi)
 		return isi, nil
 	}
@@ -384,7 +406,9 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 
 	original := stream.DeepCopy()
 
-	err = r.createImages(ctx, isi, stream, nextGeneration, now)
+	imageCreater := newCachedImageCreater(r.strategy, r.images, r.imageUpdater)
+	imageCreater.dryRun = dryRun
+	err = r.createImages(ctx, isi, stream, nextGeneration, now, imageCreater)
 	if err != nil {
 		return nil, err
 	}
@@ -404,6 +428,20 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 	}
 	stream = internal.(*imageapi.ImageStream)
 
+	if dryRun {
+		diff := r.diffImageStream(ctx, namespace, isi, original, stream, imageCreater.created)
+		data, err := json.Marshal(diff)
+		if err != nil {
+			return nil, kapierrors.NewInternalError(err)
+		}
+		if isi.Annotations == nil {
+			isi.Annotations = make(map[string]string)
+		}
+		isi.Annotations[imageapi.ImageStreamImportDiffAnnotation] = string(data)
+		isi.Status.Import = stream
+		return isi, nil
+	}
+
 	// if and only if we have changes between the original and the imported stream, trigger
 	// an import
 	hasChanges := !kapihelper.Semantic.DeepEqual(original, stream)
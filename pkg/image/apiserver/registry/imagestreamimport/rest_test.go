@@ -249,7 +249,7 @@ func TestImportSuccessful(t *testing.T) {
 		storage := REST{
 			images: &fakeImageCreater{},
 		}
-		imageCreater := newCachedImageCreater(nil, storage.images)
+		imageCreater := newCachedImageCreater(nil, storage.images, nil)
 		_, _, err = storage.importSuccessful(apirequest.NewDefaultContext(), test.image, nil, test.stream,
 			tag, ref.Exact(), two, now, importPolicy, referencePolicy, imageCreater)
 		if err != nil {
@@ -389,7 +389,7 @@ func TestImportSuccessfulWithSubmanifests(t *testing.T) {
 			storage := REST{
 				images: restImageCreater,
 			}
-			imageCreater := newCachedImageCreater(nil, storage.images)
+			imageCreater := newCachedImageCreater(nil, storage.images, nil)
 
 			_, updatedSubmanifests, err := storage.importSuccessful(
 				apirequest.NewDefaultContext(),
@@ -553,7 +553,7 @@ func TestCreateImages(t *testing.T) {
 					Images: []imageapi.ImageImportStatus{testCase.imageImportStatus},
 				},
 			}
-			err := storage.createImages(ctx, isi, is, one, metav1.NewTime(time.Now()))
+			err := storage.createImages(ctx, isi, is, one, metav1.NewTime(time.Now()), newCachedImageCreater(storage.strategy, storage.images, nil))
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -592,3 +592,66 @@ func TestCreateImages(t *testing.T) {
 	// }
 	// success image stream condition should be empty
 }
+
+type fakeImageUpdater struct {
+	images map[string]*imageapi.Image
+}
+
+func (_ fakeImageUpdater) New() runtime.Object {
+	return nil
+}
+
+func (f *fakeImageUpdater) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, _ rest.ValidateObjectFunc, _ rest.ValidateObjectUpdateFunc, _ bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	updated, err := objInfo.UpdatedObject(ctx, f.images[name])
+	if err != nil {
+		return nil, false, err
+	}
+	f.images[name] = updated.(*imageapi.Image)
+	return updated, false, nil
+}
+
+func TestCachedImageCreaterAddsSignaturesToExistingImage(t *testing.T) {
+	const imageDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	existing := mockImage(imageDigest)
+	existing.Signatures = []imageapi.ImageSignature{
+		{ObjectMeta: metav1.ObjectMeta{Name: imageDigest + "@pushed"}, Type: imageapi.ImageSignatureTypeAtomicImageV1},
+		{ObjectMeta: metav1.ObjectMeta{Name: imageDigest + "@cosign-1"}, Type: imageapi.ImageSignatureTypeCosignV1},
+	}
+	updater := &fakeImageUpdater{images: map[string]*imageapi.Image{imageDigest: existing}}
+	creater := &fakeImageCreater{
+		errors: map[string]error{imageDigest: kerrors.NewAlreadyExists(imageapi.Resource("images"), imageDigest)},
+	}
+
+	imported := mockImage(imageDigest)
+	imported.Signatures = []imageapi.ImageSignature{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: imageDigest + "@cosign-1"},
+			Type:       imageapi.ImageSignatureTypeCosignV1,
+			Conditions: []imageapi.SignatureCondition{{Type: imageapi.SignatureTrusted, Status: kapi.ConditionTrue}},
+		},
+		{ObjectMeta: metav1.ObjectMeta{Name: imageDigest + "@cosign-2"}, Type: imageapi.ImageSignatureTypeCosignV1},
+	}
+	imageCreater := newCachedImageCreater(&strategy{}, creater, updater)
+	created, err := imageCreater.Create(context.Background(), imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Signatures) != 3 {
+		t.Errorf("expected the stored signatures to be returned, got %#v", created.Signatures)
+	}
+
+	signatures := updater.images[imageDigest].Signatures
+	if len(signatures) != 3 {
+		t.Fatalf("expected 3 signatures, got %#v", signatures)
+	}
+	if signatures[0].Name != imageDigest+"@pushed" {
+		t.Errorf("expected pushed signature to be kept, got %#v", signatures[0])
+	}
+	if len(signatures[1].Conditions) != 1 {
+		t.Errorf("expected imported signature to be replaced, got %#v", signatures[1])
+	}
+	if signatures[2].Name != imageDigest+"@cosign-2" {
+		t.Errorf("expected imported signature to be added, got %#v", signatures[2])
+	}
+}
@@ -65,4 +65,11 @@ const (
 	// ProjectRequester is the username that requested a given project.  Its not guaranteed to be present,
 	// but it is set by the default project template.
 	ProjectRequester = "openshift.io/requester"
+	// ProjectTemplateFlavor is set on a ProjectRequest to the flavor of the project template it
+	// requests, and on the project created from the template of that flavor.
+	ProjectTemplateFlavor = "openshift.io/template-flavor"
+	// ProjectTemplateParameterPrefix prefixes the annotations of a ProjectRequest setting the
+	// parameters of its project template, the name of the annotation being the name of the
+	// parameter.
+	ProjectTemplateParameterPrefix = "template-parameter.openshift.io/"
 )
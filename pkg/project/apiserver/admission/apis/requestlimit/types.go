@@ -7,8 +7,9 @@ import (
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ProjectRequestLimitConfig is the configuration for the project request limit plug-in
-// It contains an ordered list of limits based on user label selectors. Selectors will
-// be checked in order and the first one that applies will be used as the limit.
+// It contains an ordered list of limits based on user label selectors, groups and requested
+// project flavors. Limits will be checked in order and the first one that applies will be used
+// as the limit.
 type ProjectRequestLimitConfig struct {
 	metav1.TypeMeta
 	Limits []ProjectLimitBySelector
@@ -27,6 +28,13 @@ type ProjectRequestLimitConfig struct {
 type ProjectLimitBySelector struct {
 	// Selector is a user label selector. An empty selector selects everything.
 	Selector map[string]string
+	// Groups restricts the limit to the members of one of the groups. The limit applies to all the
+	// users if empty.
+	Groups []string
+	// Flavors restricts the limit to the requests of a project of one of the template flavors, and
+	// only the projects of these flavors count towards the limit. The limit applies to the requests
+	// of any project, and all the projects count towards it, if empty.
+	Flavors []string
 	// MaxProjects is the number of projects allowed for this class of users. If MaxProjects is nil,
 	// there is no limit to the number of projects users can request. An unlimited number of projects
 	// is useful in the case a limit is specified as the default for all users and only users with a
@@ -8,6 +8,8 @@ package v1
 var map_ProjectLimitBySelector = map[string]string{
 	"":            "ProjectLimitBySelector specifies the maximum number of projects allowed for a given user label selector",
 	"selector":    "Selector is a user label selector. An empty selector selects everything.",
+	"groups":      "Groups restricts the limit to the members of one of the groups. The limit applies to all the users if empty.",
+	"flavors":     "Flavors restricts the limit to the requests of a project of one of the template flavors, and only the projects of these flavors count towards the limit. The limit applies to the requests of any project, and all the projects count towards it, if empty.",
 	"maxProjects": "MaxProjects is the number of projects allowed for this class of users. If MaxProjects is nil, there is no limit to the number of projects users can request. An unlimited number of projects is useful in the case a limit is specified as the default for all users and only users with a specific set of labels should be allowed unlimited project creation.",
 }
 
@@ -16,7 +18,7 @@ func (ProjectLimitBySelector) SwaggerDoc() map[string]string {
 }
 
 var map_ProjectRequestLimitConfig = map[string]string{
-	"":                              "ProjectRequestLimitConfig is the configuration for the project request limit plug-in It contains an ordered list of limits based on user label selectors. Selectors will be checked in order and the first one that applies will be used as the limit.",
+	"":                              "ProjectRequestLimitConfig is the configuration for the project request limit plug-in It contains an ordered list of limits based on user label selectors, groups and requested project flavors. Limits will be checked in order and the first one that applies will be used as the limit.",
 	"limits":                        "Limits are the project request limits",
 	"maxProjectsForSystemUsers":     "MaxProjectsForSystemUsers controls how many projects a certificate user may have.  Certificate users do not have any labels associated with them for more fine grained control",
 	"maxProjectsForServiceAccounts": "MaxProjectsForServiceAccounts controls how many projects a service account may have.  Service accounts can't create projects by default, but if they are allowed to create projects, you cannot trust any labels placed on them since project editors can manipulate those labels",
@@ -45,6 +45,8 @@ func RegisterConversions(s *runtime.Scheme) error {
 
 func autoConvert_v1_ProjectLimitBySelector_To_requestlimit_ProjectLimitBySelector(in *ProjectLimitBySelector, out *requestlimit.ProjectLimitBySelector, s conversion.Scope) error {
 	out.Selector = *(*map[string]string)(unsafe.Pointer(&in.Selector))
+	out.Groups = *(*[]string)(unsafe.Pointer(&in.Groups))
+	out.Flavors = *(*[]string)(unsafe.Pointer(&in.Flavors))
 	out.MaxProjects = (*int)(unsafe.Pointer(in.MaxProjects))
 	return nil
 }
@@ -56,6 +58,8 @@ func Convert_v1_ProjectLimitBySelector_To_requestlimit_ProjectLimitBySelector(in
 
 func autoConvert_requestlimit_ProjectLimitBySelector_To_v1_ProjectLimitBySelector(in *requestlimit.ProjectLimitBySelector, out *ProjectLimitBySelector, s conversion.Scope) error {
 	out.Selector = *(*map[string]string)(unsafe.Pointer(&in.Selector))
+	out.Groups = *(*[]string)(unsafe.Pointer(&in.Groups))
+	out.Flavors = *(*[]string)(unsafe.Pointer(&in.Flavors))
 	out.MaxProjects = (*int)(unsafe.Pointer(in.MaxProjects))
 	return nil
 }
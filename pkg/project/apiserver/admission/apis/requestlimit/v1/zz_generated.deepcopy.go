@@ -19,6 +19,16 @@ func (in *ProjectLimitBySelector) DeepCopyInto(out *ProjectLimitBySelector) {
 			(*out)[key] = val
 		}
 	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Flavors != nil {
+		in, out := &in.Flavors, &out.Flavors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.MaxProjects != nil {
 		in, out := &in.MaxProjects, &out.MaxProjects
 		*out = new(int)
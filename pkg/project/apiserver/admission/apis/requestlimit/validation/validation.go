@@ -2,6 +2,7 @@ package validation
 
 import (
 	unversionedvalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/openshift/openshift-apiserver/pkg/project/apiserver/admission/apis/requestlimit"
@@ -24,6 +25,16 @@ func ValidateProjectRequestLimitConfig(config *requestlimit.ProjectRequestLimitC
 func ValidateProjectLimitBySelector(limit requestlimit.ProjectLimitBySelector, path *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 	allErrs = append(allErrs, unversionedvalidation.ValidateLabels(limit.Selector, path.Child("selector"))...)
+	for i, group := range limit.Groups {
+		if len(group) == 0 {
+			allErrs = append(allErrs, field.Required(path.Child("groups").Index(i), "group name cannot be empty"))
+		}
+	}
+	for i, flavor := range limit.Flavors {
+		for _, msg := range validation.IsDNS1123Label(flavor) {
+			allErrs = append(allErrs, field.Invalid(path.Child("flavors").Index(i), flavor, msg))
+		}
+	}
 	if limit.MaxProjects != nil && *limit.MaxProjects < 0 {
 		allErrs = append(allErrs, field.Invalid(path.Child("maxProjects"), *limit.MaxProjects, "cannot be a negative number"))
 	}
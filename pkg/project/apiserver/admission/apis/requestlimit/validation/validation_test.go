@@ -85,6 +85,46 @@ func TestValidateProjectRequestLimitConfig(t *testing.T) {
 			errType:     field.ErrorTypeInvalid,
 			errField:    "limits[2].selector",
 		},
+		// 5: groups and flavors
+		{
+			config: requestlimit.ProjectRequestLimitConfig{
+				Limits: []requestlimit.ProjectLimitBySelector{
+					{
+						Groups:      []string{"gpu-users"},
+						Flavors:     []string{"gpu"},
+						MaxProjects: intp(1),
+					},
+				},
+			},
+		},
+		// 6: empty group (error)
+		{
+			config: requestlimit.ProjectRequestLimitConfig{
+				Limits: []requestlimit.ProjectLimitBySelector{
+					{
+						Groups:      []string{"gpu-users", ""},
+						MaxProjects: intp(1),
+					},
+				},
+			},
+			errExpected: true,
+			errType:     field.ErrorTypeRequired,
+			errField:    "limits[0].groups[1]",
+		},
+		// 7: invalid flavor (error)
+		{
+			config: requestlimit.ProjectRequestLimitConfig{
+				Limits: []requestlimit.ProjectLimitBySelector{
+					{
+						Flavors:     []string{"GPU"},
+						MaxProjects: intp(1),
+					},
+				},
+			},
+			errExpected: true,
+			errType:     field.ErrorTypeInvalid,
+			errField:    "limits[0].flavors[0]",
+		},
 	}
 
 	for i, tc := range tests {
@@ -5,21 +5,27 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/admission"
 	"k8s.io/apiserver/pkg/admission/initializer"
 	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/warning"
 	"k8s.io/client-go/informers"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 
 	"github.com/openshift/api/project"
+	userv1 "github.com/openshift/api/user/v1"
 	uservalidation "github.com/openshift/apiserver-library-go/pkg/apivalidation"
 	usertypedclient "github.com/openshift/client-go/user/clientset/versioned/typed/user/v1"
 	"github.com/openshift/library-go/pkg/apiserver/admission/admissionrestconfig"
@@ -37,6 +43,10 @@ const allowedTerminatingProjects = 2
 
 const timeToWaitForCacheSync = 10 * time.Second
 
+// MaxProjectsAnnotation is set on a user to the maximum number of projects the user may request,
+// overriding the configured limits. It is ignored if no limits are configured.
+const MaxProjectsAnnotation = "project.openshift.io/max-projects"
+
 func Register(plugins *admission.Plugins) {
 	plugins.Register("project.openshift.io/ProjectRequestLimit",
 		func(config io.Reader) (admission.Interface, error) {
@@ -103,65 +113,125 @@ func (o *projectRequestLimit) Validate(ctx context.Context, a admission.Attribut
 	}
 
 	userName := a.GetUserInfo().GetName()
-	projectCount, err := o.projectCountByRequester(userName)
+	flavor := a.GetObject().(*projectapi.ProjectRequest).Annotations[projectapi.ProjectTemplateFlavor]
+	limit, projectCount, err := o.check(ctx, a.GetUserInfo(), flavor, nil)
 	if err != nil {
 		return err
 	}
-	maxProjects, hasLimit, err := o.maxProjectsByRequester(ctx, userName)
-	if err != nil {
-		return err
+	if limit.limited && projectCount >= limit.maxProjects {
+		return admission.NewForbidden(a, fmt.Errorf("user %s cannot create more than %d project(s), as limited by %s.", userName, limit.maxProjects, limit.source))
 	}
-	if hasLimit && projectCount >= maxProjects {
-		return admission.NewForbidden(a, fmt.Errorf("user %s cannot create more than %d project(s).", userName, maxProjects))
+	// a dry run tells the user how many more projects they may request
+	if a.IsDryRun() {
+		if limit.limited {
+			warning.AddWarning(ctx, "", fmt.Sprintf("user %s may create %d more project(s), as limited by %s", userName, limit.maxProjects-projectCount, limit.source))
+		} else {
+			warning.AddWarning(ctx, "", fmt.Sprintf("user %s may create an unlimited number of projects", userName))
+		}
 	}
 	return nil
 }
 
-// maxProjectsByRequester returns the maximum number of projects allowed for a given user, whether a limit exists, and an error
-// if an error occurred. If a limit doesn't exist, the maximum number should be ignored.
-func (o *projectRequestLimit) maxProjectsByRequester(ctx context.Context, userName string) (int, bool, error) {
+// check returns the limit of the projects of the user requesting a project of the flavor, and the
+// number of their projects counting towards it, including their project requests pending approval
+// of pendingFlavors.
+func (o *projectRequestLimit) check(ctx context.Context, userInfo user.Info, flavor string, pendingFlavors []string) (requesterLimit, int, error) {
+	limit, err := o.maxProjectsByRequester(ctx, userInfo, flavor)
+	if err != nil {
+		return requesterLimit{}, 0, err
+	}
+	projectCount, err := o.projectCountByRequester(userInfo.GetName(), limit.flavors)
+	if err != nil {
+		return requesterLimit{}, 0, err
+	}
+	for _, pendingFlavor := range pendingFlavors {
+		if len(limit.flavors) == 0 || limit.flavors.Has(pendingFlavor) {
+			projectCount++
+		}
+	}
+	return limit, projectCount, nil
+}
+
+// requesterLimit is the maximum number of projects a user may have.
+type requesterLimit struct {
+	// maxProjects is the maximum number of projects, ignored if the number is not limited.
+	maxProjects int
+	limited     bool
+	// source describes the configuration the limit comes from.
+	source string
+	// flavors are the flavors of the projects counting towards the limit, all the projects count
+	// towards it if empty.
+	flavors sets.Set[string]
+}
+
+// maxProjectsByRequester returns the limit of the projects of a given user requesting a project of
+// the flavor, or an error if an error occurred. The MaxProjectsAnnotation of the user overrides the
+// configured limits.
+func (o *projectRequestLimit) maxProjectsByRequester(ctx context.Context, userInfo user.Info, flavor string) (requesterLimit, error) {
+	userName := userInfo.GetName()
 	// service accounts have a different ruleset, check them
 	if _, _, err := serviceaccount.SplitUsername(userName); err == nil {
 		if o.config.MaxProjectsForServiceAccounts == nil {
-			return 0, false, nil
+			return requesterLimit{}, nil
 		}
 
-		return *o.config.MaxProjectsForServiceAccounts, true, nil
+		return requesterLimit{maxProjects: *o.config.MaxProjectsForServiceAccounts, limited: true, source: "maxProjectsForServiceAccounts"}, nil
 	}
 
 	// if we aren't a valid username, we came in as cert user for certain, use our cert user rules
 	if reasons := uservalidation.ValidateUserName(userName, false); len(reasons) != 0 {
 		if o.config.MaxProjectsForSystemUsers == nil {
-			return 0, false, nil
+			return requesterLimit{}, nil
 		}
 
-		return *o.config.MaxProjectsForSystemUsers, true, nil
+		return requesterLimit{maxProjects: *o.config.MaxProjectsForSystemUsers, limited: true, source: "maxProjectsForSystemUsers"}, nil
 	}
 
 	// prevent a user lookup if no limits are configured
 	if len(o.config.Limits) == 0 {
-		return 0, false, nil
+		return requesterLimit{}, nil
 	}
 
+	// a user without a User object has no labels and no override
 	user, err := o.userClient.Users().Get(ctx, userName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		user, err = &userv1.User{}, nil
+	}
 	if err != nil {
-		return 0, false, err
+		return requesterLimit{}, err
+	}
+	if value, ok := user.Annotations[MaxProjectsAnnotation]; ok {
+		maxProjects, err := strconv.Atoi(value)
+		if err == nil && maxProjects >= 0 {
+			return requesterLimit{maxProjects: maxProjects, limited: true, source: fmt.Sprintf("the %s annotation of user %s", MaxProjectsAnnotation, userName)}, nil
+		}
+		klog.Warningf("ignoring the invalid %s annotation of user %s: %q", MaxProjectsAnnotation, userName, value)
 	}
 	userLabels := labels.Set(user.Labels)
+	userGroups := sets.New(userInfo.GetGroups()...)
 
-	for _, limit := range o.config.Limits {
+	for i, limit := range o.config.Limits {
 		selector := labels.Set(limit.Selector).AsSelector()
-		if selector.Matches(userLabels) {
-			if limit.MaxProjects == nil {
-				return 0, false, nil
-			}
-			return *limit.MaxProjects, true, nil
+		if !selector.Matches(userLabels) {
+			continue
+		}
+		if len(limit.Groups) > 0 && !userGroups.HasAny(limit.Groups...) {
+			continue
+		}
+		if len(limit.Flavors) > 0 && !sets.New(limit.Flavors...).Has(flavor) {
+			continue
 		}
+		if limit.MaxProjects == nil {
+			return requesterLimit{}, nil
+		}
+		return requesterLimit{maxProjects: *limit.MaxProjects, limited: true, source: fmt.Sprintf("limits[%d]", i), flavors: sets.New(limit.Flavors...)}, nil
 	}
-	return 0, false, nil
+	return requesterLimit{}, nil
 }
 
-func (o *projectRequestLimit) projectCountByRequester(userName string) (int, error) {
+// projectCountByRequester returns the number of projects of the user of one of flavors, of any
+// flavor if flavors is empty.
+func (o *projectRequestLimit) projectCountByRequester(userName string, flavors sets.Set[string]) (int, error) {
 	// our biggest clusters have less than 10k namespaces.  project requests are infrequent.  This is iterating on an
 	// in memory set of pointers.  I can live with all this to avoid a secondary cache.
 	allNamespaces, err := o.nsLister.List(labels.Everything())
@@ -171,9 +241,13 @@ func (o *projectRequestLimit) projectCountByRequester(userName string) (int, err
 	namespaces := []*corev1.Namespace{}
 	for i := range allNamespaces {
 		ns := allNamespaces[i]
-		if ns.Annotations[projectapi.ProjectRequester] == userName {
-			namespaces = append(namespaces, ns)
+		if ns.Annotations[projectapi.ProjectRequester] != userName {
+			continue
+		}
+		if len(flavors) > 0 && !flavors.Has(ns.Annotations[projectapi.ProjectTemplateFlavor]) {
+			continue
 		}
+		namespaces = append(namespaces, ns)
 	}
 
 	terminatingCount := 0
@@ -3,14 +3,18 @@ package requestlimit
 import (
 	"bytes"
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/admission"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/storage/names"
+	"k8s.io/apiserver/pkg/warning"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	clientgotesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
@@ -148,14 +152,14 @@ func TestMaxProjectByRequester(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
-		user := fakeUser("testuser", tc.userLabels)
-		client := fakeuserclient.NewSimpleClientset(user)
+		client := fakeuserclient.NewSimpleClientset(fakeUser("testuser", tc.userLabels))
 		reqLimit.(*projectRequestLimit).userClient = client.UserV1()
 
-		maxProjects, hasLimit, err := reqLimit.(*projectRequestLimit).maxProjectsByRequester(context.TODO(), "testuser")
+		limit, err := reqLimit.(*projectRequestLimit).maxProjectsByRequester(context.TODO(), &user.DefaultInfo{Name: "testuser"}, "")
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
+		maxProjects, hasLimit := limit.maxProjects, limit.limited
 
 		if tc.expectUnlimited {
 
@@ -174,6 +178,179 @@ func TestMaxProjectByRequester(t *testing.T) {
 	}
 }
 
+func TestMaxProjectsByGroupAndFlavor(t *testing.T) {
+	config := &requestlimitapi.ProjectRequestLimitConfig{
+		Limits: []requestlimitapi.ProjectLimitBySelector{
+			{Groups: []string{"ml-team"}, Flavors: []string{"gpu"}, MaxProjects: intp(2)},
+			{Flavors: []string{"gpu"}, MaxProjects: intp(0)},
+			{Groups: []string{"platform", "sre"}, MaxProjects: nil},
+			{Selector: map[string]string{}, MaxProjects: intp(1)},
+		},
+	}
+	tests := []struct {
+		name            string
+		groups          []string
+		annotations     map[string]string
+		flavor          string
+		expectUnlimited bool
+		expectedLimit   int
+		expectedSource  string
+	}{
+		{name: "gpu flavor for group member", groups: []string{"ml-team"}, flavor: "gpu", expectedLimit: 2, expectedSource: "limits[0]"},
+		{name: "gpu flavor for others", flavor: "gpu", expectedLimit: 0, expectedSource: "limits[1]"},
+		{name: "any flavor for group member", groups: []string{"ml-team"}, expectedLimit: 1, expectedSource: "limits[3]"},
+		{name: "unlimited group", groups: []string{"sre"}, expectUnlimited: true},
+		{name: "override", annotations: map[string]string{MaxProjectsAnnotation: "5"}, flavor: "gpu", expectedLimit: 5, expectedSource: "the project.openshift.io/max-projects annotation of user testuser"},
+		{name: "invalid override", annotations: map[string]string{MaxProjectsAnnotation: "many"}, expectedLimit: 1, expectedSource: "limits[3]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reqLimit, err := NewProjectRequestLimit(config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			u := fakeUser("testuser", nil)
+			u.Annotations = tc.annotations
+			reqLimit.(*projectRequestLimit).userClient = fakeuserclient.NewSimpleClientset(u).UserV1()
+
+			limit, err := reqLimit.(*projectRequestLimit).maxProjectsByRequester(context.TODO(), &user.DefaultInfo{Name: "testuser", Groups: tc.groups}, tc.flavor)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if limit.limited == tc.expectUnlimited {
+				t.Fatalf("Expected unlimited: %t, got limit %#v", tc.expectUnlimited, limit)
+			}
+			if limit.limited && (limit.maxProjects != tc.expectedLimit || limit.source != tc.expectedSource) {
+				t.Errorf("Expected limit %d from %s, got %d from %s", tc.expectedLimit, tc.expectedSource, limit.maxProjects, limit.source)
+			}
+		})
+	}
+}
+
+func TestMaxProjectsWithoutUser(t *testing.T) {
+	reqLimit, err := NewProjectRequestLimit(multiLevelConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reqLimit.(*projectRequestLimit).userClient = fakeuserclient.NewSimpleClientset().UserV1()
+	limit, err := reqLimit.(*projectRequestLimit).maxProjectsByRequester(context.TODO(), &user.DefaultInfo{Name: "testuser"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !limit.limited || limit.maxProjects != 1 || limit.source != "limits[4]" {
+		t.Errorf("Expected the limit of a user without labels, got %#v", limit)
+	}
+
+	// the user is not looked up without limits
+	client := fakeuserclient.NewSimpleClientset()
+	client.PrependReactor("get", "users", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unexpected user lookup")
+	})
+	reqLimit, err = NewProjectRequestLimit(emptyConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reqLimit.(*projectRequestLimit).userClient = client.UserV1()
+	limit, err = reqLimit.(*projectRequestLimit).maxProjectsByRequester(context.TODO(), &user.DefaultInfo{Name: "testuser"}, "")
+	if err != nil || limit.limited {
+		t.Errorf("Expected no limit without limits, got %#v: %v", limit, err)
+	}
+}
+
+func TestProjectCountByFlavor(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, flavor := range []string{"gpu", "gpu", "small", ""} {
+		ns := fakeNs("user1", false)
+		if len(flavor) > 0 {
+			ns.Annotations[projectapi.ProjectTemplateFlavor] = flavor
+		}
+		indexer.Add(ns)
+	}
+	reqLimit := &projectRequestLimit{nsLister: corev1listers.NewNamespaceLister(indexer)}
+	for flavors, expected := range map[string]int{"gpu": 2, "gpu,small": 3, "": 4} {
+		var flavorSet sets.Set[string]
+		if len(flavors) > 0 {
+			flavorSet = sets.New(strings.Split(flavors, ",")...)
+		}
+		actual, err := reqLimit.projectCountByRequester("user1", flavorSet)
+		if err != nil {
+			t.Errorf("unexpected: %v", err)
+		}
+		if actual != expected {
+			t.Errorf("flavors %q got %d, expected %d", flavors, actual, expected)
+		}
+	}
+}
+
+type warningRecorder []string
+
+func (r *warningRecorder) AddWarning(agent, text string) {
+	*r = append(*r, text)
+}
+
+func TestDryRun(t *testing.T) {
+	reqLimit, err := NewProjectRequestLimit(multiLevelConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := fakeuserclient.NewSimpleClientset()
+	client.PrependReactor("get", "users", userFn(map[string]labels.Set{"user1": {"silver": "yes"}}))
+	reqLimit.(*projectRequestLimit).userClient = client.UserV1()
+	reqLimit.(*projectRequestLimit).nsLister = fakeNamespaceLister(map[string]projectCount{"user1": {1, 0}})
+	reqLimit.(*projectRequestLimit).nsListerSynced = func() bool { return true }
+
+	recorder := &warningRecorder{}
+	err = reqLimit.(admission.ValidationInterface).Validate(warning.WithWarningRecorder(context.TODO(), recorder), admission.NewAttributesRecord(
+		&projectapi.ProjectRequest{},
+		nil,
+		project.Kind("ProjectRequest").WithVersion("version"),
+		"foo",
+		"name",
+		project.Resource("projectrequests").WithVersion("version"),
+		"",
+		"CREATE",
+		nil,
+		true,
+		&user.DefaultInfo{Name: "user1"}), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"user user1 may create 2 more project(s), as limited by limits[2]"}; len(*recorder) != 1 || (*recorder)[0] != expected[0] {
+		t.Errorf("Expected warnings %v, got %v", expected, *recorder)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	userInfo := &user.DefaultInfo{Name: "user1"}
+	var unset *Limits
+	if err := unset.Check(context.TODO(), userInfo, "", []string{"", "", ""}); err != nil {
+		t.Errorf("Expected no limits without the limits, got %v", err)
+	}
+	limits := NewLimits()
+	if err := limits.Check(context.TODO(), userInfo, "", []string{"", "", ""}); err != nil {
+		t.Errorf("Expected no limits without the plugin, got %v", err)
+	}
+
+	reqLimit, err := NewProjectRequestLimit(multiLevelConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := fakeuserclient.NewSimpleClientset()
+	client.PrependReactor("get", "users", userFn(map[string]labels.Set{"user1": {"silver": "yes"}}))
+	reqLimit.(*projectRequestLimit).userClient = client.UserV1()
+	reqLimit.(*projectRequestLimit).nsLister = fakeNamespaceLister(map[string]projectCount{"user1": {1, 0}})
+	reqLimit.(*projectRequestLimit).nsListerSynced = func() bool { return true }
+	NewInitializer(limits).Initialize(reqLimit)
+
+	// user1 has a project and may have 3
+	if err := limits.Check(context.TODO(), userInfo, "", []string{"small"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := limits.Check(context.TODO(), userInfo, "", []string{"small", "gpu"}); !apierrors.IsForbidden(err) {
+		t.Errorf("Expected the pending project requests to count towards the limit, got %v", err)
+	}
+}
+
 func TestProjectCountByRequester(t *testing.T) {
 	nsLister := fakeNamespaceLister(map[string]projectCount{
 		"user1": {1, 5}, // total 6, expect 4
@@ -203,7 +380,7 @@ func TestProjectCountByRequester(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		actual, err := reqLimit.projectCountByRequester(test.user)
+		actual, err := reqLimit.projectCountByRequester(test.user, nil)
 		if err != nil {
 			t.Errorf("unexpected: %v", err)
 		}
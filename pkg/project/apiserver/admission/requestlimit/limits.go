@@ -0,0 +1,72 @@
+package requestlimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	"github.com/openshift/api/project"
+)
+
+// Limits checks the project requests kept until they are approved against the limits of the
+// ProjectRequestLimit plugin, which are only enforced by admission when the project is requested.
+// Every request is allowed while the plugin is not enabled.
+type Limits struct {
+	lock   sync.RWMutex
+	plugin *projectRequestLimit
+}
+
+// NewLimits returns the Limits of the ProjectRequestLimit plugin initialized by NewInitializer.
+func NewLimits() *Limits {
+	return &Limits{}
+}
+
+// Check returns a Forbidden error if the user may not have another project of the flavor in
+// addition to their project requests pending approval of pendingFlavors.
+func (l *Limits) Check(ctx context.Context, userInfo user.Info, flavor string, pendingFlavors []string) error {
+	if l == nil {
+		return nil
+	}
+	l.lock.RLock()
+	plugin := l.plugin
+	l.lock.RUnlock()
+	if plugin == nil || plugin.config == nil {
+		return nil
+	}
+	if !plugin.waitForSyncedStore(time.After(timeToWaitForCacheSync)) {
+		return apierrors.NewForbidden(project.Resource("projectrequests"), "", errors.New("project.openshift.io/ProjectRequestLimit: caches not synchronized"))
+	}
+	limit, projectCount, err := plugin.check(ctx, userInfo, flavor, pendingFlavors)
+	if err != nil {
+		return err
+	}
+	if limit.limited && projectCount >= limit.maxProjects {
+		return apierrors.NewForbidden(project.Resource("projectrequests"), "", fmt.Errorf("user %s cannot have more than %d project(s) and project request(s) pending approval, as limited by %s", userInfo.GetName(), limit.maxProjects, limit.source))
+	}
+	return nil
+}
+
+// NewInitializer returns an admission plugin initializer giving the limits the configured
+// ProjectRequestLimit plugin.
+func NewInitializer(limits *Limits) admission.PluginInitializer {
+	return &limitsInitializer{limits: limits}
+}
+
+type limitsInitializer struct {
+	limits *Limits
+}
+
+// Initialize gives the limits the ProjectRequestLimit plugin.
+func (i *limitsInitializer) Initialize(plugin admission.Interface) {
+	if plugin, ok := plugin.(*projectRequestLimit); ok {
+		i.limits.lock.Lock()
+		defer i.limits.lock.Unlock()
+		i.limits.plugin = plugin
+	}
+}
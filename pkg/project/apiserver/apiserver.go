@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -11,6 +12,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	"k8s.io/client-go/dynamic"
@@ -27,13 +29,25 @@ import (
 	projectcache "github.com/openshift/openshift-apiserver/pkg/project/cache"
 )
 
+// deniedProjectRequestsPruneInterval is how often the project requests denied for longer than
+// their retention are deleted.
+const deniedProjectRequestsPruneInterval = time.Hour
+
 type ExtraConfig struct {
 	KubeAPIServerClientConfig *restclient.Config
 	ProjectAuthorizationCache *projectauth.AuthorizationCache
 	ProjectCache              *projectcache.ProjectCache
 	ProjectRequestTemplate    string
 	ProjectRequestMessage     string
-	RESTMapper                meta.RESTMapper
+	// ProjectRequestTemplateFlavors are the <namespace>/<name> of the templates of the project
+	// flavors, by flavor name.
+	ProjectRequestTemplateFlavors map[string]string
+	// ProjectRequestApprovalNamespace is the namespace the project requests pending approval are
+	// kept in, if project requests must be approved.
+	ProjectRequestApprovalNamespace string
+	// ProjectRequestLimits are the limits the project requests pending approval count towards.
+	ProjectRequestLimits projectrequeststorage.ProjectRequestLimits
+	RESTMapper           meta.RESTMapper
 
 	// TODO these should all become local eventually
 	Scheme *runtime.Scheme
@@ -42,6 +56,7 @@ type ExtraConfig struct {
 	makeV1Storage sync.Once
 	v1Storage     map[string]rest.Storage
 	v1StorageErr  error
+	startFns      []func(<-chan struct{})
 }
 
 type ProjectAPIServerConfig struct {
@@ -96,6 +111,17 @@ func (c completedConfig) New(delegationTarget genericapiserver.DelegationTarget)
 		return nil, err
 	}
 
+	if len(c.ExtraConfig.startFns) > 0 {
+		if err := s.GenericAPIServer.AddPostStartHook("project.openshift.io-deniedprojectrequests", func(context genericapiserver.PostStartHookContext) error {
+			for _, fn := range c.ExtraConfig.startFns {
+				go fn(context.Done())
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
 	return s, nil
 }
 
@@ -136,11 +162,24 @@ func (c *completedConfig) newV1RESTStorage() (map[string]rest.Storage, error) {
 		klog.Errorf("Error parsing project request template value: %v", err)
 		// we can continue on, the storage that gets created will be valid, it simply won't work properly.  There's no reason to kill the master
 	}
+	flavors := []projectrequeststorage.TemplateFlavor{}
+	for name, template := range c.ExtraConfig.ProjectRequestTemplateFlavors {
+		flavorNamespace, flavorTemplateName, err := parseNamespaceAndName(template)
+		if err != nil || len(flavorTemplateName) == 0 {
+			klog.Errorf("Error parsing the template of project request flavor %q: %v", name, err)
+			continue
+		}
+		flavors = append(flavors, projectrequeststorage.TemplateFlavor{Name: name, Namespace: flavorNamespace, TemplateName: flavorTemplateName})
+	}
 
 	projectRequestStorage := projectrequeststorage.NewREST(
 		c.ExtraConfig.ProjectRequestMessage,
 		namespace, templateName,
+		flavors,
+		c.ExtraConfig.ProjectRequestApprovalNamespace,
+		c.ExtraConfig.ProjectRequestLimits,
 		projectClient.ProjectV1(),
+		kubeClient.CoreV1(),
 		templateClient,
 		authorizationClient.SubjectAccessReviews(),
 		dynamicClient,
@@ -151,6 +190,12 @@ func (c *completedConfig) newV1RESTStorage() (map[string]rest.Storage, error) {
 	v1Storage := map[string]rest.Storage{}
 	v1Storage["projects"] = projectStorage
 	v1Storage["projectrequests"] = projectRequestStorage
+	if len(c.ExtraConfig.ProjectRequestApprovalNamespace) > 0 {
+		v1Storage["projectrequests/approval"] = projectrequeststorage.NewApprovalREST(projectRequestStorage)
+		c.ExtraConfig.startFns = append(c.ExtraConfig.startFns, func(stopCh <-chan struct{}) {
+			wait.JitterUntilWithContext(wait.ContextForChannel(stopCh), projectRequestStorage.PruneDeniedRequests, deniedProjectRequestsPruneInterval, 0.1, true)
+		})
+	}
 	return v1Storage, nil
 }
 
@@ -0,0 +1,450 @@
+package delegated
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/api/project"
+	projectv1 "github.com/openshift/api/project/v1"
+	"github.com/openshift/library-go/pkg/authorization/authorizationutil"
+	projectapi "github.com/openshift/openshift-apiserver/pkg/project/apis/project"
+)
+
+const (
+	// approveVerb is the verb on projectrequests a user needs to approve or deny the request of a
+	// project, the resource name being the name of the project.
+	approveVerb = "approve"
+
+	// ProjectRequestStateLabel is set on the config maps of the project requests to the state of
+	// the request.
+	ProjectRequestStateLabel = "project.openshift.io/request-state"
+	// ProjectRequestDecidedByAnnotation is set on the config map of a denied project request to the
+	// name of the user who denied it.
+	ProjectRequestDecidedByAnnotation = "project.openshift.io/request-decided-by"
+	// ProjectRequestReasonAnnotation is set on the config map of a denied project request to the
+	// reason it was denied for.
+	ProjectRequestReasonAnnotation = "project.openshift.io/request-reason"
+	// ProjectRequestDeniedAtAnnotation is set on the config map of a denied project request to the
+	// RFC 3339 time it was denied at.
+	ProjectRequestDeniedAtAnnotation = "project.openshift.io/request-denied-at"
+	// projectRequestKey is the key of the config map of a project request holding the request.
+	projectRequestKey = "request"
+
+	// PendingProjectRequestCause is the type of the causes describing the project requests of the
+	// caller pending approval. The field of the cause is the name of the project.
+	PendingProjectRequestCause metav1.CauseType = "PendingProjectRequest"
+	// DeniedProjectRequestCause is the type of the causes describing the denied project requests of
+	// the caller. The field of the cause is the name of the project, and its message the reason the
+	// request was denied for.
+	DeniedProjectRequestCause metav1.CauseType = "DeniedProjectRequest"
+
+	// maxApprovalLength is the maximum length of a project request approval.
+	maxApprovalLength = 16 * 1024
+	// maxDenialReasonLength is the maximum length of the reason a project request is denied for.
+	maxDenialReasonLength = 1024
+	// maxPendingProjectRequests is the maximum number of project requests of a user pending
+	// approval.
+	maxPendingProjectRequests = 5
+	// deniedProjectRequestRetention is how long denied project requests are kept to tell their
+	// requesters why they were denied.
+	deniedProjectRequestRetention = 7 * 24 * time.Hour
+)
+
+// ProjectRequestState is the state of a project request needing approval.
+type ProjectRequestState string
+
+const (
+	// ProjectRequestPending is the state of a project request waiting for approval.
+	ProjectRequestPending ProjectRequestState = "Pending"
+	// ProjectRequestDenied is the state of a project request an approver denied.
+	ProjectRequestDenied ProjectRequestState = "Denied"
+)
+
+// ProjectRequestLimits checks the number of projects users may request.
+type ProjectRequestLimits interface {
+	// Check returns a Forbidden error if the user may not have another project of the flavor in
+	// addition to their project requests pending approval of pendingFlavors.
+	Check(ctx context.Context, userInfo user.Info, flavor string, pendingFlavors []string) error
+}
+
+// pendingProjectRequest is a project request kept in a config map until it is approved, with the
+// user who requested the project.
+type pendingProjectRequest struct {
+	DisplayName string              `json:"displayName,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Annotations map[string]string   `json:"annotations,omitempty"`
+	User        string              `json:"user"`
+	UID         string              `json:"uid,omitempty"`
+	Groups      []string            `json:"groups,omitempty"`
+	Extra       map[string][]string `json:"extra,omitempty"`
+}
+
+// ProjectRequestApproval is the body of a POST to the approval subresource of projectrequests.
+type ProjectRequestApproval struct {
+	// Approved is true to approve the request and provision the project, false to deny it.
+	Approved bool `json:"approved"`
+	// Reason is the reason the request is denied for.
+	Reason string `json:"reason,omitempty"`
+}
+
+// requestApproval keeps the project request in a config map of the approval namespace until an
+// approver approves it. The projects requested by users who may approve them are provisioned
+// without approval. A dry run validates the request without keeping it.
+func (r *REST) requestApproval(ctx context.Context, projectRequest *projectapi.ProjectRequest, userInfo user.Info, dryRun bool) (runtime.Object, error) {
+	if userInfo == nil {
+		return nil, apierror.NewForbidden(project.Resource("projectrequest"), projectRequest.Name, fmt.Errorf("a user must be provided"))
+	}
+	approver, err := r.approvalAllowed(ctx, userInfo, projectRequest.Name)
+	if err != nil {
+		return nil, err
+	}
+	if approver {
+		return r.createProject(ctx, projectRequest, userInfo, dryRun)
+	}
+	// validate the flavor and the parameters of the request before it waits for approval
+	if _, _, err := r.resolveTemplate(ctx, projectRequest, userInfo); err != nil {
+		return nil, err
+	}
+	// the project requests pending approval count towards the limits of the user
+	pendingFlavors, err := r.pendingFlavors(ctx, userInfo.GetName(), projectRequest.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(pendingFlavors) >= maxPendingProjectRequests {
+		return nil, apierror.NewForbidden(project.Resource("projectrequest"), projectRequest.Name, fmt.Errorf("user %s cannot have more than %d project requests pending approval", userInfo.GetName(), maxPendingProjectRequests))
+	}
+	if err := r.checkLimits(ctx, userInfo, projectRequest, pendingFlavors); err != nil {
+		return nil, err
+	}
+	status := &metav1.Status{
+		Status:  metav1.StatusSuccess,
+		Code:    http.StatusAccepted,
+		Message: fmt.Sprintf("the request of project %q is pending approval", projectRequest.Name),
+		Details: &metav1.StatusDetails{
+			Name:   projectRequest.Name,
+			Group:  project.GroupName,
+			Kind:   "projectrequests",
+			Causes: []metav1.StatusCause{{Type: PendingProjectRequestCause, Field: projectRequest.Name}},
+		},
+	}
+	if dryRun {
+		return status, nil
+	}
+
+	data, err := json.Marshal(&pendingProjectRequest{
+		DisplayName: projectRequest.DisplayName,
+		Description: projectRequest.Description,
+		Annotations: projectRequest.Annotations,
+		User:        userInfo.GetName(),
+		UID:         userInfo.GetUID(),
+		Groups:      userInfo.GetGroups(),
+		Extra:       userInfo.GetExtra(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        projectRequest.Name,
+			Namespace:   r.approvalNamespace,
+			Labels:      map[string]string{ProjectRequestStateLabel: string(ProjectRequestPending)},
+			Annotations: map[string]string{projectapi.ProjectRequester: userInfo.GetName()},
+		},
+		Data: map[string]string{projectRequestKey: string(data)},
+	}
+	configMaps := r.configMaps.ConfigMaps(r.approvalNamespace)
+	_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+	if apierror.IsAlreadyExists(err) {
+		// a denied request may be requested again
+		existing, getErr := configMaps.Get(ctx, projectRequest.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return nil, getErr
+		}
+		if existing.Labels[ProjectRequestStateLabel] != string(ProjectRequestDenied) {
+			return nil, apierror.NewAlreadyExists(project.Resource("projectrequest"), projectRequest.Name)
+		}
+		configMap.ResourceVersion = existing.ResourceVersion
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// requestedProjects returns the causes describing the project requests of the user pending
+// approval or denied.
+func (r *REST) requestedProjects(ctx context.Context, userInfo user.Info) ([]metav1.StatusCause, error) {
+	if len(r.approvalNamespace) == 0 {
+		return nil, nil
+	}
+	configMaps, err := r.configMaps.ConfigMaps(r.approvalNamespace).List(ctx, metav1.ListOptions{LabelSelector: ProjectRequestStateLabel})
+	if err != nil {
+		return nil, err
+	}
+	var causes []metav1.StatusCause
+	expiry := time.Now().Add(-deniedProjectRequestRetention)
+	for _, configMap := range configMaps.Items {
+		if configMap.Annotations[projectapi.ProjectRequester] != userInfo.GetName() || deniedBefore(&configMap, expiry) {
+			continue
+		}
+		switch ProjectRequestState(configMap.Labels[ProjectRequestStateLabel]) {
+		case ProjectRequestPending:
+			causes = append(causes, metav1.StatusCause{Type: PendingProjectRequestCause, Field: configMap.Name})
+		case ProjectRequestDenied:
+			causes = append(causes, metav1.StatusCause{Type: DeniedProjectRequestCause, Field: configMap.Name, Message: configMap.Annotations[ProjectRequestReasonAnnotation]})
+		}
+	}
+	return causes, nil
+}
+
+// pendingFlavors returns the flavors of the project requests of the user pending approval, but the
+// request of the project name.
+func (r *REST) pendingFlavors(ctx context.Context, userName, name string) ([]string, error) {
+	configMaps, err := r.configMaps.ConfigMaps(r.approvalNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{ProjectRequestStateLabel: string(ProjectRequestPending)}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	flavors := []string{}
+	for _, configMap := range configMaps.Items {
+		if configMap.Name == name || configMap.Annotations[projectapi.ProjectRequester] != userName {
+			continue
+		}
+		pending := &pendingProjectRequest{}
+		if err := json.Unmarshal([]byte(configMap.Data[projectRequestKey]), pending); err != nil {
+			klog.V(4).Infof("the request of project %q cannot be read: %v", configMap.Name, err)
+		}
+		flavors = append(flavors, pending.Annotations[projectapi.ProjectTemplateFlavor])
+	}
+	return flavors, nil
+}
+
+// checkLimits returns a Forbidden error if the project request exceeds the limits of the user, with
+// their project requests pending approval of pendingFlavors.
+func (r *REST) checkLimits(ctx context.Context, userInfo user.Info, projectRequest *projectapi.ProjectRequest, pendingFlavors []string) error {
+	if r.limits == nil {
+		return nil
+	}
+	return r.limits.Check(ctx, userInfo, projectRequest.Annotations[projectapi.ProjectTemplateFlavor], pendingFlavors)
+}
+
+// PruneDeniedRequests deletes the denied project requests kept longer than their retention.
+func (r *REST) PruneDeniedRequests(ctx context.Context) {
+	configMaps := r.configMaps.ConfigMaps(r.approvalNamespace)
+	list, err := configMaps.List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{ProjectRequestStateLabel: string(ProjectRequestDenied)}.String(),
+	})
+	if err != nil {
+		klog.V(2).Infof("unable to list the denied project requests: %v", err)
+		return
+	}
+	expiry := time.Now().Add(-deniedProjectRequestRetention)
+	for i := range list.Items {
+		configMap := &list.Items[i]
+		if !deniedBefore(configMap, expiry) {
+			continue
+		}
+		// the project may have been requested again since it was listed
+		err := configMaps.Delete(ctx, configMap.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &configMap.UID, ResourceVersion: &configMap.ResourceVersion}})
+		if err != nil && !apierror.IsNotFound(err) && !apierror.IsConflict(err) {
+			klog.V(2).Infof("unable to delete the denied request of project %q: %v", configMap.Name, err)
+		}
+	}
+}
+
+// deniedBefore returns true if the config map keeps a project request denied before expiry. The
+// requests denied without the time they were denied at expire with their creation time.
+func deniedBefore(configMap *corev1.ConfigMap, expiry time.Time) bool {
+	if configMap.Labels[ProjectRequestStateLabel] != string(ProjectRequestDenied) {
+		return false
+	}
+	deniedAt := configMap.CreationTimestamp.Time
+	if value, ok := configMap.Annotations[ProjectRequestDeniedAtAnnotation]; ok {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			deniedAt = t
+		}
+	}
+	return deniedAt.Before(expiry)
+}
+
+// approvalAllowed returns true if the user may approve the request of the project name.
+func (r *REST) approvalAllowed(ctx context.Context, userInfo user.Info, name string) (bool, error) {
+	accessReview := authorizationutil.AddUserToSAR(userInfo, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     approveVerb,
+				Group:    projectv1.GroupName,
+				Resource: "projectrequests",
+				Name:     name,
+			},
+		},
+	})
+	resp, err := r.sarClient.Create(ctx, accessReview, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return resp.Status.Allowed, nil
+}
+
+// ApprovalREST implements the approval subresource of projectrequests, which approves or denies
+// a project request pending approval.
+type ApprovalREST struct {
+	projectRequests *REST
+}
+
+var _ = rest.Connecter(&ApprovalREST{})
+var _ = rest.StorageMetadata(&ApprovalREST{})
+
+// NewApprovalREST returns the approval subresource of the project requests of projectRequests.
+func NewApprovalREST(projectRequests *REST) *ApprovalREST {
+	return &ApprovalREST{projectRequests: projectRequests}
+}
+
+// New returns a new ProjectRequest
+func (r *ApprovalREST) New() runtime.Object {
+	return &projectapi.ProjectRequest{}
+}
+
+func (r *ApprovalREST) Destroy() {}
+
+// Connect returns a handler approving or denying the request of the project name.
+func (r *ApprovalREST) Connect(ctx context.Context, name string, options runtime.Object, responder rest.Responder) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		approval := &ProjectRequestApproval{}
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxApprovalLength+1))
+		if err != nil {
+			responder.Error(apierror.NewBadRequest(err.Error()))
+			return
+		}
+		if len(body) > maxApprovalLength {
+			responder.Error(apierror.NewRequestEntityTooLargeError(fmt.Sprintf("limit is %d", maxApprovalLength)))
+			return
+		}
+		if err := json.Unmarshal(body, approval); err != nil {
+			responder.Error(apierror.NewBadRequest(fmt.Sprintf("the approval of project request %q is invalid: %v", name, err)))
+			return
+		}
+		obj, err := r.projectRequests.decide(ctx, name, approval)
+		if err != nil {
+			responder.Error(err)
+			return
+		}
+		responder.Object(http.StatusOK, obj)
+	}), nil
+}
+
+// NewConnectOptions returns nil, the approval is the body of the request.
+func (r *ApprovalREST) NewConnectOptions() (runtime.Object, bool, string) {
+	return nil, false, ""
+}
+
+// ConnectMethods returns POST, the only method the approval subresource supports.
+func (r *ApprovalREST) ConnectMethods() []string {
+	return []string{http.MethodPost}
+}
+
+// ProducesMIMETypes returns the MIME types of the responses of the approval subresource.
+func (r *ApprovalREST) ProducesMIMETypes(verb string) []string {
+	return []string{"application/json"}
+}
+
+// ProducesObject returns the object of the responses of the approval subresource.
+func (r *ApprovalREST) ProducesObject(verb string) interface{} {
+	return projectv1.Project{}
+}
+
+// decide approves or denies the request of the project name. An approved request is provisioned
+// for the user who requested the project, and stays pending if it cannot be.
+func (r *REST) decide(ctx context.Context, name string, approval *ProjectRequestApproval) (runtime.Object, error) {
+	userInfo, ok := apirequest.UserFrom(ctx)
+	if !ok {
+		return nil, apierror.NewForbidden(project.Resource("projectrequest"), name, fmt.Errorf("a user must be provided"))
+	}
+	allowed, err := r.approvalAllowed(ctx, userInfo, name)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, apierror.NewForbidden(project.Resource("projectrequest"), name, fmt.Errorf("you may not approve the request of project %q", name))
+	}
+
+	configMaps := r.configMaps.ConfigMaps(r.approvalNamespace)
+	configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if apierror.IsNotFound(err) || (err == nil && !labels.Set(configMap.Labels).Has(ProjectRequestStateLabel)) {
+		return nil, apierror.NewNotFound(project.Resource("projectrequest"), name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if state := ProjectRequestState(configMap.Labels[ProjectRequestStateLabel]); state != ProjectRequestPending {
+		return nil, apierror.NewConflict(project.Resource("projectrequest"), name, fmt.Errorf("the request of project %q is %s", name, state))
+	}
+
+	if !approval.Approved {
+		if len(approval.Reason) > maxDenialReasonLength {
+			return nil, apierror.NewInvalid(project.Kind("ProjectRequestApproval"), name, field.ErrorList{field.TooLong(field.NewPath("reason"), approval.Reason, maxDenialReasonLength)})
+		}
+		if configMap.Annotations == nil {
+			configMap.Annotations = map[string]string{}
+		}
+		configMap.Labels[ProjectRequestStateLabel] = string(ProjectRequestDenied)
+		configMap.Annotations[ProjectRequestDecidedByAnnotation] = userInfo.GetName()
+		configMap.Annotations[ProjectRequestReasonAnnotation] = approval.Reason
+		configMap.Annotations[ProjectRequestDeniedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		if _, err := configMaps.Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+			return nil, err
+		}
+		return &metav1.Status{
+			Status:  metav1.StatusSuccess,
+			Code:    http.StatusOK,
+			Message: fmt.Sprintf("the request of project %q was denied", name),
+			Details: &metav1.StatusDetails{Name: name, Group: project.GroupName, Kind: "projectrequests"},
+		}, nil
+	}
+
+	pending := &pendingProjectRequest{}
+	if err := json.Unmarshal([]byte(configMap.Data[projectRequestKey]), pending); err != nil {
+		return nil, apierror.NewInternalError(fmt.Errorf("the request of project %q cannot be read: %v", name, err))
+	}
+	requester := &user.DefaultInfo{Name: pending.User, UID: pending.UID, Groups: pending.Groups, Extra: pending.Extra}
+	projectRequest := &projectapi.ProjectRequest{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Annotations: pending.Annotations},
+		DisplayName: pending.DisplayName,
+		Description: pending.Description,
+	}
+	// the limits of the requester may have been reached since the project was requested
+	pendingFlavors, err := r.pendingFlavors(ctx, pending.User, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkLimits(ctx, requester, projectRequest, pendingFlavors); err != nil {
+		return nil, err
+	}
+	createdProject, err := r.createProject(apirequest.WithUser(ctx, requester), projectRequest, requester, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := configMaps.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierror.IsNotFound(err) {
+		utilruntime.HandleError(fmt.Errorf("error deleting the approved request of project %q: %v", name, err))
+	}
+	return createdProject, nil
+}
@@ -0,0 +1,224 @@
+package delegated
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/openshift/api/project"
+	templatev1 "github.com/openshift/api/template/v1"
+	templatefake "github.com/openshift/client-go/template/clientset/versioned/fake"
+	projectapi "github.com/openshift/openshift-apiserver/pkg/project/apis/project"
+)
+
+// newApprovalREST returns a REST keeping the project requests in the namespace approvals, manager
+// may approve them and alice may request projects of the small flavor.
+func newApprovalREST() (*REST, *kubefake.Clientset) {
+	kubeClient := kubefake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		sar := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := sar.Spec.ResourceAttributes
+		switch attrs.Verb {
+		case approveVerb:
+			sar.Status.Allowed = sar.Spec.User == "manager"
+		case flavorVerb:
+			sar.Status.Allowed = attrs.Name == "small"
+		}
+		return true, sar, nil
+	})
+	templateClient := templatefake.NewSimpleClientset(&templatev1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "small-project", Namespace: "templates"},
+	})
+	storage := NewREST("", "", "", []TemplateFlavor{{Name: "small", Namespace: "templates", TemplateName: "small-project"}},
+		"approvals", nil, nil, kubeClient.CoreV1(), templateClient, kubeClient.AuthorizationV1().SubjectAccessReviews(), nil, nil, nil)
+	return storage, kubeClient
+}
+
+func TestRequestApproval(t *testing.T) {
+	storage, kubeClient := newApprovalREST()
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{"developers"}}
+	aliceCtx := apirequest.WithUser(apirequest.NewContext(), alice)
+	managerCtx := apirequest.WithUser(apirequest.NewContext(), &user.DefaultInfo{Name: "manager"})
+	request := &projectapi.ProjectRequest{
+		ObjectMeta:  metav1.ObjectMeta{Name: "payments", Annotations: map[string]string{projectapi.ProjectTemplateFlavor: "small"}},
+		DisplayName: "Payments",
+	}
+
+	if _, err := storage.requestApproval(aliceCtx, request, alice, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubeClient.CoreV1().ConfigMaps("approvals").Get(context.TODO(), "payments", metav1.GetOptions{}); !apierror.IsNotFound(err) {
+		t.Errorf("expected a dry run not to keep the request, got %v", err)
+	}
+
+	obj, err := storage.requestApproval(aliceCtx, request, alice, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := obj.(*metav1.Status); status.Code != http.StatusAccepted || status.Details.Name != "payments" {
+		t.Errorf("unexpected status %#v", status)
+	}
+	configMap, err := kubeClient.CoreV1().ConfigMaps("approvals").Get(context.TODO(), "payments", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configMap.Labels[ProjectRequestStateLabel] != string(ProjectRequestPending) || configMap.Annotations[projectapi.ProjectRequester] != "alice" {
+		t.Errorf("unexpected pending request %#v", configMap)
+	}
+	if _, err := storage.requestApproval(aliceCtx, request, alice, false); !apierror.IsAlreadyExists(err) {
+		t.Errorf("expected a pending request not to be requested again, got %v", err)
+	}
+	causes, err := storage.requestedProjects(aliceCtx, alice)
+	if err != nil || len(causes) != 1 || causes[0].Type != PendingProjectRequestCause || causes[0].Field != "payments" {
+		t.Errorf("expected the pending request of alice to be listed, got %#v: %v", causes, err)
+	}
+
+	if _, err := storage.decide(aliceCtx, "payments", &ProjectRequestApproval{Approved: true}); !apierror.IsForbidden(err) {
+		t.Errorf("expected alice not to be allowed to approve her request, got %v", err)
+	}
+	if _, err := storage.decide(managerCtx, "billing", &ProjectRequestApproval{Approved: true}); !apierror.IsNotFound(err) {
+		t.Errorf("expected a request which does not exist not to be found, got %v", err)
+	}
+
+	// the project cannot be provisioned without the flavor, the request stays pending
+	delete(storage.flavors, "small")
+	if _, err := storage.decide(managerCtx, "payments", &ProjectRequestApproval{Approved: true}); !apierror.IsInvalid(err) {
+		t.Errorf("expected the approval to fail without the flavor, got %v", err)
+	}
+	if configMap, _ := kubeClient.CoreV1().ConfigMaps("approvals").Get(context.TODO(), "payments", metav1.GetOptions{}); configMap.Labels[ProjectRequestStateLabel] != string(ProjectRequestPending) {
+		t.Errorf("expected the request to stay pending, got %#v", configMap)
+	}
+
+	if _, err := storage.decide(managerCtx, "payments", &ProjectRequestApproval{Reason: "use the shared project"}); err != nil {
+		t.Fatal(err)
+	}
+	causes, err = storage.requestedProjects(aliceCtx, alice)
+	if err != nil || len(causes) != 1 || causes[0].Type != DeniedProjectRequestCause || causes[0].Message != "use the shared project" {
+		t.Errorf("expected the denied request of alice to be listed, got %#v: %v", causes, err)
+	}
+	if configMap, _ := kubeClient.CoreV1().ConfigMaps("approvals").Get(context.TODO(), "payments", metav1.GetOptions{}); len(configMap.Annotations[ProjectRequestDeniedAtAnnotation]) == 0 {
+		t.Errorf("expected the time the request was denied at to be kept, got %#v", configMap)
+	}
+	if _, err := storage.decide(managerCtx, "payments", &ProjectRequestApproval{Approved: true}); !apierror.IsConflict(err) {
+		t.Errorf("expected a denied request not to be approved, got %v", err)
+	}
+
+	// a denied request may be requested again
+	storage.flavors["small"] = TemplateFlavor{Name: "small", Namespace: "templates", TemplateName: "small-project"}
+	if _, err := storage.requestApproval(aliceCtx, request, alice, false); err != nil {
+		t.Fatal(err)
+	}
+	if configMap, _ := kubeClient.CoreV1().ConfigMaps("approvals").Get(context.TODO(), "payments", metav1.GetOptions{}); configMap.Labels[ProjectRequestStateLabel] != string(ProjectRequestPending) {
+		t.Errorf("expected the request to be pending again, got %#v", configMap)
+	}
+}
+
+// fakeLimits allows users maxProjects projects, counting their project requests pending approval.
+type fakeLimits struct {
+	maxProjects int
+}
+
+func (l *fakeLimits) Check(ctx context.Context, userInfo user.Info, flavor string, pendingFlavors []string) error {
+	if len(pendingFlavors) >= l.maxProjects {
+		return apierror.NewForbidden(project.Resource("projectrequests"), "", fmt.Errorf("user %s cannot have more than %d project(s)", userInfo.GetName(), l.maxProjects))
+	}
+	return nil
+}
+
+func TestRequestApprovalLimits(t *testing.T) {
+	storage, kubeClient := newApprovalREST()
+	limits := &fakeLimits{maxProjects: 2}
+	storage.limits = limits
+	alice := &user.DefaultInfo{Name: "alice"}
+	aliceCtx := apirequest.WithUser(apirequest.NewContext(), alice)
+	managerCtx := apirequest.WithUser(apirequest.NewContext(), &user.DefaultInfo{Name: "manager"})
+	newRequest := func(name string) *projectapi.ProjectRequest {
+		return &projectapi.ProjectRequest{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{projectapi.ProjectTemplateFlavor: "small"}}}
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := storage.requestApproval(aliceCtx, newRequest(name), alice, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := storage.requestApproval(aliceCtx, newRequest("c"), alice, true); !apierror.IsForbidden(err) {
+		t.Errorf("expected the pending requests to count towards the limits, got %v", err)
+	}
+
+	limits.maxProjects = 10
+	for i := 2; i < maxPendingProjectRequests; i++ {
+		if _, err := storage.requestApproval(aliceCtx, newRequest(fmt.Sprintf("p%d", i)), alice, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := storage.requestApproval(aliceCtx, newRequest("c"), alice, false); !apierror.IsForbidden(err) {
+		t.Errorf("expected at most %d pending requests, got %v", maxPendingProjectRequests, err)
+	}
+
+	// the limits are checked again when the request is approved
+	limits.maxProjects = 1
+	if _, err := storage.decide(managerCtx, "a", &ProjectRequestApproval{Approved: true}); !apierror.IsForbidden(err) {
+		t.Errorf("expected the approval to exceed the limits, got %v", err)
+	}
+	if configMap, _ := kubeClient.CoreV1().ConfigMaps("approvals").Get(context.TODO(), "a", metav1.GetOptions{}); configMap.Labels[ProjectRequestStateLabel] != string(ProjectRequestPending) {
+		t.Errorf("expected the request to stay pending, got %#v", configMap)
+	}
+}
+
+func TestPruneDeniedRequests(t *testing.T) {
+	storage, kubeClient := newApprovalREST()
+	alice := &user.DefaultInfo{Name: "alice"}
+	expired := metav1.NewTime(time.Now().Add(-deniedProjectRequestRetention - time.Hour))
+	newConfigMap := func(name string, state ProjectRequestState, created metav1.Time, deniedAt string) *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "approvals",
+			CreationTimestamp: created,
+			Labels:            map[string]string{ProjectRequestStateLabel: string(state)},
+			Annotations:       map[string]string{projectapi.ProjectRequester: "alice"},
+		}}
+		if len(deniedAt) > 0 {
+			configMap.Annotations[ProjectRequestDeniedAtAnnotation] = deniedAt
+		}
+		return configMap
+	}
+	for _, configMap := range []*corev1.ConfigMap{
+		newConfigMap("expired", ProjectRequestDenied, expired, expired.UTC().Format(time.RFC3339)),
+		newConfigMap("recent", ProjectRequestDenied, expired, time.Now().UTC().Format(time.RFC3339)),
+		newConfigMap("created", ProjectRequestDenied, expired, ""),
+		newConfigMap("pending", ProjectRequestPending, expired, ""),
+	} {
+		if _, err := kubeClient.CoreV1().ConfigMaps("approvals").Create(context.TODO(), configMap, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	causes, err := storage.requestedProjects(context.TODO(), alice)
+	if err != nil || len(causes) != 2 || causes[0].Field != "pending" || causes[1].Field != "recent" {
+		t.Errorf("expected the expired requests not to be listed, got %#v: %v", causes, err)
+	}
+	storage.PruneDeniedRequests(context.TODO())
+	configMaps, err := kubeClient.CoreV1().ConfigMaps("approvals").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remaining []string
+	for _, configMap := range configMaps.Items {
+		remaining = append(remaining, configMap.Name)
+	}
+	if len(remaining) != 2 || remaining[0] != "pending" || remaining[1] != "recent" {
+		t.Errorf("expected the expired denied requests to be deleted, got %v", remaining)
+	}
+}
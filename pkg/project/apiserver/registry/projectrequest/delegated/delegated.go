@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"k8s.io/klog/v2"
 
@@ -22,10 +21,13 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/util/dryrun"
 	"k8s.io/client-go/dynamic"
 	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	rbacv1listers "k8s.io/client-go/listers/rbac/v1"
 	"k8s.io/client-go/util/retry"
 
@@ -45,9 +47,16 @@ type REST struct {
 	message           string
 	templateNamespace string
 	templateName      string
+	flavors           map[string]TemplateFlavor
+	// approvalNamespace is the namespace of the config maps keeping the project requests pending
+	// approval, empty if project requests need no approval.
+	approvalNamespace string
+	// limits are the limits the project requests pending approval count towards.
+	limits ProjectRequestLimits
 
 	sarClient      authorizationclient.SubjectAccessReviewInterface
 	projectGetter  projectv1typedclient.ProjectsGetter
+	configMaps     corev1client.ConfigMapsGetter
 	templateClient templatev1client.Interface
 	client         dynamic.Interface
 	restMapper     meta.RESTMapper
@@ -64,17 +73,29 @@ var _ rest.Storage = &REST{}
 var _ rest.SingularNameProvider = &REST{}
 
 func NewREST(message, templateNamespace, templateName string,
+	flavors []TemplateFlavor,
+	approvalNamespace string,
+	limits ProjectRequestLimits,
 	projectClient projectv1typedclient.ProjectsGetter,
+	configMaps corev1client.ConfigMapsGetter,
 	templateClient templatev1client.Interface,
 	sarClient authorizationclient.SubjectAccessReviewInterface,
 	client dynamic.Interface,
 	restMapper meta.RESTMapper,
 	roleBindings rbacv1listers.RoleBindingLister) *REST {
+	flavorsByName := map[string]TemplateFlavor{}
+	for _, flavor := range flavors {
+		flavorsByName[flavor.Name] = flavor
+	}
 	return &REST{
 		message:           message,
 		templateNamespace: templateNamespace,
 		templateName:      templateName,
+		flavors:           flavorsByName,
+		approvalNamespace: approvalNamespace,
+		limits:            limits,
 		projectGetter:     projectClient,
+		configMaps:        configMaps,
 		templateClient:    templateClient,
 		sarClient:         sarClient,
 		client:            client,
@@ -142,22 +163,59 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		return nil, apierror.NewAlreadyExists(project.Resource("project"), projectRequest.Name)
 	}
 
+	// a dry run processes the template without creating anything, the ProjectRequestLimit admission
+	// plugin warns the user about the number of projects they may still request
+	dryRun := options != nil && dryrun.IsDryRun(options.DryRun)
+	userInfo, _ := apirequest.UserFrom(ctx)
+	if len(r.approvalNamespace) > 0 {
+		return r.requestApproval(ctx, projectRequest, userInfo, dryRun)
+	}
+	return r.createProject(ctx, projectRequest, userInfo, dryRun)
+}
+
+// resolveTemplate returns the template of the project requested by the user and the name of its
+// flavor, with the parameters set by the request.
+func (r *REST) resolveTemplate(ctx context.Context, projectRequest *projectapi.ProjectRequest, userInfo user.Info) (*templatev1.Template, string, error) {
+	templateNamespace, templateName := r.templateNamespace, r.templateName
+	flavorName := projectRequest.Annotations[projectapi.ProjectTemplateFlavor]
+	if len(flavorName) > 0 {
+		flavor, err := r.flavor(ctx, userInfo, projectRequest.Name, flavorName)
+		if err != nil {
+			return nil, "", err
+		}
+		templateNamespace, templateName = flavor.Namespace, flavor.TemplateName
+	}
+
+	template, err := r.getTemplate(ctx, templateNamespace, templateName)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(template.Namespace) == 0 {
+		// template is a namespaced resource, so we need to specify one
+		template.Namespace = "default"
+	}
+
+	if errs := setUserParameters(template, requestedParameters(projectRequest)); len(errs) > 0 {
+		return nil, "", apierror.NewInvalid(project.Kind("ProjectRequest"), projectRequest.Name, errs)
+	}
+	return template, flavorName, nil
+}
+
+// createProject processes the project template for the user and creates the project and the
+// objects of the template. A dry run returns the project of the template without creating it.
+func (r *REST) createProject(ctx context.Context, projectRequest *projectapi.ProjectRequest, userInfo user.Info, dryRun bool) (runtime.Object, error) {
 	projectName := projectRequest.Name
 	projectAdmin := ""
 	projectRequester := ""
-	if userInfo, exists := apirequest.UserFrom(ctx); exists {
+	if userInfo != nil {
 		projectAdmin = userInfo.GetName()
 		projectRequester = userInfo.GetName()
 	}
 
-	template, err := r.getTemplate(ctx)
+	template, flavorName, err := r.resolveTemplate(ctx, projectRequest, userInfo)
 	if err != nil {
 		return nil, err
 	}
-	if len(template.Namespace) == 0 {
-		// template is a namespaced resource, so we need to specify one
-		template.Namespace = "default"
-	}
 
 	for i := range template.Parameters {
 		switch template.Parameters[i].Name {
@@ -188,7 +246,7 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		switch item.GroupVersionKind().GroupKind() {
 		case schema.GroupKind{Group: "project.openshift.io", Kind: "Project"}:
 			if projectFromTemplate != nil {
-				return nil, apierror.NewInternalError(fmt.Errorf("the project template (%s/%s) is not correctly configured: must contain only one project resource", r.templateNamespace, r.templateName))
+				return nil, apierror.NewInternalError(fmt.Errorf("the project template (%s/%s) is not correctly configured: must contain only one project resource", template.Namespace, template.Name))
 			}
 			projectFromTemplate = &projectv1.Project{}
 			err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, projectFromTemplate)
@@ -208,7 +266,24 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		objectsToCreate = append(objectsToCreate, &item)
 	}
 	if projectFromTemplate == nil {
-		return nil, apierror.NewInternalError(fmt.Errorf("the project template (%s/%s) is not correctly configured: must contain a project resource", r.templateNamespace, r.templateName))
+		return nil, apierror.NewInternalError(fmt.Errorf("the project template (%s/%s) is not correctly configured: must contain a project resource", template.Namespace, template.Name))
+	}
+	if len(flavorName) > 0 {
+		if projectFromTemplate.Annotations == nil {
+			projectFromTemplate.Annotations = map[string]string{}
+		}
+		projectFromTemplate.Annotations[projectapi.ProjectTemplateFlavor] = flavorName
+	}
+
+	// resolve the resources of the template objects before creating anything, so that a template
+	// which cannot be provisioned does not leave a project behind
+	restMappings, err := r.restMappings(projectName, objectsToCreate)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("error mapping items of requested project %q: %v", projectName, err))
+		return nil, err
+	}
+	if dryRun {
+		return projectFromTemplate, nil
 	}
 
 	// we split out project creation separately so that in a case of racers for the same project, only one will win and create the rest of their template objects
@@ -221,58 +296,8 @@ func (r *REST) Create(ctx context.Context, obj runtime.Object, createValidation
 		return nil, err
 	}
 
-	// TODO, stop doing this crazy thing, but for now it's a very simple way to get the unstructured objects we need
-	for _, toCreate := range objectsToCreate {
-		var restMapping *meta.RESTMapping
-		var mappingErr error
-		for i := 0; i < 3; i++ {
-			restMapping, err = r.restMapper.RESTMapping(toCreate.GroupVersionKind().GroupKind(), toCreate.GroupVersionKind().Version)
-			if err == nil {
-				mappingErr = nil
-				break
-			}
-			mappingErr = fmt.Errorf("mapping %s failed: %v", toCreate.GroupVersionKind(), err)
-
-			// the refresh is every 10 seconds
-			time.Sleep(11 * time.Second)
-		}
-		if mappingErr != nil {
-			utilruntime.HandleError(fmt.Errorf("error creating items in requested project %q: %v", createdProject.Name, mappingErr))
-			// We have to clean up the project if any part of the project request template fails
-			if deleteErr := r.projectGetter.Projects().Delete(ctx, createdProject.Name, metav1.DeleteOptions{}); deleteErr != nil {
-				utilruntime.HandleError(fmt.Errorf("error cleaning up requested project %q: %v", createdProject.Name, deleteErr))
-			}
-			return nil, apierror.NewInternalError(mappingErr)
-		}
-
-		if restMapping.Scope == nil || restMapping.Scope.Name() != meta.RESTScopeNameNamespace {
-			scopeErr := fmt.Errorf("%s specified in project template is not namespace scoped", toCreate.GroupVersionKind())
-			utilruntime.HandleError(fmt.Errorf("error creating items in requested project %q: %v", createdProject.Name, scopeErr))
-			// We have to clean up the project if any part of the project request template fails
-			if deleteErr := r.projectGetter.Projects().Delete(ctx, createdProject.Name, metav1.DeleteOptions{}); deleteErr != nil {
-				utilruntime.HandleError(fmt.Errorf("error cleaning up requested project %q: %v", createdProject.Name, deleteErr))
-			}
-			return nil, apierror.NewInternalError(scopeErr)
-		}
-
-		_, createErr := r.client.Resource(restMapping.Resource).Namespace(createdProject.Name).Create(ctx, toCreate, metav1.CreateOptions{})
-		// if a default role binding already exists, we're probably racing the controller.  Don't die
-		if gvk := restMapping.GroupVersionKind; apierror.IsAlreadyExists(createErr) &&
-			gvk.Kind == roleBindingKind && roleBindingGroups.Has(gvk.Group) && defaultRoleBindingNames.Has(toCreate.GetName()) {
-			continue
-		}
-		// it is safe to ignore all such errors since stopOnErr will only let these through for the default role bindings
-		if apierror.IsAlreadyExists(createErr) {
-			continue
-		}
-		if createErr != nil {
-			utilruntime.HandleError(fmt.Errorf("error creating items in requested project %q: %v", createdProject.Name, createErr))
-			// We have to clean up the project if any part of the project request template fails
-			if deleteErr := r.projectGetter.Projects().Delete(ctx, createdProject.Name, metav1.DeleteOptions{}); deleteErr != nil {
-				utilruntime.HandleError(fmt.Errorf("error cleaning up requested project %q: %v", createdProject.Name, deleteErr))
-			}
-			return nil, apierror.NewInternalError(createErr)
-		}
+	if err := r.provision(ctx, createdProject.Name, objectsToCreate, restMappings); err != nil {
+		return nil, err
 	}
 
 	// wait for a rolebinding if we created one
@@ -299,12 +324,12 @@ func (r *REST) waitForRoleBinding(namespace, name string) {
 	}
 }
 
-func (r *REST) getTemplate(ctx context.Context) (*templatev1.Template, error) {
-	if len(r.templateNamespace) == 0 || len(r.templateName) == 0 {
+func (r *REST) getTemplate(ctx context.Context, templateNamespace, templateName string) (*templatev1.Template, error) {
+	if len(templateNamespace) == 0 || len(templateName) == 0 {
 		return DefaultTemplate(), nil
 	}
 
-	return r.templateClient.TemplateV1().Templates(r.templateNamespace).Get(ctx, r.templateName, metav1.GetOptions{})
+	return r.templateClient.TemplateV1().Templates(templateNamespace).Get(ctx, templateName, metav1.GetOptions{})
 }
 
 var _ = rest.Lister(&REST{})
@@ -331,7 +356,21 @@ func (r *REST) List(ctx context.Context, options *metainternal.ListOptions) (run
 		return nil, err
 	}
 	if accessReviewResponse.Status.Allowed {
-		return &metav1.Status{Status: metav1.StatusSuccess}, nil
+		// the flavors the caller may request and its requests pending approval are described by the
+		// causes of the status
+		status := &metav1.Status{Status: metav1.StatusSuccess}
+		flavors, err := r.availableFlavors(ctx, userInfo)
+		if err != nil {
+			return nil, err
+		}
+		requests, err := r.requestedProjects(ctx, userInfo)
+		if err != nil {
+			return nil, err
+		}
+		if causes := append(flavors, requests...); len(causes) > 0 {
+			status.Details = &metav1.StatusDetails{Kind: "ProjectRequest", Causes: causes}
+		}
+		return status, nil
 	}
 
 	forbiddenError := apierror.NewForbidden(project.Resource("projectrequest"), "", errors.New("you may not request a new project via this API."))
@@ -354,6 +393,7 @@ func (r *REST) ConvertToTable(ctx context.Context, obj runtime.Object, tableOpti
 	table := &metav1.Table{
 		ColumnDefinitions: []metav1.TableColumnDefinition{
 			{Name: "Status", Type: "string", Format: "name", Description: "Describes whether the user is allowed to create projectrequests"},
+			{Name: "Flavors", Type: "string", Description: "The project template flavors the user may request"},
 		},
 	}
 
@@ -372,7 +412,15 @@ func (r *REST) ConvertToTable(ctx context.Context, obj runtime.Object, tableOpti
 	var err error
 	table.Rows, err = metatable.MetaToTableRow(obj, func(obj runtime.Object, m metav1.Object, name, age string) ([]interface{}, error) {
 		status := obj.(*metav1.Status)
-		return []interface{}{status.Status}, nil
+		flavors := []string{}
+		if status.Details != nil {
+			for _, cause := range status.Details.Causes {
+				if cause.Type == TemplateFlavorCause {
+					flavors = append(flavors, cause.Field)
+				}
+			}
+		}
+		return []interface{}{status.Status, strings.Join(flavors, ",")}, nil
 	})
 	return table, err
 }
@@ -0,0 +1,118 @@
+package delegated
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/api/project"
+	projectv1 "github.com/openshift/api/project/v1"
+	"github.com/openshift/library-go/pkg/authorization/authorizationutil"
+	projectapi "github.com/openshift/openshift-apiserver/pkg/project/apis/project"
+)
+
+const (
+	// flavorVerb is the verb on the flavor subresource of projectrequests a user needs to request
+	// a project of a flavor, the resource name being the name of the flavor.
+	flavorVerb = "use"
+	// flavorSubresource is the subresource of projectrequests the flavors are authorized on.
+	flavorSubresource = "flavor"
+	// TemplateFlavorCause is the type of the causes of the status returned by a list of
+	// projectrequests describing the flavors available to the caller. The field of the cause is
+	// the name of the flavor, and its message the description of its template.
+	TemplateFlavorCause metav1.CauseType = "TemplateFlavor"
+	// templateDescriptionAnnotation is the annotation describing a template.
+	templateDescriptionAnnotation = "description"
+)
+
+// TemplateFlavor is a project template a ProjectRequest may request by name with the
+// ProjectTemplateFlavor annotation, instead of the default project template.
+type TemplateFlavor struct {
+	// Name is the name of the flavor.
+	Name string
+	// Namespace is the namespace of the template.
+	Namespace string
+	// TemplateName is the name of the template.
+	TemplateName string
+}
+
+// flavor returns the flavor name for the user, or an error if the flavor does not exist or the
+// user may not request it.
+func (r *REST) flavor(ctx context.Context, userInfo user.Info, projectName, name string) (*TemplateFlavor, error) {
+	flavor, ok := r.flavors[name]
+	if !ok {
+		fldPath := field.NewPath("metadata", "annotations").Key(projectapi.ProjectTemplateFlavor)
+		return nil, apierror.NewInvalid(project.Kind("ProjectRequest"), projectName, field.ErrorList{field.NotSupported(fldPath, name, r.flavorNames())})
+	}
+	allowed, err := r.flavorAllowed(ctx, userInfo, name)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, apierror.NewForbidden(project.Resource("projectrequest"), projectName, fmt.Errorf("you may not request a project of flavor %q", name))
+	}
+	return &flavor, nil
+}
+
+// flavorAllowed returns true if the user may request a project of the flavor name.
+func (r *REST) flavorAllowed(ctx context.Context, userInfo user.Info, name string) (bool, error) {
+	if userInfo == nil {
+		return false, nil
+	}
+	accessReview := authorizationutil.AddUserToSAR(userInfo, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:        flavorVerb,
+				Group:       projectv1.GroupName,
+				Resource:    "projectrequests",
+				Subresource: flavorSubresource,
+				Name:        name,
+			},
+		},
+	})
+	resp, err := r.sarClient.Create(ctx, accessReview, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return resp.Status.Allowed, nil
+}
+
+// availableFlavors returns the causes describing the flavors the user may request.
+func (r *REST) availableFlavors(ctx context.Context, userInfo user.Info) ([]metav1.StatusCause, error) {
+	var causes []metav1.StatusCause
+	for _, name := range r.flavorNames() {
+		allowed, err := r.flavorAllowed(ctx, userInfo, name)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			continue
+		}
+		flavor := r.flavors[name]
+		description := ""
+		if template, err := r.templateClient.TemplateV1().Templates(flavor.Namespace).Get(ctx, flavor.TemplateName, metav1.GetOptions{}); err != nil {
+			klog.V(4).Infof("could not get the template %s/%s of the project flavor %q: %v", flavor.Namespace, flavor.TemplateName, name, err)
+		} else {
+			description = template.Annotations[templateDescriptionAnnotation]
+		}
+		causes = append(causes, metav1.StatusCause{Type: TemplateFlavorCause, Field: name, Message: description})
+	}
+	return causes, nil
+}
+
+// flavorNames returns the sorted names of the flavors.
+func (r *REST) flavorNames() []string {
+	names := make([]string, 0, len(r.flavors))
+	for name := range r.flavors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,86 @@
+package delegated
+
+import (
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	templatefake "github.com/openshift/client-go/template/clientset/versioned/fake"
+)
+
+// newFlavorsREST returns a REST with the flavors small and gpu, alice may request both and bob
+// only small.
+func newFlavorsREST() *REST {
+	kubeClient := kubefake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		sar := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := sar.Spec.ResourceAttributes
+		switch {
+		case attrs.Subresource == "":
+			sar.Status.Allowed = attrs.Verb == "create"
+		case attrs.Verb == "use" && attrs.Subresource == "flavor":
+			sar.Status.Allowed = sar.Spec.User == "alice" || attrs.Name == "small"
+		}
+		return true, sar, nil
+	})
+	templateClient := templatefake.NewSimpleClientset(&templatev1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "small-project", Namespace: "templates", Annotations: map[string]string{"description": "A small project"}},
+	})
+	return NewREST("", "", "", []TemplateFlavor{
+		{Name: "small", Namespace: "templates", TemplateName: "small-project"},
+		{Name: "gpu", Namespace: "templates", TemplateName: "gpu-project"},
+	}, "", nil, nil, nil, templateClient, kubeClient.AuthorizationV1().SubjectAccessReviews(), nil, nil, nil)
+}
+
+func TestListFlavors(t *testing.T) {
+	storage := newFlavorsREST()
+	for userName, expected := range map[string][]metav1.StatusCause{
+		"alice": {
+			{Type: TemplateFlavorCause, Field: "gpu"},
+			{Type: TemplateFlavorCause, Field: "small", Message: "A small project"},
+		},
+		"bob": {
+			{Type: TemplateFlavorCause, Field: "small", Message: "A small project"},
+		},
+	} {
+		ctx := apirequest.WithUser(apirequest.NewContext(), &user.DefaultInfo{Name: userName})
+		obj, err := storage.List(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := obj.(*metav1.Status)
+		if status.Status != metav1.StatusSuccess || status.Details == nil || len(status.Details.Causes) != len(expected) {
+			t.Fatalf("unexpected status for %s: %#v", userName, status)
+		}
+		for i := range expected {
+			if status.Details.Causes[i] != expected[i] {
+				t.Errorf("expected flavor %#v for %s, got %#v", expected[i], userName, status.Details.Causes[i])
+			}
+		}
+	}
+}
+
+func TestResolveFlavor(t *testing.T) {
+	storage := newFlavorsREST()
+	ctx := apirequest.NewContext()
+	alice, bob := &user.DefaultInfo{Name: "alice"}, &user.DefaultInfo{Name: "bob"}
+
+	flavor, err := storage.flavor(ctx, alice, "project", "gpu")
+	if err != nil || flavor.Namespace != "templates" || flavor.TemplateName != "gpu-project" {
+		t.Errorf("unexpected flavor %#v: %v", flavor, err)
+	}
+	if _, err := storage.flavor(ctx, bob, "project", "gpu"); !apierror.IsForbidden(err) {
+		t.Errorf("expected bob not to be allowed to request the gpu flavor, got %v", err)
+	}
+	if _, err := storage.flavor(ctx, alice, "project", "large"); !apierror.IsInvalid(err) {
+		t.Errorf("expected an unknown flavor to be invalid, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package delegated
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/openshift/library-go/pkg/template/generator"
+	projectapi "github.com/openshift/openshift-apiserver/pkg/project/apis/project"
+)
+
+// UserSettableParametersAnnotation is set on a project template to the comma separated names of
+// the parameters the requesters of projects may set.
+const UserSettableParametersAnnotation = "template.openshift.io/user-settable-parameters"
+
+// expressionGenerator is the generator of the parameters whose From is an expression of the
+// template generator.
+const expressionGenerator = "expression"
+
+var (
+	// generatorConstructExp matches the "[ranges]{length}" constructs replaced by the expression
+	// generator, as the generator parses them.
+	generatorConstructExp = regexp.MustCompile(`\[([a-zA-Z0-9\-\\]+)\](\{([0-9]+)\})`)
+	// generatorRangeExp matches the ranges of a construct: a class like \w or a range like a-z.
+	generatorRangeExp = regexp.MustCompile(`([\\]?[a-zA-Z0-9]\-?[a-zA-Z0-9]?)`)
+	// generatorRangesExp matches the valid ranges of a construct.
+	generatorRangesExp = regexp.MustCompile(`\[(\\w|\\d|\\a|\\A)|([a-zA-Z0-9]\-[a-zA-Z0-9])+\]`)
+)
+
+// generatorExpression returns a regular expression matching the values of a parameter generated
+// from the expression from. The generator copies from, replacing its "[ranges]{length}" constructs
+// by length characters of the ranges, which are ranges like a-z or the classes \w (letters,
+// numerals and _), \d (numerals), \a (letters and numerals) and \A (symbols). An error is returned
+// for the expressions the generator rejects.
+func generatorExpression(from string) (*regexp.Regexp, error) {
+	pattern := &strings.Builder{}
+	pattern.WriteString("^")
+	last := 0
+	for _, match := range generatorConstructExp.FindAllStringSubmatchIndex(from, -1) {
+		pattern.WriteString(regexp.QuoteMeta(from[last:match[0]]))
+		last = match[1]
+
+		ranges := from[match[2]:match[3]]
+		if !generatorRangesExp.MatchString("[" + ranges + "]") {
+			return nil, fmt.Errorf("malformed expression syntax: [%s]", ranges)
+		}
+		length, err := strconv.Atoi(from[match[6]:match[7]])
+		if err != nil || length < 1 || length > 255 {
+			return nil, fmt.Errorf("range must be within [1-255] characters (%s)", from[match[6]:match[7]])
+		}
+		class, err := generatorClass(ranges)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(pattern, "[%s]{%d}", class, length)
+	}
+	pattern.WriteString(regexp.QuoteMeta(from[last:]))
+	pattern.WriteString("$")
+	return regexp.Compile(pattern.String())
+}
+
+// generatorClass returns the content of the regular expression character class matching the
+// characters of the ranges of a generator construct.
+func generatorClass(ranges string) (string, error) {
+	class := &strings.Builder{}
+	for _, r := range generatorRangeExp.FindAllString(ranges, -1) {
+		var characters string
+		switch from, to := r[0], r[len(r)-1]; r[0:1] + r[len(r)-1:] {
+		case `\w`:
+			characters = generator.Alphabet + generator.Numerals + "_"
+		case `\d`:
+			characters = generator.Numerals
+		case `\a`:
+			characters = generator.Alphabet + generator.Numerals
+		case `\A`:
+			characters = generator.Symbols
+		default:
+			start, end := strings.IndexByte(generator.ASCII, from), strings.LastIndexByte(generator.ASCII, to)
+			if start < 0 || end < 0 || start > end {
+				return "", fmt.Errorf("invalid range specified: %c-%c", from, to)
+			}
+			characters = generator.ASCII[start : end+1]
+		}
+		for i := 0; i < len(characters); i++ {
+			if c := characters[i]; !isAlphanumeric(c) {
+				class.WriteByte('\\')
+			}
+			class.WriteByte(characters[i])
+		}
+	}
+	return class.String(), nil
+}
+
+func isAlphanumeric(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// reservedParameters are the parameters set from the ProjectRequest, which the annotations of
+// the request may not set.
+var reservedParameters = sets.New(parameters...)
+
+// requestedParameters returns the values of the template parameters set by the annotations of a
+// ProjectRequest, by parameter name.
+func requestedParameters(projectRequest *projectapi.ProjectRequest) map[string]string {
+	values := map[string]string{}
+	for key, value := range projectRequest.Annotations {
+		if name := strings.TrimPrefix(key, projectapi.ProjectTemplateParameterPrefix); name != key {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// setUserParameters sets the parameters of template requested by the user. Only the parameters of
+// the template listed by its UserSettableParametersAnnotation may be set, and their values must
+// match the expression of the parameters generated from one, as the template generator reads it. Required user-settable parameters
+// without a default must be set.
+func setUserParameters(template *templatev1.Template, values map[string]string) field.ErrorList {
+	var errs field.ErrorList
+	settable := sets.New[string]()
+	for _, name := range strings.Split(template.Annotations[UserSettableParametersAnnotation], ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 && !reservedParameters.Has(name) {
+			settable.Insert(name)
+		}
+	}
+	defined := sets.New[string]()
+	for i := range template.Parameters {
+		defined.Insert(template.Parameters[i].Name)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fldPath := field.NewPath("metadata", "annotations").Key(projectapi.ProjectTemplateParameterPrefix + name)
+		switch {
+		case reservedParameters.Has(name):
+			errs = append(errs, field.Forbidden(fldPath, "the parameter is set from the project request"))
+		case !defined.Has(name):
+			errs = append(errs, field.NotSupported(fldPath, name, sets.List(settable.Intersection(defined))))
+		case !settable.Has(name):
+			errs = append(errs, field.Forbidden(fldPath, "the parameter may not be set by the requester"))
+		}
+	}
+
+	for i := range template.Parameters {
+		param := &template.Parameters[i]
+		if !settable.Has(param.Name) {
+			continue
+		}
+		fldPath := field.NewPath("metadata", "annotations").Key(projectapi.ProjectTemplateParameterPrefix + param.Name)
+		value, ok := values[param.Name]
+		if !ok || len(value) == 0 {
+			if param.Required && len(param.Value) == 0 && len(param.Generate) == 0 {
+				errs = append(errs, field.Required(fldPath, "the parameter is required"))
+			}
+			continue
+		}
+		if param.Generate == expressionGenerator && len(param.From) > 0 {
+			expression, err := generatorExpression(param.From)
+			if err != nil {
+				errs = append(errs, field.Invalid(fldPath, value, fmt.Sprintf("cannot be checked against the expression %s of the template: %v", param.From, err)))
+				continue
+			}
+			if !expression.MatchString(value) {
+				errs = append(errs, field.Invalid(fldPath, value, "must match "+param.From))
+				continue
+			}
+		}
+		param.Value = value
+	}
+	return errs
+}
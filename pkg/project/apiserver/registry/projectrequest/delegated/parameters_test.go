@@ -0,0 +1,140 @@
+package delegated
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	projectapi "github.com/openshift/openshift-apiserver/pkg/project/apis/project"
+)
+
+func TestSetUserParameters(t *testing.T) {
+	newTemplate := func() *templatev1.Template {
+		return &templatev1.Template{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{UserSettableParametersAnnotation: "COST_CENTER, TEAM,PROJECT_NAME,SUFFIX,ZONE"}},
+			Parameters: []templatev1.Parameter{
+				{Name: ProjectNameParam},
+				{Name: "COST_CENTER", Required: true},
+				{Name: "TEAM", Generate: "expression", From: "team-[a-z]{5}"},
+				{Name: "SUFFIX", Generate: "expression", From: "[a-z]{5,8}"},
+				{Name: "ZONE", Generate: "expression", From: "[ab]{1}"},
+				{Name: "QUOTA", Value: "small"},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		values   map[string]string
+		expected map[string]string
+		errs     []string
+	}{
+		{
+			name:     "settable parameters",
+			values:   map[string]string{"COST_CENTER": "1234", "TEAM": "team-infra"},
+			expected: map[string]string{"COST_CENTER": "1234", "TEAM": "team-infra", "QUOTA": "small"},
+		},
+		{
+			name:   "missing required parameter",
+			values: map[string]string{"TEAM": "team-infra"},
+			errs:   []string{"metadata.annotations[template-parameter.openshift.io/COST_CENTER]: Required value"},
+		},
+		{
+			name:   "value not matching the expression",
+			values: map[string]string{"COST_CENTER": "1234", "TEAM": "team-Infra1"},
+			errs:   []string{"metadata.annotations[template-parameter.openshift.io/TEAM]: Invalid value: \"team-Infra1\": must match team-[a-z]{5}"},
+		},
+		{
+			name:   "expression not supported by the generator",
+			values: map[string]string{"COST_CENTER": "1234", "SUFFIX": "abcdef"},
+			errs:   []string{"metadata.annotations[template-parameter.openshift.io/SUFFIX]: Invalid value: \"abcdef\": must match [a-z]{5,8}"},
+		},
+		{
+			name:   "expression rejected by the generator",
+			values: map[string]string{"COST_CENTER": "1234", "ZONE": "a"},
+			errs:   []string{"metadata.annotations[template-parameter.openshift.io/ZONE]: Invalid value: \"a\": cannot be checked against the expression [ab]{1} of the template: malformed expression syntax"},
+		},
+		{
+			name:   "parameters not settable",
+			values: map[string]string{"COST_CENTER": "1234", "QUOTA": "large", "PROJECT_NAME": "other", "UNKNOWN": "x"},
+			errs: []string{
+				"metadata.annotations[template-parameter.openshift.io/PROJECT_NAME]: Forbidden",
+				"metadata.annotations[template-parameter.openshift.io/QUOTA]: Forbidden",
+				"metadata.annotations[template-parameter.openshift.io/UNKNOWN]: Unsupported value: \"UNKNOWN\": supported values: \"COST_CENTER\", \"SUFFIX\", \"TEAM\", \"ZONE\"",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			template := newTemplate()
+			errs := setUserParameters(template, tc.values)
+			if len(errs) != len(tc.errs) {
+				t.Fatalf("expected errors %v, got %v", tc.errs, errs)
+			}
+			for i := range errs {
+				if !strings.HasPrefix(errs[i].Error(), tc.errs[i]) {
+					t.Errorf("expected error %q, got %q", tc.errs[i], errs[i].Error())
+				}
+			}
+			for _, param := range template.Parameters {
+				if expected, ok := tc.expected[param.Name]; ok && param.Value != expected {
+					t.Errorf("expected parameter %s to be %q, got %q", param.Name, expected, param.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratorExpression(t *testing.T) {
+	tests := []struct {
+		from     string
+		matches  []string
+		mismatch []string
+		invalid  bool
+	}{
+		{from: "[a-zA-Z0-9]{8}", matches: []string{"hW4yQU5i", "zzzzZZZ9"}, mismatch: []string{"hW4yQU5", "hW4yQU5i1", "hW4y-U5i"}},
+		{from: "test[0-9]{1}x", matches: []string{"test7x"}, mismatch: []string{"test77x", "tst7x", "testax"}},
+		{from: "[\\w]{4}", matches: []string{"a_Z9"}, mismatch: []string{"a-Z9"}},
+		{from: "[\\d]{2}", matches: []string{"42"}, mismatch: []string{"4a"}},
+		{from: "[\\a]{3}", matches: []string{"aZ9"}, mismatch: []string{"a_9", "aaa\n"}},
+		{from: "[\\A]{3}", matches: []string{"-]^"}, mismatch: []string{"ab!"}},
+		{from: "[\\a\\d]{2}-x.y", matches: []string{"b4-x.y"}, mismatch: []string{"b4-xzy"}},
+		{from: "[a-z]{5,8}", matches: []string{"[a-z]{5,8}"}, mismatch: []string{"abcde"}},
+		{from: "(a|b)*", matches: []string{"(a|b)*"}, mismatch: []string{"aab"}},
+		{from: "[a-z]{0}", invalid: true},
+		{from: "[a-z]{256}", invalid: true},
+		{from: "[z-a]{3}", invalid: true},
+		{from: "[ab]{3}", invalid: true},
+	}
+	for _, tc := range tests {
+		expression, err := generatorExpression(tc.from)
+		if (err != nil) != tc.invalid {
+			t.Errorf("%s: expected invalid to be %t, got %v", tc.from, tc.invalid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		for _, value := range tc.matches {
+			if !expression.MatchString(value) {
+				t.Errorf("%s: expected %q to match %s", tc.from, value, expression)
+			}
+		}
+		for _, value := range tc.mismatch {
+			if expression.MatchString(value) {
+				t.Errorf("%s: expected %q not to match %s", tc.from, value, expression)
+			}
+		}
+	}
+}
+
+func TestRequestedParameters(t *testing.T) {
+	request := &projectapi.ProjectRequest{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		projectapi.ProjectTemplateParameterPrefix + "TEAM": "infra",
+		projectapi.ProjectTemplateFlavor:                   "small",
+	}}}
+	if values := requestedParameters(request); len(values) != 1 || values["TEAM"] != "infra" {
+		t.Errorf("unexpected parameters %v", values)
+	}
+}
@@ -0,0 +1,116 @@
+package delegated
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
+	"github.com/openshift/api/project"
+)
+
+const (
+	// TemplateObjectCause is the type of the cause of a failed ProjectRequest naming the object of
+	// the project template which could not be created. The field of the cause is the index of the
+	// object in the processed template, and its message the reason of the failure.
+	TemplateObjectCause metav1.CauseType = "TemplateObject"
+	// ProjectRollbackCause is the type of the cause of a failed ProjectRequest reporting whether
+	// the project created before the failure was deleted.
+	ProjectRollbackCause metav1.CauseType = "ProjectRollback"
+)
+
+// restMappings returns the REST mappings of the objects of a project template, in order. A kind
+// the mapper does not know makes it reset once, if it can, so that kinds added since its last
+// refresh are discovered without waiting for the periodic reset.
+func (r *REST) restMappings(projectName string, objects []*unstructured.Unstructured) ([]*meta.RESTMapping, error) {
+	mappings := make([]*meta.RESTMapping, 0, len(objects))
+	reset := false
+	for i, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		mapping, err := r.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) && !reset {
+			if resettable, ok := r.restMapper.(meta.ResettableRESTMapper); ok {
+				resettable.Reset()
+				reset = true
+				mapping, err = r.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+			}
+		}
+		if err != nil {
+			return nil, provisioningError(projectName, i, obj, fmt.Errorf("mapping %s failed: %v", gvk, err), nil, false)
+		}
+		if mapping.Scope == nil || mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			return nil, provisioningError(projectName, i, obj, fmt.Errorf("%s specified in project template is not namespace scoped", gvk), nil, false)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// provision creates the objects of a project template in the created project. If any of them
+// cannot be created, the project is deleted and the error names the failed object.
+func (r *REST) provision(ctx context.Context, projectName string, objects []*unstructured.Unstructured, mappings []*meta.RESTMapping) error {
+	for i, toCreate := range objects {
+		restMapping := mappings[i]
+		_, createErr := r.client.Resource(restMapping.Resource).Namespace(projectName).Create(ctx, toCreate, metav1.CreateOptions{})
+		// if a default role binding already exists, we're probably racing the controller.  Don't die
+		if gvk := restMapping.GroupVersionKind; apierror.IsAlreadyExists(createErr) &&
+			gvk.Kind == roleBindingKind && roleBindingGroups.Has(gvk.Group) && defaultRoleBindingNames.Has(toCreate.GetName()) {
+			continue
+		}
+		// it is safe to ignore all such errors since stopOnErr will only let these through for the default role bindings
+		if apierror.IsAlreadyExists(createErr) {
+			continue
+		}
+		if createErr != nil {
+			utilruntime.HandleError(fmt.Errorf("error creating items in requested project %q: %v", projectName, createErr))
+			// We have to clean up the project if any part of the project request template fails
+			deleteErr := r.projectGetter.Projects().Delete(ctx, projectName, metav1.DeleteOptions{})
+			if deleteErr != nil {
+				utilruntime.HandleError(fmt.Errorf("error cleaning up requested project %q: %v", projectName, deleteErr))
+			}
+			return provisioningError(projectName, i, toCreate, createErr, deleteErr, true)
+		}
+	}
+	return nil
+}
+
+// provisioningError returns the error of a ProjectRequest whose template object at index could
+// not be created because of err. If the project had been created, rollbackErr is the error
+// deleting it.
+func provisioningError(projectName string, index int, obj *unstructured.Unstructured, err, rollbackErr error, created bool) *apierror.StatusError {
+	gvk := obj.GroupVersionKind()
+	object := fmt.Sprintf("%s %q", gvk.Kind, obj.GetName())
+	if len(gvk.Group) > 0 {
+		object = fmt.Sprintf("%s.%s %q", gvk.Kind, gvk.Group, obj.GetName())
+	}
+	message := fmt.Sprintf("could not create %s of the project template: %v", object, err)
+	causes := []metav1.StatusCause{{Type: TemplateObjectCause, Field: fmt.Sprintf("objects[%d]", index), Message: message}}
+	if status, ok := err.(apierror.APIStatus); ok && status.Status().Details != nil {
+		causes = append(causes, status.Status().Details.Causes...)
+	}
+	if created {
+		rollback := "the project was deleted"
+		if rollbackErr != nil {
+			rollback = fmt.Sprintf("the project could not be deleted: %v", rollbackErr)
+		}
+		causes = append(causes, metav1.StatusCause{Type: ProjectRollbackCause, Message: rollback})
+		message = fmt.Sprintf("%s; %s", message, rollback)
+	}
+	return &apierror.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusInternalServerError,
+		Reason:  metav1.StatusReasonInternalError,
+		Message: fmt.Sprintf("Internal error occurred: project %q could not be provisioned: %s", projectName, message),
+		Details: &metav1.StatusDetails{
+			Name:   projectName,
+			Group:  project.GroupName,
+			Kind:   "projectrequests",
+			Causes: causes,
+		},
+	}}
+}
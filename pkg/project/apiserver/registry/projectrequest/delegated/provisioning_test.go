@@ -0,0 +1,102 @@
+package delegated
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	projectv1 "github.com/openshift/api/project/v1"
+	projectfake "github.com/openshift/client-go/project/clientset/versioned/fake"
+)
+
+var (
+	configMapGVK   = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	roleBindingGVK = schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"}
+	clusterRoleGVK = schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+)
+
+// resettableMapper is a RESTMapper which only knows about role bindings once reset.
+type resettableMapper struct {
+	*meta.DefaultRESTMapper
+	resets int
+}
+
+func (m *resettableMapper) Reset() {
+	m.resets++
+	m.Add(roleBindingGVK, meta.RESTScopeNamespace)
+}
+
+func newObject(gvk schema.GroupVersionKind, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	return obj
+}
+
+func TestRESTMappings(t *testing.T) {
+	mapper := &resettableMapper{DefaultRESTMapper: meta.NewDefaultRESTMapper(nil)}
+	mapper.Add(configMapGVK, meta.RESTScopeNamespace)
+	mapper.Add(clusterRoleGVK, meta.RESTScopeRoot)
+	storage := &REST{restMapper: mapper}
+
+	objects := []*unstructured.Unstructured{newObject(configMapGVK, "settings"), newObject(roleBindingGVK, "admin")}
+	mappings, err := storage.restMappings("project", objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings) != 2 || mappings[1].Resource.Resource != "rolebindings" || mapper.resets != 1 {
+		t.Errorf("expected the mapper to be reset once to map the role binding, got %d resets and %#v", mapper.resets, mappings)
+	}
+
+	objects = append(objects, newObject(clusterRoleGVK, "viewer"))
+	_, err = storage.restMappings("project", objects)
+	status, ok := err.(apierror.APIStatus)
+	if !ok || !apierror.IsInternalError(err) {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+	causes := status.Status().Details.Causes
+	if len(causes) != 1 || causes[0].Type != TemplateObjectCause || causes[0].Field != "objects[2]" || !strings.Contains(causes[0].Message, `ClusterRole.rbac.authorization.k8s.io "viewer"`) {
+		t.Errorf("expected the cluster role to be reported, got %#v", causes)
+	}
+}
+
+func TestProvisionRollback(t *testing.T) {
+	projectClient := projectfake.NewSimpleClientset(&projectv1.Project{ObjectMeta: metav1.ObjectMeta{Name: "project"}})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	dynamicClient.PrependReactor("create", "rolebindings", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierror.NewForbidden(schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"}, "admin", errors.New("escalation"))
+	})
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(configMapGVK, meta.RESTScopeNamespace)
+	mapper.Add(roleBindingGVK, meta.RESTScopeNamespace)
+	storage := &REST{projectGetter: projectClient.ProjectV1(), client: dynamicClient, restMapper: mapper}
+
+	objects := []*unstructured.Unstructured{newObject(configMapGVK, "settings"), newObject(roleBindingGVK, "admin")}
+	mappings, err := storage.restMappings("project", objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.provision(context.TODO(), "project", objects, mappings)
+	status, ok := err.(apierror.APIStatus)
+	if !ok || !apierror.IsInternalError(err) {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+	causes := status.Status().Details.Causes
+	if len(causes) != 2 || causes[0].Field != "objects[1]" || !strings.Contains(causes[0].Message, "escalation") ||
+		causes[1].Type != ProjectRollbackCause || causes[1].Message != "the project was deleted" {
+		t.Errorf("unexpected causes %#v", causes)
+	}
+	if _, err := projectClient.ProjectV1().Projects().Get(context.TODO(), "project", metav1.GetOptions{}); !apierror.IsNotFound(err) {
+		t.Errorf("expected the project to be deleted, got %v", err)
+	}
+}